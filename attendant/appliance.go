@@ -29,12 +29,11 @@
 package attendant
 
 import (
+  "context"
   "fmt"
   "strings"
   "time"
 
-  "github.com/spf13/viper"
-
   "github.com/aws/aws-sdk-go/aws"
   "github.com/aws/aws-sdk-go/service/cloudformation"
 )
@@ -43,14 +42,20 @@ type Appliance struct {
   Name string
   Domain *Domain
   Stack *cloudformation.Stack
-  MessageHandler func(msg string)
+  MessageHandler EventHandler
+  client *Client
 }
 
 type ApplianceDetails struct {
-  Ip string
-  KeyPair string
-  Url string
-  Extra map[string]string
+  Name string `json:"Name" yaml:"Name"`
+  Region string `json:"Region" yaml:"Region"`
+  Status string `json:"Status" yaml:"Status"`
+  StackName string `json:"StackName" yaml:"StackName"`
+  CreationTime string `json:"CreationTime" yaml:"CreationTime"`
+  Ip string `json:"Ip" yaml:"Ip"`
+  KeyPair string `json:"KeyPair" yaml:"KeyPair"`
+  Url string `json:"Url" yaml:"Url"`
+  Extra map[string]string `json:"Extra" yaml:"Extra"`
 }
 
 var BaseApplianceNames = []string{
@@ -84,8 +89,14 @@ var ApplianceInstanceTypes = []string{
   "large-c4.8xlarge",
 }
 
-func NewAppliance(name string, domain *Domain, handler func(msg string)) *Appliance {
-  return &Appliance{name, domain, nil, handler}
+func NewAppliance(name string, domain *Domain, handler EventHandler) *Appliance {
+  return domain.Client().NewAppliance(name, domain, handler)
+}
+
+// Client returns the client that the appliance belongs to.
+func (a *Appliance) Client() *Client {
+  if a.client == nil { return a.Domain.Client() }
+  return a.client
 }
 
 func IsValidApplianceInstanceType(instanceType string) bool {
@@ -101,7 +112,7 @@ func (a *Appliance) LoadStack() error {
   if a.Stack != nil {
     return nil
   }
-  svc, err := CloudFormation()
+  svc, err := a.Client().CloudFormation()
   if err != nil { return err }
   stack, err := getStack(svc, "flight-" + a.Domain.Name + "-" + a.Name)
   if err != nil { return err }
@@ -109,93 +120,96 @@ func (a *Appliance) LoadStack() error {
   return nil
 }
 
-func (a *Appliance) Create() error {
-  svc, err := CloudFormation()
+func (a *Appliance) Create(ctx context.Context) error {
+  svc, err := a.Client().CloudFormation()
   if err != nil { return err }
 
-  url := TemplateUrl(ApplianceTemplates[a.Name])
+  url := a.Client().TemplateUrl(ApplianceTemplates[a.Name])
   if url == "" {
     return fmt.Errorf("Unknown appliance type: %s", a.Name)
   }
 
   if err = a.Domain.AssertReady(); err != nil { return err }
 
-  var launchParams []*cloudformation.Parameter
+  var parameterSet *ParameterSet
   switch a.Name {
   case "directory", "monitor":
-    launchParams = createApplianceLaunchParameters(a, loadParameterSet(a.Name, DomainApplianceParameters))
+    parameterSet, err = a.Client().loadParameterSet(a.Name, DomainApplianceParameters)
   case "controller":
     defaultParams := make(map[string]string)
     for k,v := range DomainApplianceParameters {
       defaultParams[k] = v
     }
     defaultParams["PrvSubnet"] = "%PRV_SUBNET%"
-    launchParams = createApplianceLaunchParameters(a, loadParameterSet(a.Name, defaultParams))
+    parameterSet, err = a.Client().loadParameterSet(a.Name, defaultParams)
   case "silo":
-    launchParams = createApplianceLaunchParameters(a, loadParameterSet(a.Name, SiloParameters))
+    parameterSet, err = a.Client().loadParameterSet(a.Name, SiloParameters)
   case "access-manager", "storage-manager":
-    launchParams = createApplianceLaunchParameters(a, loadParameterSet(a.Name, BasicApplianceParameters))
+    parameterSet, err = a.Client().loadParameterSet(a.Name, BasicApplianceParameters)
   default:
     return fmt.Errorf("Appliance unsupported: %s", a.Name)
   }
+  if err != nil { return err }
+  launchParams, err := applianceResolver(a).LaunchParameters(parameterSet, url)
+  if err != nil { return err }
 
   stackName := fmt.Sprintf("flight-%s-%s", a.Domain.Name, a.Name)
-  tArn, qUrl, err := setupEventHandling(stackName)
+  tArn, qUrl, err := a.Client().setupEventHandling(stackName)
   if err != nil { return err }
-  go a.processQueue(qUrl)
+  go a.processQueue(ctx, qUrl)
   tags := []*cloudformation.Tag{
     &cloudformation.Tag{Key: aws.String("flight:appliance"), Value: aws.String(a.Name)},
   }
-  stack, err := createStack(svc, launchParams, tags, url, stackName, "appliance", *tArn, a.Domain)
+  stack, err := a.Client().createStack(ctx, svc, launchParams, tags, url, stackName, "appliance", *tArn, a.Domain)
 
-  if err != nil { cleanupEventHandling(stackName) }
+  if err != nil { a.Client().cleanupEventHandling(stackName) }
 
-  a.MessageHandler("DONE")
+  a.MessageHandler(NewDoneEvent())
 
   a.Stack = stack
 
   return err
 }
 
-func (a Appliance) processQueue(qArn *string) {
-  for a.MessageHandler != nil {
+func (a *Appliance) processQueue(ctx context.Context, qArn *string) {
+  for a.MessageHandler != nil && ctx.Err() == nil {
     time.Sleep(500 * time.Millisecond)
-    receiveMessage(qArn, a.MessageHandler)
+    a.Client().receiveMessage(qArn, a.MessageHandler)
   }
 }
 
-func (a Appliance) Purge() error {
-  svc, err := CloudFormation()
+func (a Appliance) Purge(ctx context.Context) error {
+  svc, err := a.Client().CloudFormation()
   if err != nil { return err }
 
   stackName := fmt.Sprintf("flight-%s-%s", a.Domain.Name, a.Name)
   if err != nil { return err }
 
-  err = destroyStack(svc, stackName)
+  err = destroyStack(ctx, svc, stackName)
   if err != nil { return err }
 
-  err = cleanupEventHandling(stackName)
+  err = a.Client().cleanupEventHandling(stackName)
   if err != nil { return err }
 
   return err
 }
 
-func (a Appliance) Destroy() error {
-  svc, err := CloudFormation()
+func (a Appliance) Destroy(ctx context.Context) error {
+  svc, err := a.Client().CloudFormation()
   if err != nil { return err }
 
   stackName := fmt.Sprintf("flight-%s-%s", a.Domain.Name, a.Name)
-  qUrl, err := getEventQueueUrl(stackName)
+  qUrl, err := a.Client().getEventQueueUrl(stackName)
   if err != nil { return err }
-  go a.processQueue(qUrl)
+  go a.processQueue(ctx, qUrl)
 
-  err = destroyStack(svc, stackName)
+  err = destroyStack(ctx, svc, stackName)
   if err != nil { return err }
 
-  err = cleanupEventHandling(stackName)
+  err = a.Client().cleanupEventHandling(stackName)
   if err != nil { return err }
 
-  a.MessageHandler("DONE")
+  a.MessageHandler(NewDoneEvent())
 
   return err
 }
@@ -203,6 +217,13 @@ func (a Appliance) Destroy() error {
 func (a Appliance) Details() *ApplianceDetails {
   var details ApplianceDetails = ApplianceDetails{}
   details.Extra = make(map[string]string)
+  details.Name = a.Name
+  details.Region = a.Client().Config().AwsRegion
+  if a.Stack != nil {
+    details.Status = *a.Stack.StackStatus
+    details.StackName = *a.Stack.StackName
+    details.CreationTime = a.Stack.CreationTime.Format(time.RFC3339)
+  }
   switch a.Name {
   case "directory":
     details.Ip = getStackOutput(a.Stack, "DirectoryAccessIP")
@@ -318,53 +339,3 @@ func (a Appliance) GetDetails() string {
   return details
 }
 
-func createApplianceLaunchParameters(appliance *Appliance, parameterSet map[string]string) []*cloudformation.Parameter {
-  params := []*cloudformation.Parameter{}
-  for key, value := range parameterSet {
-    var val string
-    switch value  {
-    case "%ACCESS_KEY_NAME%":
-      val = Config().AccessKeyName
-    case "%VPC%":
-      val = appliance.Domain.VPC()
-    case "%DOMAIN%":
-      val = appliance.Domain.Prefix()
-    case "%PUB_SUBNET%":
-      val = appliance.Domain.PublicSubnet()
-    case "%MGT_SUBNET%":
-      val = appliance.Domain.ManagementSubnet()
-    case "%PRV_SUBNET%":
-      val = appliance.Domain.PrivateSubnet()
-    case "%PLACEMENT_GROUP%":
-      val = appliance.Domain.PlacementGroup()
-    case "%APPLIANCE_FEATURES%":
-      val = viper.GetString(appliance.Name + "-features")
-    case "%APPLIANCE_PROFILES%":
-      val = viper.GetString(appliance.Name + "-profiles")
-    case "%APPLIANCE_INSTANCE_TYPE%":
-      val = viper.GetString(appliance.Name + "-instance-type")
-      if val == "" { val = viper.GetString("appliance-instance-type") }
-      if val == "" { val = ApplianceInstanceTypes[0] }
-    case "%MASTER_IP%":
-      val = appliance.Domain.MasterIP()
-    default:
-      if strings.HasPrefix(value, "%") && strings.HasSuffix(value, "%") {
-        configKey := strings.ToLower(strings.Replace(value[1:len(value)-1], "_", "-", -1))
-        if viper.IsSet(configKey) {
-          val = viper.GetString(configKey)
-        } else {
-          val = "%NULL%"
-        }
-      } else {
-        val = value
-      }
-    }
-    if val != "%NULL%" {
-      params = append(params, &cloudformation.Parameter{
-        ParameterKey: aws.String(key),
-        ParameterValue: aws.String(val),
-      })
-    }
-  }
-  return params
-}
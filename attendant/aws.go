@@ -29,8 +29,11 @@
 package attendant
 
 import (
+  "context"
+  "crypto/tls"
   "fmt"
   "html"
+  "net/http"
   "regexp"
   "strconv"
   "strings"
@@ -43,15 +46,13 @@ import (
   "github.com/aws/aws-sdk-go/service/autoscaling"
   "github.com/aws/aws-sdk-go/service/cloudformation"
   "github.com/aws/aws-sdk-go/service/ec2"
+  "github.com/aws/aws-sdk-go/service/s3"
   "github.com/aws/aws-sdk-go/service/sns"
   "github.com/aws/aws-sdk-go/service/sqs"
   "github.com/guregu/dynamo"
   "github.com/go-ini/ini"
 )
 
-var awsSession *session.Session
-var allStacks, allFlightStacks []*cloudformation.Stack
-
 var sqsPolicyTemplate = `
 {
   "Version": "2012-10-17",
@@ -73,62 +74,94 @@ var sqsPolicyTemplate = `
   ]
 }`
 
-func AwsSession() (*session.Session, error) {
-  if awsSession != nil {
-    return awsSession, nil
+func (c *Client) Session() (*session.Session, error) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.session != nil {
+    return c.session, nil
   }
 
-  var opts session.Options
-  var config aws.Config
-  var creds *credentials.Credentials
-  if Config().AwsAccessKey != "" && Config().AwsSecretKey != "" {
-    creds = credentials.NewStaticCredentials(Config().AwsAccessKey, Config().AwsSecretKey, "")
-  }
-  config = aws.Config{Region: &Config().AwsRegion, Credentials: creds}
-  opts = session.Options{Config: config}
-  awsSession, err := session.NewSessionWithOptions(opts)
+  creds, err := c.sharedCredentials()
+  if err != nil { return nil, err }
+  opts := session.Options{Config: *c.awsConfig(creds)}
+  sess, err := session.NewSessionWithOptions(opts)
   if err != nil { return nil, err }
-  return awsSession, nil
+  c.session = sess
+  return sess, nil
 }
 
-func CloudFormation() (*cloudformation.CloudFormation, error) {
-  sess, err := AwsSession()
-  if err != nil { return nil, err }
-  return cloudformation.New(sess), nil
+func (c *Client) awsConfig(creds *credentials.Credentials) *aws.Config {
+  config := &aws.Config{Region: aws.String(c.config.AwsRegion), Credentials: creds}
+  if c.config.DisableTLSVerify {
+    config.HTTPClient = &http.Client{
+      Transport: &http.Transport{
+        Proxy: http.ProxyFromEnvironment,
+        TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+      },
+    }
+  }
+  return config
 }
 
-func Dynamo() (*dynamo.DB, error) {
-  sess, err := AwsSession()
-  if err != nil { return nil, err }
-  return dynamo.New(sess), nil
+func (c *Client) serviceConfig(service string) *aws.Config {
+  config := aws.NewConfig()
+  if endpoint := c.config.Endpoints[service]; endpoint != "" {
+    config = config.WithEndpoint(endpoint)
+  }
+  return config
 }
 
-func EC2() (*ec2.EC2, error) {
-  sess, err := AwsSession()
+func (c *Client) CloudFormation() (StackService, error) {
+  svc, err := c.Provider().Stacks(c)
   if err != nil { return nil, err }
-  return ec2.New(sess), nil
+  return &cachingStackService{svc, c}, nil
 }
 
-func SNS() (*sns.SNS, error) {
-  sess, err := AwsSession()
+func (c *Client) Dynamo() (*dynamo.DB, error) {
+  sess, err := c.Session()
   if err != nil { return nil, err }
-  return sns.New(sess), nil
+  return dynamo.New(sess, c.serviceConfig("dynamodb")), nil
 }
 
-func SQS() (*sqs.SQS, error) {
-  sess, err := AwsSession()
+func (c *Client) S3() (*s3.S3, error) {
+  sess, err := c.Session()
   if err != nil { return nil, err }
-  return sqs.New(sess), nil
+  config := c.serviceConfig("s3")
+  if c.config.Endpoints["s3"] != "" {
+    config = config.WithS3ForcePathStyle(true)
+  }
+  return s3.New(sess, config), nil
 }
 
-func AutoScaling() (*autoscaling.AutoScaling, error) {
-  sess, err := AwsSession()
-  if err != nil { return nil, err }
-  return autoscaling.New(sess), nil
+func (c *Client) EC2() (NetworkService, error) {
+  return c.Provider().Network(c)
+}
+
+func (c *Client) Events() (EventBus, error) {
+  return c.Provider().Events(c)
+}
+
+func (c *Client) AutoScaling() (AutoscalingService, error) {
+  return c.Provider().Autoscaling(c)
+}
+
+func (c *Client) State() (StateStore, error) {
+  store := c.store
+  if store == nil {
+    var err error
+    store, err = c.OpenStateStore(c.config.StateBackend)
+    if err != nil { return nil, err }
+  }
+  if c.plan != nil { return planStore(store), nil }
+  return store, nil
 }
 
 func IsValidKeyPairName(name string) bool {
-  svc, err := EC2()
+  return DefaultClient().IsValidKeyPairName(name)
+}
+
+func (c *Client) IsValidKeyPairName(name string) bool {
+  svc, err := c.EC2()
   if err != nil { return false }
   o, err := throttleProtected(
     func() (interface{}, error) {
@@ -144,14 +177,16 @@ func IsValidKeyPairName(name string) bool {
 }
 
 func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(string)) error {
-  snsSvc, err := SNS()
-  if err != nil { return err }
-  sqsSvc, err := SQS()
+  return DefaultClient().CleanFlightEventHandling(stacks, dryrun, messageHandler)
+}
+
+func (c *Client) CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(string)) error {
+  bus, err := c.Events()
   if err != nil { return err }
 
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return snsSvc.ListTopics(&sns.ListTopicsInput{})
+      return bus.ListTopics(&sns.ListTopicsInput{})
     },
   )
   if err != nil { return err }
@@ -165,7 +200,7 @@ func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(
 
         o, err := throttleProtected(
           func() (interface{}, error) {
-            return snsSvc.ListSubscriptionsByTopic(&sns.ListSubscriptionsByTopicInput{TopicArn: topic.TopicArn})
+            return bus.ListSubscriptionsByTopic(&sns.ListSubscriptionsByTopicInput{TopicArn: topic.TopicArn})
           },
         )
         if err != nil { return err }
@@ -174,13 +209,13 @@ func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(
           for _, sub := range subResp.Subscriptions {
             throttleProtected(
               func() (interface{}, error) {
-                return snsSvc.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: sub.SubscriptionArn})
+                return bus.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: sub.SubscriptionArn})
               },
             )
           }
           throttleProtected(
             func() (interface{}, error) {
-              return snsSvc.DeleteTopic(&sns.DeleteTopicInput{TopicArn: topic.TopicArn})
+              return bus.DeleteTopic(&sns.DeleteTopicInput{TopicArn: topic.TopicArn})
             },
           )
         }
@@ -192,7 +227,7 @@ func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(
 
   o, err = throttleProtected(
     func() (interface{}, error) {
-      return sqsSvc.ListQueues(&sqs.ListQueuesInput{})
+      return bus.ListQueues(&sqs.ListQueuesInput{})
     },
   )
   if err != nil { return err }
@@ -206,7 +241,7 @@ func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(
         if !dryrun {
           throttleProtected(
             func() (interface{}, error) {
-              return sqsSvc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: queue})
+              return bus.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: queue})
             },
           )
         }
@@ -218,8 +253,9 @@ func CleanFlightEventHandling(stacks []string, dryrun bool, messageHandler func(
   return nil
 }
 
-func createStack(
-  svc *cloudformation.CloudFormation,
+func (c *Client) createStack(
+  ctx context.Context,
+  svc StackService,
   params []*cloudformation.Parameter,
   tags []*cloudformation.Tag,
   templateUrl string,
@@ -228,6 +264,9 @@ func createStack(
   topicArn string,
   domain *Domain) (*cloudformation.Stack, error) {
 
+  template, err := c.loadTemplate(templateUrl)
+  if err != nil { return nil, err }
+
   var stackTags []*cloudformation.Tag
   stackTags = append(tags, []*cloudformation.Tag{
     {
@@ -236,7 +275,7 @@ func createStack(
     },
     {
       Key: aws.String("flight:template"),
-      Value: aws.String(templateUrl),
+      Value: aws.String(template.source),
     },
   }...)
   if domain != nil {
@@ -250,30 +289,42 @@ func createStack(
     Capabilities: []*string{aws.String("CAPABILITY_IAM")},
     NotificationARNs: []*string{aws.String(topicArn)},
     StackName: aws.String(stackName),
-    TemplateURL: aws.String(templateUrl),
+    TemplateURL: template.urlParam(),
+    TemplateBody: template.bodyParam(),
     Parameters: params,
     Tags: stackTags,
   }
 
-  _, err := throttleProtected(
+  _, err = throttleProtectedWithContext(ctx,
     func() (interface{}, error) {
       return svc.CreateStack(createParams)
     },
   )
   if err != nil { return nil, err }
 
-  return awaitStack(svc, stackName)
+  return c.awaitStack(ctx, svc, stackName)
 }
 
-func awaitStack(svc *cloudformation.CloudFormation, stackName string) (*cloudformation.Stack, error) {
+func (c *Client) awaitStack(ctx context.Context, svc StackService, stackName string) (*cloudformation.Stack, error) {
   stackParams := &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}
 
-  _, err := throttleProtected(
+  _, err := throttleProtectedWithContext(ctx,
     func() (interface{}, error) {
-      return nil, svc.WaitUntilStackCreateComplete(stackParams)
+      return nil, svc.WaitUntilStackCreateCompleteWithContext(ctx, stackParams)
     },
   )
-  if err != nil { return nil, err }
+  // an interrupted wait leaves the stack to carry on
+  if err != nil && ctx.Err() != nil { return nil, ctx.Err() }
+  if err != nil {
+    failure := stackFailure(svc, stackName, "CREATE_FAILED")
+    if failure == nil { return nil, err }
+    if c.Config().DeleteFailedStacks {
+      if err := destroyStack(ctx, svc, stackName); err != nil {
+        return nil, fmt.Errorf("%s (the failed stack could not be deleted: %s)", failure.Error(), err.Error())
+      }
+    }
+    return nil, failure
+  }
 
   o, err := throttleProtected(
     func() (interface{}, error) {
@@ -286,9 +337,58 @@ func awaitStack(svc *cloudformation.CloudFormation, stackName string) (*cloudfor
   return stacksResp.Stacks[0], nil
 }
 
-func destroyStack(svc *cloudformation.CloudFormation, stackName string) error {
+// StackFailure describes the resource that caused a stack operation to
+// fail, as reported by the stack's events.
+type StackFailure struct {
+  StackName string
+  LogicalId string
+  ResourceType string
+  Status string
+  Reason string
+}
+
+func (f *StackFailure) Error() string {
+  return fmt.Sprintf("Stack %s failed: %s (%s) %s: %s", f.StackName, f.LogicalId, f.ResourceType, f.Status, f.Reason)
+}
+
+// stackFailure finds the earliest event with the failed status given
+// for a stack since its latest create or update began.  Nil is returned
+// if there isn't one or the events can't be retrieved.
+func stackFailure(svc StackService, stackName, failedStatus string) *StackFailure {
+  var failure *StackFailure
+  params := &cloudformation.DescribeStackEventsInput{StackName: aws.String(stackName)}
+  for {
+    o, err := throttleProtected(
+      func() (interface{}, error) {
+        return svc.DescribeStackEvents(params)
+      },
+    )
+    if err != nil { return failure }
+    resp := o.(*cloudformation.DescribeStackEventsOutput)
+    // events are listed most recent first
+    for _, event := range resp.StackEvents {
+      status := aws.StringValue(event.ResourceStatus)
+      if aws.StringValue(event.LogicalResourceId) == stackName && (status == "CREATE_IN_PROGRESS" || status == "UPDATE_IN_PROGRESS") {
+        return failure
+      }
+      if status == failedStatus {
+        failure = &StackFailure{
+          StackName: stackName,
+          LogicalId: aws.StringValue(event.LogicalResourceId),
+          ResourceType: aws.StringValue(event.ResourceType),
+          Status: failedStatus,
+          Reason: aws.StringValue(event.ResourceStatusReason),
+        }
+      }
+    }
+    if resp.NextToken == nil { return failure }
+    params.NextToken = resp.NextToken
+  }
+}
+
+func destroyStack(ctx context.Context, svc StackService, stackName string) error {
   deleteParams := &cloudformation.DeleteStackInput{StackName: aws.String(stackName)}
-  _, err := throttleProtected(
+  _, err := throttleProtectedWithContext(ctx,
     func() (interface{}, error) {
       return svc.DeleteStack(deleteParams)
     },
@@ -296,9 +396,9 @@ func destroyStack(svc *cloudformation.CloudFormation, stackName string) error {
   if err != nil { return err }
 
   stackParams := &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}
-  _, err = throttleProtected(
+  _, err = throttleProtectedWithContext(ctx,
     func() (interface{}, error) {
-      return nil, svc.WaitUntilStackDeleteComplete(stackParams)
+      return nil, svc.WaitUntilStackDeleteCompleteWithContext(ctx, stackParams)
     },
   )
   if err != nil { return err }
@@ -306,8 +406,8 @@ func destroyStack(svc *cloudformation.CloudFormation, stackName string) error {
   return nil
 }
 
-func destroyDetachedNICs(subnetId string) error {
-  svc, err := EC2()
+func (c *Client) destroyDetachedNICs(subnetId string) error {
+  svc, err := c.EC2()
   if err != nil { return err }
   // list NICs for subnet
   o, err := throttleProtected(
@@ -342,7 +442,7 @@ func destroyDetachedNICs(subnetId string) error {
   return nil
 }
 
-func getStack(svc *cloudformation.CloudFormation, stackName string) (*cloudformation.Stack, error) {
+func getStack(svc StackService, stackName string) (*cloudformation.Stack, error) {
   stackParams := &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}
 
   o, err := throttleProtected(
@@ -367,6 +467,15 @@ func getStackParameter(stack *cloudformation.Stack, key string) string {
   return v
 }
 
+func hasStackParameter(stack *cloudformation.Stack, key string) bool {
+  for _, param := range stack.Parameters {
+    if *param.ParameterKey == key {
+      return true
+    }
+  }
+  return false
+}
+
 func getStackTag(stack *cloudformation.Stack, key string) string {
   var v string
   for _, tag := range stack.Tags {
@@ -433,8 +542,12 @@ func getStackConfigValue(stack *cloudformation.Stack, key string) string {
 }
 
 func OtherStacks() ([]*cloudformation.Stack, error) {
+  return DefaultClient().OtherStacks()
+}
+
+func (c *Client) OtherStacks() ([]*cloudformation.Stack, error) {
   var otherStacks = []*cloudformation.Stack{}
-  err := eachRunningStackAll(func(stack *cloudformation.Stack) {
+  err := c.eachStackAll(context.Background(), func(stack *cloudformation.Stack) {
     if getStackTag(stack, "flight:type") == "" {
       otherStacks = append(otherStacks, stack)
     }
@@ -442,9 +555,9 @@ func OtherStacks() ([]*cloudformation.Stack, error) {
   return otherStacks, err
 }
 
-func getComponentStacksForCluster(cluster *Cluster) ([]*cloudformation.Stack, error) {
+func getComponentStacksForCluster(ctx context.Context, cluster *Cluster) ([]*cloudformation.Stack, error) {
   var componentStacks = []*cloudformation.Stack{}
-  err := eachRunningStack(func(stack *cloudformation.Stack) {
+  err := cluster.Client().eachStack(ctx, func(stack *cloudformation.Stack) {
     if getStackTag(stack, "flight:type") == "component" &&
       getStackTag(stack, "flight:cluster") == cluster.Name &&
       getStackTag(stack, "flight:domain") == cluster.Domain.Name {
@@ -454,9 +567,9 @@ func getComponentStacksForCluster(cluster *Cluster) ([]*cloudformation.Stack, er
   return componentStacks, err
 }
 
-func getComputeGroupStacksForCluster(cluster *Cluster) ([]*cloudformation.Stack, error) {
+func getComputeGroupStacksForCluster(ctx context.Context, cluster *Cluster) ([]*cloudformation.Stack, error) {
   var computeGroupStacks = []*cloudformation.Stack{}
-  err := eachRunningStack(func(stack *cloudformation.Stack) {
+  err := cluster.Client().eachStack(ctx, func(stack *cloudformation.Stack) {
     if getStackTag(stack, "flight:type") == "compute" &&
       getStackTag(stack, "flight:cluster") == cluster.Name &&
       getStackTag(stack, "flight:domain") == cluster.Domain.Name {
@@ -466,51 +579,90 @@ func getComputeGroupStacksForCluster(cluster *Cluster) ([]*cloudformation.Stack,
   return computeGroupStacks, err
 }
 
-func eachRunningStackAll(fn func(stack *cloudformation.Stack)) error {
-  if len(allStacks) == 0 {
-    svc, err := CloudFormation()
-    if err != nil { return err }
+// Stacks in these states are running, including those that have been
+// updated since they were created.
+var runningStackStatuses = []string{
+  "CREATE_COMPLETE",
+  "CREATE_IN_PROGRESS",
+  "UPDATE_COMPLETE",
+  "UPDATE_IN_PROGRESS",
+  "UPDATE_COMPLETE_CLEANUP_IN_PROGRESS",
+  "UPDATE_ROLLBACK_COMPLETE",
+  "UPDATE_ROLLBACK_IN_PROGRESS",
+  "UPDATE_ROLLBACK_COMPLETE_CLEANUP_IN_PROGRESS",
+}
 
-    listParams := &cloudformation.ListStacksInput{
-      StackStatusFilter: []*string{
-        aws.String("CREATE_COMPLETE"),
-        aws.String("CREATE_IN_PROGRESS"),
-      },
-    }
+func isRunningStack(stack *cloudformation.Stack) bool {
+  return containsS(runningStackStatuses, *stack.StackStatus)
+}
 
-    o, err := throttleProtected(
+// regionStacks returns every stack in the current region that hasn't
+// been deleted, from the stack cache if it holds a listing for it.
+func (c *Client) regionStacks(ctx context.Context) ([]*cloudformation.Stack, error) {
+  cache := c.StackCache()
+  if cache.TTL <= 0 { return c.describeRegionStacks(ctx) }
+  key, persist := c.stackCacheKey()
+  if stacks, exists := cache.Get(key); exists {
+    return stacks, nil
+  }
+  stacks, err := c.describeRegionStacks(ctx)
+  if err != nil { return nil, err }
+  cache.put(key, stacks, persist)
+  return stacks, nil
+}
+
+// describeRegionStacks describes the stacks in the current region a
+// page at a time.
+func (c *Client) describeRegionStacks(ctx context.Context) ([]*cloudformation.Stack, error) {
+  svc, err := c.CloudFormation()
+  if err != nil { return nil, err }
+
+  stacks := []*cloudformation.Stack{}
+  params := &cloudformation.DescribeStacksInput{}
+  for {
+    o, err := throttleProtectedWithContext(ctx,
       func() (interface{}, error) {
-        return svc.ListStacks(listParams)
+        return svc.DescribeStacks(params)
       },
     )
-    if err != nil { return err }
-    resp := o.(*cloudformation.ListStacksOutput)
+    if err != nil { return nil, err }
+    resp := o.(*cloudformation.DescribeStacksOutput)
+    stacks = append(stacks, resp.Stacks...)
+    if resp.NextToken == nil || *resp.NextToken == "" {
+      break
+    }
+    params.NextToken = resp.NextToken
+  }
+  return stacks, nil
+}
 
-    for _, value := range resp.StackSummaries {
-      o, err = throttleProtected(
-        func() (interface{}, error) {
-          return svc.DescribeStacks(&cloudformation.DescribeStacksInput{
-            StackName: value.StackName,
-          })
-        },
-      )
-      if err == nil {
-        stacksResp := o.(*cloudformation.DescribeStacksOutput)
-        if len(stacksResp.Stacks) > 0 {
-          fn(stacksResp.Stacks[0])
-          allStacks = append(allStacks, stacksResp.Stacks[0])
-        }
-      } else {
-        fmt.Println("Error: " + err.Error())
-      }
+// eachStackAll calls fn for every stack in the current region whatever
+// its status, including stacks that don't belong to Flight.
+func (c *Client) eachStackAll(ctx context.Context, fn func(stack *cloudformation.Stack)) error {
+  stacks, err := c.regionStacks(ctx)
+  if err != nil { return err }
+  for _, stack := range stacks {
+    fn(stack)
+  }
+  return nil
+}
+
+// eachStack calls fn for every Flight stack in the current region
+// whatever its status.
+func (c *Client) eachStack(ctx context.Context, fn func(stack *cloudformation.Stack)) error {
+  return c.eachStackAll(ctx, func(stack *cloudformation.Stack) {
+    if strings.HasPrefix(*stack.StackName, "flight-") {
+      fn(stack)
     }
-    return err
-  } else {
-    for _, stack := range allStacks {
+  })
+}
+
+func (c *Client) eachRunningStack(ctx context.Context, fn func(stack *cloudformation.Stack)) error {
+  return c.eachStack(ctx, func(stack *cloudformation.Stack) {
+    if isRunningStack(stack) {
       fn(stack)
     }
-    return nil
-  }
+  })
 }
 
 func canThrottleRetry(err error) bool {
@@ -530,11 +682,25 @@ func canThrottleRetry(err error) bool {
 }
 
 func throttleProtected(fn func() (interface{}, error)) (interface{}, error) {
+  return throttleProtectedWithContext(context.Background(), fn)
+}
+
+// throttleProtectedWithContext retries fn while it is throttled, giving
+// up as soon as ctx is cancelled.  A cancelled call always fails with
+// the context's error, whatever fn itself returned.
+func throttleProtectedWithContext(ctx context.Context, fn func() (interface{}, error)) (interface{}, error) {
   throttleWait := time.Millisecond * 500
   o, err := fn()
   for err != nil {
+    if ctx.Err() != nil {
+      return nil, ctx.Err()
+    }
     if canThrottleRetry(err) {
-      time.Sleep(throttleWait)
+      select {
+      case <-time.After(throttleWait):
+      case <-ctx.Done():
+        return nil, ctx.Err()
+      }
       // 500ms, 1s, 2s, 4s, 8s, 16s, 32s
       throttleWait = throttleWait * 2
       if throttleWait > time.Second * 32 {
@@ -548,61 +714,12 @@ func throttleProtected(fn func() (interface{}, error)) (interface{}, error) {
   return o, nil
 }
 
-func eachRunningStack(fn func(stack *cloudformation.Stack)) error {
-  if len(allFlightStacks) == 0 {
-    svc, err := CloudFormation()
-    if err != nil { return err }
-
-    listParams := &cloudformation.ListStacksInput{
-      StackStatusFilter: []*string{
-        aws.String("CREATE_COMPLETE"),
-        aws.String("CREATE_IN_PROGRESS"),
-      },
-    }
-
-    o, err := throttleProtected(
-      func() (interface{}, error) {
-        return svc.ListStacks(listParams)
-      },
-    )
-    if err != nil { return err }
-    resp := o.(*cloudformation.ListStacksOutput)
-
-    for _, value := range resp.StackSummaries {
-      if strings.HasPrefix(*value.StackName, "flight-") {
-        o, err = throttleProtected(
-          func() (interface{}, error) {
-            return svc.DescribeStacks(&cloudformation.DescribeStacksInput{
-              StackName: value.StackName,
-            })
-          },
-        )
-        if err == nil {
-          stacksResp := o.(*cloudformation.DescribeStacksOutput)
-          if len(stacksResp.Stacks) > 0 {
-            fn(stacksResp.Stacks[0])
-            allFlightStacks = append(allFlightStacks, stacksResp.Stacks[0])
-          }
-        } else {
-          fmt.Println("Error: " + err.Error())
-        }
-      }
-    }
-    return err
-  } else {
-    for _, stack := range allFlightStacks {
-      fn(stack)
-    }
-    return nil
-  }
-}
-
-func getEventQueueUrl(name string) (*string, error) {
-  sqsSvc, err := SQS()
+func (c *Client) getEventQueueUrl(name string) (*string, error) {
+  bus, err := c.Events()
   if err != nil { return nil, err }
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return sqsSvc.CreateQueue(&sqs.CreateQueueInput{QueueName: &name})
+      return bus.CreateQueue(&sqs.CreateQueueInput{QueueName: &name})
     },
   )
   if err != nil { return nil, err }
@@ -610,13 +727,13 @@ func getEventQueueUrl(name string) (*string, error) {
   return queueResp.QueueUrl, nil
 }
 
-func getEventTopic(name string) (*string, error) {
-  snsSvc, err := SNS()
+func (c *Client) getEventTopic(name string) (*string, error) {
+  bus, err := c.Events()
   if err != nil { return nil, err }
 
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return snsSvc.CreateTopic(&sns.CreateTopicInput{Name: &name})
+      return bus.CreateTopic(&sns.CreateTopicInput{Name: &name})
     },
   )
   if err != nil { return nil, err }
@@ -624,21 +741,19 @@ func getEventTopic(name string) (*string, error) {
   return topicResp.TopicArn, nil
 }
 
-func cleanupEventHandling(stackName string) error {
-  snsSvc, err := SNS()
-  if err != nil { return err }
-  sqsSvc, err := SQS()
+func (c *Client) cleanupEventHandling(stackName string) error {
+  bus, err := c.Events()
   if err != nil { return err }
 
-  tArn, err := getEventTopic(stackName)
+  tArn, err := c.getEventTopic(stackName)
   if err != nil { return err }
 
-  qUrl, err := getEventQueueUrl(stackName)
+  qUrl, err := c.getEventQueueUrl(stackName)
   if err != nil { return err }
 
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return snsSvc.ListSubscriptionsByTopic(&sns.ListSubscriptionsByTopicInput{TopicArn: tArn})
+      return bus.ListSubscriptionsByTopic(&sns.ListSubscriptionsByTopicInput{TopicArn: tArn})
     },
   )
   if err != nil { return err }
@@ -647,28 +762,26 @@ func cleanupEventHandling(stackName string) error {
   for _, sub := range resp.Subscriptions {
     throttleProtected(
       func() (interface{}, error) {
-        return snsSvc.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: sub.SubscriptionArn})
+        return bus.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: sub.SubscriptionArn})
       },
     )
   }
 
   throttleProtected(
     func() (interface{}, error) {
-      return snsSvc.DeleteTopic(&sns.DeleteTopicInput{TopicArn: tArn})
+      return bus.DeleteTopic(&sns.DeleteTopicInput{TopicArn: tArn})
     },
   )
   throttleProtected(
     func() (interface{}, error) {
-      return sqsSvc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: qUrl})
+      return bus.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: qUrl})
     },
   )
   return nil
 }
 
-func setupEventHandling(stackName string) (*string, *string, error) {
-  snsSvc, err := SNS()
-  if err != nil { return nil, nil, err }
-  sqsSvc, err := SQS()
+func (c *Client) setupEventHandling(stackName string) (*string, *string, error) {
+  bus, err := c.Events()
   if err != nil { return nil, nil, err }
 
   var tArn, qUrl *string
@@ -678,7 +791,7 @@ func setupEventHandling(stackName string) (*string, *string, error) {
     if tArn != nil {
       throttleProtected(
         func() (interface{}, error) {
-          return snsSvc.DeleteTopic(&sns.DeleteTopicInput{TopicArn: tArn})
+          return bus.DeleteTopic(&sns.DeleteTopicInput{TopicArn: tArn})
         },
       )
     }
@@ -686,20 +799,20 @@ func setupEventHandling(stackName string) (*string, *string, error) {
     if qUrl != nil {
       throttleProtected(
         func() (interface{}, error) {
-          return sqsSvc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: qUrl})
+          return bus.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: qUrl})
         },
       )
     }
   }
 
-  tArn, err = getEventTopic(stackName)
+  tArn, err = c.getEventTopic(stackName)
   if err != nil { return nil, nil, err }
-  qUrl, err = getEventQueueUrl(stackName)
+  qUrl, err = c.getEventQueueUrl(stackName)
   if err != nil { cleanUp(); return nil, nil, err }
 
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return sqsSvc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+      return bus.GetQueueAttributes(&sqs.GetQueueAttributesInput{
         AttributeNames: []*string{aws.String("QueueArn")},
         QueueUrl: qUrl,
       })
@@ -711,7 +824,7 @@ func setupEventHandling(stackName string) (*string, *string, error) {
   
   _, err = throttleProtected(
     func() (interface{}, error) {
-      return sqsSvc.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+      return bus.SetQueueAttributes(&sqs.SetQueueAttributesInput{
         Attributes: map[string]*string{"Policy": aws.String(fmt.Sprintf(sqsPolicyTemplate, *qArn, *qArn, *tArn))},
         QueueUrl: qUrl,
       })
@@ -721,7 +834,7 @@ func setupEventHandling(stackName string) (*string, *string, error) {
   
   _, err = throttleProtected(
     func() (interface{}, error) {
-      return snsSvc.Subscribe(&sns.SubscribeInput{Endpoint: qArn, Protocol: aws.String("sqs"), TopicArn: tArn})
+      return bus.Subscribe(&sns.SubscribeInput{Endpoint: qArn, Protocol: aws.String("sqs"), TopicArn: tArn})
     },
   )
   if err != nil { cleanUp(); return nil, nil, err }
@@ -729,15 +842,15 @@ func setupEventHandling(stackName string) (*string, *string, error) {
   return tArn, qUrl, nil
 }
 
-func receiveMessage(qUrl *string, handler func(msg string)) {
-  svc, err := SQS()
+func (c *Client) receiveMessage(qUrl *string, handler EventHandler) {
+  bus, err := c.Events()
   if err != nil {
     fmt.Println("Error: " + err.Error())
     return
   }
   o, err := throttleProtected(
     func() (interface{}, error) {
-      return svc.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: qUrl, MaxNumberOfMessages: aws.Int64(10)})
+      return bus.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: qUrl, MaxNumberOfMessages: aws.Int64(10)})
     },
   )
   if err != nil {
@@ -766,26 +879,13 @@ func receiveMessage(qUrl *string, handler func(msg string)) {
         fmt.Println("Error: " + err.Error())
         return
       }
-      section := cfg.Section("")
-      var physResStr, logicalResStr string
-      physRes := section.Key("PhysicalResourceId")
-      if physRes != nil {
-        physResStr = physRes.String()
-        if physResStr != "" {
-          logicalRes := section.Key("LogicalResourceId")
-          if logicalRes != nil {
-            logicalResStr = logicalRes.String()
-            resStatus := section.Key("ResourceStatus")
-            if resStatus != nil {
-              handler(fmt.Sprintf("%s %s (%s)", resStatus.String(), logicalResStr, physResStr))
-            }
-          }
-        }
+      if event := notificationEvent(cfg.Section("")); event != nil {
+        handler(event)
       }
     }
     _, err := throttleProtected(
       func() (interface{}, error) {
-        return svc.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: qUrl, ReceiptHandle: message.ReceiptHandle})
+        return bus.DeleteMessage(&sqs.DeleteMessageInput{QueueUrl: qUrl, ReceiptHandle: message.ReceiptHandle})
       },
     )
     if err != nil {
@@ -807,8 +907,8 @@ type NotificationMessage struct {
   UnsubscribeURL string
 }
 
-func describeVPNConnection(connectionId string) (*string, error) {
-  svc, err := EC2()
+func (c *Client) describeVPNConnection(connectionId string) (*string, error) {
+  svc, err := c.EC2()
   if err != nil { return nil, err }
   // list NICs for subnet
   o, err := throttleProtected(
@@ -823,8 +923,8 @@ func describeVPNConnection(connectionId string) (*string, error) {
   return resp.VpnConnections[0].CustomerGatewayConfiguration, nil
 }
 
-func describeAutoscalingGroup(name string) (*autoscaling.Group, error) {
-  svc, err := AutoScaling()
+func (c *Client) describeAutoscalingGroup(name string) (*autoscaling.Group, error) {
+  svc, err := c.AutoScaling()
   if err != nil { return nil, err }
   o, err := throttleProtected(
     func() (interface{}, error) {
@@ -835,11 +935,14 @@ func describeAutoscalingGroup(name string) (*autoscaling.Group, error) {
   )
   if err != nil { return nil, err }
   resp := o.(*autoscaling.DescribeAutoScalingGroupsOutput)
+  if len(resp.AutoScalingGroups) == 0 {
+    return nil, fmt.Errorf("Autoscaling group not found: %s", name)
+  }
   return resp.AutoScalingGroups[0], nil
 }
 
-func getStackResources(stack *cloudformation.Stack) ([]*cloudformation.StackResourceSummary, error) {
-  svc, err := CloudFormation()
+func (c *Client) getStackResources(stack *cloudformation.Stack) ([]*cloudformation.StackResourceSummary, error) {
+  svc, err := c.CloudFormation()
   if err != nil { return nil, err }
   o, err := throttleProtected(
     func() (interface{}, error) {
@@ -853,8 +956,8 @@ func getStackResources(stack *cloudformation.Stack) ([]*cloudformation.StackReso
   return resp.StackResourceSummaries, nil
 }
 
-func getAutoscalingResource(stack *cloudformation.Stack) (*cloudformation.StackResourceSummary, error) {
-  resources, err := getStackResources(stack)
+func (c *Client) getAutoscalingResource(stack *cloudformation.Stack) (*cloudformation.StackResourceSummary, error) {
+  resources, err := c.getStackResources(stack)
   if err != nil { return nil, err }
   for _, res := range resources {
     if *res.ResourceType == "AWS::AutoScaling::AutoScalingGroup" {
@@ -865,12 +968,18 @@ func getAutoscalingResource(stack *cloudformation.Stack) (*cloudformation.StackR
 }
 
 func PreflightCheck() error {
-  matched, err := regexp.Match("^[a-z]{2}-[a-z]+-[1-9]$",[]byte(Config().AwsRegion))
-  if err != nil { return err }
-  if !matched {
-    return fmt.Errorf("Bad region: %s", Config().AwsRegion)
+  return DefaultClient().PreflightCheck()
+}
+
+func (c *Client) PreflightCheck() error {
+  if !c.Config().HasEndpointOverrides() {
+    matched, err := regexp.Match("^[a-z]{2}-[a-z]+-[1-9]$",[]byte(c.Config().AwsRegion))
+    if err != nil { return err }
+    if !matched {
+      return fmt.Errorf("Bad region: %s", c.Config().AwsRegion)
+    }
   }
-  svc, err := CloudFormation()
+  svc, err := c.CloudFormation()
   if err != nil { return err }
   _, err = throttleProtected(
     func() (interface{}, error) {
@@ -892,8 +1001,12 @@ func PreflightCheck() error {
 }
 
 func ExpiredStacks() ([]*cloudformation.Stack, error) {
+  return DefaultClient().ExpiredStacks()
+}
+
+func (c *Client) ExpiredStacks() ([]*cloudformation.Stack, error) {
   var expiredStacks = []*cloudformation.Stack{}
-  err := eachRunningStack(func(stack *cloudformation.Stack) {
+  err := c.eachRunningStack(context.Background(), func(stack *cloudformation.Stack) {
     expiryTimeStr := getStackTag(stack, "flight:expiry")
     if expiryTimeStr != "" {
       expiryTime, err := strconv.ParseInt(expiryTimeStr, 10, 64)
@@ -905,13 +1018,19 @@ func ExpiredStacks() ([]*cloudformation.Stack, error) {
   return expiredStacks, err
 }
 
-func createDomain(d *Domain, stackName, prefix string, tArn *string, launchParams []*cloudformation.Parameter) error {
-  svc, err := CloudFormation()
+func createDomain(ctx context.Context, d *Domain, stackName, prefix string, tArn *string, launchParams []*cloudformation.Parameter) error {
+  svc, err := d.Client().CloudFormation()
   if err != nil { return err }
+  template, err := d.Client().loadTemplate(d.Client().TemplateUrl("domain.json"))
+  if err != nil {
+    d.Client().cleanupEventHandling(stackName)
+    return err
+  }
 
   params := &cloudformation.CreateStackInput{
     StackName: aws.String(stackName),
-    TemplateURL: aws.String(TemplateUrl("domain.json")),
+    TemplateURL: template.urlParam(),
+    TemplateBody: template.bodyParam(),
     NotificationARNs: []*string{tArn},
     Tags: []*cloudformation.Tag{
       {
@@ -926,20 +1045,25 @@ func createDomain(d *Domain, stackName, prefix string, tArn *string, launchParam
         Key: aws.String("flight:type"),
         Value: aws.String("domain"),
       },
+      {
+        Key: aws.String("flight:template"),
+        Value: aws.String(template.source),
+      },
     },
     Parameters: launchParams,
   }
 
-  _, err = throttleProtected(
+  _, err = throttleProtectedWithContext(ctx,
     func() (interface{}, error) {
       return svc.CreateStack(params)
     },
   )
   if err != nil {
-    cleanupEventHandling(stackName)
+    d.Client().cleanupEventHandling(stackName)
     return err
   }
-  stack, err := awaitStack(svc, stackName)
+  stack, err := d.Client().awaitStack(ctx, svc, stackName)
+  if err != nil { d.Client().cleanupEventHandling(stackName) }
   d.Stack = stack
   return err
 }
@@ -0,0 +1,173 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "strconv"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// pagedProvider is a MemoryProvider whose stack listings are returned
+// a few stacks at a time, as CloudFormation does for large accounts.
+type pagedProvider struct {
+  *MemoryProvider
+  pageSize int
+  listings int
+}
+
+type pagedStackService struct {
+  StackService
+  provider *pagedProvider
+}
+
+func (p *pagedProvider) Stacks(c *Client) (StackService, error) {
+  return &pagedStackService{p.MemoryProvider, p}, nil
+}
+
+func (s *pagedStackService) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+  if input.StackName != nil { return s.StackService.DescribeStacks(input) }
+  s.provider.listings += 1
+  resp, err := s.StackService.DescribeStacks(input)
+  if err != nil { return nil, err }
+  start := 0
+  if input.NextToken != nil {
+    start, _ = strconv.Atoi(*input.NextToken)
+  }
+  end := start + s.provider.pageSize
+  if end >= len(resp.Stacks) {
+    return &cloudformation.DescribeStacksOutput{Stacks: resp.Stacks[start:]}, nil
+  }
+  return &cloudformation.DescribeStacksOutput{
+    Stacks: resp.Stacks[start:end],
+    NextToken: aws.String(strconv.Itoa(end)),
+  }, nil
+}
+
+func newPagedTestClient(t *testing.T, pageSize int) (*Client, *pagedProvider) {
+  paged := &pagedProvider{MemoryProvider: NewMemoryProvider("us-east-1"), pageSize: pageSize}
+  c, err := NewClient(WithProvider(paged), WithRegion("us-east-1"), WithEventSink(DiscardEvents))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  return c, paged
+}
+
+func TestFailedStacks(t *testing.T) {
+  for _, deleteFailed := range []bool{false, true} {
+    c, mem := newTestClient(t)
+    c.config.DeleteFailedStacks = deleteFailed
+    mem.Failures = map[string]string{"InternetGateway": "Simulated failure"}
+    err := c.NewDomain("d1", nil).Create(context.Background(), "d1", "")
+    if err == nil {
+      t.Fatalf("expected the domain launch to fail")
+    }
+    if !strings.Contains(err.Error(), "InternetGateway") || !strings.Contains(err.Error(), "Simulated failure") {
+      t.Errorf("error %q doesn't report the failed resource", err.Error())
+    }
+    if stackExists(mem, "flight-d1") == deleteFailed {
+      t.Errorf("with delete-failed-stacks %v, failed stack exists = %v", deleteFailed, !deleteFailed)
+    }
+  }
+}
+
+func TestStackListingIsPaginated(t *testing.T) {
+  c, paged := newPagedTestClient(t, 2)
+  paged.Failures = map[string]string{"InternetGateway": "Simulated failure"}
+  if err := c.NewDomain("d1", nil).Create(context.Background(), "d1", ""); err == nil {
+    t.Fatalf("expected the domain launch to fail")
+  }
+  paged.Failures = nil
+  createTestCluster(t, createTestDomain(t, c, "d2"), "c1", true)
+
+  paged.listings = 0
+  statuses := map[string]string{}
+  err := c.eachStack(context.Background(), func(stack *cloudformation.Stack) {
+    statuses[*stack.StackName] = *stack.StackStatus
+  })
+  if err != nil { t.Fatalf("eachStack: %s", err) }
+  if paged.listings != 3 {
+    t.Errorf("listed %d pages, want 3", paged.listings)
+  }
+  for _, name := range []string{"flight-d1", "flight-d2", "flight-d2-c1-network", "flight-d2-c1-master", "flight-d2-c1-compute-default"} {
+    if _, exists := statuses[name]; !exists {
+      t.Errorf("stack %s was not listed", name)
+    }
+  }
+  if statuses["flight-d1"] != "ROLLBACK_COMPLETE" {
+    t.Errorf("flight-d1 status = %s, want ROLLBACK_COMPLETE", statuses["flight-d1"])
+  }
+
+  running := []string{}
+  err = c.eachRunningStack(context.Background(), func(stack *cloudformation.Stack) {
+    running = append(running, *stack.StackName)
+  })
+  if err != nil { t.Fatalf("eachRunningStack: %s", err) }
+  if len(running) != 4 || containsS(running, "flight-d1") {
+    t.Errorf("running stacks = %v, want the four stacks of d2", running)
+  }
+}
+
+func TestThrottleProtectedStopsWhenCancelled(t *testing.T) {
+  ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+  defer cancel()
+  calls := 0
+  start := time.Now()
+  _, err := throttleProtectedWithContext(ctx, func() (interface{}, error) {
+    calls += 1
+    return nil, awserr.NewRequestFailure(awserr.New("Throttling", "Rate exceeded", nil), 400, "")
+  })
+  if err != context.DeadlineExceeded {
+    t.Errorf("err = %v, want the context's error", err)
+  }
+  if calls != 1 {
+    t.Errorf("retried %d times after cancellation", calls - 1)
+  }
+  if elapsed := time.Since(start); elapsed > 400 * time.Millisecond {
+    t.Errorf("took %s to give up, want it to stop when cancelled", elapsed)
+  }
+}
+
+func TestInterruptedWaitLeavesStackRunning(t *testing.T) {
+  c, mem := newTestClient(t)
+  mem.Latency = 10 * time.Millisecond
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  err := c.NewDomain("d1", nil).Create(ctx, "d1", "")
+  if err != context.Canceled {
+    t.Fatalf("Create: got %v, want context.Canceled", err)
+  }
+  if !stackExists(mem, "flight-d1") {
+    t.Errorf("interrupted launch removed its stack")
+  }
+}
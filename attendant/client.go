@@ -0,0 +1,228 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "sync"
+
+  "github.com/aws/aws-sdk-go/aws/credentials"
+  "github.com/aws/aws-sdk-go/aws/session"
+  "github.com/briandowns/spinner"
+)
+
+// Client drives Flight stacks in one region of one account.  It owns
+// the configuration, provider, AWS session and caches used by the
+// attendant API, so that several clients may be used concurrently from
+// one program.  The package-level functions use the default client,
+// which is the one configured by the fly command.
+type Client struct {
+  config *Configuration
+  provider Provider
+  store StateStore
+  handler EventHandler
+  spinner *spinner.Spinner
+  plan *Plan
+
+  mutex sync.Mutex
+  credentials *credentials.Credentials
+  account *Account
+  session *session.Session
+  stackCache *StackCache
+  stagedTemplates map[string]string
+  dynamoTables map[string]bool
+  priceOverrides map[string]*PriceTable
+}
+
+// ClientOption configures a client created with NewClient.
+type ClientOption func(*Client) error
+
+var defaultClient *Client
+
+// DefaultClient returns the client used by the package-level functions.
+func DefaultClient() *Client {
+  if defaultClient == nil {
+    defaultClient = newClient(defaultConfiguration())
+  }
+  return defaultClient
+}
+
+func newClient(config *Configuration) *Client {
+  return &Client{
+    config: config,
+    spinner: newSpinner(),
+    stagedTemplates: make(map[string]string),
+    dynamoTables: make(map[string]bool),
+  }
+}
+
+// NewClient creates a client from the default configuration as changed
+// by options.  Unless a provider is given, the provider is chosen by the
+// configured backend.
+func NewClient(options ...ClientOption) (*Client, error) {
+  c := newClient(defaultConfiguration())
+  for _, option := range options {
+    if err := option(c); err != nil { return nil, err }
+  }
+  if c.provider == nil {
+    if err := c.SetupBackend(); err != nil { return nil, err }
+  }
+  return c, nil
+}
+
+// WithConfig uses a copy of config in place of the default
+// configuration.
+func WithConfig(config Configuration) ClientOption {
+  return func(c *Client) error {
+    endpoints := make(map[string]string)
+    for service, endpoint := range config.Endpoints {
+      endpoints[service] = endpoint
+    }
+    config.Endpoints = endpoints
+    settings := make(map[string]string)
+    for key, val := range config.Settings {
+      settings[key] = val
+    }
+    config.Settings = settings
+    c.config = &config
+    return nil
+  }
+}
+
+func WithRegion(region string) ClientOption {
+  return func(c *Client) error {
+    c.config.AwsRegion = region
+    return nil
+  }
+}
+
+func WithCredentials(accessKey, secretKey string) ClientOption {
+  return func(c *Client) error {
+    c.config.AwsAccessKey = accessKey
+    c.config.AwsSecretKey = secretKey
+    return nil
+  }
+}
+
+// WithProfile uses a named profile from the shared AWS config and
+// credentials files.
+func WithProfile(profile string) ClientOption {
+  return func(c *Client) error {
+    c.config.AwsProfile = profile
+    return nil
+  }
+}
+
+// WithRole assumes a role, with an external ID if one is given.
+func WithRole(roleArn, externalId string) ClientOption {
+  return func(c *Client) error {
+    c.config.AwsRoleArn = roleArn
+    c.config.AwsExternalId = externalId
+    return nil
+  }
+}
+
+// WithTemplateSource selects templates from an explicit root URL or
+// local directory, or from a predefined template set when root is
+// empty.
+func WithTemplateSource(root, set string) ClientOption {
+  return func(c *Client) error {
+    if root != "" {
+      c.config.TemplateRoot = root
+      c.config.TemplateSet = ""
+    } else if set != "" {
+      c.config.TemplateSet = set
+    }
+    return nil
+  }
+}
+
+func WithProvider(p Provider) ClientOption {
+  return func(c *Client) error {
+    c.provider = p
+    return nil
+  }
+}
+
+// WithStateStore keeps domain, cluster and operation records in store
+// rather than the store selected by the state backend setting.
+func WithStateStore(store StateStore) ClientOption {
+  return func(c *Client) error {
+    c.store = store
+    return nil
+  }
+}
+
+// WithEventSink sends stack events to handler for domains, clusters and
+// appliances created without a handler of their own.
+func WithEventSink(handler EventHandler) ClientOption {
+  return func(c *Client) error {
+    c.handler = handler
+    return nil
+  }
+}
+
+func (c *Client) Config() *Configuration {
+  return c.config
+}
+
+// ForRegion returns a client for the same account and backend in
+// another region.  The stack cache is shared, as its entries are kept
+// per region, as are the credentials, so that an MFA token is asked for
+// only once.
+func (c *Client) ForRegion(region string) *Client {
+  config := *c.config
+  config.AwsRegion = region
+  r := newClient(&config)
+  r.provider = c.provider
+  r.store = c.store
+  r.handler = c.handler
+  r.spinner = c.spinner
+  r.stackCache = c.StackCache()
+  c.mutex.Lock()
+  r.credentials, _ = c.sharedCredentials()
+  r.account = c.account
+  c.mutex.Unlock()
+  return r
+}
+
+func (c *Client) NewCluster(name string, domain *Domain, handler EventHandler) *Cluster {
+  if handler == nil { handler = c.handler }
+  return &Cluster{Name: name, Domain: domain, MessageHandler: handler, ExpiryTime: -1, Quota: -1, client: c}
+}
+
+func (c *Client) NewDomain(name string, handler EventHandler) *Domain {
+  if handler == nil { handler = c.handler }
+  return &Domain{Name: name, MessageHandler: handler, client: c}
+}
+
+func (c *Client) NewAppliance(name string, domain *Domain, handler EventHandler) *Appliance {
+  if handler == nil { handler = c.handler }
+  return &Appliance{Name: name, Domain: domain, MessageHandler: handler, client: c}
+}
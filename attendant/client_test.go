@@ -0,0 +1,77 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "context"
+  "testing"
+)
+
+// newTestClient returns a client backed by an in-memory provider, with
+// stack operations completing immediately.
+func newTestClient(t *testing.T) (*Client, *MemoryProvider) {
+  mem := NewMemoryProvider("us-east-1")
+  c, err := NewClient(WithProvider(mem), WithRegion("us-east-1"), WithEventSink(DiscardEvents))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  return c, mem
+}
+
+func createTestDomain(t *testing.T, c *Client, name string) *Domain {
+  d := c.NewDomain(name, nil)
+  if err := d.Create(context.Background(), name, ""); err != nil {
+    t.Fatalf("Domain.Create: %s", err)
+  }
+  return d
+}
+
+func createTestCluster(t *testing.T, d *Domain, name string, withQ bool) *Cluster {
+  cluster := d.Client().NewCluster(name, d, nil)
+  if err := cluster.Create(context.Background(), withQ); err != nil {
+    t.Fatalf("Cluster.Create: %s", err)
+  }
+  return cluster
+}
+
+func TestForRegionSharesProvider(t *testing.T) {
+  c, mem := newTestClient(t)
+  r := c.ForRegion("eu-west-1")
+  if r.Config().AwsRegion != "eu-west-1" {
+    t.Errorf("region = %s, want eu-west-1", r.Config().AwsRegion)
+  }
+  if c.Config().AwsRegion != "us-east-1" {
+    t.Errorf("original client region changed to %s", c.Config().AwsRegion)
+  }
+  if r.Provider() != Provider(mem) {
+    t.Errorf("regional client has a different provider")
+  }
+  if r.Spinner() != c.Spinner() {
+    t.Errorf("regional client has a different spinner")
+  }
+}
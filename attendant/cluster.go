@@ -29,20 +29,17 @@
 package attendant
 
 import (
+  "context"
   "fmt"
-  "io/ioutil"
+  "os"
   "strconv"
   "strings"
   "time"
 
-  "github.com/spf13/viper"
-
   "github.com/aws/aws-sdk-go/aws"
   "github.com/aws/aws-sdk-go/aws/awserr"
   "github.com/aws/aws-sdk-go/service/autoscaling"
   "github.com/aws/aws-sdk-go/service/cloudformation"
-
-  "gopkg.in/yaml.v2"
 )
 
 var clusterNetworkTemplate = "cluster-network.json"
@@ -110,38 +107,48 @@ type Cluster struct {
   Master *Master
   ComputeGroups []*ComputeGroup
   TopicARN string
-  MessageHandler func(msg string)
+  MessageHandler EventHandler
   ExpiryTime int64
   Quota int64
   SoloMode string
+  client *Client
 }
 
 type ClusterDetails struct {
-  Ip string
-  KeyPair string
-  Url string
-  Username string
-  Uuid string
-  Token string
-  Queues []QueueDetails
-  Components []string
-  ExpiryTime int64
-  Quota int64
-  VPNAccess string
-  SSHAccess string
-  ConfigValues map[string]string
+  Name string `json:"Name" yaml:"Name"`
+  Domain string `json:"Domain,omitempty" yaml:"Domain,omitempty"`
+  Region string `json:"Region" yaml:"Region"`
+  Status string `json:"Status" yaml:"Status"`
+  CreationTime string `json:"CreationTime" yaml:"CreationTime"`
+  Stacks []string `json:"Stacks" yaml:"Stacks"`
+  Ip string `json:"Ip" yaml:"Ip"`
+  KeyPair string `json:"KeyPair" yaml:"KeyPair"`
+  Url string `json:"Url" yaml:"Url"`
+  Username string `json:"Username" yaml:"Username"`
+  Uuid string `json:"Uuid" yaml:"Uuid"`
+  Token string `json:"Token" yaml:"Token"`
+  Queues []QueueDetails `json:"Queues" yaml:"Queues"`
+  Components []string `json:"Components" yaml:"Components"`
+  ExpiryTime int64 `json:"ExpiryTime" yaml:"ExpiryTime"`
+  Quota int64 `json:"Quota" yaml:"Quota"`
+  VPNAccess string `json:"VPNAccess" yaml:"VPNAccess"`
+  SSHAccess string `json:"SSHAccess" yaml:"SSHAccess"`
+  ConfigValues map[string]string `json:"ConfigValues" yaml:"ConfigValues"`
 }
 
 type QueueDetails struct {
-  Name string
-  InstanceType string
-  Pricing string
-  ResourceName string
-  MaxSize int
-  MinSize int
-  DesiredCapacity int
-  Running int
-  ExpiryTime int64
+  Name string `json:"Name" yaml:"Name"`
+  StackName string `json:"StackName" yaml:"StackName"`
+  Status string `json:"Status" yaml:"Status"`
+  InstanceType string `json:"InstanceType" yaml:"InstanceType"`
+  Pricing string `json:"Pricing" yaml:"Pricing"`
+  ResourceName string `json:"ResourceName" yaml:"ResourceName"`
+  MaxSize int `json:"MaxSize" yaml:"MaxSize"`
+  MinSize int `json:"MinSize" yaml:"MinSize"`
+  DesiredCapacity int `json:"DesiredCapacity" yaml:"DesiredCapacity"`
+  Running int `json:"Running" yaml:"Running"`
+  SuspendedProcesses []string `json:"SuspendedProcesses" yaml:"SuspendedProcesses"`
+  ExpiryTime int64 `json:"ExpiryTime" yaml:"ExpiryTime"`
 }
 
 type ClusterNetwork struct {
@@ -221,17 +228,26 @@ type ComputeGroup struct {
   ResourceName string
   _AutoscalingGroup *autoscaling.Group
   ExpiryTime int64
+  client *Client
+}
+
+func NewCluster(name string, domain *Domain, handler EventHandler) *Cluster {
+  if domain == nil { return DefaultClient().NewCluster(name, domain, handler) }
+  return domain.Client().NewCluster(name, domain, handler)
 }
 
-func NewCluster(name string, domain *Domain, handler func(msg string)) *Cluster {
-  return &Cluster{name, domain, nil, nil, nil, "", handler, -1, -1, ""}
+// Client returns the client that the cluster belongs to.
+func (c *Cluster) Client() *Client {
+  if c.client != nil { return c.client }
+  if c.Domain != nil { return c.Domain.Client() }
+  return DefaultClient()
 }
 
-func (c *Cluster) processQueue(qArn *string) {
-  for c.MessageHandler != nil {
+func (c *Cluster) processQueue(ctx context.Context, qArn *string) {
+  for c.MessageHandler != nil && ctx.Err() == nil {
     time.Sleep(500 * time.Millisecond)
     if c.MessageHandler != nil {
-      receiveMessage(qArn, c.MessageHandler)
+      c.Client().receiveMessage(qArn, c.MessageHandler)
     }
   }
 }
@@ -245,84 +261,301 @@ func (c *Cluster) Tags() []*cloudformation.Tag {
   }
 }
 
-func (c *Cluster) Create(withQ bool) error {
-  svc, err := CloudFormation()
+func (c *Cluster) Create(ctx context.Context, withQ bool) error {
+  var op *OperationEntity
+  var err error
+  if c.Domain == nil {
+    op, err = startOperation(ClusterLaunchOperation, c, "event-handling", "solo-stack")
+  } else {
+    steps := []string{"event-handling", "book-network", "network-stack", "entity", "master-stack"}
+    if withQ {
+      steps = append(steps, "default-queue")
+    }
+    op, err = startOperation(ClusterLaunchOperation, c, steps...)
+  }
   if err != nil { return err }
+  return c.create(ctx, op)
+}
+
+func (c *Cluster) create(ctx context.Context, op *OperationEntity) error {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return op.finish(err) }
+
+  err = c.createStacks(ctx, op, svc)
+  // an interrupted launch is left for the user to resume or abort
+  if isInterrupted(err) { return op.finish(err) }
+  if err != nil {
+    c.rollbackCreate(ctx, svc)
+    return op.compensated(err)
+  }
+
+  c.MessageHandler(NewDoneEvent())
 
+  if op.findStep("default-queue") != nil {
+    stackName := fmt.Sprintf("flight-%s-%s-compute-default", c.Domain.Name, c.Name)
+    _, err = op.stackStep(ctx, svc, "default-queue", stackName, func() error { return c.AddQueue(ctx, "default", "", 0) })
+  }
+  return op.finish(err)
+}
+
+func (c *Cluster) createStacks(ctx context.Context, op *OperationEntity, svc StackService) error {
   if c.Domain == nil {
     // launch a solo cluster
-    tArn, qUrl, err := setupEventHandling("flight-cluster-" + c.Name)
+    err := op.rerun("event-handling", func() error { return c.setupEvents(ctx, "flight-cluster-" + c.Name) })
     if err != nil { return err }
-    go c.processQueue(qUrl)
-    c.TopicARN = *tArn
-    err = createSoloCluster(c, svc)
+    stack, err := op.stackStep(ctx, svc, "solo-stack", "flight-cluster-" + c.Name, func() error { return createSoloCluster(ctx, c, svc) })
     if err != nil { return err }
-  } else {
-    tArn, qUrl, err := setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+    c.Master = &Master{stack}
+    return nil
+  }
+
+  err := op.rerun("event-handling", func() error { return c.setupEvents(ctx, "flight-" + c.Domain.Name + "-cluster-" + c.Name) })
+  if err != nil { return err }
+
+  err = op.step("book-network", func() error {
+    network, err := c.Domain.BookNetwork()
     if err != nil { return err }
-    go c.processQueue(qUrl)
-    c.TopicARN = *tArn
+    op.Options["NetworkIndex"] = strconv.Itoa(network)
+    return nil
+  })
+  if err != nil { return err }
+  network, err := strconv.Atoi(op.Options["NetworkIndex"])
+  if err != nil { return err }
+  c.Network = &ClusterNetwork{network, nil}
+
+  stackName := fmt.Sprintf("flight-%s-%s-network", c.Domain.Name, c.Name)
+  c.Network.Stack, err = op.stackStep(ctx, svc, "network-stack", stackName, func() error { return createClusterNetwork(ctx, c, svc) })
+  if err != nil { return err }
+  err = op.step("entity", c.CreateEntity)
+  if err != nil { return err }
+
+  // create master node
+  stackName = fmt.Sprintf("flight-%s-%s-master", c.Domain.Name, c.Name)
+  stack, err := op.stackStep(ctx, svc, "master-stack", stackName, func() error { return createMaster(ctx, c, svc) })
+  if err != nil { return err }
+  c.Master = &Master{stack}
+  return nil
+}
+
+func (c *Cluster) setupEvents(ctx context.Context, name string) error {
+  tArn, qUrl, err := c.Client().setupEventHandling(name)
+  if err != nil { return err }
+  go c.processQueue(ctx, qUrl)
+  c.TopicARN = *tArn
+  return nil
+}
 
-    err = createClusterNetwork(c, svc)
+// rollbackCreate undoes the parts of a failed cluster launch that
+// succeeded: the network stack, its network booking and entity, and the
+// cluster's event topic and queue.
+func (c *Cluster) rollbackCreate(ctx context.Context, svc StackService) {
+  if c.Domain == nil {
+    c.Client().cleanupEventHandling("flight-cluster-" + c.Name)
+    return
+  }
+  if c.Network != nil {
+    if c.Network.Stack != nil {
+      c.MessageHandler(noticeEvent(fmt.Sprintf("Launch failed; removing network for cluster '%s'.", c.Name)))
+      destroyClusterNetwork(ctx, c, svc)
+      c.DestroyEntity()
+    }
+    c.Domain.ReleaseNetwork(c.Network.Index)
+    c.Network = nil
+  }
+  c.Client().cleanupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+}
+
+// abortCreate undoes an interrupted launch, destroying the stacks it
+// had created or was creating.
+func (c *Cluster) abortCreate(ctx context.Context, op *OperationEntity, svc StackService) error {
+  if c.Domain == nil {
+    eventName := "flight-cluster-" + c.Name
+    err := c.setupEvents(ctx, eventName)
     if err != nil { return err }
-    // create master node
-    err = createMaster(c, svc)
+    err = destroyStack(ctx, svc, eventName)
     if err != nil { return err }
+    return c.Client().cleanupEventHandling(eventName)
   }
 
-  c.MessageHandler("DONE")
-
-  if withQ {
-    err = c.AddQueue("default", "", 0)
+  eventName := "flight-" + c.Domain.Name + "-cluster-" + c.Name
+  err := c.setupEvents(ctx, eventName)
+  if err != nil { return err }
+  stackNames := []string{fmt.Sprintf("flight-%s-%s-master", c.Domain.Name, c.Name)}
+  if op.findStep("default-queue") != nil {
+    stackNames = append([]string{fmt.Sprintf("flight-%s-%s-compute-default", c.Domain.Name, c.Name)}, stackNames...)
+  }
+  for _, stackName := range stackNames {
+    err = destroyStack(ctx, svc, stackName)
     if err != nil { return err }
   }
-  return nil
+  err = destroyClusterNetwork(ctx, c, svc)
+  if err != nil { return err }
+  if step := op.findStep("entity"); step != nil && step.Status != "pending" {
+    c.DestroyEntity()
+  }
+  if v, exists := op.Options["NetworkIndex"]; exists {
+    network, err := strconv.Atoi(v)
+    if err != nil { return err }
+    err = c.Domain.ReleaseNetwork(network)
+    if err != nil && err != ErrNetworkNotBooked { return err }
+  }
+  return c.Client().cleanupEventHandling(eventName)
 }
 
-func (c *Cluster) AddQueue(queueName, queueParamsFile string, expiryTime int64) error {
-  svc, err := CloudFormation()
+func (c *Cluster) AddQueue(ctx context.Context, queueName, queueParamsFile string, expiryTime int64) error {
+  svc, err := c.Client().CloudFormation()
   if err != nil { return err }
 
-  // Load some cluster information, specifically Network
-  networkStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-network")
-  if err != nil { return err }
-  idx, err := strconv.Atoi(getStackTag(networkStack, "flight:network"))
-  if err != nil { return err }
-  c.Network = &ClusterNetwork{idx, networkStack}
-  masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master")
+  err = c.loadInfrastructure(svc)
   if err != nil { return err }
-  c.Master = &Master{masterStack}
 
-  tArn, qUrl, err := setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+  tArn, qUrl, err := c.Client().setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
   if err != nil { return err }
-  go c.processQueue(qUrl)
+  go c.processQueue(ctx, qUrl)
   c.TopicARN = *tArn
 
   // create compute group(s)
-  err = createComputeGroup(c, queueName, queueParamsFile, expiryTime, svc)
+  err = createComputeGroup(ctx, c, queueName, queueParamsFile, expiryTime, svc)
   if err != nil { return err }
-  c.MessageHandler("DONE")
+  c.MessageHandler(NewDoneEvent())
   return nil
 }
 
-func (c *Cluster) DestroyQueue(queueName string) error {
-  svc, err := CloudFormation()
-  if err != nil { return err }
-  qUrl, err := getEventQueueUrl("flight-" + c.Domain.Name + "-cluster-" + c.Name)
-  if err != nil { return err }
-  go c.processQueue(qUrl)
+// QueueModification describes changes to a compute group's scaling.
+// Sizes that are nil are left unchanged; the process lists name the
+// scaling processes to suspend or resume, or "all".
+type QueueModification struct {
+  MinSize *int64
+  MaxSize *int64
+  DesiredCapacity *int64
+  Suspend []string
+  Resume []string
+}
 
-  err = destroyComputeGroup(c, queueName, svc)
-  if err != nil { return err }
-  c.MessageHandler("DONE")
+var ScalingProcesses = []string{
+  "Launch",
+  "Terminate",
+  "HealthCheck",
+  "ReplaceUnhealthy",
+  "AZRebalance",
+  "AlarmNotification",
+  "ScheduledActions",
+  "AddToLoadBalancer",
+}
+
+func scalingProcessQuery(groupName string, processes []string) (*autoscaling.ScalingProcessQuery, error) {
+  query := &autoscaling.ScalingProcessQuery{AutoScalingGroupName: aws.String(groupName)}
+  if containsS(processes, "all") { return query, nil }
+  for _, process := range processes {
+    if !containsS(ScalingProcesses, process) {
+      return nil, fmt.Errorf("Unknown scaling process: %s (valid processes: all, %s)", process, strings.Join(ScalingProcesses, ", "))
+    }
+  }
+  query.ScalingProcesses = aws.StringSlice(processes)
+  return query, nil
+}
+
+// checkComputeCeiling refuses a queue maximum size above the configured
+// compute-max-nodes ceiling.
+func (c *Cluster) checkComputeCeiling(maxSize int64) error {
+  if ceiling := c.Client().Config().ComputeMaxNodes; ceiling > 0 && maxSize > ceiling {
+    return fmt.Errorf("Maximum size %d exceeds the configured ceiling of %d nodes (compute-max-nodes).", maxSize, ceiling)
+  }
   return nil
 }
 
-func (c *Cluster) Expand(componentType, componentName, componentParamsFile string) error {
-  svc, err := CloudFormation()
+// ModifyQueue resizes a compute group and suspends or resumes its
+// scaling processes.  New bounds are persisted to the queue's stack
+// parameters so that a later update doesn't revert them.
+func (c *Cluster) ModifyQueue(ctx context.Context, queueName string, mod *QueueModification) error {
+  err := c.LoadComputeGroups()
+  if err != nil { return err }
+  var group *ComputeGroup
+  for _, g := range c.ComputeGroups {
+    if g.Name == queueName {
+      group = g
+    }
+  }
+  if group == nil {
+    return fmt.Errorf("No compute queue named '%s' running on cluster: %s/%s (%s)", queueName, c.Domain.Name, c.Name, c.Client().Config().AwsRegion)
+  }
+  if group.ResourceName == "" {
+    return fmt.Errorf("Autoscaling resource not found for stack: %s", *group.Stack.StackName)
+  }
+
+  minSize, maxSize, desired := int64(group.MinSize()), int64(group.MaxSize()), int64(group.DesiredCapacity())
+  if mod.MinSize != nil { minSize = *mod.MinSize }
+  if mod.MaxSize != nil { maxSize = *mod.MaxSize }
+  if mod.DesiredCapacity != nil { desired = *mod.DesiredCapacity }
+  if mod.MaxSize != nil {
+    if err := c.checkComputeCeiling(maxSize); err != nil { return err }
+  }
+  if minSize < 0 || minSize > maxSize {
+    return fmt.Errorf("Minimum size %d must be between 0 and the maximum size of %d.", minSize, maxSize)
+  }
+  if desired < minSize || desired > maxSize {
+    return fmt.Errorf("Desired capacity %d must be between the minimum (%d) and maximum (%d) sizes.", desired, minSize, maxSize)
+  }
+  if mod.MaxSize != nil && maxSize > int64(group.MaxSize()) {
+    if err := c.checkQueueQuota(*group.Stack.StackName, group.InstanceType, maxSize); err != nil { return err }
+  }
+
+  var suspend, resume *autoscaling.ScalingProcessQuery
+  if len(mod.Suspend) > 0 {
+    if suspend, err = scalingProcessQuery(group.ResourceName, mod.Suspend); err != nil { return err }
+  }
+  if len(mod.Resume) > 0 {
+    if resume, err = scalingProcessQuery(group.ResourceName, mod.Resume); err != nil { return err }
+  }
+
+  // The stack is updated first so that a failed update leaves the
+  // group as it was.  The templates have no minimum size parameter, so
+  // only the maximum and desired sizes can be persisted.
+  overrides := make(map[string]string)
+  for key, size := range map[string]*int64{"ComputeMaxNodes": mod.MaxSize, "ComputeInitialNodes": mod.DesiredCapacity} {
+    if size != nil && hasStackParameter(group.Stack, key) {
+      overrides[key] = strconv.FormatInt(*size, 10)
+    }
+  }
+  if len(overrides) > 0 {
+    update, err := c.PrepareUpdate(ctx, "compute", queueName, "", overrides, false)
+    if err != nil { return err }
+    if update.HasChanges() {
+      if err = c.applyUpdate(ctx, update); err != nil {
+        c.DiscardUpdate(update)
+        return err
+      }
+    }
+  }
+
+  svc, err := c.Client().AutoScaling()
   if err != nil { return err }
+  if mod.MinSize != nil || mod.MaxSize != nil || mod.DesiredCapacity != nil {
+    _, err = throttleProtected(func() (interface{}, error) {
+      return svc.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+        AutoScalingGroupName: aws.String(group.ResourceName),
+        MinSize: mod.MinSize,
+        MaxSize: mod.MaxSize,
+        DesiredCapacity: mod.DesiredCapacity,
+      })
+    })
+    if err != nil { return err }
+  }
+  if resume != nil {
+    _, err = throttleProtected(func() (interface{}, error) { return svc.ResumeProcesses(resume) })
+    if err != nil { return err }
+  }
+  if suspend != nil {
+    _, err = throttleProtected(func() (interface{}, error) { return svc.SuspendProcesses(suspend) })
+    if err != nil { return err }
+  }
+  c.MessageHandler(NewDoneEvent())
+  return nil
+}
 
-  // Load some cluster information, specifically Network
+// loadInfrastructure loads the network and master stacks that queues
+// and components are launched alongside.
+func (c *Cluster) loadInfrastructure(svc StackService) error {
   networkStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-network")
   if err != nil { return err }
   idx, err := strconv.Atoi(getStackTag(networkStack, "flight:network"))
@@ -331,48 +564,101 @@ func (c *Cluster) Expand(componentType, componentName, componentParamsFile strin
   masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master")
   if err != nil { return err }
   c.Master = &Master{masterStack}
+  return nil
+}
+
+func (c *Cluster) DestroyQueue(ctx context.Context, queueName string) error {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return err }
+  qUrl, err := c.Client().getEventQueueUrl("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+  if err != nil { return err }
+  go c.processQueue(ctx, qUrl)
+
+  err = destroyComputeGroup(ctx, c, queueName, svc)
+  if err != nil { return err }
+  c.MessageHandler(NewDoneEvent())
+  return nil
+}
+
+func (c *Cluster) Expand(ctx context.Context, componentType, componentName, componentParamsFile string) error {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return err }
+
+  err = c.loadInfrastructure(svc)
+  if err != nil { return err }
   
-  tArn, qUrl, err := setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+  tArn, qUrl, err := c.Client().setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
   if err != nil { return err }
-  go c.processQueue(qUrl)
+  go c.processQueue(ctx, qUrl)
   c.TopicARN = *tArn
 
-  err = createComponent(componentType, componentName, componentParamsFile, c, svc)
+  err = createComponent(ctx, componentType, componentName, componentParamsFile, c, svc)
   if err != nil { return err }
 
-  c.MessageHandler("DONE")
+  c.MessageHandler(NewDoneEvent())
   return nil
 }
 
-func (c *Cluster) Reduce(componentType, componentName string) error {
-  svc, err := CloudFormation()
+func (c *Cluster) Reduce(ctx context.Context, componentType, componentName string) error {
+  svc, err := c.Client().CloudFormation()
   if err != nil { return err }
 
-  tArn, qUrl, err := setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+  tArn, qUrl, err := c.Client().setupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
   if err != nil { return err }
-  go c.processQueue(qUrl)
+  go c.processQueue(ctx, qUrl)
   c.TopicARN = *tArn
 
-  err = destroyComponent(c, componentType, componentName, svc)
+  err = destroyComponent(ctx, c, componentType, componentName, svc)
   if err != nil { return err }
 
-  c.MessageHandler("DONE")
+  c.MessageHandler(NewDoneEvent())
   return nil
 }
 
-func (c *Cluster) Purge() error {
-  svc, err := CloudFormation()
+func (c *Cluster) Purge(ctx context.Context) error {
+  op, err := startOperation(ClusterPurgeOperation, c, "stacks", "network-stack", "event-handling", "release-network", "entity")
+  if err != nil { return err }
+  return c.purge(ctx, op)
+}
+
+func (c *Cluster) purge(ctx context.Context, op *OperationEntity) error {
+  return op.finish(c.purgeSteps(ctx, op))
+}
 
+func (c *Cluster) purgeSteps(ctx context.Context, op *OperationEntity) error {
+  svc, err := c.Client().CloudFormation()
   if err != nil { return err }
+
+  err = op.step("stacks", func() error { return c.purgeStacks(ctx, svc) })
+  if err != nil { return err }
+
+  // have to wait until everything else is destroyed before destroing the network
+  err = op.step("network-stack", func() error {
+    stackName := fmt.Sprintf("flight-%s-%s-network", c.Domain.Name, c.Name)
+    c.MessageHandler(NewStackEvent("DELETE_IN_PROGRESS", stackName))
+    if err := destroyClusterNetwork(ctx, c, svc); isInterrupted(err) { return err }
+    c.MessageHandler(NewStackEvent("DELETE_COMPLETE", stackName))
+    return nil
+  })
+  if err != nil { return err }
+
+  err = op.step("event-handling", func() error { return c.Client().cleanupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name) })
+  if err != nil { return err }
+
+  return c.releaseEntity(op)
+}
+
+// purgeStacks destroys the component, compute group and master stacks
+// of a cluster in parallel.
+func (c *Cluster) purgeStacks(ctx context.Context, svc StackService) error {
   var ch chan string = make(chan string)
   // purge components
-  componentStacks, err := getComponentStacksForCluster(c)
+  componentStacks, err := getComponentStacksForCluster(ctx, c)
   if err != nil { return err }
   for _, stack := range componentStacks {
     go func(stack *cloudformation.Stack, ch chan<- string) {
-      n := fmt.Sprintf("%s %s", *stack.StackName, *stack.StackName)
-      c.MessageHandler("DELETE_IN_PROGRESS " + n)
-      destroyStack(svc, *stack.StackName)
+      c.MessageHandler(NewStackEvent("DELETE_IN_PROGRESS", *stack.StackName))
+      destroyStack(ctx, svc, *stack.StackName)
       ch <- *stack.StackName
     }(stack, ch)
   }
@@ -381,110 +667,136 @@ func (c *Cluster) Purge() error {
   c.LoadComputeGroups()
   for _, group := range c.ComputeGroups {
     go func(stack *cloudformation.Stack, ch chan<- string) {
-      n := fmt.Sprintf("%s %s", *stack.StackName, *stack.StackName)
-      c.MessageHandler("DELETE_IN_PROGRESS " + n)
-      destroyStack(svc, *stack.StackName)
+      c.MessageHandler(NewStackEvent("DELETE_IN_PROGRESS", *stack.StackName))
+      destroyStack(ctx, svc, *stack.StackName)
       ch <- *stack.StackName
     }(group.Stack, ch)
   }
 
   // purge master
   go func(ch chan<- string) {
-    n := fmt.Sprintf("%s %s", *c.Master.Stack.StackName, *c.Master.Stack.StackName)
-    c.MessageHandler("DELETE_IN_PROGRESS " + n)
-    destroyMaster(c, svc)
-    ch <- *c.Master.Stack.StackName
+    stackName := fmt.Sprintf("flight-%s-%s-master", c.Domain.Name, c.Name)
+    c.MessageHandler(NewStackEvent("DELETE_IN_PROGRESS", stackName))
+    destroyMaster(ctx, c, svc)
+    ch <- stackName
   }(ch)
 
   count := len(componentStacks) + len(c.ComputeGroups) + 1
   for item := <- ch; item != ""; item = <- ch {
-    c.MessageHandler("DELETE_COMPLETE " + item + " " + item)
+    c.MessageHandler(NewStackEvent("DELETE_COMPLETE", item))
     count -= 1
     if count == 0 {
       break
     }
   }
+  return ctx.Err()
+}
 
-  // have to wait until everything else is destroyed before destroing the network
-  n := fmt.Sprintf("%s %s", *c.Network.Stack.StackName, *c.Network.Stack.StackName)
-  c.MessageHandler("DELETE_IN_PROGRESS " + n)
-  destroyClusterNetwork(c, svc)
-  c.MessageHandler("DELETE_COMPLETE " + n)
-
-  err = cleanupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+// releaseEntity releases the network booking of a destroyed cluster and
+// removes its entity.
+func (c *Cluster) releaseEntity(op *OperationEntity) error {
+  err := op.step("release-network", func() error {
+    entity, err := c.LoadEntity()
+    // a failed launch releases its own booking
+    if err == ErrEntityNotFound { return nil }
+    if err != nil { return err }
+    err = c.Domain.ReleaseNetwork(entity.NetworkIndex)
+    if err == ErrNetworkNotBooked { return nil }
+    return err
+  })
   if err != nil { return err }
+  return op.step("entity", func() error {
+    c.DestroyEntity()
+    return nil
+  })
+}
 
-  entity, err := c.LoadEntity()
-  if err != nil { return err }
-  err = c.Domain.ReleaseNetwork(entity.NetworkIndex)
+func (c *Cluster) Destroy(ctx context.Context) error {
+  var op *OperationEntity
+  var err error
+  if c.Domain == nil {
+    op, err = startOperation(ClusterDestroyOperation, c, "solo-stack", "event-handling")
+  } else {
+    op, err = startOperation(ClusterDestroyOperation, c, "components", "compute-groups", "master-stack", "network-stack", "event-handling", "release-network", "entity")
+  }
   if err != nil { return err }
-  c.DestroyEntity()
+  return c.destroy(ctx, op)
+}
 
-  return nil
+func (c *Cluster) destroy(ctx context.Context, op *OperationEntity) error {
+  return op.finish(c.destroySteps(ctx, op))
 }
 
-func (c *Cluster) Destroy() error {
-  svc, err := CloudFormation()
+func (c *Cluster) destroySteps(ctx context.Context, op *OperationEntity) error {
+  svc, err := c.Client().CloudFormation()
   if err != nil { return err }
   if c.Domain == nil {
     // destroying a solo cluster
-    qUrl, err := getEventQueueUrl("flight-cluster-" + c.Name)
-    if err != nil { return err }
-    go c.processQueue(qUrl)
-    err = destroySoloCluster(c, svc)
+    eventName := "flight-cluster-" + c.Name
+    if !op.done("event-handling") {
+      qUrl, err := c.Client().getEventQueueUrl(eventName)
+      if err != nil { return err }
+      go c.processQueue(ctx, qUrl)
+    }
+    err = op.step("solo-stack", func() error { return destroySoloCluster(ctx, c, svc) })
     if err != nil { return err }
 
-    err = cleanupEventHandling("flight-cluster-" + c.Name)
+    err = op.step("event-handling", func() error { return c.Client().cleanupEventHandling(eventName) })
     if err != nil { return err }
 
-    c.MessageHandler("DONE")
-  } else {
-    qUrl, err := getEventQueueUrl("flight-" + c.Domain.Name + "-cluster-" + c.Name)
+    c.MessageHandler(NewDoneEvent())
+    return nil
+  }
+
+  eventName := "flight-" + c.Domain.Name + "-cluster-" + c.Name
+  if !op.done("event-handling") {
+    qUrl, err := c.Client().getEventQueueUrl(eventName)
     if err != nil { return err }
-    go c.processQueue(qUrl)
+    go c.processQueue(ctx, qUrl)
+  }
 
-    // get any components and destroy them first
-    componentStacks, err := getComponentStacksForCluster(c)
+  // get any components and destroy them first
+  err = op.step("components", func() error {
+    componentStacks, err := getComponentStacksForCluster(ctx, c)
     if err != nil { return err }
-    c.MessageHandler("DISABLE-COUNTERS")
+    c.MessageHandler(newEvent(DisableCountersEvent))
     for _, stack := range componentStacks {
-      err = destroyStack(svc, *stack.StackName)
+      err = destroyStack(ctx, svc, *stack.StackName)
       if err != nil { return err }
     }
-    c.MessageHandler("ENABLE-COUNTERS")
+    c.MessageHandler(newEvent(EnableCountersEvent))
+    return nil
+  })
+  if err != nil { return err }
 
-    // get compute group stacks and destroy them next
+  // get compute group stacks and destroy them next
+  err = op.step("compute-groups", func() error {
     c.LoadComputeGroups()
-    if err != nil { return err }
-    c.MessageHandler(fmt.Sprintf("COUNTERS=%d",ClusterResourceCount + (ComputeGroupResourceCount * len(c.ComputeGroups))))
+    c.MessageHandler(countersEvent(ClusterResourceCount + (ComputeGroupResourceCount * len(c.ComputeGroups))))
     for _, group := range c.ComputeGroups {
-      err = destroyStack(svc, *group.Stack.StackName)
+      err := destroyStack(ctx, svc, *group.Stack.StackName)
       if err != nil { return err }
     }
+    return nil
+  })
+  if err != nil { return err }
 
-    err = destroyMaster(c, svc)
-    if err != nil { return err }
-    err = destroyClusterNetwork(c, svc)
-    if err != nil { return err }
-
-    err = cleanupEventHandling("flight-" + c.Domain.Name + "-cluster-" + c.Name)
-    if err != nil { return err }
+  err = op.step("master-stack", func() error { return destroyMaster(ctx, c, svc) })
+  if err != nil { return err }
+  err = op.step("network-stack", func() error { return destroyClusterNetwork(ctx, c, svc) })
+  if err != nil { return err }
 
-    c.MessageHandler("DONE")
+  err = op.step("event-handling", func() error { return c.Client().cleanupEventHandling(eventName) })
+  if err != nil { return err }
 
-    entity, err := c.LoadEntity()
-    if err != nil { return err }
-    err = c.Domain.ReleaseNetwork(entity.NetworkIndex)
-    if err != nil { return err }
-    c.DestroyEntity()
-  }
+  c.MessageHandler(NewDoneEvent())
 
-  return nil
+  return c.releaseEntity(op)
 }
 
 func (c *Cluster) Exists() bool {
   if c.Master == nil {
-    if svc, err := CloudFormation(); err == nil {
+    if svc, err := c.Client().CloudFormation(); err == nil {
       if masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master"); err == nil {
         c.Master = &Master{masterStack}
       }
@@ -497,15 +809,15 @@ func (c *Cluster) LoadComputeGroups() error {
   if len(c.ComputeGroups) > 0 {
     return nil
   }
-  stacks, err := getComputeGroupStacksForCluster(c)
+  stacks, err := getComputeGroupStacksForCluster(context.Background(), c)
   if err != nil { return err }
   for _, stack := range stacks {
-    c.ComputeGroups = append(c.ComputeGroups, computeGroupFromStack(stack))
+    c.ComputeGroups = append(c.ComputeGroups, c.Client().computeGroupFromStack(stack))
   }
   return nil
 }
 
-func computeGroupFromStack(stack *cloudformation.Stack) *ComputeGroup {
+func (c *Client) computeGroupFromStack(stack *cloudformation.Stack) *ComputeGroup {
   // split after first `compute-`
   var queueName, pricing, resourceName string
   queueNameParts := strings.SplitAfterN(*stack.StackName, "-compute-", 2)
@@ -524,24 +836,43 @@ func computeGroupFromStack(stack *cloudformation.Stack) *ComputeGroup {
   } else {
     pricing = "on-demand"
   }
-  autoscalingResource, _ := getAutoscalingResource(stack)
+  autoscalingResource, _ := c.getAutoscalingResource(stack)
   if autoscalingResource != nil {
     resourceName = *autoscalingResource.PhysicalResourceId
   }
   expiryTime, _ := strconv.ParseInt(getStackTag(stack, "flight:expiry"), 10, 64)
-  return &ComputeGroup{stack,queueName,instanceType,pricing,resourceName,nil,expiryTime}
+  return &ComputeGroup{stack,queueName,instanceType,pricing,resourceName,nil,expiryTime,c}
 }
 
 func (c *Cluster) Details() *ClusterDetails {
   var details ClusterDetails = ClusterDetails{}
   if c.Master == nil {
-    if svc, err := CloudFormation(); err == nil {
+    if svc, err := c.Client().CloudFormation(); err == nil {
       if masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master"); err == nil {
         c.Master = &Master{masterStack}
       }
     }
   }
+  details.Name = c.Name
+  if c.Domain != nil {
+    details.Domain = c.Domain.Name
+  }
+  details.Region = c.Client().Config().AwsRegion
   if c.Master != nil {
+    details.Status = *c.Master.Stack.StackStatus
+    details.CreationTime = c.Master.Stack.CreationTime.Format(time.RFC3339)
+    if c.Network == nil && c.Domain != nil {
+      if svc, err := c.Client().CloudFormation(); err == nil {
+        if networkStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-network"); err == nil {
+          idx, _ := strconv.Atoi(getStackTag(networkStack, "flight:network"))
+          c.Network = &ClusterNetwork{idx, networkStack}
+        }
+      }
+    }
+    if c.Network != nil && c.Network.Stack != nil {
+      details.Stacks = append(details.Stacks, *c.Network.Stack.StackName)
+    }
+    details.Stacks = append(details.Stacks, *c.Master.Stack.StackName)
     details.Ip = getStackOutput(c.Master.Stack, "AccessIP")
     if details.Ip == "" {
       details.Ip = getStackOutput(c.Master.Stack, "MasterPrivateIP")
@@ -553,7 +884,7 @@ func (c *Cluster) Details() *ClusterDetails {
       details.Url = getStackOutput(c.Master.Stack, "PrivateWebAccess")
     }
     c.LoadComputeGroups()
-    componentStacks, _ := getComponentStacksForCluster(c)
+    componentStacks, _ := getComponentStacksForCluster(context.Background(), c)
     details.Uuid = getStackConfigValue(c.Master.Stack, "UUID")
     if details.Uuid == "" { details.Uuid = "<unknown>" }
     details.Token = getStackConfigValue(c.Master.Stack, "Token")
@@ -574,11 +905,13 @@ func (c *Cluster) Details() *ClusterDetails {
       details.Queues = []QueueDetails{}
       for _, group := range c.ComputeGroups {
         details.Queues = append(details.Queues, group.Details())
+        details.Stacks = append(details.Stacks, *group.Stack.StackName)
       }
     }
     if (len(componentStacks) > 0) {
       for _, stack := range componentStacks {
         details.Components = append(details.Components, *stack.StackName)
+        details.Stacks = append(details.Stacks, *stack.StackName)
       }
     }
   }
@@ -588,7 +921,7 @@ func (c *Cluster) Details() *ClusterDetails {
 
 func (c *Cluster) GetDetails() string {
   if c.Master == nil {
-    if svc, err := CloudFormation(); err == nil {
+    if svc, err := c.Client().CloudFormation(); err == nil {
       if masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master"); err == nil {
         c.Master = &Master{masterStack}
       }
@@ -606,6 +939,9 @@ func (c *Cluster) GetDetails() string {
       url = getStackOutput(c.Master.Stack, "PrivateWebAccess")
     }
     details := fmt.Sprintf("Administrator username: %s\nIP address: %s\nKey pair: %s\n", username, ip, keypair)
+    if !isRunningStack(c.Master.Stack) {
+      details = "Status: " + *c.Master.Stack.StackStatus + "\n" + details
+    }
     if url != "" {
       details += "Access URL: " + url + "\n"
     }
@@ -626,7 +962,7 @@ func (c *Cluster) GetDetails() string {
     })
 
     c.LoadComputeGroups()
-    componentStacks, _ := getComponentStacksForCluster(c)
+    componentStacks, _ := getComponentStacksForCluster(context.Background(), c)
     uuid := getStackConfigValue(c.Master.Stack, "UUID")
     if uuid == "" { uuid = "<unknown>" }
     token := getStackConfigValue(c.Master.Stack, "Token")
@@ -673,7 +1009,7 @@ func (c *Cluster) GetDetails() string {
 func (c *Cluster) GetExpiryTime() int64 {
   if c.ExpiryTime == -1 {
     if c.Master == nil {
-      if svc, err := CloudFormation(); err == nil {
+      if svc, err := c.Client().CloudFormation(); err == nil {
         if masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master"); err == nil {
           c.Master = &Master{masterStack}
         }
@@ -689,7 +1025,7 @@ func (c *Cluster) GetExpiryTime() int64 {
 func (c *Cluster) GetQuota() int64 {
   if c.Quota == -1 {
     if c.Master == nil {
-      if svc, err := CloudFormation(); err == nil {
+      if svc, err := c.Client().CloudFormation(); err == nil {
         if masterStack, err := getStack(svc, "flight-" + c.Domain.Name + "-" + c.Name + "-master"); err == nil {
           c.Master = &Master{masterStack}
         }
@@ -703,12 +1039,14 @@ func (c *Cluster) GetQuota() int64 {
 }
 
 func (g *ComputeGroup) loadAutoscalingGroup() {
-  g._AutoscalingGroup, _ = describeAutoscalingGroup(g.ResourceName)
+  g._AutoscalingGroup, _ = g.client.describeAutoscalingGroup(g.ResourceName)
 }
 
 func (g *ComputeGroup) Details() QueueDetails {
   details := QueueDetails{}
   details.Name = g.Name
+  details.StackName = *g.Stack.StackName
+  details.Status = *g.Stack.StackStatus
   details.InstanceType = g.InstanceType
   details.Pricing = g.Pricing
   details.ResourceName = g.ResourceName
@@ -717,6 +1055,7 @@ func (g *ComputeGroup) Details() QueueDetails {
   details.MinSize = g.MinSize()
   details.DesiredCapacity = g.DesiredCapacity()
   details.Running = g.Running()
+  details.SuspendedProcesses = g.SuspendedProcesses()
   return details
 }
 
@@ -738,27 +1077,37 @@ func (g *ComputeGroup) DesiredCapacity() int {
   return int(*g._AutoscalingGroup.DesiredCapacity)
 }
 
+func (g *ComputeGroup) SuspendedProcesses() []string {
+  if g._AutoscalingGroup == nil { g.loadAutoscalingGroup() }
+  if g._AutoscalingGroup == nil { return nil }
+  processes := []string{}
+  for _, sp := range g._AutoscalingGroup.SuspendedProcesses {
+    processes = append(processes, *sp.ProcessName)
+  }
+  return processes
+}
+
 func (g *ComputeGroup) Running() int {
   if g._AutoscalingGroup == nil { g.loadAutoscalingGroup() }
   if g._AutoscalingGroup == nil { return 0 }
   return len(g._AutoscalingGroup.Instances)
 }
 
-func destroyComputeGroup(cluster *Cluster, queueName string, svc *cloudformation.CloudFormation) error {
+func destroyComputeGroup(ctx context.Context, cluster *Cluster, queueName string, svc StackService) error {
   stackName := fmt.Sprintf("flight-%s-%s-compute-%s",
     cluster.Domain.Name,
     cluster.Name,
     queueName)
 
-  return destroyStack(svc, stackName)
+  return destroyStack(ctx, svc, stackName)
 }
 
-func destroyMaster(cluster *Cluster, svc *cloudformation.CloudFormation) error {
+func destroyMaster(ctx context.Context, cluster *Cluster, svc StackService) error {
   stackName := fmt.Sprintf("flight-%s-%s-master", cluster.Domain.Name, cluster.Name)
-  return destroyStack(svc, stackName)
+  return destroyStack(ctx, svc, stackName)
 }
 
-func destroyClusterNetwork(cluster *Cluster, svc *cloudformation.CloudFormation) error {
+func destroyClusterNetwork(ctx context.Context, cluster *Cluster, svc StackService) error {
   stackName := fmt.Sprintf("flight-%s-%s-network", cluster.Domain.Name, cluster.Name)
 
   networkStack, err := getStack(svc, stackName)
@@ -776,32 +1125,34 @@ func destroyClusterNetwork(cluster *Cluster, svc *cloudformation.CloudFormation)
   cluster.Network = &ClusterNetwork{idx, networkStack}
 
   // handle destruction of unassociated NICs
-  err = destroyDetachedNICs(cluster.Network.ManagementSubnet())
+  err = cluster.Client().destroyDetachedNICs(cluster.Network.ManagementSubnet())
   if err != nil { return err }
 
-  return destroyStack(svc, stackName)
+  return destroyStack(ctx, svc, stackName)
 }
 
-func destroySoloCluster(cluster *Cluster, svc *cloudformation.CloudFormation) error {
+func destroySoloCluster(ctx context.Context, cluster *Cluster, svc StackService) error {
   stackName := fmt.Sprintf("flight-cluster-%s", cluster.Name)
-  return destroyStack(svc, stackName)
+  return destroyStack(ctx, svc, stackName)
 }
 
-func destroyComponent(cluster *Cluster, componentType, componentName string, svc *cloudformation.CloudFormation) error {
+func destroyComponent(ctx context.Context, cluster *Cluster, componentType, componentName string, svc StackService) error {
   if componentName == "" {
     componentName = componentType
   } else {
     componentName = componentType + "-" + componentName
   }
   stackName := fmt.Sprintf("flight-%s-%s-component-%s", cluster.Domain.Name, cluster.Name, componentName)
-  return destroyStack(svc, stackName)
+  return destroyStack(ctx, svc, stackName)
 }
 
-func createMaster(cluster *Cluster, svc *cloudformation.CloudFormation) error {
-  launchParams := createClusterComponentLaunchParameters(cluster,
-    loadParameterSet("cluster-master", ClusterMasterParameters))
+func createMaster(ctx context.Context, cluster *Cluster, svc StackService) error {
+  parameterSet, err := cluster.Client().loadParameterSet("cluster-master", ClusterMasterParameters)
+  if err != nil { return err }
+  url := cluster.Client().TemplateUrl(clusterMasterTemplate)
+  launchParams, err := clusterResolver(cluster).LaunchParameters(parameterSet, url)
+  if err != nil { return err }
   stackName := fmt.Sprintf("flight-%s-%s-master", cluster.Domain.Name, cluster.Name)
-  url := TemplateUrl(clusterMasterTemplate)
 
   tags := cluster.Tags()
   if cluster.ExpiryTime > 0 {
@@ -810,95 +1161,107 @@ func createMaster(cluster *Cluster, svc *cloudformation.CloudFormation) error {
   if cluster.Quota > 0 {
     tags = append(tags, &cloudformation.Tag{Key: aws.String("flight:quota"), Value: aws.String(strconv.FormatInt(cluster.Quota, 10))})
   }
-  stack, err := createStack(svc, launchParams, tags, url, stackName, "master", cluster.TopicARN, cluster.Domain)
+  stack, err := cluster.Client().createStack(ctx, svc, launchParams, tags, url, stackName, "master", cluster.TopicARN, cluster.Domain)
   if err != nil { return err }
 
   cluster.Master = &Master{stack}
   return nil
 }
 
-func createComponent(componentType, componentName, componentParamsFile string, cluster *Cluster, svc *cloudformation.CloudFormation) error {
-  launchParams := createClusterComponentLaunchParameters(cluster, loadComponentParameters(componentParamsFile))
+func createComponent(ctx context.Context, componentType, componentName, componentParamsFile string, cluster *Cluster, svc StackService) error {
+  parameterSet, err := loadComponentParameters(componentParamsFile)
+  if err != nil { return err }
+  url := cluster.Client().TemplateUrl(componentType + ".json")
+  launchParams, err := clusterResolver(cluster).LaunchParameters(parameterSet, url)
+  if err != nil { return err }
   if componentName == "" {
     componentName = componentType
   } else {
     componentName = componentType + "-" + componentName
   }
   stackName := fmt.Sprintf("flight-%s-%s-component-%s", cluster.Domain.Name, cluster.Name, componentName)
-  url := TemplateUrl(componentType + ".json")
+  err = cluster.checkQuota(stackName, launchParams)
+  if err != nil { return err }
 
-  _, err := createStack(svc, launchParams, cluster.Tags(), url, stackName, "component", cluster.TopicARN, cluster.Domain)
+  _, err = cluster.Client().createStack(ctx, svc, launchParams, cluster.Tags(), url, stackName, "component", cluster.TopicARN, cluster.Domain)
   if err != nil { return err }
 
   return nil
 }
 
-func loadParameterSet(componentName string, defaultParameterSet map[string]string) map[string]string {
-  if Config().ParameterDirectory != "" {
-    parameterSet := loadComponentParameters(Config().ParameterDirectory + "/" + componentName + ".yml")
-    if len(parameterSet) > 0 {
-      return parameterSet
+func (c *Client) loadParameterSet(componentName string, defaultParameterSet map[string]string) (*ParameterSet, error) {
+  if c.Config().ParameterDirectory != "" {
+    paramsFile := c.Config().ParameterDirectory + "/" + componentName + ".yml"
+    if _, err := os.Stat(paramsFile); err == nil {
+      parameterSet, err := loadComponentParameters(paramsFile)
+      if err != nil { return nil, err }
+      if len(parameterSet.Values) > 0 {
+        return parameterSet, nil
+      }
     }
   }
-  return defaultParameterSet
+  return builtinParameterSet(componentName, defaultParameterSet), nil
 }
 
-func createComputeGroup(cluster *Cluster, queueName, queueParamsFile string, expiryTime int64, svc *cloudformation.CloudFormation) error {
-  var defaultLaunchParams map[string]string
+func createComputeGroup(ctx context.Context, cluster *Cluster, queueName, queueParamsFile string, expiryTime int64, svc StackService) error {
+  var parameterSet *ParameterSet
+  var err error
   if queueParamsFile == "" {
-    defaultLaunchParams = loadParameterSet("cluster-compute", ClusterComputeParameters)
+    parameterSet, err = cluster.Client().loadParameterSet("cluster-compute", ClusterComputeParameters)
   } else {
-    defaultLaunchParams = loadComponentParameters(queueParamsFile)
+    parameterSet, err = loadComponentParameters(queueParamsFile)
   }
-  launchParams := createClusterComponentLaunchParameters(cluster, defaultLaunchParams)
+  if err != nil { return err }
+  url := cluster.Client().TemplateUrl(clusterComputeTemplate)
+  launchParams, err := clusterResolver(cluster).LaunchParameters(parameterSet, url)
+  if err != nil { return err }
   stackName := fmt.Sprintf("flight-%s-%s-compute-%s",
     cluster.Domain.Name,
     cluster.Name,
     queueName)
-  url := TemplateUrl(clusterComputeTemplate)
+  err = cluster.checkQuota(stackName, launchParams)
+  if err != nil { return err }
 
   tags := cluster.Tags()
   if expiryTime > 0 {
     tags = append(tags, &cloudformation.Tag{Key: aws.String("flight:expiry"), Value: aws.String(strconv.FormatInt(expiryTime, 10))})
   }
-  stack, err := createStack(svc, launchParams, tags, url, stackName, "compute", cluster.TopicARN, cluster.Domain)
+  stack, err := cluster.Client().createStack(ctx, svc, launchParams, tags, url, stackName, "compute", cluster.TopicARN, cluster.Domain)
   if err != nil { return err }
 
-  cluster.ComputeGroups = append(cluster.ComputeGroups, computeGroupFromStack(stack))
+  cluster.ComputeGroups = append(cluster.ComputeGroups, cluster.Client().computeGroupFromStack(stack))
   return nil
 }
 
-func createClusterNetwork(cluster *Cluster, svc *cloudformation.CloudFormation) error {
-  network, err := cluster.Domain.BookNetwork()
+func createClusterNetwork(ctx context.Context, cluster *Cluster, svc StackService) error {
+  url := cluster.Client().TemplateUrl(clusterNetworkTemplate)
+  parameterSet, err := cluster.Client().loadParameterSet("cluster-network", ClusterNetworkParameters)
+  if err != nil { return err }
+  launchParams, err := clusterResolver(cluster).LaunchParameters(parameterSet, url)
   if err != nil { return err }
-
-  cluster.Network = &ClusterNetwork{network, nil}
-  launchParams := createClusterComponentLaunchParameters(cluster,
-    loadParameterSet("cluster-network", ClusterNetworkParameters))
   stackName := fmt.Sprintf("flight-%s-%s-network", cluster.Domain.Name, cluster.Name)
-  url := TemplateUrl(clusterNetworkTemplate)
-  tags := append(cluster.Tags(), &cloudformation.Tag{Key: aws.String("flight:network"), Value: aws.String(strconv.Itoa(network))})
+  tags := append(cluster.Tags(), &cloudformation.Tag{Key: aws.String("flight:network"), Value: aws.String(strconv.Itoa(cluster.Network.Index))})
 
-  stack, err := createStack(svc, launchParams, tags, url, stackName, "network", cluster.TopicARN, cluster.Domain)
+  stack, err := cluster.Client().createStack(ctx, svc, launchParams, tags, url, stackName, "network", cluster.TopicARN, cluster.Domain)
   if err != nil { return err }
 
   cluster.Network.Stack = stack
-  err = cluster.CreateEntity()
-  if err != nil { return err }
-
   return nil
 }
 
-func createSoloCluster(cluster *Cluster, svc *cloudformation.CloudFormation) error {
-  var parameterSet map[string]string
+func createSoloCluster(ctx context.Context, cluster *Cluster, svc StackService) error {
+  var parameterSet *ParameterSet
+  var err error
   if cluster.SoloMode == "legacy" {
-    parameterSet = loadParameterSet("solo-legacy", LegacySoloParameters)
+    parameterSet, err = cluster.Client().loadParameterSet("solo-legacy", LegacySoloParameters)
   } else {
-    parameterSet = loadParameterSet("solo", SoloParameters)
+    parameterSet, err = cluster.Client().loadParameterSet("solo", SoloParameters)
   }
-  launchParams := createClusterComponentLaunchParameters(cluster, parameterSet)
+  if err != nil { return err }
+  url := cluster.Client().TemplateUrl(soloClusterTemplate)
+  launchParams, err := clusterResolver(cluster).LaunchParameters(parameterSet, url)
+  if err != nil { return err }
   stackName := fmt.Sprintf("flight-cluster-%s", cluster.Name)
-  url := TemplateUrl(soloClusterTemplate)
 
   tags := cluster.Tags()
   if cluster.ExpiryTime > 0 {
@@ -907,136 +1270,45 @@ func createSoloCluster(cluster *Cluster, svc *cloudformation.CloudFormation) err
   if cluster.Quota > 0 {
     tags = append(tags, &cloudformation.Tag{Key: aws.String("flight:quota"), Value: aws.String(strconv.FormatInt(cluster.Quota, 10))})
   }
-  stack, err := createStack(svc, launchParams, tags, url, stackName, "solo", cluster.TopicARN, nil)
+  stack, err := cluster.Client().createStack(ctx, svc, launchParams, tags, url, stackName, "solo", cluster.TopicARN, nil)
   if err != nil { return err }
 
   cluster.Master = &Master{stack}
   return nil
 }
 
-func createClusterComponentLaunchParameters(cluster *Cluster, parameterSet map[string]string) []*cloudformation.Parameter {
-  params := []*cloudformation.Parameter{}
-  for key, value := range parameterSet {
-    var val string
-    switch value  {
-    case "%CLUSTER_NAME%":
-      val = cluster.Name
-    case "%ACCESS_KEY_NAME%":
-      val = Config().AccessKeyName
-    case "%MASTER_INSTANCE_TYPE%":
-      instanceOverride := viper.GetString("master-instance-override")
-      if instanceOverride != "" {
-        val = "other"
-      } else {
-        val = viper.GetString("master-instance-type")
-      }
-    case "%MASTER_INSTANCE_OVERRIDE%":
-      val = viper.GetString("master-instance-override")
-      if val == "" { val = "%NULL%" }
-    case "%MASTER_FEATURES%":
-      // XXX - should password-auth be mandated within template?
-      masterFeatures := viper.GetString("master-features")
-      if masterFeatures != "" {
-        val = masterFeatures + " password-auth"
-      } else {
-        val = "password-auth"
-      }
-    case "%COMPUTE_INSTANCE_TYPE%":
-      instanceOverride := viper.GetString("queue-instance-override")
-      if instanceOverride != "" {
-        val = "other"
-      } else {
-        // if we're launching via cluster launch, we use default-queue-instance-type, otherwise we use queue-instance-type
-        val = viper.GetString("queue-instance-type")
-        if val == "" {
-          val = viper.GetString("default-queue-instance-type")
-        }
-      }
-    case "%COMPUTE_INSTANCE_OVERRIDE%":
-      val = viper.GetString("queue-instance-override")
-      if val == "" { val = "%NULL%" }
-    case "%VPC%":
-      val = cluster.Domain.VPC()
-    case "%NETWORK_POOL%":
-      val = cluster.Network.NetworkPool()
-    case "%NETWORK_INDEX%":
-      val = cluster.Network.NetworkIndex()
-    case "%PUB_ROUTE_TABLE%":
-      val = cluster.Domain.PublicRouteTable()
-    case "%DOMAIN%":
-      val = cluster.Domain.Prefix()
-    case "%PUB_SUBNET%":
-      val = cluster.Network.PublicSubnet()
-    case "%MGT_SUBNET%":
-      val = cluster.Network.ManagementSubnet()
-    case "%PRV_SUBNET%":
-      val = cluster.Network.PrivateSubnet()
-    case "%PLACEMENT_GROUP%":
-      val = cluster.Network.PlacementGroup()
-    case "%MASTER_IP%":
-      val = cluster.Master.PrivateIP()
-    case "%CLUSTER_UUID%":
-      val = cluster.Master.ClusterUUID()
-    case "%CLUSTER_SECURITY_TOKEN%":
-      val = cluster.Master.ClusterSecurityToken()
-    default:
-      if strings.HasPrefix(value, "%") && strings.HasSuffix(value, "%") {
-        configKey := strings.ToLower(strings.Replace(value[1:len(value)-1], "_", "-", -1))
-        if viper.IsSet(configKey) {
-          val = viper.GetString(configKey)
-        } else {
-          val = "%NULL%"
-        }
-      } else {
-        val = value
-      }
-    }
-    if val != "%NULL%" {
-      // fmt.Println(key + " -> " + val)
-      params = append(params, &cloudformation.Parameter{
-        ParameterKey: aws.String(key),
-        ParameterValue: aws.String(val),
-      })
-    }
-  }
-  return params
-}
-
-func loadComponentParameters(paramsFile string) map[string]string {
-  params := make(map[string]string)
-  if paramsFile != "" {
-    data, err := ioutil.ReadFile(paramsFile)
-    if err != nil { fmt.Println(err.Error()) }
-    err = yaml.Unmarshal(data, &params)
-    if err != nil { fmt.Println(err.Error()) }
-  }
-  return params
+func ExpiredClusters() ([]string, error) {
+  return DefaultClient().ExpiredClusters()
 }
 
-func ExpiredClusters() ([]string, error) {
-  stacks, err := ExpiredStacks()
+func (c *Client) ExpiredClusters() ([]string, error) {
+  stacks, err := c.ExpiredStacks()
   names := []string{}
   if err != nil { return nil, err }
   for _, stack := range stacks {
-    var descriptor string
-    name := getStackTag(stack, "flight:cluster")
-    stackType := getStackTag(stack, "flight:type")
-    domain := getStackTag(stack, "flight:domain")
-    if stackType == "master" {
-      descriptor = fmt.Sprintf("CLUSTER:%s/%s", domain, name)
-    } else if stackType == "compute" {
-      var queueName string
-      queueNameParts := strings.SplitAfterN(*stack.StackName, "-compute-", 2)
-      if len(queueNameParts) > 1 {
-        queueName = queueNameParts[1]
-      } else {
-        queueName = queueNameParts[0]
-      }
-      descriptor = fmt.Sprintf("QUEUE:%s/%s/%s", domain, name, queueName)
+    names = append(names, expiryDescriptor(stack))
+  }
+  return names, nil
+}
+
+// expiryDescriptor identifies what an expired stack belongs to, as
+// CLUSTER:<domain>/<cluster>, QUEUE:<domain>/<cluster>/<queue> or
+// SOLO:<cluster>.
+func expiryDescriptor(stack *cloudformation.Stack) string {
+  name := getStackTag(stack, "flight:cluster")
+  stackType := getStackTag(stack, "flight:type")
+  domain := getStackTag(stack, "flight:domain")
+  if stackType == "master" {
+    return fmt.Sprintf("CLUSTER:%s/%s", domain, name)
+  } else if stackType == "compute" {
+    var queueName string
+    queueNameParts := strings.SplitAfterN(*stack.StackName, "-compute-", 2)
+    if len(queueNameParts) > 1 {
+      queueName = queueNameParts[1]
     } else {
-      descriptor = fmt.Sprintf("SOLO:%s", name)
+      queueName = queueNameParts[0]
     }
-    names = append(names, descriptor)
+    return fmt.Sprintf("QUEUE:%s/%s/%s", domain, name, queueName)
   }
-  return names, nil
+  return fmt.Sprintf("SOLO:%s", name)
 }
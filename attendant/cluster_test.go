@@ -0,0 +1,223 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "context"
+  "sort"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func stackExists(mem *MemoryProvider, name string) bool {
+  resp, err := mem.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+  return err == nil && len(resp.Stacks) == 1 && *resp.Stacks[0].StackStatus != "DELETE_COMPLETE"
+}
+
+func TestClusterCreate(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+
+  for _, name := range []string{"flight-d1-c1-network", "flight-d1-c1-master", "flight-d1-c1-compute-default"} {
+    if !stackExists(mem, name) {
+      t.Errorf("stack %s was not created", name)
+    }
+  }
+  if cluster.Master == nil {
+    t.Fatalf("cluster has no master")
+  }
+  if tag := getStackTag(cluster.Master.Stack, "flight:domain"); tag != "d1" {
+    t.Errorf("master flight:domain = %q, want d1", tag)
+  }
+  record, err := mem.GetCluster("c1")
+  if err != nil { t.Fatalf("GetCluster: %s", err) }
+  if record.Domain != "d1" || record.NetworkIndex != cluster.Network.Index {
+    t.Errorf("cluster record = %+v", record)
+  }
+}
+
+func TestClusterCreateBooksSeparateNetworks(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  first := createTestCluster(t, d, "c1", false)
+  second := createTestCluster(t, d, "c2", false)
+  if first.Network.Index == second.Network.Index {
+    t.Errorf("both clusters were given network %d", first.Network.Index)
+  }
+}
+
+func TestClusterPurge(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  createTestCluster(t, d, "c1", true)
+
+  cluster := c.NewCluster("c1", c.NewDomain("d1", nil), nil)
+  if err := cluster.Purge(context.Background()); err != nil {
+    t.Fatalf("Purge: %s", err)
+  }
+  for _, name := range []string{"flight-d1-c1-network", "flight-d1-c1-master", "flight-d1-c1-compute-default"} {
+    if stackExists(mem, name) {
+      t.Errorf("stack %s still exists", name)
+    }
+  }
+  if _, err := mem.GetCluster("c1"); err != ErrEntityNotFound {
+    t.Errorf("cluster record still exists (err = %v)", err)
+  }
+  ops, err := mem.Operations()
+  if err != nil { t.Fatalf("Operations: %s", err) }
+  if len(ops) != 0 {
+    t.Errorf("%d operations left behind after purge", len(ops))
+  }
+}
+
+func TestExpiredClusters(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+
+  expired := c.NewCluster("old", d, nil)
+  expired.ExpiryTime = time.Now().Add(-time.Hour).Unix()
+  if err := expired.Create(context.Background(), false); err != nil {
+    t.Fatalf("Create: %s", err)
+  }
+  current := c.NewCluster("new", d, nil)
+  current.ExpiryTime = time.Now().Add(time.Hour).Unix()
+  if err := current.Create(context.Background(), false); err != nil {
+    t.Fatalf("Create: %s", err)
+  }
+  createTestCluster(t, d, "forever", false)
+  if err := current.AddQueue(context.Background(), "q1", "", time.Now().Add(-time.Minute).Unix()); err != nil {
+    t.Fatalf("AddQueue: %s", err)
+  }
+
+  names, err := c.ExpiredClusters()
+  if err != nil { t.Fatalf("ExpiredClusters: %s", err) }
+  sort.Strings(names)
+  want := []string{"CLUSTER:d1/old", "QUEUE:d1/new/q1"}
+  if len(names) != len(want) {
+    t.Fatalf("expired = %v, want %v", names, want)
+  }
+  for i := range want {
+    if names[i] != want[i] {
+      t.Errorf("expired = %v, want %v", names, want)
+    }
+  }
+}
+
+func testComputeGroup(t *testing.T, c *Client, d *Domain, name string) *ComputeGroup {
+  cluster := c.NewCluster("c1", d, nil)
+  if err := cluster.LoadComputeGroups(); err != nil { t.Fatalf("LoadComputeGroups: %s", err) }
+  for _, group := range cluster.ComputeGroups {
+    if group.Name == name { return group }
+  }
+  t.Fatalf("no compute group %s", name)
+  return nil
+}
+
+func TestModifyQueue(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+
+  maxSize, desired := int64(5), int64(2)
+  err := cluster.ModifyQueue(context.Background(), "default", &QueueModification{MaxSize: &maxSize, DesiredCapacity: &desired})
+  if err != nil { t.Fatalf("ModifyQueue: %s", err) }
+  group := testComputeGroup(t, c, d, "default")
+  if group.MaxSize() != 5 || group.DesiredCapacity() != 2 {
+    t.Errorf("group max %d, desired %d; want 5 and 2", group.MaxSize(), group.DesiredCapacity())
+  }
+  // the new bounds are kept in the stack so that updates don't revert them
+  stack, err := getStack(mem, "flight-d1-c1-compute-default")
+  if err != nil { t.Fatalf("getStack: %s", err) }
+  if val := getStackParameter(stack, "ComputeMaxNodes"); val != "5" {
+    t.Errorf("ComputeMaxNodes = %s, want 5", val)
+  }
+}
+
+func TestModifyQueueCeiling(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+  before := testComputeGroup(t, c, d, "default").MaxSize()
+
+  c.config.ComputeMaxNodes = 4
+  maxSize := int64(5)
+  err := cluster.ModifyQueue(context.Background(), "default", &QueueModification{MaxSize: &maxSize})
+  if err == nil || !strings.Contains(err.Error(), "compute-max-nodes") {
+    t.Errorf("expected the ceiling to refuse a maximum of 5, got %v", err)
+  }
+  if after := testComputeGroup(t, c, d, "default").MaxSize(); after != before {
+    t.Errorf("group max changed from %d to %d", before, after)
+  }
+}
+
+func TestModifyQueueFailedUpdate(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+  before := testComputeGroup(t, c, d, "default").MaxSize()
+
+  mem.Failures = map[string]string{"ComputeGroup": "Simulated failure"}
+  maxSize := int64(before + 1)
+  if err := cluster.ModifyQueue(context.Background(), "default", &QueueModification{MaxSize: &maxSize}); err == nil {
+    t.Fatalf("expected the failed stack update to fail ModifyQueue")
+  }
+  if after := testComputeGroup(t, c, d, "default").MaxSize(); after != before {
+    t.Errorf("group max changed from %d to %d despite the failed update", before, after)
+  }
+}
+
+func TestClusterCreateRollsBack(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  mem.Failures = map[string]string{"flight-d1-c1-master/Master": "Insufficient capacity"}
+
+  err := c.NewCluster("c1", d, nil).Create(context.Background(), false)
+  if err == nil { t.Fatalf("expected the launch to fail") }
+  if !strings.Contains(err.Error(), "Master") || !strings.Contains(err.Error(), "Insufficient capacity") {
+    t.Errorf("error %q doesn't report the failed resource", err.Error())
+  }
+  if stackExists(mem, "flight-d1-c1-network") {
+    t.Errorf("network stack wasn't rolled back")
+  }
+  if !stackExists(mem, "flight-d1-c1-master") {
+    t.Errorf("failed master stack wasn't kept for inspection")
+  }
+  if _, err := mem.GetCluster("c1"); err != ErrEntityNotFound {
+    t.Errorf("cluster record wasn't removed: %v", err)
+  }
+  if record, err := mem.GetDomain("d1"); err == nil && len(record.NetBookings) != 0 {
+    t.Errorf("network bookings %v weren't released", record.NetBookings)
+  }
+}
@@ -31,7 +31,9 @@ package attendant
 import (
   "fmt"
   "os"
+  "path/filepath"
   "strings"
+  "time"
   "gopkg.in/yaml.v2"
 )
 
@@ -41,9 +43,44 @@ var ConfigDefaults = map[string]string {
   "region": "us-east-1",
   "access-key": "",
   "secret-key": "",
+  "profile": "",
+  "role-arn": "",
+  "external-id": "",
+  "role-session-name": "",
+  "mfa-serial": "",
+  "role-duration": "",
+  "cache-credentials": "true",
+  "credential-cache-file": "",
   "template-root": "",
   "template-set": FlightRelease,
+  "template-staging-bucket": "",
+  "template-staging-prefix": "flight-attendant/templates/",
   "parameter-directory": "",
+  "validate-parameters": "true",
+  "price-file": "",
+  "delete-failed-stacks": "false",
+  "stack-cache-ttl": "60s",
+  "stack-cache-file": "",
+  "region-concurrency": "4",
+  "output": "text",
+  "progress": "spinner",
+  "backend": "aws",
+  "simulator-directory": "",
+  "simulator-failures": "",
+  "cloudformation-endpoint": "",
+  "ec2-endpoint": "",
+  "sns-endpoint": "",
+  "sqs-endpoint": "",
+  "autoscaling-endpoint": "",
+  "dynamodb-endpoint": "",
+  "s3-endpoint": "",
+  "sts-endpoint": "",
+  "iam-endpoint": "",
+  "disable-tls-verify": "false",
+  "state-backend": "",
+  "state-file": "",
+  "state-bucket": "",
+  "state-prefix": "flight-attendant/",
   
   "admin-user-name": "alces",
   "access-network": "0.0.0.0/0",
@@ -119,33 +156,98 @@ type Configuration struct {
   AwsRegion string
   AwsAccessKey string
   AwsSecretKey string
+  AwsProfile string
+  AwsRoleArn string
+  AwsExternalId string
+  AwsRoleSessionName string
+  AwsMfaSerial string
+  AwsRoleDuration time.Duration
+  CacheCredentials bool
+  CredentialCacheFile string
   AccessKeyName string
   TemplateRoot string
   TemplateSet string
+  TemplateStagingBucket string
+  TemplateStagingPrefix string
   ParameterDirectory string
+  ValidateParameters bool
+  PriceFile string
+  DeleteFailedStacks bool
+  ComputeMaxNodes int64
+  MasterInstanceType string
+  MasterInstanceOverride string
+  MasterFeatures string
+  QueueInstanceType string
+  DefaultQueueInstanceType string
+  QueueInstanceOverride string
+  ApplianceInstanceType string
+  // Settings holds every configuration value by its key, for appliance
+  // settings and for tokens named after a config key.
+  Settings map[string]string
+  StackCacheTTL time.Duration
+  StackCacheFile string
   SimpleOutput bool
+  Output string
+  Progress string
+  Backend string
+  SimulatorDirectory string
+  SimulatorFailures []string
+  Endpoints map[string]string
+  DisableTLSVerify bool
+  StateBackend string
+  StateFile string
+  StateBucket string
+  StatePrefix string
 }
 
-var config *Configuration
+var EndpointServices = []string{"cloudformation", "ec2", "sns", "sqs", "autoscaling", "dynamodb", "s3", "sts", "iam"}
 
+// Config returns the configuration of the default client.
 func Config() *Configuration {
-  if config != nil {
-    return config
+  return DefaultClient().Config()
+}
+
+func defaultConfiguration() *Configuration {
+  settings := make(map[string]string)
+  for key, val := range ConfigDefaults {
+    settings[key] = val
   }
-  config = &Configuration{
+  return &Configuration{
     AwsRegion: "us-east-1",
     AccessKeyName: "flight-admin",
     TemplateRoot: DefaultTemplateRoot,
     TemplateSet: FlightRelease,
     SimpleOutput: false,
+    Output: "text",
+    Progress: "spinner",
+    ValidateParameters: true,
+    StackCacheTTL: time.Minute,
+    CacheCredentials: true,
+    Backend: "aws",
+    MasterInstanceType: MasterInstanceTypes[0],
+    DefaultQueueInstanceType: ComputeInstanceTypes[0],
+    Endpoints: make(map[string]string),
+    Settings: settings,
   }
-  return config
+}
+
+// Setting returns the value of a config key, if it has one.
+func (c *Configuration) Setting(key string) (string, bool) {
+  val, exists := c.Settings[key]
+  return val, exists
 }
 
 func (c *Configuration) IsValidKeyPair() bool {
   return IsValidKeyPairName(c.AccessKeyName)
 }
 
+func (c *Configuration) HasEndpointOverrides() bool {
+  for _, endpoint := range c.Endpoints {
+    if endpoint != "" { return true }
+  }
+  return false
+}
+
 func RenderConfig() ([]byte, error) {
   return yaml.Marshal(&ConfigDefaults)
 }
@@ -164,12 +266,23 @@ func RenderConfigValues() (string, error) {
 }
 
 func TemplateUrl(templateName string) string {
-  templateSet := Config().TemplateSet
+  return DefaultClient().TemplateUrl(templateName)
+}
+
+func (c *Client) TemplateUrl(templateName string) string {
+  templateSet := c.Config().TemplateSet
+  templateRoot := c.Config().TemplateRoot
+  if templateRoot != "" && !strings.Contains(templateRoot, "://") {
+    // A plain directory is treated as a local template root.
+    if path, err := filepath.Abs(templateRoot); err == nil {
+      templateRoot = "file://" + path
+    }
+  }
   var url string
   if templateSet == "" {
-    url = Config().TemplateRoot + "/" + templateName
+    url = templateRoot + "/" + templateName
   } else {
-    url = Config().TemplateRoot + "/" + Config().TemplateSet + "/" + templateName
+    url = templateRoot + "/" + c.Config().TemplateSet + "/" + templateName
   }
   return url
 }
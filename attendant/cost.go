@@ -0,0 +1,415 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "sort"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+  "gopkg.in/yaml.v2"
+)
+
+// HoursPerMonth converts monthly EBS prices to hourly rates.
+var HoursPerMonth = 730.0
+
+// PriceTable holds hourly on-demand instance prices, keyed by EC2
+// instance type or Flight instance type name, and monthly per-GB volume
+// prices keyed by EBS volume type.
+type PriceTable struct {
+  Instances map[string]float64 `yaml:"instances"`
+  Volumes map[string]float64 `yaml:"volumes"`
+}
+
+// Linux on-demand prices in us-east-1.  Other regions are estimated
+// from these using regionPriceFactors; a price file may replace any of
+// them.
+var bundledInstancePrices = map[string]float64{
+  "c5.large": 0.085,
+  "c5.xlarge": 0.17,
+  "c5.2xlarge": 0.34,
+  "c5.4xlarge": 0.68,
+  "c5.9xlarge": 1.53,
+  "c5.18xlarge": 3.06,
+  "c4.large": 0.1,
+  "c4.xlarge": 0.199,
+  "c4.2xlarge": 0.398,
+  "c4.4xlarge": 0.796,
+  "c4.8xlarge": 1.591,
+  "c3.large": 0.105,
+  "c3.xlarge": 0.21,
+  "c3.2xlarge": 0.42,
+  "c3.4xlarge": 0.84,
+  "c3.8xlarge": 1.68,
+  "d2.xlarge": 0.69,
+  "d2.2xlarge": 1.38,
+  "d2.4xlarge": 2.76,
+  "d2.8xlarge": 5.52,
+  "g3.4xlarge": 1.14,
+  "g3.8xlarge": 2.28,
+  "g3.16xlarge": 4.56,
+  "f1.2xlarge": 1.65,
+  "f1.16xlarge": 13.2,
+  "g2.2xlarge": 0.65,
+  "g2.8xlarge": 2.6,
+  "h1.2xlarge": 0.468,
+  "h1.4xlarge": 0.936,
+  "h1.8xlarge": 1.872,
+  "h1.16xlarge": 3.744,
+  "i3.large": 0.156,
+  "i3.xlarge": 0.312,
+  "i3.2xlarge": 0.624,
+  "i3.4xlarge": 1.248,
+  "i3.8xlarge": 2.496,
+  "i3.16xlarge": 4.992,
+  "i2.xlarge": 0.853,
+  "i2.2xlarge": 1.705,
+  "i2.4xlarge": 3.41,
+  "i2.8xlarge": 6.82,
+  "m5.large": 0.096,
+  "m5.xlarge": 0.192,
+  "m5.2xlarge": 0.384,
+  "m5.4xlarge": 0.768,
+  "m5.12xlarge": 2.304,
+  "m5.24xlarge": 4.608,
+  "m4.large": 0.1,
+  "m4.xlarge": 0.2,
+  "m4.2xlarge": 0.4,
+  "m4.4xlarge": 0.8,
+  "m4.10xlarge": 2.0,
+  "m4.16xlarge": 3.2,
+  "m3.medium": 0.067,
+  "m3.large": 0.133,
+  "m3.xlarge": 0.266,
+  "m3.2xlarge": 0.532,
+  "p3.2xlarge": 3.06,
+  "p3.8xlarge": 12.24,
+  "p3.16xlarge": 24.48,
+  "p2.xlarge": 0.9,
+  "p2.8xlarge": 7.2,
+  "p2.16xlarge": 14.4,
+  "r4.large": 0.133,
+  "r4.xlarge": 0.266,
+  "r4.2xlarge": 0.532,
+  "r4.4xlarge": 1.064,
+  "r4.8xlarge": 2.128,
+  "r4.16xlarge": 4.256,
+  "r3.large": 0.166,
+  "r3.xlarge": 0.333,
+  "r3.2xlarge": 0.665,
+  "r3.4xlarge": 1.33,
+  "r3.8xlarge": 2.66,
+  "t2.nano": 0.0058,
+  "t2.micro": 0.0116,
+  "t2.small": 0.023,
+  "t2.medium": 0.0464,
+  "t2.large": 0.0928,
+  "t2.xlarge": 0.1856,
+  "t2.2xlarge": 0.3712,
+  "x1.16xlarge": 6.669,
+  "x1.32xlarge": 13.338,
+  "x1e.32xlarge": 26.688,
+  "x1e.16xlarge": 13.344,
+  "x1e.8xlarge": 6.672,
+  "x1e.4xlarge": 3.336,
+  "x1e.2xlarge": 1.668,
+  "x1e.xlarge": 0.834,
+}
+
+var bundledVolumePrices = map[string]float64{
+  "standard": 0.05,
+  "gp2": 0.1,
+  "st1": 0.045,
+  "sc1": 0.025,
+}
+
+var regionPriceFactors = map[string]float64{
+  "ap-northeast-1": 1.28,
+  "ap-northeast-2": 1.22,
+  "ap-south-1": 1.05,
+  "ap-southeast-1": 1.25,
+  "ap-southeast-2": 1.25,
+  "ca-central-1": 1.1,
+  "eu-west-1": 1.1,
+  "eu-west-2": 1.16,
+  "eu-west-3": 1.16,
+  "eu-central-1": 1.19,
+  "sa-east-1": 1.6,
+  "us-east-1": 1.0,
+  "us-east-2": 1.0,
+  "us-west-1": 1.12,
+  "us-west-2": 1.0,
+}
+
+func (c *Client) loadPriceOverrides() (map[string]*PriceTable, error) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.priceOverrides != nil || c.config.PriceFile == "" {
+    return c.priceOverrides, nil
+  }
+  data, err := ioutil.ReadFile(c.Config().PriceFile)
+  if err != nil { return nil, err }
+  overrides := make(map[string]*PriceTable)
+  err = yaml.Unmarshal(data, &overrides)
+  if err != nil { return nil, fmt.Errorf("%s: %s", c.Config().PriceFile, err.Error()) }
+  c.priceOverrides = overrides
+  return c.priceOverrides, nil
+}
+
+func PricesFor(region string) (*PriceTable, error) {
+  return DefaultClient().PricesFor(region)
+}
+
+// PricesFor returns the price table for a region: the bundled prices,
+// scaled for the region, with any from the price file laid over them.
+func (c *Client) PricesFor(region string) (*PriceTable, error) {
+  overrides, err := c.loadPriceOverrides()
+  if err != nil { return nil, err }
+  override := overrides[region]
+  factor, known := regionPriceFactors[region]
+  if !known && override == nil {
+    return nil, fmt.Errorf("No prices available for region %s; add them to a price file (price-file).", region)
+  }
+  table := &PriceTable{make(map[string]float64), make(map[string]float64)}
+  if known {
+    for name, price := range bundledInstancePrices {
+      table.Instances[name] = price * factor
+    }
+    for name, price := range bundledVolumePrices {
+      table.Volumes[name] = price * factor
+    }
+  }
+  if override != nil {
+    for name, price := range override.Instances {
+      table.Instances[name] = price
+    }
+    for name, price := range override.Volumes {
+      table.Volumes[name] = price
+    }
+  }
+  return table, nil
+}
+
+// InstancePrice looks up the hourly price of an instance type by its
+// full name, then by the EC2 instance type it names.
+func (t *PriceTable) InstancePrice(instanceType string) (float64, error) {
+  if price, ok := t.Instances[instanceType]; ok {
+    return price, nil
+  }
+  if price, ok := t.Instances[ec2InstanceTypeRegexp.FindString(instanceType)]; ok {
+    return price, nil
+  }
+  return 0, fmt.Errorf("No price for instance type: %s", instanceType)
+}
+
+// VolumePrice looks up the monthly per-GB price of a volume type, given
+// either as an EBS type or as a Flight volume type name such as
+// "general-purpose-ssd.gp2".
+func (t *PriceTable) VolumePrice(volumeType string) (float64, error) {
+  parts := strings.Split(volumeType, ".")
+  if price, ok := t.Volumes[parts[len(parts) - 1]]; ok {
+    return price, nil
+  }
+  return 0, fmt.Errorf("No price for volume type: %s", volumeType)
+}
+
+// CostItem is the cost of a set of instances or a volume.  Running is
+// the number currently in service and MaxSize the most there could be.
+type CostItem struct {
+  StackName string `json:"StackName" yaml:"StackName"`
+  Resource string `json:"Resource" yaml:"Resource"`
+  Type string `json:"Type" yaml:"Type"`
+  Pricing string `json:"Pricing" yaml:"Pricing"`
+  Rate float64 `json:"Rate" yaml:"Rate"`
+  Running int64 `json:"Running" yaml:"Running"`
+  MaxSize int64 `json:"MaxSize" yaml:"MaxSize"`
+}
+
+func (i *CostItem) CurrentHourly() float64 {
+  return i.Rate * float64(i.Running)
+}
+
+func (i *CostItem) MaximumHourly() float64 {
+  return i.Rate * float64(i.MaxSize)
+}
+
+// CostEstimate is the hourly cost of a cluster or domain, projected over
+// a number of hours.
+type CostEstimate struct {
+  Region string `json:"Region" yaml:"Region"`
+  Hours float64 `json:"Hours" yaml:"Hours"`
+  Items []*CostItem `json:"Items" yaml:"Items"`
+}
+
+func (e *CostEstimate) CurrentHourly() float64 {
+  var cost float64
+  for _, item := range e.Items {
+    cost += item.CurrentHourly()
+  }
+  return cost
+}
+
+func (e *CostEstimate) MaximumHourly() float64 {
+  var cost float64
+  for _, item := range e.Items {
+    cost += item.MaximumHourly()
+  }
+  return cost
+}
+
+// The volumes described by master and solo stack parameters, as
+// <Prefix>VolumeSize and <Prefix>VolumeType.
+var costedVolumes = [][2]string{
+  {"MasterSystem", "system volume"},
+  {"LoginSystem", "system volume"},
+  {"Home", "home volume"},
+  {"Apps", "apps volume"},
+}
+
+// stackCosts prices the instances and volumes a stack's parameters
+// describe.  Spot instances are costed at the lower of their spot bid
+// and the on-demand price, as a ceiling on what will be paid for them.
+func stackCosts(stack *cloudformation.Stack, prices *PriceTable) ([]*CostItem, error) {
+  items, err := stackInstances(*stack.StackName, stack.Parameters)
+  if err != nil { return nil, err }
+  costs := []*CostItem{}
+  for _, item := range items {
+    cost := &CostItem{StackName: item.StackName, Resource: item.Role, Type: item.InstanceType, Pricing: "on-demand", Running: item.Running, MaxSize: item.MaxSize}
+    onDemand, priceErr := prices.InstancePrice(item.InstanceType)
+    spotPrice := getStackParameter(stack, item.prefix + "SpotPrice")
+    if spot, err := strconv.ParseFloat(spotPrice, 64); err == nil && spot > 0 {
+      cost.Pricing, cost.Rate = "spot (max)", spot
+      if priceErr == nil && onDemand < spot { cost.Rate = onDemand }
+    } else {
+      if priceErr != nil { return nil, priceErr }
+      cost.Rate = onDemand
+    }
+    costs = append(costs, cost)
+  }
+  for _, volume := range costedVolumes {
+    size, err := strconv.ParseFloat(getStackParameter(stack, volume[0] + "VolumeSize"), 64)
+    if err != nil || size <= 0 { continue }
+    volumeType := getStackParameter(stack, volume[0] + "VolumeType")
+    price, err := prices.VolumePrice(volumeType)
+    if err != nil { return nil, err }
+    costs = append(costs, &CostItem{
+      StackName: *stack.StackName,
+      Resource: volume[1],
+      Type: fmt.Sprintf("%s %gGB", volumeType, size),
+      Pricing: "ebs",
+      Rate: price * size / HoursPerMonth,
+      Running: 1,
+      MaxSize: 1,
+    })
+  }
+  return costs, nil
+}
+
+// clusterCosts prices each of a cluster's stacks, taking queue sizes
+// from their autoscaling groups where available.
+func (c *Cluster) clusterCosts(prices *PriceTable) ([]*CostItem, error) {
+  stacks, err := c.instanceStacks()
+  if err != nil { return nil, err }
+  costs := []*CostItem{}
+  for _, stack := range stacks {
+    items, err := stackCosts(stack, prices)
+    if err != nil { return nil, err }
+    if group := c.computeGroup(*stack.StackName); group != nil && group.ResourceName != "" {
+      for _, item := range items {
+        if item.Resource == "compute" {
+          item.MaxSize, item.Running = int64(group.MaxSize()), int64(group.Running())
+        }
+      }
+    }
+    costs = append(costs, items...)
+  }
+  return costs, nil
+}
+
+// Cost estimates what the cluster costs per hour and over the given
+// number of hours.
+func (c *Cluster) Cost(hours float64) (*CostEstimate, error) {
+  prices, err := c.Client().PricesFor(c.Client().Config().AwsRegion)
+  if err != nil { return nil, err }
+  costs, err := c.clusterCosts(prices)
+  if err != nil { return nil, err }
+  return &CostEstimate{c.Client().Config().AwsRegion, hours, costs}, nil
+}
+
+// Cost estimates what the domain's clusters and appliances cost per
+// hour and over the given number of hours.
+func (d *Domain) Cost(hours float64) (*CostEstimate, error) {
+  prices, err := d.Client().PricesFor(d.Client().Config().AwsRegion)
+  if err != nil { return nil, err }
+  status, err := d.Status(context.Background())
+  if err != nil { return nil, err }
+  estimate := &CostEstimate{d.Client().Config().AwsRegion, hours, []*CostItem{}}
+  applianceNames := []string{}
+  for name := range status.Appliances {
+    applianceNames = append(applianceNames, name)
+  }
+  sort.Strings(applianceNames)
+  for _, name := range applianceNames {
+    costs, err := stackCosts(status.Appliances[name].Stack, prices)
+    if err != nil { return nil, err }
+    estimate.Items = append(estimate.Items, costs...)
+  }
+  clusterNames := []string{}
+  for name := range status.Clusters {
+    clusterNames = append(clusterNames, name)
+  }
+  sort.Strings(clusterNames)
+  for _, name := range clusterNames {
+    cluster := status.Clusters[name]
+    if cluster.Master == nil { continue }
+    costs, err := cluster.clusterCosts(prices)
+    if err != nil { return nil, err }
+    estimate.Items = append(estimate.Items, costs...)
+  }
+  return estimate, nil
+}
+
+func (e *CostEstimate) Render() string {
+  var buf bytes.Buffer
+  w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+  fmt.Fprintln(w, "    STACK\tRESOURCE\tTYPE\tPRICING\tRATE/H\tRUNNING\tMAX\tCURRENT/H\tMAXIMUM/H")
+  for _, item := range e.Items {
+    fmt.Fprintf(w, "    %s\t%s\t%s\t%s\t$%.4f\t%d\t%d\t$%.2f\t$%.2f\n", item.StackName, item.Resource, item.Type, item.Pricing, item.Rate, item.Running, item.MaxSize, item.CurrentHourly(), item.MaximumHourly())
+  }
+  w.Flush()
+  fmt.Fprintf(&buf, "\nHourly: $%.2f (up to $%.2f)\n", e.CurrentHourly(), e.MaximumHourly())
+  fmt.Fprintf(&buf, "Projected over %g hours: $%.2f (up to $%.2f)\n", e.Hours, e.CurrentHourly() * e.Hours, e.MaximumHourly() * e.Hours)
+  return buf.String()
+}
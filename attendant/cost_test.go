@@ -0,0 +1,135 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "math"
+  "testing"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testPrices() *PriceTable {
+  return &PriceTable{
+    Instances: map[string]float64{"c4.large": 0.1, "t2.large": 0.05},
+    Volumes: map[string]float64{"gp2": 0.1},
+  }
+}
+
+func TestInstancePrice(t *testing.T) {
+  prices := testPrices()
+  if price, err := prices.InstancePrice("compute-2C-3.75GB.small-c4.large"); err != nil || price != 0.1 {
+    t.Errorf("InstancePrice = %g, %v; want 0.1", price, err)
+  }
+  if _, err := prices.InstancePrice("m9.huge"); err == nil {
+    t.Errorf("expected an error for an unpriced instance type")
+  }
+  if price, err := prices.VolumePrice("general-purpose-ssd.gp2"); err != nil || price != 0.1 {
+    t.Errorf("VolumePrice = %g, %v; want 0.1", price, err)
+  }
+}
+
+func TestStackCosts(t *testing.T) {
+  stack := &cloudformation.Stack{
+    StackName: aws.String("flight-d1-c1-master"),
+    Parameters: testParameters(map[string]string{
+      "MasterInstanceType": "small-t2.large",
+      "MasterSystemVolumeSize": "730",
+      "MasterSystemVolumeType": "general-purpose-ssd.gp2",
+    }),
+  }
+  costs, err := stackCosts(stack, testPrices())
+  if err != nil { t.Fatalf("stackCosts: %s", err) }
+  if len(costs) != 2 {
+    t.Fatalf("%d cost items, want 2", len(costs))
+  }
+  estimate := &CostEstimate{Items: costs}
+  // 730GB at $0.1/GB-month is $0.1 an hour
+  if math.Abs(estimate.CurrentHourly() - 0.15) > 1e-9 {
+    t.Errorf("hourly cost = %g, want 0.15", estimate.CurrentHourly())
+  }
+}
+
+func TestStackCostsQueue(t *testing.T) {
+  stack := &cloudformation.Stack{
+    StackName: aws.String("flight-d1-c1-compute-q1"),
+    Parameters: testParameters(map[string]string{
+      "ComputeInstanceType": "compute-2C-3.75GB.small-c4.large",
+      "ComputeMaxNodes": "4",
+    }),
+  }
+  costs, err := stackCosts(stack, testPrices())
+  if err != nil { t.Fatalf("stackCosts: %s", err) }
+  if len(costs) != 1 || costs[0].Pricing != "on-demand" {
+    t.Fatalf("costs = %+v", costs)
+  }
+  if math.Abs(costs[0].MaximumHourly() - 0.4) > 1e-9 {
+    t.Errorf("maximum hourly cost = %g, want 0.4", costs[0].MaximumHourly())
+  }
+}
+
+func TestStackCostsSpot(t *testing.T) {
+  for _, test := range []struct {
+    bid string
+    rate float64
+  }{
+    {"0.05", 0.05},
+    {"5", 0.1},
+  } {
+    stack := &cloudformation.Stack{
+      StackName: aws.String("flight-d1-c1-compute-q1"),
+      Parameters: testParameters(map[string]string{
+        "ComputeInstanceType": "compute-2C-3.75GB.small-c4.large",
+        "ComputeMaxNodes": "4",
+        "ComputeSpotPrice": test.bid,
+      }),
+    }
+    costs, err := stackCosts(stack, testPrices())
+    if err != nil { t.Fatalf("stackCosts: %s", err) }
+    if len(costs) != 1 || costs[0].Pricing != "spot (max)" {
+      t.Fatalf("costs = %+v", costs)
+    }
+    if math.Abs(costs[0].Rate - test.rate) > 1e-9 {
+      t.Errorf("rate with a bid of %s = %g, want %g", test.bid, costs[0].Rate, test.rate)
+    }
+  }
+}
+
+func TestClusterCost(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+  estimate, err := cluster.Cost(HoursPerMonth)
+  if err != nil { t.Fatalf("Cost: %s", err) }
+  if estimate.MaximumHourly() <= 0 || estimate.MaximumHourly() < estimate.CurrentHourly() {
+    t.Errorf("estimate = %g current, %g maximum", estimate.CurrentHourly(), estimate.MaximumHourly())
+  }
+}
@@ -0,0 +1,312 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "crypto/sha1"
+  "encoding/hex"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/credentials"
+  "github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+  "github.com/aws/aws-sdk-go/aws/endpoints"
+  "github.com/aws/aws-sdk-go/aws/session"
+  "github.com/aws/aws-sdk-go/service/iam"
+  "github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Temporary credentials are renewed this long before they expire.
+var credentialExpiryWindow = 5 * time.Minute
+
+// Account identifies the AWS account that a client's credentials refer
+// to.
+type Account struct {
+  Id string `json:"Id" yaml:"Id"`
+  Alias string `json:"Alias,omitempty" yaml:"Alias,omitempty"`
+  Arn string `json:"Arn,omitempty" yaml:"Arn,omitempty"`
+}
+
+func (a *Account) String() string {
+  if a.Alias != "" {
+    return a.Id + " '" + a.Alias + "'"
+  }
+  return a.Id
+}
+
+// Credentials returns the credentials used by the client.  They come
+// from the configured access key, else from a named profile in the
+// shared AWS config and credentials files, else from the usual AWS
+// environment variables and files.  When a role is configured, either
+// directly or by the profile, it is assumed using those credentials,
+// prompting for an MFA token if the role requires one.
+func (c *Client) Credentials() (*credentials.Credentials, error) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  return c.sharedCredentials()
+}
+
+func (c *Client) sharedCredentials() (*credentials.Credentials, error) {
+  if c.credentials != nil {
+    return c.credentials, nil
+  }
+  var creds *credentials.Credentials
+  var err error
+  cfg := c.config
+  if cfg.AwsAccessKey != "" && cfg.AwsSecretKey != "" {
+    creds = credentials.NewStaticCredentials(cfg.AwsAccessKey, cfg.AwsSecretKey, "")
+  } else if cfg.AwsProfile != "" {
+    creds, err = c.profileCredentials()
+    if err != nil { return nil, err }
+  }
+  if cfg.AwsRoleArn != "" {
+    creds, err = c.assumeRole(creds)
+    if err != nil { return nil, err }
+  }
+  c.credentials = creds
+  return creds, nil
+}
+
+// profileCredentials returns the credentials of the configured profile,
+// resolved by the AWS SDK from the shared config and credentials files,
+// so that roles, chained source profiles, credential sources, credential
+// processes and SSO all behave as they do for the AWS CLI.
+func (c *Client) profileCredentials() (*credentials.Credentials, error) {
+  cfg := c.config
+  duration := cfg.AwsRoleDuration
+  if duration <= 0 { duration = time.Hour }
+  opts := session.Options{
+    Config: *c.awsConfig(nil),
+    Profile: cfg.AwsProfile,
+    SharedConfigState: session.SharedConfigEnable,
+    AssumeRoleTokenProvider: mfaTokenProvider("profile " + cfg.AwsProfile),
+    AssumeRoleDuration: duration,
+  }
+  if len(cfg.Endpoints) > 0 {
+    opts.Config.EndpointResolver = endpoints.ResolverFunc(
+      func(service, region string, optFns ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+        if endpoint := cfg.Endpoints[service]; endpoint != "" {
+          return endpoints.ResolvedEndpoint{URL: endpoint, SigningRegion: region}, nil
+        }
+        return endpoints.DefaultResolver().EndpointFor(service, region, optFns...)
+      },
+    )
+  }
+  sess, err := session.NewSessionWithOptions(opts)
+  if err != nil { return nil, err }
+  if !cfg.CacheCredentials {
+    return sess.Config.Credentials, nil
+  }
+  return c.cachedCredentials(
+    []string{"profile", cfg.AwsProfile},
+    &sessionProvider{sess.Config.Credentials},
+    duration,
+  ), nil
+}
+
+// assumeRole returns temporary credentials for the configured role,
+// obtained with creds, or with the default AWS credentials if creds is
+// nil.
+func (c *Client) assumeRole(creds *credentials.Credentials) (*credentials.Credentials, error) {
+  cfg := c.config
+  sess, err := session.NewSession(c.awsConfig(creds))
+  if err != nil { return nil, err }
+  provider := &stscreds.AssumeRoleProvider{
+    Client: sts.New(sess, c.serviceConfig("sts")),
+    RoleARN: cfg.AwsRoleArn,
+    RoleSessionName: cfg.AwsRoleSessionName,
+    Duration: cfg.AwsRoleDuration,
+    ExpiryWindow: credentialExpiryWindow,
+  }
+  if provider.RoleSessionName == "" { provider.RoleSessionName = "flight-attendant" }
+  if provider.Duration <= 0 { provider.Duration = time.Hour }
+  if cfg.AwsExternalId != "" { provider.ExternalID = aws.String(cfg.AwsExternalId) }
+  if cfg.AwsMfaSerial != "" {
+    provider.SerialNumber = aws.String(cfg.AwsMfaSerial)
+    provider.TokenProvider = mfaTokenProvider(cfg.AwsMfaSerial)
+  }
+  if !cfg.CacheCredentials {
+    return credentials.NewCredentials(provider), nil
+  }
+  return c.cachedCredentials(
+    []string{cfg.AwsAccessKey, cfg.AwsProfile, cfg.AwsRoleArn, cfg.AwsExternalId, cfg.AwsMfaSerial},
+    provider,
+    provider.Duration,
+  ), nil
+}
+
+// cachedCredentials wraps provider so that the temporary credentials it
+// returns are kept in the credential cache file under a key derived
+// from keyParts.
+func (c *Client) cachedCredentials(keyParts []string, provider credentials.Provider, duration time.Duration) *credentials.Credentials {
+  path := c.config.CredentialCacheFile
+  if path == "" {
+    path = filepath.Join(os.Getenv("HOME"), ".fly-credentials.json")
+  }
+  if duration <= 0 { duration = time.Hour }
+  sum := sha1.Sum([]byte(strings.Join(keyParts, "/")))
+  return credentials.NewCredentials(&cachedCredentials{
+    key: hex.EncodeToString(sum[:]),
+    path: path,
+    duration: duration,
+    provider: provider,
+  })
+}
+
+func mfaTokenProvider(what string) func() (string, error) {
+  return func() (string, error) {
+    if !IsTerminal(os.Stdin) {
+      return "", fmt.Errorf("An MFA token for %s is required, but there is no terminal to ask for it on.", what)
+    }
+    token := Prompt("MFA token for " + what + ": ")
+    if token == "" { return "", fmt.Errorf("No MFA token given.") }
+    return token, nil
+  }
+}
+
+// sessionProvider lets the credentials of a session be cached.
+type sessionProvider struct {
+  creds *credentials.Credentials
+}
+
+func (p *sessionProvider) Retrieve() (credentials.Value, error) {
+  return p.creds.Get()
+}
+
+func (p *sessionProvider) IsExpired() bool {
+  return p.creds.IsExpired()
+}
+
+func (p *sessionProvider) ExpiresAt() time.Time {
+  expiration, err := p.creds.ExpiresAt()
+  if err != nil { return time.Time{} }
+  return expiration
+}
+
+// cachedCredentials keeps temporary credentials, such as those for an
+// assumed role, in a file, so that later commands can use them, and the
+// MFA token given to get them, until they expire.
+type cachedCredentials struct {
+  credentials.Expiry
+  key string
+  path string
+  duration time.Duration
+  provider credentials.Provider
+}
+
+type cachedCredentialsEntry struct {
+  AccessKeyID string
+  SecretAccessKey string
+  SessionToken string
+  Expiration time.Time
+}
+
+func (p *cachedCredentials) Retrieve() (credentials.Value, error) {
+  entries := make(map[string]*cachedCredentialsEntry)
+  if data, err := ioutil.ReadFile(p.path); err == nil {
+    json.Unmarshal(data, &entries)
+  }
+  if entry, exists := entries[p.key]; exists && time.Now().Add(credentialExpiryWindow).Before(entry.Expiration) {
+    p.SetExpiration(entry.Expiration, credentialExpiryWindow)
+    return credentials.Value{
+      AccessKeyID: entry.AccessKeyID,
+      SecretAccessKey: entry.SecretAccessKey,
+      SessionToken: entry.SessionToken,
+      ProviderName: "FlightAttendantCache",
+    }, nil
+  }
+  value, err := p.provider.Retrieve()
+  if err != nil { return value, err }
+  expiration := time.Now().Add(p.duration)
+  if e, ok := p.provider.(interface{ ExpiresAt() time.Time }); ok && !e.ExpiresAt().IsZero() {
+    expiration = e.ExpiresAt()
+  }
+  p.SetExpiration(expiration, credentialExpiryWindow)
+  // long-lived credentials stay where they are rather than being copied
+  if value.SessionToken == "" { return value, nil }
+  for key, entry := range entries {
+    if time.Now().After(entry.Expiration) {
+      delete(entries, key)
+    }
+  }
+  entries[p.key] = &cachedCredentialsEntry{value.AccessKeyID, value.SecretAccessKey, value.SessionToken, expiration}
+  if data, err := json.Marshal(entries); err == nil {
+    tmp := p.path + ".tmp"
+    if ioutil.WriteFile(tmp, data, 0600) == nil {
+      os.Rename(tmp, p.path)
+    }
+  }
+  return value, nil
+}
+
+// Account returns the account that the client's credentials refer to,
+// with its alias if one is set and the credentials allow it to be read.
+func (c *Client) Account() (*Account, error) {
+  c.mutex.Lock()
+  account := c.account
+  c.mutex.Unlock()
+  if account != nil { return account, nil }
+  account, err := c.Provider().Account(c)
+  if err != nil { return nil, err }
+  c.mutex.Lock()
+  c.account = account
+  c.mutex.Unlock()
+  return account, nil
+}
+
+func awsAccount(c *Client) (*Account, error) {
+  sess, err := c.Session()
+  if err != nil { return nil, err }
+  o, err := throttleProtected(
+    func() (interface{}, error) {
+      return sts.New(sess, c.serviceConfig("sts")).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+    },
+  )
+  if err != nil { return nil, err }
+  identity := o.(*sts.GetCallerIdentityOutput)
+  account := &Account{Id: *identity.Account, Arn: *identity.Arn}
+  o, err = throttleProtected(
+    func() (interface{}, error) {
+      return iam.New(sess, c.serviceConfig("iam")).ListAccountAliases(&iam.ListAccountAliasesInput{})
+    },
+  )
+  // the alias is only a nicety; many roles aren't allowed to read it
+  if err == nil {
+    if aliases := o.(*iam.ListAccountAliasesOutput).AccountAliases; len(aliases) > 0 {
+      account.Alias = *aliases[0]
+    }
+  }
+  return account, nil
+}
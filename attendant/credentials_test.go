@@ -0,0 +1,155 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// countingProvider hands out the same credentials every time, counting
+// how often it is asked for them.
+type countingProvider struct {
+  value credentials.Value
+  expiration time.Time
+  calls int
+}
+
+func (p *countingProvider) Retrieve() (credentials.Value, error) {
+  p.calls += 1
+  return p.value, nil
+}
+
+func (p *countingProvider) IsExpired() bool { return true }
+
+func (p *countingProvider) ExpiresAt() time.Time { return p.expiration }
+
+func newCredentialsTestClient(t *testing.T) (*Client, string) {
+  c, _ := newTestClient(t)
+  c.config.CredentialCacheFile = filepath.Join(t.TempDir(), "credentials.json")
+  return c, c.config.CredentialCacheFile
+}
+
+func TestCachedCredentials(t *testing.T) {
+  c, path := newCredentialsTestClient(t)
+  provider := &countingProvider{
+    value: credentials.Value{AccessKeyID: "ASIA1", SecretAccessKey: "secret", SessionToken: "token"},
+    expiration: time.Now().Add(time.Hour),
+  }
+
+  value, err := c.cachedCredentials([]string{"role"}, provider, time.Hour).Get()
+  if err != nil { t.Fatalf("Get: %s", err) }
+  if value.AccessKeyID != "ASIA1" || provider.calls != 1 {
+    t.Errorf("got %s after %d calls, want ASIA1 from the provider", value.AccessKeyID, provider.calls)
+  }
+  info, err := os.Stat(path)
+  if err != nil { t.Fatalf("credentials weren't cached: %s", err) }
+  if info.Mode().Perm() != 0600 {
+    t.Errorf("cache file mode = %o, want 600", info.Mode().Perm())
+  }
+
+  // a later command finds the credentials in the file
+  value, err = c.cachedCredentials([]string{"role"}, provider, time.Hour).Get()
+  if err != nil { t.Fatalf("Get: %s", err) }
+  if value.SessionToken != "token" || provider.calls != 1 {
+    t.Errorf("got %q after %d calls, want the cached session", value.SessionToken, provider.calls)
+  }
+
+  // other roles or profiles don't share them
+  if _, err := c.cachedCredentials([]string{"other"}, provider, time.Hour).Get(); err != nil { t.Fatalf("Get: %s", err) }
+  if provider.calls != 2 {
+    t.Errorf("provider called %d times, want 2 for another key", provider.calls)
+  }
+}
+
+func TestCachedCredentialsExpire(t *testing.T) {
+  c, _ := newCredentialsTestClient(t)
+  provider := &countingProvider{
+    value: credentials.Value{AccessKeyID: "ASIA1", SecretAccessKey: "secret", SessionToken: "token"},
+    expiration: time.Now().Add(credentialExpiryWindow / 2),
+  }
+  for i := 0; i < 2; i++ {
+    if _, err := c.cachedCredentials([]string{"role"}, provider, time.Hour).Get(); err != nil { t.Fatalf("Get: %s", err) }
+  }
+  if provider.calls != 2 {
+    t.Errorf("provider called %d times, want credentials about to expire to be renewed", provider.calls)
+  }
+}
+
+func TestLongLivedCredentialsAreNotCached(t *testing.T) {
+  c, path := newCredentialsTestClient(t)
+  provider := &countingProvider{value: credentials.Value{AccessKeyID: "AKIA1", SecretAccessKey: "secret"}}
+  value, err := c.cachedCredentials([]string{"profile", "prod"}, provider, time.Hour).Get()
+  if err != nil { t.Fatalf("Get: %s", err) }
+  if value.AccessKeyID != "AKIA1" {
+    t.Errorf("got %s, want AKIA1", value.AccessKeyID)
+  }
+  if _, err := os.Stat(path); !os.IsNotExist(err) {
+    t.Errorf("credentials without a session token were written to the cache")
+  }
+}
+
+func TestProfileCredentials(t *testing.T) {
+  dir := t.TempDir()
+  credentialsFile := filepath.Join(dir, "credentials")
+  err := ioutil.WriteFile(credentialsFile, []byte("[prod]\naws_access_key_id = AKIAPROD\naws_secret_access_key = secret\n"), 0600)
+  if err != nil { t.Fatalf("WriteFile: %s", err) }
+  t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+  t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+  c, path := newCredentialsTestClient(t)
+  c.config.AwsProfile = "prod"
+  c.config.CacheCredentials = true
+  creds, err := c.Credentials()
+  if err != nil { t.Fatalf("Credentials: %s", err) }
+  value, err := creds.Get()
+  if err != nil { t.Fatalf("Get: %s", err) }
+  if value.AccessKeyID != "AKIAPROD" {
+    t.Errorf("got %s, want the profile's key", value.AccessKeyID)
+  }
+  if _, err := os.Stat(path); !os.IsNotExist(err) {
+    t.Errorf("the profile's long-lived key was written to the cache")
+  }
+
+  // a configured access key takes precedence over the profile
+  c, _ = newCredentialsTestClient(t)
+  c.config.AwsProfile = "prod"
+  c.config.AwsAccessKey = "AKIAFLAG"
+  c.config.AwsSecretKey = "secret"
+  creds, err = c.Credentials()
+  if err != nil { t.Fatalf("Credentials: %s", err) }
+  if value, _ := creds.Get(); value.AccessKeyID != "AKIAFLAG" {
+    t.Errorf("got %s, want the configured access key", value.AccessKeyID)
+  }
+}
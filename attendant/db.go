@@ -31,7 +31,9 @@ package attendant
 import (
   "fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/guregu/dynamo"
 )
 
@@ -49,28 +51,25 @@ type ClusterEntity struct {
 }
 
 func (d *Domain) SaveEntity() error {
-  table, err := getTable("FlightDomains")
+  store, err := d.Client().State()
   if err != nil { return err }
 
   record := DomainEntity{Name: d.Name, Prefix: d.Prefix()}
-  return table.Put(record).Run()
+  return store.PutDomain(&record)
 }
 
 func (d *Domain) DestroyEntity() error {
-  table, err := getTable("FlightDomains")
+  store, err := d.Client().State()
   if err != nil { return err }
 
-  return table.Delete("Name", d.Name).Run()
+  return store.DeleteDomain(d.Name)
 }
 
 func (d *Domain) LoadEntity() (*DomainEntity, error) {
-  table, err := getTable("FlightDomains")
+  store, err := d.Client().State()
   if err != nil { return nil, err }
 
-  var record DomainEntity
-  err = table.Get("Name", d.Name).One(&record)
-
-  return &record, err
+  return store.GetDomain(d.Name)
 }
 
 func (d *Domain) BookNetwork() (int, error) {
@@ -79,13 +78,9 @@ func (d *Domain) BookNetwork() (int, error) {
 
   for i := 0; i < 128; i++ {
     if !containsI(record.NetBookings, i) {
-      table, err := getTable("FlightDomains")
+      store, err := d.Client().State()
       if err != nil { return 0, err }
-      err = table.
-        Update("Name", d.Name).
-        AddIntsToSet("NetBookings", i).
-        If("NOT contains(NetBookings, ?)",i).
-        Run()
+      err = store.AddNetworkBooking(d.Name, i)
       if err != nil { return 0, err }
       return i, nil
     }
@@ -98,52 +93,186 @@ func (d *Domain) ReleaseNetwork(index int) error {
   if err != nil { return err }
 
   if containsI(record.NetBookings, index) {
-    table, err := getTable("FlightDomains")
+    store, err := d.Client().State()
     if err != nil { return err }
 
-    err = table.
-      Update("Name", d.Name).
-      DeleteIntsFromSet("NetBookings", index).
-      If("contains(NetBookings, ?)", index).
-      Run()
-    if err != nil { return err }
-    return nil
+    return store.RemoveNetworkBooking(d.Name, index)
   }
-  return fmt.Errorf("Network not booked.")
+  return ErrNetworkNotBooked
 }
 
 func (c *Cluster) CreateEntity() error {
-  table, err := getTable("FlightClusters")
+  store, err := c.Client().State()
   if err != nil { return err }
 
   record := ClusterEntity{Name: c.Name, Domain: c.Domain.Name, NetworkIndex: c.Network.Index, GroupCount: 1}
-  return table.Put(record).Run()
+  return store.PutCluster(&record)
 }
 
 func (c *Cluster) DestroyEntity() error {
-  table, err := getTable("FlightClusters")
+  store, err := c.Client().State()
   if err != nil { return err }
 
-  return table.Delete("Name", c.Name).Run()
+  return store.DeleteCluster(c.Name)
 }
 
 func (d *Cluster) LoadEntity() (*ClusterEntity, error) {
-  table, err := getTable("FlightClusters")
+  store, err := d.Client().State()
+  if err != nil { return nil, err }
+
+  return store.GetCluster(d.Name)
+}
+
+type dynamoStateStore struct {
+  db *dynamo.DB
+  // the client records the tables known to exist in its region
+  client *Client
+}
+
+func (s *dynamoStateStore) PutDomain(record *DomainEntity) error {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return err }
+
+  return table.Put(record).Run()
+}
+
+func (s *dynamoStateStore) GetDomain(name string) (*DomainEntity, error) {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return nil, err }
+
+  var record DomainEntity
+  err = table.Get("Name", name).One(&record)
+  if err == dynamo.ErrNotFound { err = ErrEntityNotFound }
+
+  return &record, err
+}
+
+func (s *dynamoStateStore) DeleteDomain(name string) error {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return err }
+
+  return table.Delete("Name", name).Run()
+}
+
+func (s *dynamoStateStore) Domains() ([]*DomainEntity, error) {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return nil, err }
+
+  var records []*DomainEntity
+  err = table.Scan().All(&records)
+  return records, err
+}
+
+func (s *dynamoStateStore) AddNetworkBooking(domainName string, index int) error {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return err }
+
+  err = table.
+    Update("Name", domainName).
+    AddIntsToSet("NetBookings", index).
+    If("NOT contains(NetBookings, ?)", index).
+    Run()
+  if isConditionalCheckFailure(err) { return ErrNetworkBooked }
+  return err
+}
+
+func (s *dynamoStateStore) RemoveNetworkBooking(domainName string, index int) error {
+  table, err := s.getTable("FlightDomains")
+  if err != nil { return err }
+
+  err = table.
+    Update("Name", domainName).
+    DeleteIntsFromSet("NetBookings", index).
+    If("contains(NetBookings, ?)", index).
+    Run()
+  if isConditionalCheckFailure(err) { return ErrNetworkNotBooked }
+  return err
+}
+
+func (s *dynamoStateStore) PutCluster(record *ClusterEntity) error {
+  table, err := s.getTable("FlightClusters")
+  if err != nil { return err }
+
+  return table.Put(record).Run()
+}
+
+func (s *dynamoStateStore) GetCluster(name string) (*ClusterEntity, error) {
+  table, err := s.getTable("FlightClusters")
   if err != nil { return nil, err }
 
   var record ClusterEntity
-  err = table.Get("Name", d.Name).One(&record)
+  err = table.Get("Name", name).One(&record)
+  if err == dynamo.ErrNotFound { err = ErrEntityNotFound }
+
+  return &record, err
+}
+
+func (s *dynamoStateStore) DeleteCluster(name string) error {
+  table, err := s.getTable("FlightClusters")
+  if err != nil { return err }
+
+  return table.Delete("Name", name).Run()
+}
+
+func (s *dynamoStateStore) Clusters() ([]*ClusterEntity, error) {
+  table, err := s.getTable("FlightClusters")
+  if err != nil { return nil, err }
+
+  var records []*ClusterEntity
+  err = table.Scan().All(&records)
+  return records, err
+}
+
+func (s *dynamoStateStore) PutOperation(record *OperationEntity) error {
+  table, err := s.getTable("FlightOperations")
+  if err != nil { return err }
+
+  return table.Put(record).Run()
+}
+
+func (s *dynamoStateStore) GetOperation(id string) (*OperationEntity, error) {
+  table, err := s.getTable("FlightOperations")
+  if err != nil { return nil, err }
+
+  var record OperationEntity
+  err = table.Get("Id", id).One(&record)
+  if err == dynamo.ErrNotFound { err = ErrEntityNotFound }
 
   return &record, err
 }
 
-func getTable(tableName string) (*dynamo.Table, error) {
-  db, err := Dynamo()
+func (s *dynamoStateStore) DeleteOperation(id string) error {
+  table, err := s.getTable("FlightOperations")
+  if err != nil { return err }
+
+  return table.Delete("Id", id).Run()
+}
+
+func (s *dynamoStateStore) Operations() ([]*OperationEntity, error) {
+  table, err := s.getTable("FlightOperations")
+  if err != nil { return nil, err }
+
+  var records []*OperationEntity
+  err = table.Scan().All(&records)
+  return records, err
+}
+
+func (s *dynamoStateStore) getTable(tableName string) (*dynamo.Table, error) {
+  s.client.mutex.Lock()
+  known := s.client.dynamoTables[tableName]
+  s.client.mutex.Unlock()
+  if known {
+    table := s.db.Table(tableName)
+    return &table, nil
+  }
+  var err error
   switch tableName {
   case "FlightDomains":
-    err = db.CreateTable("FlightDomains", DomainEntity{}).Run()
+    err = s.db.CreateTable("FlightDomains", DomainEntity{}).Run()
   case "FlightClusters":
-    err = db.CreateTable("FlightClusters", ClusterEntity{}).Run()
+    err = s.db.CreateTable("FlightClusters", ClusterEntity{}).Run()
+  case "FlightOperations":
+    err = s.db.CreateTable("FlightOperations", OperationEntity{}).Run()
   }    
   if aerr, ok := err.(awserr.Error); ok {
     switch aerr.Code() {
@@ -152,7 +281,23 @@ func getTable(tableName string) (*dynamo.Table, error) {
     default:
       return nil, err
     }
+  } else if err != nil {
+    return nil, err
+  } else {
+    // Newly created table; wait for it to become active.
+    err = s.db.Client().WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+    if err != nil { return nil, err }
   }
-  table := db.Table(tableName)
+  s.client.mutex.Lock()
+  s.client.dynamoTables[tableName] = true
+  s.client.mutex.Unlock()
+  table := s.db.Table(tableName)
   return &table, nil
 }
+
+func isConditionalCheckFailure(err error) bool {
+  if aerr, ok := err.(awserr.Error); ok {
+    return aerr.Code() == "ConditionalCheckFailedException"
+  }
+  return false
+}
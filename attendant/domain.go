@@ -29,15 +29,12 @@
 package attendant
 
 import (
+  "context"
   "encoding/xml"
   "fmt"
   "strconv"
-  "strings"
   "time"
 
-  "github.com/spf13/viper"
-
-  "github.com/aws/aws-sdk-go/aws"
   "github.com/aws/aws-sdk-go/aws/awserr"
   "github.com/aws/aws-sdk-go/service/cloudformation"
 )
@@ -52,10 +49,12 @@ var DomainNoInternetAccessResourceCount int = 1
 type Domain struct {
   Name string
   Stack *cloudformation.Stack
-  MessageHandler func(msg string)
+  MessageHandler EventHandler
+  client *Client
 }
 
 type DomainStatus struct {
+  Domain *Domain
   Clusters map[string]*Cluster
   Appliances map[string]*Appliance
   HasInternetAccess bool
@@ -66,13 +65,18 @@ type DomainStatus struct {
 }
 
 type DomainDetails struct {
-  Clusters map[string]*ClusterDetails
-  Appliances map[string]*ApplianceDetails
-  HasInternetAccess bool
-  VPNConnectionId string
-  PeerVPC string
-  PeerVPCCIDRBlock string
-  VPNDetails VPNConnectionDetails
+  Name string `json:"Name" yaml:"Name"`
+  Region string `json:"Region" yaml:"Region"`
+  Status string `json:"Status" yaml:"Status"`
+  StackName string `json:"StackName" yaml:"StackName"`
+  CreationTime string `json:"CreationTime" yaml:"CreationTime"`
+  Clusters map[string]*ClusterDetails `json:"Clusters,omitempty" yaml:"Clusters,omitempty"`
+  Appliances map[string]*ApplianceDetails `json:"Appliances,omitempty" yaml:"Appliances,omitempty"`
+  HasInternetAccess bool `json:"HasInternetAccess" yaml:"HasInternetAccess"`
+  VPNConnectionId string `json:"VPNConnectionId,omitempty" yaml:"VPNConnectionId,omitempty"`
+  PeerVPC string `json:"PeerVPC,omitempty" yaml:"PeerVPC,omitempty"`
+  PeerVPCCIDRBlock string `json:"PeerVPCCIDRBlock,omitempty" yaml:"PeerVPCCIDRBlock,omitempty"`
+  VPNDetails *VPNConnectionDetails `json:"VPNDetails,omitempty" yaml:"VPNDetails,omitempty"`
 }
 
 type XMLVPNConnection struct {
@@ -156,27 +160,47 @@ func (d *Domain) getOutput(key string) string {
   return getStackOutput(d.Stack, key)
 }
 
-func SoloStatus() (*DomainStatus, error) {
+func SoloStatus(ctx context.Context) (*DomainStatus, error) {
+  return DefaultClient().SoloStatus(ctx)
+}
+
+func (c *Client) SoloStatus(ctx context.Context) (*DomainStatus, error) {
   var soloStatus DomainStatus
   soloStatus.Clusters = make(map[string]*Cluster)
-  err := eachRunningStack(func(stack *cloudformation.Stack) {
+  err := c.eachStack(ctx, func(stack *cloudformation.Stack) {
     stackType := getStackTag(stack, "flight:type")
     if stackType == "solo" {
       clusterName := getStackTag(stack, "flight:cluster")
-      cluster := &Cluster{Name: clusterName, Master: &Master{stack}, ExpiryTime: -1}
+      cluster := &Cluster{Name: clusterName, Master: &Master{stack}, ExpiryTime: -1, client: c}
       soloStatus.Clusters[clusterName] = cluster
     }
   })
   return &soloStatus, err
 }
 
+// Details describes the domain itself, without its clusters and
+// appliances.
+func (d *Domain) Details() *DomainDetails {
+  details := DomainDetails{Name: d.Name, Region: d.Client().Config().AwsRegion, StackName: "flight-" + d.Name}
+  if d.Stack != nil {
+    details.Status = *d.Stack.StackStatus
+    details.CreationTime = d.Stack.CreationTime.Format(time.RFC3339)
+  }
+  return &details
+}
+
 func (s *DomainStatus) Details() *DomainDetails {
   details := DomainDetails{}
+  if s.Domain != nil {
+    details = *s.Domain.Details()
+  }
   details.HasInternetAccess = s.HasInternetAccess
   details.VPNConnectionId = s.VPNConnectionId
   details.PeerVPC = s.PeerVPC
   details.PeerVPCCIDRBlock = s.PeerVPCCIDRBlock
-  details.VPNDetails = s.VPNDetails
+  if s.VPNConnectionId != "" {
+    details.VPNDetails = &s.VPNDetails
+  }
   details.Clusters = make(map[string]*ClusterDetails)
   details.Appliances = make(map[string]*ApplianceDetails)
   for _, cluster := range s.Clusters {
@@ -188,14 +212,15 @@ func (s *DomainStatus) Details() *DomainDetails {
   return &details
 }
 
-func (d *Domain) Status() (*DomainStatus, error) {
+func (d *Domain) Status(ctx context.Context) (*DomainStatus, error) {
   err := d.AssertExists()
   if err != nil { return nil, err }
   var status DomainStatus
+  status.Domain = d
   status.Clusters = make(map[string]*Cluster)
   status.Appliances = make(map[string]*Appliance)
   // check no infrastructure or clusters exist in domain
-  err = eachRunningStack(func(stack *cloudformation.Stack) {
+  err = d.Client().eachStack(ctx, func(stack *cloudformation.Stack) {
     for _, tag := range stack.Tags {
       if *tag.Key == "flight:domain" && *tag.Value == d.Name {
         stackType := getStackTag(stack, "flight:type")
@@ -204,7 +229,7 @@ func (d *Domain) Status() (*DomainStatus, error) {
           clusterName := getStackTag(stack, "flight:cluster")
           cluster, exists := status.Clusters[clusterName]
           if ! exists {
-            cluster = &Cluster{Name: clusterName, Domain: d, ExpiryTime: -1}
+            cluster = &Cluster{Name: clusterName, Domain: d, ExpiryTime: -1, client: d.Client()}
             status.Clusters[clusterName] = cluster
           }
           if stackType == "master" {
@@ -213,11 +238,11 @@ func (d *Domain) Status() (*DomainStatus, error) {
             idx, _ := strconv.Atoi(getStackTag(stack,"flight:network"))
             cluster.Network = &ClusterNetwork{idx, stack}
           } else if stackType == "compute" {
-            cluster.ComputeGroups = append(cluster.ComputeGroups, computeGroupFromStack(stack))
+            cluster.ComputeGroups = append(cluster.ComputeGroups, d.Client().computeGroupFromStack(stack))
           }
         case "appliance":
           applianceName := getStackTag(stack, "flight:appliance")
-          status.Appliances[applianceName] = &Appliance{applianceName, d, stack, nil}
+          status.Appliances[applianceName] = &Appliance{applianceName, d, stack, nil, d.Client()}
         }
       }
     }
@@ -227,13 +252,13 @@ func (d *Domain) Status() (*DomainStatus, error) {
   status.PeerVPC = getStackParameter(d.Stack, "PeerVPC")
   status.PeerVPCCIDRBlock = getStackParameter(d.Stack, "PeerVPCCIDRBlock")
   if status.VPNConnectionId != "" {
-    status.VPNDetails, err = getVPNDetails(status.VPNConnectionId)
+    status.VPNDetails, err = d.Client().getVPNDetails(status.VPNConnectionId)
   }
   return &status, err
 }
 
-func (d *Domain) Destroy() error {
-  svc, err := CloudFormation()
+func (d *Domain) Destroy(ctx context.Context) error {
+  svc, err := d.Client().CloudFormation()
   if err != nil { return err }
 
   if err = d.AssertExists(); err != nil {
@@ -241,17 +266,17 @@ func (d *Domain) Destroy() error {
   }
 
   stackName := "flight-" + d.Name
-  qUrl, err := getEventQueueUrl(stackName)
+  qUrl, err := d.Client().getEventQueueUrl(stackName)
   if err != nil { return err }
-  go d.processQueue(qUrl)
+  go d.processQueue(ctx, qUrl)
 
-  err = destroyStack(svc, stackName)
+  err = destroyStack(ctx, svc, stackName)
   if err != nil { return err }
 
-  err = cleanupEventHandling(stackName)
+  err = d.Client().cleanupEventHandling(stackName)
   if err != nil { return err }
 
-  d.MessageHandler("DONE")
+  d.MessageHandler(NewDoneEvent())
 
   err = d.DestroyEntity()
   return err
@@ -271,7 +296,7 @@ func (d *Domain) AssertExists() error {
   if d.Stack != nil {
     return nil
   }
-  svc, err := CloudFormation()
+  svc, err := d.Client().CloudFormation()
   if err != nil { return err }
   stack, err := getStack(svc, "flight-" + d.Name)
 
@@ -295,37 +320,41 @@ func (d *Domain) AssertExists() error {
   return nil
 }
 
-func (d *Domain) processQueue(qUrl *string) {
-  for d.MessageHandler != nil {
+func (d *Domain) processQueue(ctx context.Context, qUrl *string) {
+  for d.MessageHandler != nil && ctx.Err() == nil {
     time.Sleep(500 * time.Millisecond)
-    receiveMessage(qUrl, d.MessageHandler)
+    d.Client().receiveMessage(qUrl, d.MessageHandler)
   }
 }
 
-func (d *Domain) Create(prefix string, domainParamsFile string) error {
-  var defaultLaunchParams map[string]string
+func (d *Domain) Create(ctx context.Context, prefix string, domainParamsFile string) error {
+  var parameterSet *ParameterSet
+  var err error
   if domainParamsFile == "" {
-    defaultLaunchParams = loadParameterSet("domain", DomainParameters)
+    parameterSet, err = d.Client().loadParameterSet("domain", DomainParameters)
   } else {
-    defaultLaunchParams = loadComponentParameters(domainParamsFile)
+    parameterSet, err = loadComponentParameters(domainParamsFile)
   }
+  if err != nil { return err }
 
-  d.MessageHandler(fmt.Sprintf("COUNTERS=%d",resourceCountFor(defaultLaunchParams)))
+  d.MessageHandler(countersEvent(resourceCountFor(d.Client().Config(), parameterSet.Values)))
+
+  launchParams, err := configResolver(d.Client()).LaunchParameters(parameterSet, d.Client().TemplateUrl("domain.json"))
+  if err != nil { return err }
 
   stackName := "flight-" + d.Name
-  tArn, qUrl, err := setupEventHandling(stackName)
+  tArn, qUrl, err := d.Client().setupEventHandling(stackName)
   if err != nil { return err }
-  go d.processQueue(qUrl)
+  go d.processQueue(ctx, qUrl)
 
-  launchParams := createDomainLaunchParameters(d, defaultLaunchParams)
-  err = createDomain(d, stackName, prefix, tArn, launchParams)
+  err = createDomain(ctx, d, stackName, prefix, tArn, launchParams)
   if err != nil {
-    cleanupEventHandling(stackName)
+    d.Client().cleanupEventHandling(stackName)
     return err
   }
   err = d.SaveEntity()
 
-  d.MessageHandler("DONE")
+  d.MessageHandler(NewDoneEvent())
 
   return err
 }
@@ -336,7 +365,7 @@ func (d *Domain) HasInternetAccess() bool {
 
 func (d *Domain) MasterIP() string {
   // get controller stack
-  a := NewAppliance("controller", d, nil)
+  a := d.Client().NewAppliance("controller", d, nil)
   a.LoadStack()
   if a.Stack == nil {
     return ""
@@ -345,17 +374,27 @@ func (d *Domain) MasterIP() string {
   }
 }
 
-func NewDomain(name string, handler func(msg string)) *Domain {
-  return &Domain{name, nil, handler}
+func NewDomain(name string, handler EventHandler) *Domain {
+  return DefaultClient().NewDomain(name, handler)
+}
+
+// Client returns the client that the domain belongs to.
+func (d *Domain) Client() *Client {
+  if d.client == nil { return DefaultClient() }
+  return d.client
 }
 
 func AllDomains() ([]Domain, error) {
+  return DefaultClient().AllDomains()
+}
+
+func (c *Client) AllDomains() ([]Domain, error) {
   var domains []Domain
 
-  err := eachRunningStack(func(stack *cloudformation.Stack) {
+  err := c.eachStack(context.Background(), func(stack *cloudformation.Stack) {
     for _, tag := range stack.Tags {
       if *tag.Key == "flight:type" && *tag.Value == "domain" {
-        domains = append(domains, Domain{getStackTag(stack, "flight:domain"), stack, nil})
+        domains = append(domains, Domain{getStackTag(stack, "flight:domain"), stack, nil, c})
       }
     }
   })
@@ -364,52 +403,32 @@ func AllDomains() ([]Domain, error) {
 }
 
 func DefaultDomain() (*Domain, error) {
-  domains, err := AllDomains()
-  if err != nil { return nil, err }
-  if len(domains) == 0 {
-    return nil, fmt.Errorf("No domains were found.")
-  }
-  return &domains[0], nil
-}
-
-func createDomainLaunchParameters(domain *Domain, parameterSet map[string]string) []*cloudformation.Parameter {
-  params := []*cloudformation.Parameter{}
-  for key, value := range parameterSet {
-    var val string
-    if strings.HasPrefix(value, "%") && strings.HasSuffix(value, "%") {
-      configKey := strings.ToLower(strings.Replace(value[1:len(value)-1], "_", "-", -1))
-      if viper.IsSet(configKey) {
-        val = viper.GetString(configKey)
-      } else {
-        val = "%NULL%"
-      }
-    } else {
-      val = value
-    }
+  return DefaultClient().DefaultDomain()
+}
 
-    if val != "%NULL%" {
-      // fmt.Println(key + " -> " + val)
-      params = append(params, &cloudformation.Parameter{
-        ParameterKey: aws.String(key),
-        ParameterValue: aws.String(val),
-      })
+func (c *Client) DefaultDomain() (*Domain, error) {
+  domains, err := c.AllDomains()
+  if err != nil { return nil, err }
+  for i := range domains {
+    if isRunningStack(domains[i].Stack) {
+      return &domains[i], nil
     }
   }
-  return params
+  return nil, fmt.Errorf("No domains were found.")
 }
 
-func resourceCountFor(params map[string]string) int {
+func resourceCountFor(config *Configuration, params map[string]string) int {
   resourceCount := DomainResourceCount
 
   peerVpc := params["PeerVPC"]
   if peerVpc == "%PEER_VPC%" {
-    peerVpc = viper.GetString("peer-vpc")
+    peerVpc = config.Settings["peer-vpc"]
   }
   if peerVpc != "" {
     resourceCount += DomainPeeringResourceCount
     peerVpcRouteTable := params["PeerVPCRouteTable"]
     if peerVpcRouteTable == "%PEER_VPCROUTE_TABLE%" {
-      peerVpcRouteTable = viper.GetString("peer-vpc-route-table")
+      peerVpcRouteTable = config.Settings["peer-vpc-route-table"]
     }
     if peerVpcRouteTable != "" {
       resourceCount += DomainPeerRoutesResourceCount
@@ -418,7 +437,7 @@ func resourceCountFor(params map[string]string) int {
 
   allowInternet := params["AllowInternetAccess"]
   if allowInternet == "%ALLOW_INTERNET_ACCCESS%" {
-    allowInternet = viper.GetString("allow-internet-access")
+    allowInternet = config.Settings["allow-internet-access"]
   }
   if allowInternet == "0" {
     resourceCount -= DomainInternetAccessResourceCount
@@ -427,7 +446,7 @@ func resourceCountFor(params map[string]string) int {
 
   vpnGateway := params["VPNCustomerGateway"]
   if vpnGateway == "%VPN_CUSTOMER_GATEWAY%" {
-    vpnGateway = viper.GetString("vpn-customer-gateway")
+    vpnGateway = config.Settings["vpn-customer-gateway"]
   }
   if vpnGateway != "" {
     resourceCount += DomainVPNResourceCount
@@ -436,10 +455,10 @@ func resourceCountFor(params map[string]string) int {
   return resourceCount
 }
 
-func getVPNDetails(vpnConnectionId string) (VPNConnectionDetails, error) {
+func (c *Client) getVPNDetails(vpnConnectionId string) (VPNConnectionDetails, error) {
   var details VPNConnectionDetails
   var xmlData XMLVPNConnection
-  xmlStr, err := describeVPNConnection(vpnConnectionId)
+  xmlStr, err := c.describeVPNConnection(vpnConnectionId)
   if err != nil { return details, err }
   xml.Unmarshal([]byte(*xmlStr), &xmlData)
   if len(xmlData.Tunnels) == 0 { return details, fmt.Errorf("Unable to parse VPN connection data") }
@@ -0,0 +1,71 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "context"
+  "testing"
+)
+
+func TestDomainStatus(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  createTestCluster(t, d, "c1", true)
+  createTestDomain(t, c, "d2")
+
+  status, err := c.NewDomain("d1", nil).Status(context.Background())
+  if err != nil { t.Fatalf("Status: %s", err) }
+  if len(status.Clusters) != 1 {
+    t.Fatalf("%d clusters, want 1", len(status.Clusters))
+  }
+  cluster := status.Clusters["c1"]
+  if cluster == nil {
+    t.Fatalf("cluster c1 missing from status")
+  }
+  if cluster.Master == nil || cluster.Network == nil {
+    t.Errorf("cluster c1 is missing its master or network stack")
+  }
+  if len(cluster.ComputeGroups) != 1 {
+    t.Errorf("%d compute groups, want 1", len(cluster.ComputeGroups))
+  }
+
+  status, err = c.NewDomain("d2", nil).Status(context.Background())
+  if err != nil { t.Fatalf("Status: %s", err) }
+  if len(status.Clusters) != 0 {
+    t.Errorf("%d clusters in d2, want 0", len(status.Clusters))
+  }
+}
+
+func TestDomainStatusMissing(t *testing.T) {
+  c, _ := newTestClient(t)
+  if _, err := c.NewDomain("nope", nil).Status(context.Background()); err == nil {
+    t.Errorf("expected an error for a domain that doesn't exist")
+  }
+}
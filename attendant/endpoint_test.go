@@ -0,0 +1,97 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+func TestServiceConfigEndpoint(t *testing.T) {
+  config := defaultConfiguration()
+  config.Endpoints = map[string]string{"s3": "http://localhost:4566"}
+  c, err := NewClient(WithConfig(*config), WithCredentials("key", "secret"))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  // the client keeps its own copy of the endpoints
+  config.Endpoints["s3"] = "http://elsewhere"
+
+  if endpoint := c.serviceConfig("s3").Endpoint; endpoint == nil || *endpoint != "http://localhost:4566" {
+    t.Errorf("s3 endpoint = %v, want http://localhost:4566", endpoint)
+  }
+  if endpoint := c.serviceConfig("sts").Endpoint; endpoint != nil {
+    t.Errorf("sts endpoint = %s, want the default", *endpoint)
+  }
+  svc, err := c.S3()
+  if err != nil { t.Fatalf("S3: %s", err) }
+  if !*svc.Client.Config.S3ForcePathStyle {
+    t.Errorf("s3 with an endpoint override should use path-style addressing")
+  }
+}
+
+func TestEndpointOverrideIsUsed(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    r.ParseForm()
+    w.Header().Set("Content-Type", "text/xml")
+    switch r.Form.Get("Action") {
+    case "GetCallerIdentity":
+      fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult><Arn>arn:aws:iam::123456789012:user/test</Arn><UserId>X</UserId><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`)
+    case "ListAccountAliases":
+      fmt.Fprint(w, `<ListAccountAliasesResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/"><ListAccountAliasesResult><IsTruncated>false</IsTruncated><AccountAliases><member>local</member></AccountAliases></ListAccountAliasesResult></ListAccountAliasesResponse>`)
+    default:
+      w.WriteHeader(http.StatusBadRequest)
+    }
+  }))
+  defer server.Close()
+
+  config := defaultConfiguration()
+  config.AwsRegion = "local-1"
+  config.Endpoints = map[string]string{"sts": server.URL, "iam": server.URL}
+  c, err := NewClient(WithConfig(*config), WithCredentials("key", "secret"))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  account, err := c.Account()
+  if err != nil { t.Fatalf("Account: %s", err) }
+  if account.Id != "123456789012" || account.Alias != "local" {
+    t.Errorf("account = %+v", account)
+  }
+}
+
+func TestPreflightCheckRegion(t *testing.T) {
+  c, _ := newTestClient(t)
+  c.config.AwsRegion = "local-1"
+  if err := c.PreflightCheck(); err == nil || !strings.Contains(err.Error(), "Bad region") {
+    t.Errorf("expected a bad region without endpoint overrides, got %v", err)
+  }
+  c.config.Endpoints = map[string]string{"cloudformation": "http://localhost:4566"}
+  if err := c.PreflightCheck(); err != nil {
+    t.Errorf("PreflightCheck with endpoint overrides: %s", err)
+  }
+}
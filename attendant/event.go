@@ -0,0 +1,164 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/go-ini/ini"
+)
+
+// EventKind identifies what an Event reports.
+type EventKind string
+
+const (
+  // ResourceEvent reports a change in the status of a stack resource.
+  ResourceEvent EventKind = "resource"
+  // DoneEvent marks the end of the stack operations being reported.
+  DoneEvent EventKind = "done"
+  // CountersEvent gives the number of resources expected, in Count.
+  CountersEvent EventKind = "counters"
+  // DisableCountersEvent and EnableCountersEvent bracket resources
+  // that shouldn't be counted towards the expected total.
+  DisableCountersEvent EventKind = "disable-counters"
+  EnableCountersEvent EventKind = "enable-counters"
+  // NoticeEvent carries a message for the user, in Message.
+  NoticeEvent EventKind = "notice"
+)
+
+// Event is a progress report from a stack operation.
+type Event struct {
+  Kind EventKind `json:"Kind" yaml:"Kind"`
+  Stack string `json:"Stack,omitempty" yaml:"Stack,omitempty"`
+  LogicalId string `json:"LogicalId,omitempty" yaml:"LogicalId,omitempty"`
+  PhysicalId string `json:"PhysicalId,omitempty" yaml:"PhysicalId,omitempty"`
+  ResourceType string `json:"ResourceType,omitempty" yaml:"ResourceType,omitempty"`
+  Status string `json:"Status,omitempty" yaml:"Status,omitempty"`
+  Reason string `json:"Reason,omitempty" yaml:"Reason,omitempty"`
+  Count int `json:"Count,omitempty" yaml:"Count,omitempty"`
+  Message string `json:"Message,omitempty" yaml:"Message,omitempty"`
+  Timestamp time.Time `json:"Timestamp" yaml:"Timestamp"`
+}
+
+// EventHandler observes the events of a stack operation.  Clusters,
+// domains and appliances stop polling for events once their handler is
+// set to nil.
+type EventHandler func(event *Event)
+
+// ChannelHandler delivers events to a channel.
+func ChannelHandler(ch chan<- *Event) EventHandler {
+  return func(event *Event) { ch <- event }
+}
+
+// DiscardEvents is a handler that ignores all events.
+func DiscardEvents(event *Event) {}
+
+func newEvent(kind EventKind) *Event {
+  return &Event{Kind: kind, Timestamp: time.Now().UTC()}
+}
+
+// NewDoneEvent marks the end of an operation that reports its own
+// progress.
+func NewDoneEvent() *Event {
+  return newEvent(DoneEvent)
+}
+
+func countersEvent(count int) *Event {
+  event := newEvent(CountersEvent)
+  event.Count = count
+  return event
+}
+
+func noticeEvent(message string) *Event {
+  event := newEvent(NoticeEvent)
+  event.Message = message
+  return event
+}
+
+// String describes the event in a line of text.  Resource events are
+// prefixed with their stack unless they report the stack itself.
+func (e *Event) String() string {
+  switch e.Kind {
+  case ResourceEvent:
+    s := fmt.Sprintf("%s %s (%s)", e.Status, e.LogicalId, e.PhysicalId)
+    if e.Stack != "" && e.Stack != e.LogicalId { s = e.Stack + ": " + s }
+    if e.Reason != "" { s += ": " + e.Reason }
+    return s
+  case DoneEvent:
+    return "Done."
+  case CountersEvent:
+    return fmt.Sprintf("Expecting %d resources.", e.Count)
+  case DisableCountersEvent:
+    return "Resources that follow are not counted."
+  case EnableCountersEvent:
+    return "Resources that follow are counted."
+  case NoticeEvent:
+    return "Notice: " + e.Message
+  }
+  return string(e.Kind)
+}
+
+// NewStackEvent reports a change in the status of a whole stack, for
+// operations that track stacks rather than their resources.
+func NewStackEvent(status, stackName string) *Event {
+  event := newEvent(ResourceEvent)
+  event.Stack = stackName
+  event.LogicalId = stackName
+  event.PhysicalId = stackName
+  event.ResourceType = "AWS::CloudFormation::Stack"
+  event.Status = status
+  return event
+}
+
+// notificationEvent converts a CloudFormation stack notification into
+// an event.  Notifications for resources without a physical ID yet are
+// skipped.
+func notificationEvent(section *ini.Section) *Event {
+  value := func(key string) string {
+    if section.HasKey(key) {
+      return section.Key(key).String()
+    }
+    return ""
+  }
+  if value("PhysicalResourceId") == "" || value("LogicalResourceId") == "" || value("ResourceStatus") == "" {
+    return nil
+  }
+  event := newEvent(ResourceEvent)
+  event.Stack = value("StackName")
+  event.LogicalId = value("LogicalResourceId")
+  event.PhysicalId = value("PhysicalResourceId")
+  event.ResourceType = value("ResourceType")
+  event.Status = value("ResourceStatus")
+  event.Reason = value("ResourceStatusReason")
+  if t, err := time.Parse(time.RFC3339, value("Timestamp")); err == nil {
+    event.Timestamp = t
+  }
+  return event
+}
@@ -0,0 +1,105 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "encoding/json"
+  "strings"
+  "testing"
+
+  "github.com/go-ini/ini"
+)
+
+func testResourceEvent() *Event {
+  event := newEvent(ResourceEvent)
+  event.Stack = "flight-d1"
+  event.LogicalId = "FlightVPC"
+  event.PhysicalId = "vpc-1"
+  event.Status = "CREATE_FAILED"
+  event.Reason = "Limit exceeded"
+  return event
+}
+
+func TestEventString(t *testing.T) {
+  if s := testResourceEvent().String(); s != "flight-d1: CREATE_FAILED FlightVPC (vpc-1): Limit exceeded" {
+    t.Errorf("resource event = %q", s)
+  }
+  if s := NewStackEvent("CREATE_COMPLETE", "flight-d1").String(); s != "CREATE_COMPLETE flight-d1 (flight-d1)" {
+    t.Errorf("stack event = %q", s)
+  }
+  if s := noticeEvent("Capped.").String(); s != "Notice: Capped." {
+    t.Errorf("notice = %q", s)
+  }
+}
+
+func TestNotificationEvent(t *testing.T) {
+  cfg, err := ini.Load([]byte("StackName='flight-d1'\nLogicalResourceId='FlightVPC'\nPhysicalResourceId='vpc-1'\nResourceType='AWS::EC2::VPC'\nResourceStatus='CREATE_COMPLETE'\nTimestamp='2017-06-01T10:00:00.000Z'\n"))
+  if err != nil { t.Fatalf("ini.Load: %s", err) }
+  event := notificationEvent(cfg.Section(""))
+  if event == nil { t.Fatalf("notification was skipped") }
+  if event.Kind != ResourceEvent || event.Stack != "flight-d1" || event.LogicalId != "FlightVPC" || event.Status != "CREATE_COMPLETE" {
+    t.Errorf("event = %+v", event)
+  }
+  if event.Timestamp.Year() != 2017 {
+    t.Errorf("timestamp = %s, want the notification's", event.Timestamp)
+  }
+
+  // resources without a physical id yet aren't reported
+  cfg, _ = ini.Load([]byte("LogicalResourceId='FlightVPC'\nResourceStatus='CREATE_IN_PROGRESS'\n"))
+  if event := notificationEvent(cfg.Section("")); event != nil {
+    t.Errorf("expected a notification without a physical id to be skipped, got %+v", event)
+  }
+}
+
+func TestPlainRenderer(t *testing.T) {
+  var buf bytes.Buffer
+  render := PlainRenderer(&buf)
+  render(countersEvent(3))
+  render(testResourceEvent())
+  render(NewDoneEvent())
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if len(lines) != 2 || lines[1] != "Done." {
+    t.Errorf("plain output = %q, want the resource and done lines only", buf.String())
+  }
+}
+
+func TestJSONRenderer(t *testing.T) {
+  var buf bytes.Buffer
+  render := JSONRenderer(&buf)
+  render(testResourceEvent())
+  render(NewDoneEvent())
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if len(lines) != 2 { t.Fatalf("json output = %q, want a line per event", buf.String()) }
+  var event Event
+  if err := json.Unmarshal([]byte(lines[0]), &event); err != nil { t.Fatalf("Unmarshal: %s", err) }
+  if event.Kind != ResourceEvent || event.Reason != "Limit exceeded" {
+    t.Errorf("decoded event = %+v", event)
+  }
+}
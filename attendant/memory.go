@@ -0,0 +1,1472 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/aws/aws-sdk-go/aws/request"
+  "github.com/aws/aws-sdk-go/service/autoscaling"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+  "github.com/aws/aws-sdk-go/service/ec2"
+  "github.com/aws/aws-sdk-go/service/sns"
+  "github.com/aws/aws-sdk-go/service/sqs"
+)
+
+var memoryWaitAttempts = 1000
+
+var memoryStackResources = map[string][][2]string{
+  "domain": {
+    {"FlightVPC", "AWS::EC2::VPC"},
+    {"InternetGateway", "AWS::EC2::InternetGateway"},
+    {"PubRouteTable", "AWS::EC2::RouteTable"},
+    {"PubSubnet", "AWS::EC2::Subnet"},
+    {"MgtSubnet", "AWS::EC2::Subnet"},
+    {"PrvSubnet", "AWS::EC2::Subnet"},
+    {"PlacementGroup", "AWS::EC2::PlacementGroup"},
+  },
+  "network": {
+    {"PubSubnet", "AWS::EC2::Subnet"},
+    {"MgtSubnet", "AWS::EC2::Subnet"},
+    {"PrvSubnet", "AWS::EC2::Subnet"},
+    {"PlacementGroup", "AWS::EC2::PlacementGroup"},
+  },
+  "master": {
+    {"MasterSecurityGroup", "AWS::EC2::SecurityGroup"},
+    {"Master", "AWS::EC2::Instance"},
+    {"MasterEIP", "AWS::EC2::EIP"},
+  },
+  "compute": {
+    {"ComputeLaunchConfig", "AWS::AutoScaling::LaunchConfiguration"},
+    {"ComputeGroup", "AWS::AutoScaling::AutoScalingGroup"},
+  },
+  "solo": {
+    {"FlightVPC", "AWS::EC2::VPC"},
+    {"PubSubnet", "AWS::EC2::Subnet"},
+    {"MasterSecurityGroup", "AWS::EC2::SecurityGroup"},
+    {"Master", "AWS::EC2::Instance"},
+    {"MasterEIP", "AWS::EC2::EIP"},
+    {"ComputeLaunchConfig", "AWS::AutoScaling::LaunchConfiguration"},
+    {"ComputeGroup", "AWS::AutoScaling::AutoScalingGroup"},
+  },
+  "appliance": {
+    {"ApplianceSecurityGroup", "AWS::EC2::SecurityGroup"},
+    {"Appliance", "AWS::EC2::Instance"},
+  },
+  "component": {
+    {"Component", "AWS::CloudFormation::WaitConditionHandle"},
+  },
+}
+
+var memoryApplianceOutputPrefixes = map[string]string{
+  "controller": "Controller",
+  "directory": "Directory",
+  "monitor": "Monitor",
+  "storage-manager": "StorageManager",
+  "access-manager": "AccessManager",
+}
+
+// MemoryProvider is a Provider that keeps stacks, event topics and
+// queues, autoscaling groups and entities in memory.  Stack creation
+// and deletion emit the same notifications as CloudFormation and
+// produce the outputs that the Flight templates would.
+type MemoryProvider struct {
+  Region string
+  AccountId string
+  // Key pairs that exist; when empty, any key pair name is accepted.
+  KeyPairs []string
+  // Delay between simulated resource events.  When zero, stack
+  // operations complete before CreateStack/DeleteStack return.
+  Latency time.Duration
+  // When set, state is loaded from and saved to this file.
+  StateFile string
+  // Resources that fail to create or update, as "LogicalId" or
+  // "StackName/LogicalId", mapped to the reason reported.
+  Failures map[string]string
+
+  mutex sync.Mutex
+  state memoryState
+  saved []byte
+}
+
+type memoryState struct {
+  Counter int
+  Stacks []*memoryStack
+  Topics map[string]*memoryTopic
+  Queues map[string]*memoryQueue
+  Groups map[string]*autoscaling.Group
+  Domains map[string]*DomainEntity
+  Clusters map[string]*ClusterEntity
+  Operations map[string]*OperationEntity
+}
+
+type memoryStack struct {
+  Stack *cloudformation.Stack
+  Resources []*cloudformation.StackResourceSummary
+  // Stack events, most recent first.
+  Events []*cloudformation.StackEvent
+  ChangeSets []*memoryChangeSet
+  // The change set being executed while the stack is updating.
+  Update *memoryChangeSet
+}
+
+type memoryChangeSet struct {
+  Id string
+  Name string
+  Status string
+  StatusReason string
+  ExecutionStatus string
+  Parameters []*cloudformation.Parameter
+  Tags []*cloudformation.Tag
+  Changes []*cloudformation.Change
+  CreationTime time.Time
+}
+
+type memoryTopic struct {
+  Arn string
+  Subscriptions []*sns.Subscription
+}
+
+type memoryQueue struct {
+  Url string
+  Arn string
+  Attributes map[string]*string
+  Messages []*sqs.Message
+}
+
+func NewMemoryProvider(region string) *MemoryProvider {
+  return &MemoryProvider{
+    Region: region,
+    AccountId: "000000000000",
+    KeyPairs: []string{"flight-admin"},
+    state: newMemoryState(),
+  }
+}
+
+func newMemoryState() memoryState {
+  return memoryState{
+    Topics: make(map[string]*memoryTopic),
+    Queues: make(map[string]*memoryQueue),
+    Groups: make(map[string]*autoscaling.Group),
+    Domains: make(map[string]*DomainEntity),
+    Clusters: make(map[string]*ClusterEntity),
+    Operations: make(map[string]*OperationEntity),
+  }
+}
+
+// Load reads state previously saved to StateFile and resumes any stack
+// operations that were in progress when it was saved.
+func (p *MemoryProvider) Load() error {
+  data, err := ioutil.ReadFile(p.StateFile)
+  if os.IsNotExist(err) { return nil }
+  if err != nil { return err }
+  state := newMemoryState()
+  err = json.Unmarshal(data, &state)
+  if err != nil { return fmt.Errorf("Unable to load state from %s: %s", p.StateFile, err.Error()) }
+  p.lock()
+  p.state = state
+  p.saved = data
+  p.unlock()
+  for _, ms := range state.Stacks {
+    ms := ms
+    switch *ms.Stack.StackStatus {
+    case "CREATE_IN_PROGRESS":
+      p.run(func() { p.completeCreate(ms) })
+    case "DELETE_IN_PROGRESS":
+      p.run(func() { p.completeDelete(ms) })
+    case "UPDATE_IN_PROGRESS":
+      p.run(func() { p.completeUpdate(ms) })
+    }
+  }
+  return nil
+}
+
+func (p *MemoryProvider) lock() {
+  p.mutex.Lock()
+}
+
+func (p *MemoryProvider) unlock() {
+  if p.StateFile != "" {
+    p.save()
+  }
+  p.mutex.Unlock()
+}
+
+func (p *MemoryProvider) save() {
+  data, err := json.MarshalIndent(p.state, "", "  ")
+  if err != nil || bytes.Equal(data, p.saved) { return }
+  tmp := p.StateFile + ".tmp"
+  err = ioutil.WriteFile(tmp, data, 0600)
+  if err != nil { return }
+  if os.Rename(tmp, p.StateFile) == nil {
+    p.saved = data
+  }
+}
+
+func (p *MemoryProvider) Stacks(c *Client) (StackService, error) {
+  return p, nil
+}
+
+func (p *MemoryProvider) Network(c *Client) (NetworkService, error) {
+  return p, nil
+}
+
+func (p *MemoryProvider) Events(c *Client) (EventBus, error) {
+  return p, nil
+}
+
+func (p *MemoryProvider) Autoscaling(c *Client) (AutoscalingService, error) {
+  return p, nil
+}
+
+func (p *MemoryProvider) State(c *Client) (StateStore, error) {
+  return p, nil
+}
+
+func (p *MemoryProvider) Account(c *Client) (*Account, error) {
+  return &Account{Id: p.AccountId, Arn: "arn:aws:iam::" + p.AccountId + ":root"}, nil
+}
+
+func (p *MemoryProvider) nextId() string {
+  p.state.Counter += 1
+  return fmt.Sprintf("%08x", p.state.Counter)
+}
+
+func (p *MemoryProvider) run(fn func()) {
+  if p.Latency == 0 {
+    fn()
+  } else {
+    go fn()
+  }
+}
+
+func (p *MemoryProvider) pause() {
+  if p.Latency > 0 {
+    time.Sleep(p.Latency)
+  }
+}
+
+func (p *MemoryProvider) pollInterval() time.Duration {
+  if p.Latency > 0 {
+    return p.Latency / 2
+  }
+  return time.Millisecond
+}
+
+// poll waits for the poll interval, returning early if ctx is
+// cancelled.
+func (p *MemoryProvider) poll(ctx aws.Context) error {
+  select {
+  case <-time.After(p.pollInterval()):
+    return nil
+  case <-ctx.Done():
+    return awserr.New(request.CanceledErrorCode, "waiter context canceled", ctx.Err())
+  }
+}
+
+// findStack locates a live stack by name, or any stack by ID.
+func (p *MemoryProvider) findStack(nameOrId string) *memoryStack {
+  for _, s := range p.state.Stacks {
+    if *s.Stack.StackId == nameOrId {
+      return s
+    }
+    if *s.Stack.StackName == nameOrId && *s.Stack.StackStatus != "DELETE_COMPLETE" {
+      return s
+    }
+  }
+  return nil
+}
+
+func stackNotFound(name string) error {
+  return awserr.New("ValidationError", fmt.Sprintf("Stack with id %s does not exist", name), nil)
+}
+
+func copyStack(stack *cloudformation.Stack) *cloudformation.Stack {
+  c := *stack
+  return &c
+}
+
+func (p *MemoryProvider) CreateStack(input *cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error) {
+  p.lock()
+  if p.findStack(*input.StackName) != nil {
+    p.unlock()
+    return nil, awserr.New("AlreadyExistsException", fmt.Sprintf("Stack [%s] already exists", *input.StackName), nil)
+  }
+  stack := &cloudformation.Stack{
+    StackId: aws.String(fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%s", p.Region, p.AccountId, *input.StackName, p.nextId())),
+    StackName: input.StackName,
+    Parameters: input.Parameters,
+    Tags: input.Tags,
+    NotificationARNs: input.NotificationARNs,
+    Capabilities: input.Capabilities,
+    CreationTime: aws.Time(time.Now()),
+    StackStatus: aws.String("CREATE_IN_PROGRESS"),
+  }
+  ms := &memoryStack{Stack: stack}
+  for _, res := range memoryStackResources[getStackTag(stack, "flight:type")] {
+    ms.Resources = append(ms.Resources, &cloudformation.StackResourceSummary{
+      LogicalResourceId: aws.String(res[0]),
+      PhysicalResourceId: aws.String(p.physicalIdFor(*stack.StackName, res[0], res[1])),
+      ResourceType: aws.String(res[1]),
+      ResourceStatus: aws.String("CREATE_IN_PROGRESS"),
+      LastUpdatedTimestamp: aws.Time(time.Now()),
+    })
+  }
+  p.state.Stacks = append(p.state.Stacks, ms)
+  p.unlock()
+
+  p.run(func() { p.completeCreate(ms) })
+  return &cloudformation.CreateStackOutput{StackId: stack.StackId}, nil
+}
+
+func (p *MemoryProvider) completeCreate(ms *memoryStack) {
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "CREATE_IN_PROGRESS", "User Initiated")
+  for i, res := range ms.Resources {
+    if *res.ResourceStatus == "CREATE_COMPLETE" { continue }
+    p.pause()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "CREATE_IN_PROGRESS", "Resource creation Initiated")
+    p.pause()
+    if reason, fails := p.failureFor(ms, *res.LogicalResourceId); fails {
+      p.rollbackCreate(ms, i, reason)
+      return
+    }
+    p.lock()
+    if *ms.Stack.StackStatus != "CREATE_IN_PROGRESS" {
+      // deleted while being created
+      p.unlock()
+      return
+    }
+    res.ResourceStatus = aws.String("CREATE_COMPLETE")
+    res.LastUpdatedTimestamp = aws.Time(time.Now())
+    p.unlock()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "CREATE_COMPLETE", "")
+  }
+  p.lock()
+  if *ms.Stack.StackStatus != "CREATE_IN_PROGRESS" {
+    p.unlock()
+    return
+  }
+  ms.Stack.Outputs = p.outputsFor(ms)
+  for _, res := range ms.Resources {
+    if *res.ResourceType == "AWS::AutoScaling::AutoScalingGroup" {
+      p.state.Groups[*res.PhysicalResourceId] = p.groupFor(ms.Stack, *res.PhysicalResourceId)
+    }
+  }
+  ms.Stack.StackStatus = aws.String("CREATE_COMPLETE")
+  p.unlock()
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "CREATE_COMPLETE", "")
+}
+
+func (p *MemoryProvider) failureFor(ms *memoryStack, logicalId string) (string, bool) {
+  if reason, exists := p.Failures[*ms.Stack.StackName + "/" + logicalId]; exists {
+    return reason, true
+  }
+  reason, exists := p.Failures[logicalId]
+  return reason, exists
+}
+
+// rollbackCreate fails the resource at index failed and deletes those
+// created before it, leaving the stack in ROLLBACK_COMPLETE as
+// CloudFormation does.
+func (p *MemoryProvider) rollbackCreate(ms *memoryStack, failed int, reason string) {
+  res := ms.Resources[failed]
+  p.lock()
+  // resources after the failed one were never started
+  for _, later := range ms.Resources[failed+1:] {
+    later.ResourceStatus = aws.String("DELETE_COMPLETE")
+  }
+  res.ResourceStatus = aws.String("CREATE_FAILED")
+  res.ResourceStatusReason = aws.String(reason)
+  ms.Stack.StackStatus = aws.String("ROLLBACK_IN_PROGRESS")
+  ms.Stack.StackStatusReason = aws.String(fmt.Sprintf("The following resource(s) failed to create: [%s]. Rollback requested by user.", *res.LogicalResourceId))
+  p.unlock()
+  p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "CREATE_FAILED", reason)
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "ROLLBACK_IN_PROGRESS", *ms.Stack.StackStatusReason)
+  for i := failed; i >= 0; i-- {
+    res := ms.Resources[i]
+    p.pause()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "DELETE_IN_PROGRESS", "")
+    p.pause()
+    p.lock()
+    res.ResourceStatus = aws.String("DELETE_COMPLETE")
+    res.LastUpdatedTimestamp = aws.Time(time.Now())
+    p.unlock()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "DELETE_COMPLETE", "")
+  }
+  p.lock()
+  ms.Stack.StackStatus = aws.String("ROLLBACK_COMPLETE")
+  p.unlock()
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "ROLLBACK_COMPLETE", "")
+}
+
+func (p *MemoryProvider) physicalIdFor(stackName, logicalId, resourceType string) string {
+  switch resourceType {
+  case "AWS::EC2::VPC":
+    return "vpc-" + p.nextId()
+  case "AWS::EC2::Subnet":
+    return "subnet-" + p.nextId()
+  case "AWS::EC2::SecurityGroup":
+    return "sg-" + p.nextId()
+  case "AWS::EC2::Instance":
+    return "i-" + p.nextId()
+  case "AWS::EC2::RouteTable":
+    return "rtb-" + p.nextId()
+  case "AWS::EC2::InternetGateway":
+    return "igw-" + p.nextId()
+  case "AWS::EC2::EIP":
+    return p.nextIp("54.211")
+  default:
+    return fmt.Sprintf("%s-%s-%s", stackName, logicalId, strings.ToUpper(p.nextId()))
+  }
+}
+
+func (p *MemoryProvider) nextIp(prefix string) string {
+  p.state.Counter += 1
+  n := p.state.Counter
+  return fmt.Sprintf("%s.%d.%d", prefix, (n >> 8) & 0xff, (n & 0xfe) + 1)
+}
+
+func (ms *memoryStack) resource(logicalId string) string {
+  for _, res := range ms.Resources {
+    if *res.LogicalResourceId == logicalId {
+      return *res.PhysicalResourceId
+    }
+  }
+  return ""
+}
+
+func (p *MemoryProvider) outputsFor(ms *memoryStack) []*cloudformation.Output {
+  outputs := make(map[string]string)
+  stack := ms.Stack
+  switch getStackTag(stack, "flight:type") {
+  case "domain":
+    for _, key := range []string{"FlightVPC", "PubSubnet", "MgtSubnet", "PrvSubnet", "PlacementGroup", "PubRouteTable"} {
+      outputs[key] = ms.resource(key)
+    }
+    if getStackParameter(stack, "VPNCustomerGateway") != "" {
+      outputs["VpnConnection"] = "vpn-" + p.nextId()
+    }
+  case "network":
+    for _, key := range []string{"PubSubnet", "MgtSubnet", "PrvSubnet", "PlacementGroup"} {
+      outputs[key] = ms.resource(key)
+    }
+  case "master", "solo":
+    username := getStackParameter(stack, "AccessUsername")
+    if username == "" { username = "alces" }
+    outputs["AccessIP"] = ms.resource("MasterEIP")
+    outputs["MasterPrivateIP"] = p.nextIp("10.75")
+    outputs["Username"] = username
+    outputs["WebAccess"] = "https://" + outputs["AccessIP"]
+    outputs["ConfigurationResult"] = fmt.Sprintf("{\"Data\":\"UUID:%s-0000-4000-8000-%s;Token:%s\"}", p.nextId(), p.nextId() + p.nextId()[4:], p.nextId())
+  case "appliance":
+    prefix := memoryApplianceOutputPrefixes[getStackTag(stack, "flight:appliance")]
+    if prefix != "" {
+      ip := p.nextIp("54.211")
+      outputs[prefix + "AccessIP"] = ip
+      outputs[prefix + "WebAccess"] = "https://" + ip
+      outputs[prefix + "PrivateIP"] = p.nextIp("10.75")
+      outputs["ConfigurationResult"] = "{\"Data\":\"\"}"
+    }
+  }
+
+  keys := []string{}
+  for key, _ := range outputs {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  result := []*cloudformation.Output{}
+  for _, key := range keys {
+    result = append(result, &cloudformation.Output{OutputKey: aws.String(key), OutputValue: aws.String(outputs[key])})
+  }
+  return result
+}
+
+func (p *MemoryProvider) groupFor(stack *cloudformation.Stack, name string) *autoscaling.Group {
+  maxSize, err := strconv.ParseInt(getStackParameter(stack, "ComputeMaxNodes"), 10, 64)
+  if err != nil { maxSize = 8 }
+  desired, err := strconv.ParseInt(getStackParameter(stack, "ComputeInitialNodes"), 10, 64)
+  if err != nil { desired = 1 }
+  if desired > maxSize { desired = maxSize }
+  instanceType := getStackParameter(stack, "ComputeInstanceType")
+  if instanceType == "other" {
+    instanceType = getStackParameter(stack, "ComputeInstanceTypeOther")
+  }
+  group := &autoscaling.Group{
+    AutoScalingGroupName: aws.String(name),
+    MinSize: aws.Int64(0),
+    MaxSize: aws.Int64(maxSize),
+    DesiredCapacity: aws.Int64(desired),
+    CreatedTime: aws.Time(time.Now()),
+  }
+  for i := int64(0); i < desired; i++ {
+    group.Instances = append(group.Instances, &autoscaling.Instance{
+      InstanceId: aws.String("i-" + p.nextId()),
+      InstanceType: aws.String(instanceType),
+      LifecycleState: aws.String("InService"),
+      HealthStatus: aws.String("Healthy"),
+    })
+  }
+  return group
+}
+
+func (p *MemoryProvider) DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+  p.lock()
+  ms := p.findStack(*input.StackName)
+  if ms == nil || *ms.Stack.StackStatus == "DELETE_COMPLETE" || *ms.Stack.StackStatus == "DELETE_IN_PROGRESS" {
+    p.unlock()
+    return &cloudformation.DeleteStackOutput{}, nil
+  }
+  ms.Stack.StackStatus = aws.String("DELETE_IN_PROGRESS")
+  p.unlock()
+
+  p.run(func() { p.completeDelete(ms) })
+  return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (p *MemoryProvider) completeDelete(ms *memoryStack) {
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "DELETE_IN_PROGRESS", "User Initiated")
+  for i := len(ms.Resources) - 1; i >= 0; i-- {
+    res := ms.Resources[i]
+    if *res.ResourceStatus == "DELETE_COMPLETE" { continue }
+    p.pause()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "DELETE_IN_PROGRESS", "")
+    p.pause()
+    p.lock()
+    res.ResourceStatus = aws.String("DELETE_COMPLETE")
+    res.LastUpdatedTimestamp = aws.Time(time.Now())
+    delete(p.state.Groups, *res.PhysicalResourceId)
+    p.unlock()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "DELETE_COMPLETE", "")
+  }
+  p.lock()
+  ms.Stack.StackStatus = aws.String("DELETE_COMPLETE")
+  ms.Stack.DeletionTime = aws.Time(time.Now())
+  p.unlock()
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "DELETE_COMPLETE", "")
+}
+
+func (p *MemoryProvider) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+  p.lock()
+  defer p.unlock()
+  stacks := []*cloudformation.Stack{}
+  if input.StackName != nil {
+    ms := p.findStack(*input.StackName)
+    if ms == nil { return nil, stackNotFound(*input.StackName) }
+    stacks = append(stacks, copyStack(ms.Stack))
+  } else {
+    for _, ms := range p.state.Stacks {
+      if *ms.Stack.StackStatus != "DELETE_COMPLETE" {
+        stacks = append(stacks, copyStack(ms.Stack))
+      }
+    }
+  }
+  return &cloudformation.DescribeStacksOutput{Stacks: stacks}, nil
+}
+
+func (p *MemoryProvider) ListStacks(input *cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error) {
+  p.lock()
+  defer p.unlock()
+  filter := aws.StringValueSlice(input.StackStatusFilter)
+  summaries := []*cloudformation.StackSummary{}
+  for _, ms := range p.state.Stacks {
+    if len(filter) == 0 || containsS(filter, *ms.Stack.StackStatus) {
+      summaries = append(summaries, &cloudformation.StackSummary{
+        StackId: ms.Stack.StackId,
+        StackName: ms.Stack.StackName,
+        StackStatus: ms.Stack.StackStatus,
+        CreationTime: ms.Stack.CreationTime,
+        DeletionTime: ms.Stack.DeletionTime,
+      })
+    }
+  }
+  return &cloudformation.ListStacksOutput{StackSummaries: summaries}, nil
+}
+
+func (p *MemoryProvider) ListStackResources(input *cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(*input.StackName)
+  if ms == nil { return nil, stackNotFound(*input.StackName) }
+  resources := []*cloudformation.StackResourceSummary{}
+  for _, res := range ms.Resources {
+    c := *res
+    resources = append(resources, &c)
+  }
+  return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: resources}, nil
+}
+
+func (p *MemoryProvider) DescribeStackEvents(input *cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(*input.StackName)
+  if ms == nil { return nil, stackNotFound(*input.StackName) }
+  events := []*cloudformation.StackEvent{}
+  for _, event := range ms.Events {
+    c := *event
+    events = append(events, &c)
+  }
+  return &cloudformation.DescribeStackEventsOutput{StackEvents: events}, nil
+}
+
+func (p *MemoryProvider) DescribeAccountLimits(input *cloudformation.DescribeAccountLimitsInput) (*cloudformation.DescribeAccountLimitsOutput, error) {
+  return &cloudformation.DescribeAccountLimitsOutput{
+    AccountLimits: []*cloudformation.AccountLimit{
+      {Name: aws.String("StackLimit"), Value: aws.Int64(200)},
+    },
+  }, nil
+}
+
+// GetTemplateSummary is unsupported as templates are never fetched, so
+// launch parameters aren't validated against them.
+func (p *MemoryProvider) GetTemplateSummary(input *cloudformation.GetTemplateSummaryInput) (*cloudformation.GetTemplateSummaryOutput, error) {
+  return nil, ErrTemplateUnavailable
+}
+
+func (p *MemoryProvider) stackStatus(name string) (string, bool) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(name)
+  if ms == nil { return "", false }
+  return *ms.Stack.StackStatus, true
+}
+
+func (p *MemoryProvider) WaitUntilStackCreateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  for i := 0; i < memoryWaitAttempts; i++ {
+    status, exists := p.stackStatus(*input.StackName)
+    if !exists { return stackNotFound(*input.StackName) }
+    switch status {
+    case "CREATE_COMPLETE":
+      return nil
+    case "CREATE_IN_PROGRESS", "ROLLBACK_IN_PROGRESS":
+      if err := p.poll(ctx); err != nil { return err }
+    default:
+      return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+    }
+  }
+  return awserr.New(request.WaiterResourceNotReadyErrorCode, "exceeded wait attempts", nil)
+}
+
+func (p *MemoryProvider) WaitUntilStackDeleteCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  for i := 0; i < memoryWaitAttempts; i++ {
+    status, exists := p.stackStatus(*input.StackName)
+    if !exists || status == "DELETE_COMPLETE" { return nil }
+    if status == "DELETE_FAILED" {
+      return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+    }
+    if err := p.poll(ctx); err != nil { return err }
+  }
+  return awserr.New(request.WaiterResourceNotReadyErrorCode, "exceeded wait attempts", nil)
+}
+
+func (p *MemoryProvider) WaitUntilStackUpdateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  for i := 0; i < memoryWaitAttempts; i++ {
+    status, exists := p.stackStatus(*input.StackName)
+    if !exists { return stackNotFound(*input.StackName) }
+    switch status {
+    case "UPDATE_COMPLETE":
+      return nil
+    case "UPDATE_IN_PROGRESS", "UPDATE_ROLLBACK_IN_PROGRESS":
+      if err := p.poll(ctx); err != nil { return err }
+    default:
+      return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+    }
+  }
+  return awserr.New(request.WaiterResourceNotReadyErrorCode, "exceeded wait attempts", nil)
+}
+
+func changeSetNotFound(name string) error {
+  return awserr.New("ChangeSetNotFound", fmt.Sprintf("ChangeSet [%s] does not exist", name), nil)
+}
+
+func (ms *memoryStack) changeSet(nameOrId string) *memoryChangeSet {
+  for _, cs := range ms.ChangeSets {
+    if cs.Name == nameOrId || cs.Id == nameOrId {
+      return cs
+    }
+  }
+  return nil
+}
+
+// memoryReplacement guesses whether a resource would be replaced when
+// the named parameters change; only instance types force replacement.
+func memoryReplacement(resourceType string, changed []string) string {
+  if resourceType != "AWS::EC2::Instance" && resourceType != "AWS::AutoScaling::LaunchConfiguration" {
+    return "False"
+  }
+  for _, key := range changed {
+    if strings.Contains(key, "InstanceType") {
+      return "True"
+    }
+  }
+  return "False"
+}
+
+func (p *MemoryProvider) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(*input.StackName)
+  if ms == nil { return nil, stackNotFound(*input.StackName) }
+  status := *ms.Stack.StackStatus
+  if status != "CREATE_COMPLETE" && status != "UPDATE_COMPLETE" {
+    return nil, awserr.New("ValidationError", fmt.Sprintf("Stack:%s is in %s state and can not be updated.", *ms.Stack.StackId, status), nil)
+  }
+  if ms.changeSet(*input.ChangeSetName) != nil {
+    return nil, awserr.New("AlreadyExistsException", fmt.Sprintf("ChangeSet %s already exists", *input.ChangeSetName), nil)
+  }
+  cs := &memoryChangeSet{
+    Id: fmt.Sprintf("arn:aws:cloudformation:%s:%s:changeSet/%s/%s", p.Region, p.AccountId, *input.ChangeSetName, p.nextId()),
+    Name: *input.ChangeSetName,
+    Status: "CREATE_COMPLETE",
+    ExecutionStatus: "AVAILABLE",
+    Tags: input.Tags,
+    CreationTime: time.Now(),
+  }
+  changed := []string{}
+  for _, param := range input.Parameters {
+    previous := getStackParameter(ms.Stack, *param.ParameterKey)
+    val := aws.StringValue(param.ParameterValue)
+    if aws.BoolValue(param.UsePreviousValue) { val = previous }
+    cs.Parameters = append(cs.Parameters, &cloudformation.Parameter{ParameterKey: param.ParameterKey, ParameterValue: aws.String(val)})
+    if val != previous { changed = append(changed, *param.ParameterKey) }
+  }
+  templateChanged := input.Tags != nil &&
+    getStackTag(&cloudformation.Stack{Tags: input.Tags}, "flight:template") != getStackTag(ms.Stack, "flight:template")
+  for _, res := range ms.Resources {
+    var replacement string
+    if templateChanged {
+      replacement = "Conditional"
+    } else if len(changed) > 0 {
+      replacement = memoryReplacement(*res.ResourceType, changed)
+    } else {
+      continue
+    }
+    cs.Changes = append(cs.Changes, &cloudformation.Change{
+      Type: aws.String("Resource"),
+      ResourceChange: &cloudformation.ResourceChange{
+        Action: aws.String("Modify"),
+        LogicalResourceId: res.LogicalResourceId,
+        PhysicalResourceId: res.PhysicalResourceId,
+        ResourceType: res.ResourceType,
+        Replacement: aws.String(replacement),
+      },
+    })
+  }
+  if len(cs.Changes) == 0 {
+    cs.Status = "FAILED"
+    cs.StatusReason = "The submitted information didn't contain changes. Submit different information to create a change set."
+    cs.ExecutionStatus = "UNAVAILABLE"
+  }
+  ms.ChangeSets = append(ms.ChangeSets, cs)
+  return &cloudformation.CreateChangeSetOutput{Id: aws.String(cs.Id), StackId: ms.Stack.StackId}, nil
+}
+
+func (p *MemoryProvider) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(aws.StringValue(input.StackName))
+  if ms == nil { return nil, changeSetNotFound(*input.ChangeSetName) }
+  cs := ms.changeSet(*input.ChangeSetName)
+  if cs == nil { return nil, changeSetNotFound(*input.ChangeSetName) }
+  return &cloudformation.DescribeChangeSetOutput{
+    ChangeSetId: aws.String(cs.Id),
+    ChangeSetName: aws.String(cs.Name),
+    StackId: ms.Stack.StackId,
+    StackName: ms.Stack.StackName,
+    Status: aws.String(cs.Status),
+    StatusReason: aws.String(cs.StatusReason),
+    ExecutionStatus: aws.String(cs.ExecutionStatus),
+    Parameters: cs.Parameters,
+    Tags: cs.Tags,
+    Changes: cs.Changes,
+    CreationTime: aws.Time(cs.CreationTime),
+  }, nil
+}
+
+func (p *MemoryProvider) DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+  p.lock()
+  defer p.unlock()
+  ms := p.findStack(aws.StringValue(input.StackName))
+  if ms == nil || ms.changeSet(*input.ChangeSetName) == nil { return nil, changeSetNotFound(*input.ChangeSetName) }
+  changeSets := []*memoryChangeSet{}
+  for _, cs := range ms.ChangeSets {
+    if cs.Name != *input.ChangeSetName && cs.Id != *input.ChangeSetName {
+      changeSets = append(changeSets, cs)
+    }
+  }
+  ms.ChangeSets = changeSets
+  return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (p *MemoryProvider) WaitUntilChangeSetCreateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeChangeSetInput, opts ...request.WaiterOption) error {
+  o, err := p.DescribeChangeSet(input)
+  if err != nil { return err }
+  if *o.Status == "FAILED" {
+    return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+  }
+  return nil
+}
+
+func (p *MemoryProvider) ExecuteChangeSet(input *cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+  p.lock()
+  ms := p.findStack(aws.StringValue(input.StackName))
+  var cs *memoryChangeSet
+  if ms != nil { cs = ms.changeSet(*input.ChangeSetName) }
+  if cs == nil {
+    p.unlock()
+    return nil, changeSetNotFound(*input.ChangeSetName)
+  }
+  if cs.ExecutionStatus != "AVAILABLE" {
+    p.unlock()
+    return nil, awserr.New("InvalidChangeSetStatus", fmt.Sprintf("ChangeSet [%s] cannot be executed in its current status of [%s]", cs.Id, cs.Status), nil)
+  }
+  cs.ExecutionStatus = "EXECUTE_IN_PROGRESS"
+  ms.Update = cs
+  ms.ChangeSets = nil
+  ms.Stack.StackStatus = aws.String("UPDATE_IN_PROGRESS")
+  p.unlock()
+
+  p.run(func() { p.completeUpdate(ms) })
+  return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (p *MemoryProvider) completeUpdate(ms *memoryStack) {
+  cs := ms.Update
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "UPDATE_IN_PROGRESS", "User Initiated")
+  for _, change := range cs.Changes {
+    var res *cloudformation.StackResourceSummary
+    for _, r := range ms.Resources {
+      if *r.LogicalResourceId == *change.ResourceChange.LogicalResourceId {
+        res = r
+      }
+    }
+    if res == nil { continue }
+    p.pause()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "UPDATE_IN_PROGRESS", "")
+    p.pause()
+    if reason, fails := p.failureFor(ms, *res.LogicalResourceId); fails {
+      p.rollbackUpdate(ms, res, reason)
+      return
+    }
+    p.lock()
+    if *change.ResourceChange.Replacement == "True" {
+      res.PhysicalResourceId = aws.String(p.physicalIdFor(*ms.Stack.StackName, *res.LogicalResourceId, *res.ResourceType))
+    }
+    res.ResourceStatus = aws.String("UPDATE_COMPLETE")
+    res.LastUpdatedTimestamp = aws.Time(time.Now())
+    p.unlock()
+    p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "UPDATE_COMPLETE", "")
+  }
+  p.lock()
+  ms.Stack.Parameters = cs.Parameters
+  if cs.Tags != nil {
+    ms.Stack.Tags = cs.Tags
+  }
+  replaced := false
+  for _, change := range cs.Changes {
+    replaced = replaced || *change.ResourceChange.Replacement == "True"
+  }
+  if replaced {
+    ms.Stack.Outputs = p.outputsFor(ms)
+  }
+  for _, res := range ms.Resources {
+    if *res.ResourceType != "AWS::AutoScaling::AutoScalingGroup" { continue }
+    updated := p.groupFor(ms.Stack, *res.PhysicalResourceId)
+    if group, exists := p.state.Groups[*res.PhysicalResourceId]; exists {
+      p.resizeGroup(group, *group.MinSize, *updated.MaxSize, *updated.DesiredCapacity)
+    } else {
+      p.state.Groups[*res.PhysicalResourceId] = updated
+    }
+  }
+  ms.Stack.LastUpdatedTime = aws.Time(time.Now())
+  ms.Stack.StackStatus = aws.String("UPDATE_COMPLETE")
+  ms.Update = nil
+  p.unlock()
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "UPDATE_COMPLETE", "")
+}
+
+// rollbackUpdate fails the update of res and restores the resources
+// already updated, leaving the stack in UPDATE_ROLLBACK_COMPLETE with
+// its previous parameters.
+func (p *MemoryProvider) rollbackUpdate(ms *memoryStack, res *cloudformation.StackResourceSummary, reason string) {
+  p.lock()
+  res.ResourceStatus = aws.String("UPDATE_FAILED")
+  res.ResourceStatusReason = aws.String(reason)
+  ms.Stack.StackStatus = aws.String("UPDATE_ROLLBACK_IN_PROGRESS")
+  ms.Stack.StackStatusReason = aws.String(fmt.Sprintf("The following resource(s) failed to update: [%s].", *res.LogicalResourceId))
+  p.unlock()
+  p.notify(ms, *res.LogicalResourceId, *res.PhysicalResourceId, *res.ResourceType, "UPDATE_FAILED", reason)
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "UPDATE_ROLLBACK_IN_PROGRESS", *ms.Stack.StackStatusReason)
+  p.pause()
+  p.lock()
+  res.ResourceStatus = aws.String("UPDATE_COMPLETE")
+  res.ResourceStatusReason = nil
+  ms.Stack.StackStatus = aws.String("UPDATE_ROLLBACK_COMPLETE")
+  ms.Update = nil
+  p.unlock()
+  p.notify(ms, *ms.Stack.StackName, *ms.Stack.StackId, "AWS::CloudFormation::Stack", "UPDATE_ROLLBACK_COMPLETE", "")
+}
+
+func (p *MemoryProvider) DescribeKeyPairs(input *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+  names := aws.StringValueSlice(input.KeyNames)
+  if len(names) == 0 { names = p.KeyPairs }
+  keyPairs := []*ec2.KeyPairInfo{}
+  for _, name := range names {
+    if len(p.KeyPairs) > 0 && !containsS(p.KeyPairs, name) {
+      return nil, awserr.New("InvalidKeyPair.NotFound", fmt.Sprintf("The key pair '%s' does not exist", name), nil)
+    }
+    keyPairs = append(keyPairs, &ec2.KeyPairInfo{KeyName: aws.String(name)})
+  }
+  return &ec2.DescribeKeyPairsOutput{KeyPairs: keyPairs}, nil
+}
+
+func (p *MemoryProvider) DescribeNetworkInterfaces(input *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+  return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{}}, nil
+}
+
+func (p *MemoryProvider) DeleteNetworkInterface(input *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+  return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+var memoryVpnTunnelTemplate = `
+  <ipsec_tunnel>
+    <customer_gateway>
+      <tunnel_outside_address><ip_address>203.0.113.1</ip_address></tunnel_outside_address>
+      <tunnel_inside_address><ip_address>169.254.%d.2</ip_address><network_cidr>30</network_cidr></tunnel_inside_address>
+      <bgp><asn>65000</asn></bgp>
+    </customer_gateway>
+    <vpn_gateway>
+      <tunnel_outside_address><ip_address>198.51.100.%d</ip_address></tunnel_outside_address>
+      <tunnel_inside_address><ip_address>169.254.%d.1</ip_address><network_cidr>30</network_cidr></tunnel_inside_address>
+      <bgp><asn>7224</asn></bgp>
+    </vpn_gateway>
+    <ike><pre_shared_key>simulated-%d</pre_shared_key></ike>
+  </ipsec_tunnel>`
+
+func (p *MemoryProvider) DescribeVpnConnections(input *ec2.DescribeVpnConnectionsInput) (*ec2.DescribeVpnConnectionsOutput, error) {
+  connections := []*ec2.VpnConnection{}
+  for _, id := range input.VpnConnectionIds {
+    config := "<vpn_connection id=\"" + *id + "\">"
+    for i := 1; i <= 2; i++ {
+      config += fmt.Sprintf(memoryVpnTunnelTemplate, i * 4, i, i * 4, i)
+    }
+    config += "\n</vpn_connection>"
+    connections = append(connections, &ec2.VpnConnection{
+      VpnConnectionId: id,
+      State: aws.String("available"),
+      CustomerGatewayConfiguration: aws.String(config),
+    })
+  }
+  return &ec2.DescribeVpnConnectionsOutput{VpnConnections: connections}, nil
+}
+
+func (p *MemoryProvider) topicArn(name string) string {
+  return fmt.Sprintf("arn:aws:sns:%s:%s:%s", p.Region, p.AccountId, name)
+}
+
+func topicNotFound() error {
+  return awserr.New("NotFound", "Topic does not exist", nil)
+}
+
+func queueNotFound() error {
+  return awserr.New("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.", nil)
+}
+
+func (p *MemoryProvider) CreateTopic(input *sns.CreateTopicInput) (*sns.CreateTopicOutput, error) {
+  p.lock()
+  defer p.unlock()
+  arn := p.topicArn(*input.Name)
+  if _, exists := p.state.Topics[arn]; !exists {
+    p.state.Topics[arn] = &memoryTopic{Arn: arn}
+  }
+  return &sns.CreateTopicOutput{TopicArn: aws.String(arn)}, nil
+}
+
+func (p *MemoryProvider) DeleteTopic(input *sns.DeleteTopicInput) (*sns.DeleteTopicOutput, error) {
+  p.lock()
+  defer p.unlock()
+  delete(p.state.Topics, *input.TopicArn)
+  return &sns.DeleteTopicOutput{}, nil
+}
+
+func (p *MemoryProvider) ListTopics(input *sns.ListTopicsInput) (*sns.ListTopicsOutput, error) {
+  p.lock()
+  defer p.unlock()
+  arns := []string{}
+  for arn, _ := range p.state.Topics {
+    arns = append(arns, arn)
+  }
+  sort.Strings(arns)
+  topics := []*sns.Topic{}
+  for _, arn := range arns {
+    topics = append(topics, &sns.Topic{TopicArn: aws.String(arn)})
+  }
+  return &sns.ListTopicsOutput{Topics: topics}, nil
+}
+
+func (p *MemoryProvider) Subscribe(input *sns.SubscribeInput) (*sns.SubscribeOutput, error) {
+  p.lock()
+  defer p.unlock()
+  topic, exists := p.state.Topics[*input.TopicArn]
+  if !exists { return nil, topicNotFound() }
+  for _, sub := range topic.Subscriptions {
+    if *sub.Endpoint == *input.Endpoint && *sub.Protocol == *input.Protocol {
+      return &sns.SubscribeOutput{SubscriptionArn: sub.SubscriptionArn}, nil
+    }
+  }
+  sub := &sns.Subscription{
+    SubscriptionArn: aws.String(topic.Arn + ":" + p.nextId()),
+    TopicArn: input.TopicArn,
+    Endpoint: input.Endpoint,
+    Protocol: input.Protocol,
+    Owner: aws.String(p.AccountId),
+  }
+  topic.Subscriptions = append(topic.Subscriptions, sub)
+  return &sns.SubscribeOutput{SubscriptionArn: sub.SubscriptionArn}, nil
+}
+
+func (p *MemoryProvider) Unsubscribe(input *sns.UnsubscribeInput) (*sns.UnsubscribeOutput, error) {
+  p.lock()
+  defer p.unlock()
+  for _, topic := range p.state.Topics {
+    subs := []*sns.Subscription{}
+    for _, sub := range topic.Subscriptions {
+      if *sub.SubscriptionArn != *input.SubscriptionArn {
+        subs = append(subs, sub)
+      }
+    }
+    topic.Subscriptions = subs
+  }
+  return &sns.UnsubscribeOutput{}, nil
+}
+
+func (p *MemoryProvider) ListSubscriptionsByTopic(input *sns.ListSubscriptionsByTopicInput) (*sns.ListSubscriptionsByTopicOutput, error) {
+  p.lock()
+  defer p.unlock()
+  topic, exists := p.state.Topics[*input.TopicArn]
+  if !exists { return nil, topicNotFound() }
+  subs := make([]*sns.Subscription, len(topic.Subscriptions))
+  copy(subs, topic.Subscriptions)
+  return &sns.ListSubscriptionsByTopicOutput{Subscriptions: subs}, nil
+}
+
+func (p *MemoryProvider) CreateQueue(input *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+  p.lock()
+  defer p.unlock()
+  url := fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", p.Region, p.AccountId, *input.QueueName)
+  if _, exists := p.state.Queues[url]; !exists {
+    p.state.Queues[url] = &memoryQueue{
+      Url: url,
+      Arn: fmt.Sprintf("arn:aws:sqs:%s:%s:%s", p.Region, p.AccountId, *input.QueueName),
+      Attributes: make(map[string]*string),
+    }
+  }
+  return &sqs.CreateQueueOutput{QueueUrl: aws.String(url)}, nil
+}
+
+func (p *MemoryProvider) DeleteQueue(input *sqs.DeleteQueueInput) (*sqs.DeleteQueueOutput, error) {
+  p.lock()
+  defer p.unlock()
+  if _, exists := p.state.Queues[*input.QueueUrl]; !exists { return nil, queueNotFound() }
+  delete(p.state.Queues, *input.QueueUrl)
+  return &sqs.DeleteQueueOutput{}, nil
+}
+
+func (p *MemoryProvider) ListQueues(input *sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  urls := []string{}
+  for url, _ := range p.state.Queues {
+    name := url[strings.LastIndex(url, "/") + 1:]
+    if input.QueueNamePrefix == nil || strings.HasPrefix(name, *input.QueueNamePrefix) {
+      urls = append(urls, url)
+    }
+  }
+  sort.Strings(urls)
+  return &sqs.ListQueuesOutput{QueueUrls: aws.StringSlice(urls)}, nil
+}
+
+func (p *MemoryProvider) GetQueueAttributes(input *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  queue, exists := p.state.Queues[*input.QueueUrl]
+  if !exists { return nil, queueNotFound() }
+  attrs := map[string]*string{"QueueArn": aws.String(queue.Arn)}
+  for k, v := range queue.Attributes {
+    attrs[k] = v
+  }
+  return &sqs.GetQueueAttributesOutput{Attributes: attrs}, nil
+}
+
+func (p *MemoryProvider) SetQueueAttributes(input *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  queue, exists := p.state.Queues[*input.QueueUrl]
+  if !exists { return nil, queueNotFound() }
+  for k, v := range input.Attributes {
+    queue.Attributes[k] = v
+  }
+  return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func (p *MemoryProvider) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+  p.lock()
+  defer p.unlock()
+  queue, exists := p.state.Queues[*input.QueueUrl]
+  if !exists { return nil, queueNotFound() }
+  max := 1
+  if input.MaxNumberOfMessages != nil { max = int(*input.MaxNumberOfMessages) }
+  if max > len(queue.Messages) { max = len(queue.Messages) }
+  messages := queue.Messages[:max]
+  queue.Messages = queue.Messages[max:]
+  return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (p *MemoryProvider) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+  p.lock()
+  defer p.unlock()
+  if _, exists := p.state.Queues[*input.QueueUrl]; !exists { return nil, queueNotFound() }
+  return &sqs.DeleteMessageOutput{}, nil
+}
+
+// notify publishes a CloudFormation stack event to the stack's
+// notification topics, in the format that CloudFormation uses.
+func (p *MemoryProvider) notify(ms *memoryStack, logicalId, physicalId, resourceType, status, reason string) {
+  p.lock()
+  defer p.unlock()
+  event := &cloudformation.StackEvent{
+    StackId: ms.Stack.StackId,
+    StackName: ms.Stack.StackName,
+    EventId: aws.String(p.nextId()),
+    LogicalResourceId: aws.String(logicalId),
+    PhysicalResourceId: aws.String(physicalId),
+    ResourceType: aws.String(resourceType),
+    ResourceStatus: aws.String(status),
+    Timestamp: aws.Time(time.Now().UTC()),
+  }
+  if reason != "" {
+    event.ResourceStatusReason = aws.String(reason)
+  }
+  ms.Events = append([]*cloudformation.StackEvent{event}, ms.Events...)
+  message := ""
+  for _, kv := range [][2]string{
+    {"StackId", *ms.Stack.StackId},
+    {"Timestamp", event.Timestamp.Format("2006-01-02T15:04:05.000Z")},
+    {"EventId", *event.EventId},
+    {"LogicalResourceId", logicalId},
+    {"Namespace", p.AccountId},
+    {"PhysicalResourceId", physicalId},
+    {"ResourceProperties", "null"},
+    {"ResourceStatus", status},
+    {"ResourceStatusReason", reason},
+    {"ResourceType", resourceType},
+    {"StackName", *ms.Stack.StackName},
+    {"ClientRequestToken", "null"},
+  } {
+    message += fmt.Sprintf("%s='%s'\n", kv[0], kv[1])
+  }
+  for _, arn := range ms.Stack.NotificationARNs {
+    p.publish(*arn, "AWS CloudFormation Notification", message)
+  }
+}
+
+func (p *MemoryProvider) publish(topicArn, subject, message string) {
+  topic, exists := p.state.Topics[topicArn]
+  if !exists { return }
+  body, err := json.Marshal(NotificationMessage{
+    Type: "Notification",
+    MessageId: p.nextId(),
+    TopicArn: topicArn,
+    Subject: subject,
+    Message: message,
+    Timestamp: time.Now().UTC().Format(time.RFC3339),
+  })
+  if err != nil { return }
+  for _, sub := range topic.Subscriptions {
+    if *sub.Protocol != "sqs" { continue }
+    for _, queue := range p.state.Queues {
+      if queue.Arn == *sub.Endpoint {
+        id := p.nextId()
+        queue.Messages = append(queue.Messages, &sqs.Message{
+          MessageId: aws.String(id),
+          ReceiptHandle: aws.String(id),
+          Body: aws.String(string(body)),
+        })
+      }
+    }
+  }
+}
+
+func (p *MemoryProvider) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+  p.lock()
+  defer p.unlock()
+  groups := []*autoscaling.Group{}
+  for _, name := range input.AutoScalingGroupNames {
+    if group, exists := p.state.Groups[*name]; exists {
+      c := *group
+      groups = append(groups, &c)
+    }
+  }
+  return &autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: groups}, nil
+}
+
+// resizeGroup sets a group's bounds, launching or terminating instances
+// to meet its desired capacity.
+func (p *MemoryProvider) resizeGroup(group *autoscaling.Group, minSize, maxSize, desired int64) {
+  group.MinSize = aws.Int64(minSize)
+  group.MaxSize = aws.Int64(maxSize)
+  group.DesiredCapacity = aws.Int64(desired)
+  instanceType := "unknown"
+  if len(group.Instances) > 0 {
+    instanceType = *group.Instances[0].InstanceType
+  }
+  for int64(len(group.Instances)) < desired {
+    group.Instances = append(group.Instances, &autoscaling.Instance{
+      InstanceId: aws.String("i-" + p.nextId()),
+      InstanceType: aws.String(instanceType),
+      LifecycleState: aws.String("InService"),
+      HealthStatus: aws.String("Healthy"),
+    })
+  }
+  if int64(len(group.Instances)) > desired {
+    group.Instances = group.Instances[:desired]
+  }
+}
+
+func groupNotFound(name string) error {
+  return awserr.New("ValidationError", fmt.Sprintf("AutoScalingGroup name not found - AutoScalingGroup '%s' not found", name), nil)
+}
+
+func (p *MemoryProvider) UpdateAutoScalingGroup(input *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+  p.lock()
+  defer p.unlock()
+  group, exists := p.state.Groups[*input.AutoScalingGroupName]
+  if !exists { return nil, groupNotFound(*input.AutoScalingGroupName) }
+  minSize, maxSize, desired := *group.MinSize, *group.MaxSize, *group.DesiredCapacity
+  if input.MinSize != nil { minSize = *input.MinSize }
+  if input.MaxSize != nil { maxSize = *input.MaxSize }
+  if input.DesiredCapacity != nil { desired = *input.DesiredCapacity }
+  if minSize > maxSize || desired < minSize || desired > maxSize {
+    return nil, awserr.New("ValidationError", fmt.Sprintf("Desired capacity:%d must be between the specified min size:%d and max size:%d", desired, minSize, maxSize), nil)
+  }
+  p.resizeGroup(group, minSize, maxSize, desired)
+  return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (p *MemoryProvider) SuspendProcesses(input *autoscaling.ScalingProcessQuery) (*autoscaling.SuspendProcessesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  group, exists := p.state.Groups[*input.AutoScalingGroupName]
+  if !exists { return nil, groupNotFound(*input.AutoScalingGroupName) }
+  processes := aws.StringValueSlice(input.ScalingProcesses)
+  if len(processes) == 0 { processes = ScalingProcesses }
+  for _, process := range processes {
+    suspended := false
+    for _, sp := range group.SuspendedProcesses {
+      suspended = suspended || *sp.ProcessName == process
+    }
+    if !suspended {
+      group.SuspendedProcesses = append(group.SuspendedProcesses, &autoscaling.SuspendedProcess{
+        ProcessName: aws.String(process),
+        SuspensionReason: aws.String("User suspended at " + time.Now().UTC().Format(time.RFC3339)),
+      })
+    }
+  }
+  return &autoscaling.SuspendProcessesOutput{}, nil
+}
+
+func (p *MemoryProvider) ResumeProcesses(input *autoscaling.ScalingProcessQuery) (*autoscaling.ResumeProcessesOutput, error) {
+  p.lock()
+  defer p.unlock()
+  group, exists := p.state.Groups[*input.AutoScalingGroupName]
+  if !exists { return nil, groupNotFound(*input.AutoScalingGroupName) }
+  processes := aws.StringValueSlice(input.ScalingProcesses)
+  remaining := []*autoscaling.SuspendedProcess{}
+  for _, sp := range group.SuspendedProcesses {
+    if len(processes) > 0 && !containsS(processes, *sp.ProcessName) {
+      remaining = append(remaining, sp)
+    }
+  }
+  group.SuspendedProcesses = remaining
+  return &autoscaling.ResumeProcessesOutput{}, nil
+}
+
+func (p *MemoryProvider) PutDomain(record *DomainEntity) error {
+  p.lock()
+  defer p.unlock()
+  c := *record
+  c.NetBookings = append([]int{}, record.NetBookings...)
+  p.state.Domains[record.Name] = &c
+  return nil
+}
+
+func (p *MemoryProvider) GetDomain(name string) (*DomainEntity, error) {
+  p.lock()
+  defer p.unlock()
+  record, exists := p.state.Domains[name]
+  if !exists { return nil, ErrEntityNotFound }
+  c := *record
+  c.NetBookings = append([]int{}, record.NetBookings...)
+  return &c, nil
+}
+
+func (p *MemoryProvider) DeleteDomain(name string) error {
+  p.lock()
+  defer p.unlock()
+  delete(p.state.Domains, name)
+  return nil
+}
+
+func (p *MemoryProvider) Domains() ([]*DomainEntity, error) {
+  p.lock()
+  defer p.unlock()
+  records := []*DomainEntity{}
+  for _, record := range p.state.Domains {
+    c := *record
+    c.NetBookings = append([]int{}, record.NetBookings...)
+    records = append(records, &c)
+  }
+  sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+  return records, nil
+}
+
+func (p *MemoryProvider) AddNetworkBooking(domainName string, index int) error {
+  p.lock()
+  defer p.unlock()
+  record, exists := p.state.Domains[domainName]
+  if !exists {
+    record = &DomainEntity{Name: domainName}
+    p.state.Domains[domainName] = record
+  }
+  if containsI(record.NetBookings, index) { return ErrNetworkBooked }
+  record.NetBookings = append(record.NetBookings, index)
+  return nil
+}
+
+func (p *MemoryProvider) RemoveNetworkBooking(domainName string, index int) error {
+  p.lock()
+  defer p.unlock()
+  record, exists := p.state.Domains[domainName]
+  if !exists || !containsI(record.NetBookings, index) { return ErrNetworkNotBooked }
+  record.NetBookings = removeI(record.NetBookings, index)
+  return nil
+}
+
+func (p *MemoryProvider) PutCluster(record *ClusterEntity) error {
+  p.lock()
+  defer p.unlock()
+  c := *record
+  p.state.Clusters[record.Name] = &c
+  return nil
+}
+
+func (p *MemoryProvider) GetCluster(name string) (*ClusterEntity, error) {
+  p.lock()
+  defer p.unlock()
+  record, exists := p.state.Clusters[name]
+  if !exists { return nil, ErrEntityNotFound }
+  c := *record
+  return &c, nil
+}
+
+func (p *MemoryProvider) DeleteCluster(name string) error {
+  p.lock()
+  defer p.unlock()
+  delete(p.state.Clusters, name)
+  return nil
+}
+
+func (p *MemoryProvider) Clusters() ([]*ClusterEntity, error) {
+  p.lock()
+  defer p.unlock()
+  records := []*ClusterEntity{}
+  for _, record := range p.state.Clusters {
+    c := *record
+    records = append(records, &c)
+  }
+  sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+  return records, nil
+}
+
+func (p *MemoryProvider) PutOperation(record *OperationEntity) error {
+  p.lock()
+  defer p.unlock()
+  p.state.Operations[record.Id] = copyOperation(record)
+  return nil
+}
+
+func (p *MemoryProvider) GetOperation(id string) (*OperationEntity, error) {
+  p.lock()
+  defer p.unlock()
+  record, exists := p.state.Operations[id]
+  if !exists { return nil, ErrEntityNotFound }
+  return copyOperation(record), nil
+}
+
+func (p *MemoryProvider) DeleteOperation(id string) error {
+  p.lock()
+  defer p.unlock()
+  delete(p.state.Operations, id)
+  return nil
+}
+
+func (p *MemoryProvider) Operations() ([]*OperationEntity, error) {
+  p.lock()
+  defer p.unlock()
+  records := []*OperationEntity{}
+  for _, record := range p.state.Operations {
+    records = append(records, copyOperation(record))
+  }
+  sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+  return records, nil
+}
+
+func copyOperation(record *OperationEntity) *OperationEntity {
+  c := *record
+  c.Options = make(map[string]string)
+  for k, v := range record.Options {
+    c.Options[k] = v
+  }
+  c.Steps = nil
+  for _, step := range record.Steps {
+    s := *step
+    c.Steps = append(c.Steps, &s)
+  }
+  return &c
+}
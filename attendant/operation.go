@@ -0,0 +1,395 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Operation kinds that are journaled in the state store.
+const (
+  ClusterLaunchOperation = "cluster-launch"
+  ClusterDestroyOperation = "cluster-destroy"
+  ClusterPurgeOperation = "cluster-purge"
+)
+
+// OperationEntity journals the steps of a multi-stack operation, so that
+// one that is interrupted can be resumed or aborted later.  Completed
+// and aborted operations are removed from the journal.
+type OperationEntity struct {
+  Id string `dynamo:",hash" json:"Id" yaml:"Id"`
+  Kind string `json:"Kind" yaml:"Kind"`
+  Region string `json:"Region" yaml:"Region"`
+  Domain string `json:"Domain,omitempty" yaml:"Domain,omitempty"`
+  Cluster string `json:"Cluster" yaml:"Cluster"`
+  Options map[string]string `json:"Options,omitempty" yaml:"Options,omitempty"`
+  Steps []*OperationStep `json:"Steps" yaml:"Steps"`
+  Status string `json:"Status" yaml:"Status"`
+  Error string `json:"Error,omitempty" yaml:"Error,omitempty"`
+  StartTime int64 `json:"StartTime" yaml:"StartTime"`
+  UpdateTime int64 `json:"UpdateTime" yaml:"UpdateTime"`
+  client *Client
+}
+
+type OperationStep struct {
+  Name string `json:"Name" yaml:"Name"`
+  Status string `json:"Status" yaml:"Status"`
+}
+
+func startOperation(kind string, c *Cluster, steps ...string) (*OperationEntity, error) {
+  now := time.Now()
+  op := &OperationEntity{
+    Id: strconv.FormatInt(now.UnixNano() / int64(time.Millisecond), 36),
+    Kind: kind,
+    Region: c.Client().Config().AwsRegion,
+    Cluster: c.Name,
+    Options: make(map[string]string),
+    Status: "running",
+    StartTime: now.Unix(),
+    client: c.Client(),
+  }
+  if c.Domain != nil {
+    op.Domain = c.Domain.Name
+  }
+  if c.SoloMode != "" {
+    op.Options["SoloMode"] = c.SoloMode
+  }
+  op.Options["ExpiryTime"] = strconv.FormatInt(c.ExpiryTime, 10)
+  op.Options["Quota"] = strconv.FormatInt(c.Quota, 10)
+  if kind == ClusterLaunchOperation {
+    // resuming a launch must use the same templates and key pair
+    op.Options["TemplateRoot"] = c.Client().Config().TemplateRoot
+    op.Options["TemplateSet"] = c.Client().Config().TemplateSet
+    op.Options["KeyPair"] = c.Client().Config().AccessKeyName
+    op.Options["ParameterDirectory"] = c.Client().Config().ParameterDirectory
+  }
+  for _, name := range steps {
+    op.Steps = append(op.Steps, &OperationStep{name, "pending"})
+  }
+  return op, op.save()
+}
+
+// Client returns the client that the operation was started or loaded
+// with.
+func (o *OperationEntity) Client() *Client {
+  if o.client == nil { return DefaultClient() }
+  return o.client
+}
+
+func (o *OperationEntity) save() error {
+  store, err := o.Client().State()
+  if err != nil { return err }
+  o.UpdateTime = time.Now().Unix()
+  return store.PutOperation(o)
+}
+
+func (o *OperationEntity) forget() error {
+  store, err := o.Client().State()
+  if err != nil { return err }
+  return store.DeleteOperation(o.Id)
+}
+
+func (o *OperationEntity) findStep(name string) *OperationStep {
+  for _, step := range o.Steps {
+    if step.Name == name {
+      return step
+    }
+  }
+  return nil
+}
+
+func (o *OperationEntity) done(name string) bool {
+  step := o.findStep(name)
+  return step != nil && step.Status == "done"
+}
+
+func (o *OperationEntity) setStep(name, status string) error {
+  step := o.findStep(name)
+  if step == nil {
+    step = &OperationStep{name, status}
+    o.Steps = append(o.Steps, step)
+  }
+  step.Status = status
+  return o.save()
+}
+
+// step runs a step of the operation unless it has already been done.
+func (o *OperationEntity) step(name string, fn func() error) error {
+  if o.done(name) { return nil }
+  return o.run(name, fn)
+}
+
+// rerun runs a step even if it has already been done, for steps that
+// are safe to repeat and restore state needed by the steps after them.
+func (o *OperationEntity) rerun(name string, fn func() error) error {
+  return o.run(name, fn)
+}
+
+func (o *OperationEntity) run(name string, fn func() error) error {
+  if err := o.setStep(name, "running"); err != nil { return err }
+  if err := fn(); err != nil {
+    if isInterrupted(err) {
+      o.setStep(name, "interrupted")
+    } else {
+      o.setStep(name, "failed")
+    }
+    return err
+  }
+  return o.setStep(name, "done")
+}
+
+// stackStep runs a step that creates a stack.  A stack left by an
+// interrupted attempt at the step is waited for rather than created
+// again.  The stack is returned whether or not the step was run.
+func (o *OperationEntity) stackStep(ctx context.Context, svc StackService, name, stackName string, fn func() error) (*cloudformation.Stack, error) {
+  resuming := o.findStep(name) != nil && o.findStep(name).Status != "pending"
+  err := o.step(name, func() error {
+    if _, err := getStack(svc, stackName); err == nil && resuming {
+      _, err = o.Client().awaitStack(ctx, svc, stackName)
+      return err
+    }
+    return fn()
+  })
+  if err != nil { return nil, err }
+  return getStack(svc, stackName)
+}
+
+// InterruptedError is returned when an operation stops because its
+// context was cancelled.  The operation stays in the journal so that it
+// can be resumed or aborted later.
+type InterruptedError struct {
+  Operation *OperationEntity
+}
+
+func (e *InterruptedError) Error() string {
+  return fmt.Sprintf("Operation %s was interrupted.", e.Operation.Id)
+}
+
+func isInterrupted(err error) bool {
+  return err == context.Canceled || err == context.DeadlineExceeded
+}
+
+// finish removes a successful operation from the journal, or records
+// the error that stopped it so that it may be resumed.
+func (o *OperationEntity) finish(err error) error {
+  if err == nil {
+    o.Status = "complete"
+    o.forget()
+    return nil
+  }
+  if isInterrupted(err) {
+    o.Status = "interrupted"
+    o.save()
+    return &InterruptedError{o}
+  }
+  o.Status = "failed"
+  o.Error = err.Error()
+  o.save()
+  return err
+}
+
+// compensated removes an operation whose effects have been undone from
+// the journal.
+func (o *OperationEntity) compensated(err error) error {
+  o.forget()
+  return err
+}
+
+func (o *OperationEntity) Target() string {
+  if o.Domain == "" {
+    return o.Cluster + " (solo)"
+  }
+  return o.Domain + "/" + o.Cluster
+}
+
+// Progress summarises the steps of the operation, e.g. "3/6
+// (master-stack)" naming the first step that isn't done.
+func (o *OperationEntity) Progress() string {
+  count := 0
+  next := ""
+  for _, step := range o.Steps {
+    if step.Status == "done" {
+      count += 1
+    } else if next == "" {
+      next = step.Name
+    }
+  }
+  s := fmt.Sprintf("%d/%d", count, len(o.Steps))
+  if next != "" {
+    s += " (" + next + ")"
+  }
+  return s
+}
+
+func (o *OperationEntity) Render() string {
+  lines := []string{
+    fmt.Sprintf("Operation: %s", o.Id),
+    fmt.Sprintf("Kind: %s", o.Kind),
+    fmt.Sprintf("Cluster: %s", o.Target()),
+    fmt.Sprintf("Region: %s", o.Region),
+    fmt.Sprintf("Status: %s", o.Status),
+    fmt.Sprintf("Started: %s", time.Unix(o.StartTime, 0).UTC().Format(time.RFC3339)),
+    fmt.Sprintf("Updated: %s", time.Unix(o.UpdateTime, 0).UTC().Format(time.RFC3339)),
+  }
+  if o.Error != "" {
+    lines = append(lines, "Error: " + o.Error)
+  }
+  lines = append(lines, "Steps:")
+  for _, step := range o.Steps {
+    lines = append(lines, fmt.Sprintf("  %-16s %s", step.Name, step.Status))
+  }
+  return strings.Join(lines, "\n")
+}
+
+func Operations() ([]*OperationEntity, error) {
+  return DefaultClient().Operations()
+}
+
+// Operations lists the journaled operations for the current region,
+// oldest first.
+func (c *Client) Operations() ([]*OperationEntity, error) {
+  store, err := c.State()
+  if err != nil { return nil, err }
+  all, err := store.Operations()
+  if err != nil { return nil, err }
+  ops := []*OperationEntity{}
+  for _, op := range all {
+    if op.Region == c.config.AwsRegion {
+      op.client = c
+      ops = append(ops, op)
+    }
+  }
+  sort.Slice(ops, func(i, j int) bool { return ops[i].StartTime < ops[j].StartTime })
+  return ops, nil
+}
+
+func LoadOperation(id string) (*OperationEntity, error) {
+  return DefaultClient().LoadOperation(id)
+}
+
+func (c *Client) LoadOperation(id string) (*OperationEntity, error) {
+  store, err := c.State()
+  if err != nil { return nil, err }
+  op, err := store.GetOperation(id)
+  if err == ErrEntityNotFound {
+    return nil, fmt.Errorf("Operation '%s' was not found.", id)
+  }
+  if err != nil { return nil, err }
+  if op.Region != c.config.AwsRegion {
+    return nil, fmt.Errorf("Operation '%s' is in region %s.", id, op.Region)
+  }
+  op.client = c
+  return op, nil
+}
+
+// OperationCluster returns the cluster that an operation acts on.
+func (o *OperationEntity) OperationCluster(handler EventHandler) (*Cluster, error) {
+  var domain *Domain
+  if o.Domain != "" {
+    domain = o.Client().NewDomain(o.Domain, nil)
+    if err := domain.AssertExists(); err != nil { return nil, err }
+  }
+  c := o.Client().NewCluster(o.Cluster, domain, handler)
+  if v, exists := o.Options["SoloMode"]; exists {
+    c.SoloMode = v
+  }
+  if v, exists := o.Options["ExpiryTime"]; exists {
+    c.ExpiryTime, _ = strconv.ParseInt(v, 10, 64)
+  }
+  if v, exists := o.Options["Quota"]; exists {
+    c.Quota, _ = strconv.ParseInt(v, 10, 64)
+  }
+  return c, nil
+}
+
+// Resume continues an operation from the first step that isn't done.
+func (o *OperationEntity) Resume(ctx context.Context, handler EventHandler) (*Cluster, error) {
+  c, err := o.OperationCluster(handler)
+  if err != nil { return nil, err }
+  o.Status = "running"
+  o.Error = ""
+  switch o.Kind {
+  case ClusterLaunchOperation:
+    o.Client().Config().TemplateRoot = o.Options["TemplateRoot"]
+    o.Client().Config().TemplateSet = o.Options["TemplateSet"]
+    o.Client().Config().AccessKeyName = o.Options["KeyPair"]
+    o.Client().Config().ParameterDirectory = o.Options["ParameterDirectory"]
+    err = c.create(ctx, o)
+  case ClusterDestroyOperation:
+    err = c.destroy(ctx, o)
+  case ClusterPurgeOperation:
+    err = c.purge(ctx, o)
+  default:
+    err = fmt.Errorf("Unknown operation kind: %s", o.Kind)
+  }
+  return c, err
+}
+
+// Abort undoes what an interrupted launch has created and removes the
+// operation from the journal.  Destruction can't be undone, so aborting
+// a destroy or purge only removes its event queue and its entry in the
+// journal, leaving any stacks that remain in place.
+func (o *OperationEntity) Abort(ctx context.Context, handler EventHandler) error {
+  o.Status = "aborted"
+  if o.Kind != ClusterLaunchOperation {
+    if !o.done("event-handling") {
+      eventName := "flight-cluster-" + o.Cluster
+      if o.Domain != "" {
+        eventName = "flight-" + o.Domain + "-cluster-" + o.Cluster
+      }
+      if err := o.Client().cleanupEventHandling(eventName); err != nil { return err }
+    }
+    return o.forget()
+  }
+  c, err := o.OperationCluster(handler)
+  if err != nil { return err }
+  svc, err := o.Client().CloudFormation()
+  if err != nil { return err }
+  // stops the event processing that abortCreate starts
+  eventCtx, cancel := context.WithCancel(ctx)
+  err = c.abortCreate(eventCtx, o, svc)
+  cancel()
+  if err == nil {
+    c.MessageHandler(NewDoneEvent())
+  }
+  if err != nil {
+    o.Status = "failed"
+    o.Error = err.Error()
+    o.save()
+    return err
+  }
+  return o.forget()
+}
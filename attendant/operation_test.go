@@ -0,0 +1,130 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "testing"
+  "time"
+)
+
+// interruptLaunch starts launching cluster c1 in domain d1 and cancels
+// the launch once its master stack has begun to be created.
+func interruptLaunch(t *testing.T, c *Client, mem *MemoryProvider) *OperationEntity {
+  d := createTestDomain(t, c, "d1")
+  mem.Latency = 10 * time.Millisecond
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  go func() {
+    for ctx.Err() == nil {
+      if _, exists := mem.stackStatus("flight-d1-c1-master"); exists {
+        cancel()
+        return
+      }
+      time.Sleep(time.Millisecond)
+    }
+  }()
+  err := d.Client().NewCluster("c1", d, nil).Create(ctx, false)
+  if _, ok := err.(*InterruptedError); !ok {
+    t.Fatalf("Create: got %v, want an InterruptedError", err)
+  }
+  ops, err := c.Operations()
+  if err != nil { t.Fatalf("Operations: %s", err) }
+  if len(ops) != 1 {
+    t.Fatalf("got %d operations, want 1", len(ops))
+  }
+  op := ops[0]
+  if op.Status != "interrupted" {
+    t.Errorf("status = %s, want interrupted", op.Status)
+  }
+  if op.done("master-stack") {
+    t.Errorf("master-stack step was recorded as done")
+  }
+  return op
+}
+
+func awaitStackStatus(t *testing.T, mem *MemoryProvider, name, want string) {
+  for i := 0; i < 1000; i++ {
+    if status, _ := mem.stackStatus(name); status == want { return }
+    time.Sleep(time.Millisecond)
+  }
+  t.Fatalf("stack %s did not reach %s", name, want)
+}
+
+func TestResumeInterruptedLaunch(t *testing.T) {
+  c, mem := newTestClient(t)
+  op := interruptLaunch(t, c, mem)
+
+  op, err := c.LoadOperation(op.Id)
+  if err != nil { t.Fatalf("LoadOperation: %s", err) }
+  cluster, err := op.Resume(context.Background(), DiscardEvents)
+  if err != nil { t.Fatalf("Resume: %s", err) }
+  if cluster.Master == nil {
+    t.Errorf("resumed cluster has no master")
+  }
+  for _, name := range []string{"flight-d1-c1-network", "flight-d1-c1-master"} {
+    if status, _ := mem.stackStatus(name); status != "CREATE_COMPLETE" {
+      t.Errorf("stack %s is %s, want CREATE_COMPLETE", name, status)
+    }
+  }
+  ops, err := c.Operations()
+  if err != nil { t.Fatalf("Operations: %s", err) }
+  if len(ops) != 0 {
+    t.Errorf("resumed operation was left in the journal")
+  }
+  if _, err := c.LoadOperation(op.Id); err == nil {
+    t.Errorf("LoadOperation found the resumed operation")
+  }
+}
+
+func TestAbortInterruptedLaunch(t *testing.T) {
+  c, mem := newTestClient(t)
+  op := interruptLaunch(t, c, mem)
+  awaitStackStatus(t, mem, "flight-d1-c1-master", "CREATE_COMPLETE")
+
+  if err := op.Abort(context.Background(), DiscardEvents); err != nil {
+    t.Fatalf("Abort: %s", err)
+  }
+  for _, name := range []string{"flight-d1-c1-network", "flight-d1-c1-master"} {
+    if stackExists(mem, name) {
+      t.Errorf("stack %s was not destroyed", name)
+    }
+  }
+  ops, err := c.Operations()
+  if err != nil { t.Fatalf("Operations: %s", err) }
+  if len(ops) != 0 {
+    t.Errorf("aborted operation was left in the journal")
+  }
+
+  record, err := c.NewDomain("d1", nil).LoadEntity()
+  if err != nil { t.Fatalf("LoadEntity: %s", err) }
+  if len(record.NetBookings) != 0 {
+    t.Errorf("network bookings = %v, want none", record.NetBookings)
+  }
+}
@@ -0,0 +1,72 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+
+  "gopkg.in/yaml.v2"
+)
+
+// OutputFormats are the values accepted by the output setting.  Other
+// than text, each prints a single document describing the result of a
+// command.
+var OutputFormats = []string{"text", "json", "yaml"}
+
+func IsValidOutputFormat(format string) bool {
+  return containsS(OutputFormats, format)
+}
+
+func IsStructuredOutput() bool {
+  return DefaultClient().IsStructuredOutput()
+}
+
+func (c *Client) IsStructuredOutput() bool {
+  return c.config.Output == "json" || c.config.Output == "yaml"
+}
+
+// Marshal renders a value as JSON or YAML.
+func Marshal(format string, v interface{}) (string, error) {
+  switch format {
+  case "json":
+    var buf bytes.Buffer
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(v); err != nil { return "", err }
+    return buf.String(), nil
+  case "yaml":
+    data, err := yaml.Marshal(v)
+    if err != nil { return "", err }
+    return string(data), nil
+  }
+  return "", fmt.Errorf("Unknown output format: %s (valid formats: json, yaml)", format)
+}
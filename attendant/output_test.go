@@ -0,0 +1,51 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "strings"
+  "testing"
+)
+
+func TestMarshal(t *testing.T) {
+  value := map[string]string{"Url": "http://example.com/?a=1&b=2"}
+  out, err := Marshal("json", value)
+  if err != nil { t.Fatalf("Marshal json: %s", err) }
+  if !strings.Contains(out, `"http://example.com/?a=1&b=2"`) {
+    t.Errorf("json output escapes HTML: %s", out)
+  }
+  out, err = Marshal("yaml", value)
+  if err != nil { t.Fatalf("Marshal yaml: %s", err) }
+  if strings.TrimSpace(out) != "Url: http://example.com/?a=1&b=2" {
+    t.Errorf("yaml output = %q", out)
+  }
+  if _, err := Marshal("text", value); err == nil {
+    t.Errorf("expected text to be refused as a structured format")
+  }
+}
@@ -0,0 +1,347 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+  "gopkg.in/yaml.v2"
+)
+
+// ParameterSet is a set of launch parameters along with where it came
+// from, so that problems can be reported against the original file.
+type ParameterSet struct {
+  Source string
+  Values map[string]string
+  lines map[string]int
+}
+
+var parameterKeyPattern = regexp.MustCompile(`^['"]?([A-Za-z0-9_]+)['"]?\s*:`)
+
+func builtinParameterSet(name string, values map[string]string) *ParameterSet {
+  return &ParameterSet{Source: "default " + name + " parameters", Values: values}
+}
+
+func loadComponentParameters(paramsFile string) (*ParameterSet, error) {
+  set := &ParameterSet{Source: paramsFile, Values: make(map[string]string), lines: make(map[string]int)}
+  if paramsFile == "" { return set, nil }
+  data, err := ioutil.ReadFile(paramsFile)
+  if err != nil { return nil, err }
+  err = yaml.Unmarshal(data, &set.Values)
+  if err != nil { return nil, fmt.Errorf("%s: %s", paramsFile, err.Error()) }
+  for i, line := range strings.Split(string(data), "\n") {
+    if match := parameterKeyPattern.FindStringSubmatch(line); match != nil {
+      if _, exists := set.lines[match[1]]; !exists {
+        set.lines[match[1]] = i + 1
+      }
+    }
+  }
+  return set, nil
+}
+
+func (s *ParameterSet) position(key string) string {
+  if line, exists := s.lines[key]; exists {
+    return fmt.Sprintf("%s:%d", s.Source, line)
+  }
+  return s.Source
+}
+
+// TemplateFor returns the template file used for a component, queue or
+// appliance parameter set name, e.g. "cluster-compute".
+func TemplateFor(name string) string {
+  if template, exists := ApplianceTemplates[name]; exists {
+    return template
+  }
+  if strings.HasSuffix(name, ".json") {
+    return name
+  }
+  return name + ".json"
+}
+
+func ValidateParameterFile(paramsFile, templateName string) error {
+  return DefaultClient().ValidateParameterFile(paramsFile, templateName)
+}
+
+// ValidateParameterFile checks a parameter file against a template.  If
+// no template is given, it is named after the file.  Values containing
+// tokens are checked for their keys only.
+func (c *Client) ValidateParameterFile(paramsFile, templateName string) error {
+  if templateName == "" {
+    templateName = strings.TrimSuffix(filepath.Base(paramsFile), filepath.Ext(paramsFile))
+  }
+  set, err := loadComponentParameters(paramsFile)
+  if err != nil { return err }
+  values := make(map[string]string)
+  dropped := make(map[string]bool)
+  for key, val := range set.Values {
+    if val == "%NULL%" {
+      dropped[key] = true
+    } else if !tokenPattern.MatchString(val) {
+      values[key] = val
+    }
+  }
+  templateUrl := c.TemplateUrl(TemplateFor(templateName))
+  declared, err := c.declaredParameters(templateUrl)
+  if err == ErrTemplateUnavailable {
+    return fmt.Errorf("Template parameters are not available from the %s backend.", c.Config().Backend)
+  }
+  if err != nil { return fmt.Errorf("Unable to retrieve template %s: %s", templateUrl, err.Error()) }
+  return set.check(declared, templateUrl, values, dropped)
+}
+
+// Validate compares resolved launch parameters with the parameters
+// declared by the template at templateUrl.
+func (s *ParameterSet) Validate(c *Client, templateUrl string, params []*cloudformation.Parameter) error {
+  if !c.Config().ValidateParameters { return nil }
+  return s.validateResolved(c, templateUrl, params)
+}
+
+func (s *ParameterSet) validateResolved(c *Client, templateName string, params []*cloudformation.Parameter) error {
+  values := make(map[string]string)
+  for _, param := range params {
+    values[*param.ParameterKey] = aws.StringValue(param.ParameterValue)
+  }
+  dropped := make(map[string]bool)
+  for key, _ := range s.Values {
+    if _, exists := values[key]; !exists {
+      dropped[key] = true
+    }
+  }
+  declared, err := c.declaredParameters(templateName)
+  if err == ErrTemplateUnavailable { return nil }
+  if err != nil { return fmt.Errorf("Unable to retrieve template %s: %s", templateName, err.Error()) }
+  return s.check(declared, templateName, values, dropped)
+}
+
+// templateParameter is a parameter declared by a template, including
+// the constraints that GetTemplateSummary doesn't report.
+type templateParameter struct {
+  Type string `yaml:"Type"`
+  Default interface{} `yaml:"Default"`
+  AllowedValues []interface{} `yaml:"AllowedValues"`
+  AllowedPattern string `yaml:"AllowedPattern"`
+  MinValue interface{} `yaml:"MinValue"`
+  MaxValue interface{} `yaml:"MaxValue"`
+  MinLength interface{} `yaml:"MinLength"`
+  MaxLength interface{} `yaml:"MaxLength"`
+}
+
+// templateDocument is the part of a template that declares parameters.
+// Templates may be JSON or YAML; short form intrinsic functions in the
+// rest of a YAML template are ignored.
+type templateDocument struct {
+  Parameters map[string]*templateParameter `yaml:"Parameters"`
+}
+
+// declaredParameters returns the parameters declared by the template at
+// templateUrl.  Local templates are read directly rather than staged, so
+// that validating parameters or planning never uploads anything.  The
+// body of a remote template is fetched for its constraints; if it can't
+// be, the template summary is used alone.
+func (c *Client) declaredParameters(templateUrl string) (map[string]*templateParameter, error) {
+  if isLocalTemplate(templateUrl) {
+    data, err := ioutil.ReadFile(strings.TrimPrefix(templateUrl, "file://"))
+    if err != nil { return nil, err }
+    return parseTemplateParameters(data)
+  }
+  declared, err := c.summaryParameters(&cloudformation.GetTemplateSummaryInput{TemplateURL: aws.String(templateUrl)})
+  if err != nil { return nil, err }
+  if data, err := c.fetchTemplate(templateUrl); err == nil {
+    if parsed, err := parseTemplateParameters(data); err == nil && len(parsed) == len(declared) {
+      return parsed, nil
+    }
+  }
+  return declared, nil
+}
+
+// summaryParameters returns the parameters reported by
+// GetTemplateSummary, which carry allowed values but no other
+// constraints.
+func (c *Client) summaryParameters(input *cloudformation.GetTemplateSummaryInput) (map[string]*templateParameter, error) {
+  summary, err := c.templateParameters(input)
+  if err != nil { return nil, err }
+  declared := make(map[string]*templateParameter)
+  for key, decl := range summary {
+    param := &templateParameter{Type: aws.StringValue(decl.ParameterType)}
+    if decl.DefaultValue != nil {
+      param.Default = *decl.DefaultValue
+    }
+    if decl.ParameterConstraints != nil {
+      for _, val := range decl.ParameterConstraints.AllowedValues {
+        param.AllowedValues = append(param.AllowedValues, *val)
+      }
+    }
+    declared[key] = param
+  }
+  return declared, nil
+}
+
+func (c *Client) fetchTemplate(templateUrl string) ([]byte, error) {
+  client := c.awsConfig(nil).HTTPClient
+  if client == nil { client = http.DefaultClient }
+  resp, err := client.Get(templateUrl)
+  if err != nil { return nil, err }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("Unable to fetch template %s: %s", templateUrl, resp.Status)
+  }
+  return ioutil.ReadAll(resp.Body)
+}
+
+func parseTemplateParameters(data []byte) (map[string]*templateParameter, error) {
+  doc := templateDocument{}
+  if err := yaml.Unmarshal(data, &doc); err != nil { return nil, err }
+  declared := make(map[string]*templateParameter)
+  for key, param := range doc.Parameters {
+    if param == nil { param = &templateParameter{} }
+    declared[key] = param
+  }
+  return declared, nil
+}
+
+// templateParameters returns the parameters declared by a template,
+// keyed by name.
+func (c *Client) templateParameters(input *cloudformation.GetTemplateSummaryInput) (map[string]*cloudformation.ParameterDeclaration, error) {
+  svc, err := c.CloudFormation()
+  if err != nil { return nil, err }
+  res, err := throttleProtected(func() (interface{}, error) {
+    return svc.GetTemplateSummary(input)
+  })
+  if err != nil { return nil, err }
+  declared := make(map[string]*cloudformation.ParameterDeclaration)
+  for _, decl := range res.(*cloudformation.GetTemplateSummaryOutput).Parameters {
+    declared[*decl.ParameterKey] = decl
+  }
+  return declared, nil
+}
+
+func (s *ParameterSet) check(declared map[string]*templateParameter, templateName string, values map[string]string, dropped map[string]bool) error {
+  keys := []string{}
+  for key, _ := range s.Values {
+    keys = append(keys, key)
+  }
+  for key, _ := range declared {
+    if _, exists := s.Values[key]; !exists {
+      keys = append(keys, key)
+    }
+  }
+  sort.Slice(keys, func(i, j int) bool {
+    if s.lines[keys[i]] != s.lines[keys[j]] {
+      return s.lines[keys[i]] < s.lines[keys[j]]
+    }
+    return keys[i] < keys[j]
+  })
+
+  problems := []string{}
+  for _, key := range keys {
+    decl, isDeclared := declared[key]
+    val, isSet := values[key]
+    _, isListed := s.Values[key]
+    switch {
+    case !isDeclared:
+      // Parameters dropped with %NULL% are harmless, whatever the template.
+      if !dropped[key] {
+        problems = append(problems, fmt.Sprintf("%s: unknown parameter '%s'", s.position(key), key))
+      }
+    case (!isListed || dropped[key]) && decl.Default == nil:
+      problems = append(problems, fmt.Sprintf("%s: missing required parameter '%s'", s.position(key), key))
+    case isSet:
+      if problem := checkParameterValue(key, decl, val); problem != "" {
+        problems = append(problems, fmt.Sprintf("%s: %s", s.position(key), problem))
+      }
+    }
+  }
+  if len(problems) > 0 {
+    return fmt.Errorf("Parameters do not match template %s:\n  %s", templateName, strings.Join(problems, "\n  "))
+  }
+  return nil
+}
+
+func checkParameterValue(key string, decl *templateParameter, val string) string {
+  items := []string{val}
+  if strings.HasPrefix(decl.Type, "List<") || decl.Type == "CommaDelimitedList" {
+    items = strings.Split(val, ",")
+    for i, item := range items {
+      items[i] = strings.TrimSpace(item)
+    }
+  }
+  allowed := []string{}
+  for _, v := range decl.AllowedValues {
+    allowed = append(allowed, fmt.Sprint(v))
+  }
+  for _, item := range items {
+    if decl.Type == "Number" || decl.Type == "List<Number>" {
+      num, err := strconv.ParseFloat(item, 64)
+      if err != nil {
+        return fmt.Sprintf("value '%s' for '%s' is not a number", item, key)
+      }
+      if min, ok := constraintNumber(decl.MinValue); ok && num < min {
+        return fmt.Sprintf("value '%s' for '%s' is less than the minimum of %v", item, key, min)
+      }
+      if max, ok := constraintNumber(decl.MaxValue); ok && num > max {
+        return fmt.Sprintf("value '%s' for '%s' is greater than the maximum of %v", item, key, max)
+      }
+    }
+    if decl.Type == "String" {
+      if min, ok := constraintNumber(decl.MinLength); ok && float64(len(item)) < min {
+        return fmt.Sprintf("value '%s' for '%s' is shorter than %v characters", item, key, min)
+      }
+      if max, ok := constraintNumber(decl.MaxLength); ok && float64(len(item)) > max {
+        return fmt.Sprintf("value '%s' for '%s' is longer than %v characters", item, key, max)
+      }
+      if decl.AllowedPattern != "" {
+        // CloudFormation matches the whole value against the pattern
+        if pattern, err := regexp.Compile("^(?:" + decl.AllowedPattern + ")$"); err == nil && !pattern.MatchString(item) {
+          return fmt.Sprintf("value '%s' for '%s' does not match pattern %s", item, key, decl.AllowedPattern)
+        }
+      }
+    }
+    if len(allowed) > 0 && !containsS(allowed, item) {
+      return fmt.Sprintf("value '%s' for '%s' is not one of: %s", item, key, strings.Join(allowed, ", "))
+    }
+  }
+  return ""
+}
+
+// constraintNumber reads a numeric constraint, which templates may give
+// as either a number or a string.
+func constraintNumber(val interface{}) (float64, bool) {
+  if val == nil { return 0, false }
+  num, err := strconv.ParseFloat(fmt.Sprint(val), 64)
+  return num, err == nil
+}
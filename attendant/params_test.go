@@ -0,0 +1,139 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+var testTemplate = `
+Parameters:
+  Size:
+    Type: Number
+    MinValue: 1
+    MaxValue: "8"
+  Name:
+    Type: String
+    MinLength: 3
+    MaxLength: 6
+    AllowedPattern: "[a-z]+"
+  Mode:
+    Type: String
+    AllowedValues: [fast, slow]
+    Default: fast
+  Subnets:
+    Type: CommaDelimitedList
+    AllowedValues: [a, b, c]
+Resources:
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Ref Name
+`
+
+func writeTestTemplate(t *testing.T) (*Client, string) {
+  dir, err := ioutil.TempDir("", "fly-params")
+  if err != nil { t.Fatal(err) }
+  if err := ioutil.WriteFile(filepath.Join(dir, "test.json"), []byte(testTemplate), 0600); err != nil { t.Fatal(err) }
+  c, _ := newTestClient(t)
+  c.Config().TemplateRoot = dir
+  c.Config().TemplateSet = ""
+  return c, dir
+}
+
+func TestParseTemplateParameters(t *testing.T) {
+  declared, err := parseTemplateParameters([]byte(testTemplate))
+  if err != nil { t.Fatalf("parseTemplateParameters: %s", err) }
+  if len(declared) != 4 {
+    t.Fatalf("%d parameters, want 4", len(declared))
+  }
+  if declared["Mode"].Default != "fast" || declared["Size"].Default != nil {
+    t.Errorf("defaults = %v, %v", declared["Mode"].Default, declared["Size"].Default)
+  }
+}
+
+func TestCheckParameterValue(t *testing.T) {
+  declared, err := parseTemplateParameters([]byte(testTemplate))
+  if err != nil { t.Fatalf("parseTemplateParameters: %s", err) }
+  tests := []struct {
+    key string
+    value string
+    problem string
+  }{
+    {"Size", "4", ""},
+    {"Size", "four", "is not a number"},
+    {"Size", "0", "less than the minimum"},
+    {"Size", "9", "greater than the maximum"},
+    {"Name", "abc", ""},
+    {"Name", "ab", "shorter than"},
+    {"Name", "abcdefg", "longer than"},
+    {"Name", "abc1", "does not match pattern"},
+    {"Mode", "slow", ""},
+    {"Mode", "medium", "is not one of"},
+    {"Subnets", "a, c", ""},
+    {"Subnets", "a,d", "'d' for 'Subnets' is not one of"},
+  }
+  for _, test := range tests {
+    problem := checkParameterValue(test.key, declared[test.key], test.value)
+    if test.problem == "" && problem != "" || !strings.Contains(problem, test.problem) {
+      t.Errorf("%s=%q: problem %q, want %q", test.key, test.value, problem, test.problem)
+    }
+  }
+}
+
+func TestValidateParameterFile(t *testing.T) {
+  c, dir := writeTestTemplate(t)
+  defer os.RemoveAll(dir)
+  paramsFile := filepath.Join(dir, "test.yml")
+  ioutil.WriteFile(paramsFile, []byte("Size: 9\nName: abc\nUnknown: x\nSubnets: a\n"), 0600)
+
+  err := c.ValidateParameterFile(paramsFile, "")
+  if err == nil { t.Fatalf("expected problems with %s", paramsFile) }
+  for _, want := range []string{paramsFile + ":1: value '9' for 'Size'", paramsFile + ":3: unknown parameter 'Unknown'"} {
+    if !strings.Contains(err.Error(), want) {
+      t.Errorf("error %q does not report %q", err.Error(), want)
+    }
+  }
+
+  ioutil.WriteFile(paramsFile, []byte("Size: 2\nName: abc\nSubnets: a,b\nMode: '%NULL%'\n"), 0600)
+  if err := c.ValidateParameterFile(paramsFile, ""); err != nil {
+    t.Errorf("ValidateParameterFile: %s", err)
+  }
+
+  ioutil.WriteFile(paramsFile, []byte("Size: 2\nSubnets: a\n"), 0600)
+  err = c.ValidateParameterFile(paramsFile, "")
+  if err == nil || !strings.Contains(err.Error(), "missing required parameter 'Name'") {
+    t.Errorf("expected Name to be reported missing, got %v", err)
+  }
+}
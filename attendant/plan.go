@@ -0,0 +1,359 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "fmt"
+  "sort"
+  "strings"
+  "text/tabwriter"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/request"
+  "github.com/aws/aws-sdk-go/service/autoscaling"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+  "github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Plan records the stacks that an operation would create.  While a plan
+// is running, stacks are created as placeholders whose outputs refer to
+// the planned stack, and no state or event handling resources are
+// written.
+type Plan struct {
+  Stacks []*PlannedStack `json:"Stacks" yaml:"Stacks"`
+  drops []string
+}
+
+type PlannedStack struct {
+  StackName string `json:"StackName" yaml:"StackName"`
+  TemplateURL string `json:"TemplateURL" yaml:"TemplateURL"`
+  StagedURL string `json:"StagedURL,omitempty" yaml:"StagedURL,omitempty"`
+  Tags []PlannedValue `json:"Tags" yaml:"Tags"`
+  Parameters []PlannedValue `json:"Parameters" yaml:"Parameters"`
+  Dropped []string `json:"Dropped" yaml:"Dropped"`
+}
+
+type PlannedValue struct {
+  Key string `json:"Key" yaml:"Key"`
+  Value string `json:"Value" yaml:"Value"`
+}
+
+func NewPlan() *Plan {
+  return &Plan{Stacks: []*PlannedStack{}}
+}
+
+// Run calls fn with a planning provider installed in the default
+// client.
+func (p *Plan) Run(fn func() error) error {
+  return DefaultClient().Plan(p, fn)
+}
+
+// Plan calls fn with a planning provider installed in place of the
+// client's provider.  Reads are passed through to that provider.
+func (c *Client) Plan(p *Plan, fn func() error) error {
+  previous := c.Provider()
+  c.SetProvider(&planProvider{previous, p, NewMemoryProvider(c.config.AwsRegion)})
+  c.plan = p
+  defer func() {
+    c.plan = nil
+    c.SetProvider(previous)
+  }()
+  return fn()
+}
+
+func (c *Client) noteDroppedParameter(key string) {
+  if c.plan != nil {
+    c.plan.drops = append(c.plan.drops, key)
+  }
+}
+
+func (p *Plan) record(input *cloudformation.CreateStackInput) {
+  stack := &PlannedStack{
+    StackName: aws.StringValue(input.StackName),
+    TemplateURL: aws.StringValue(input.TemplateURL),
+    Tags: []PlannedValue{},
+    Parameters: []PlannedValue{},
+    Dropped: p.drops,
+  }
+  if stack.TemplateURL == "" || strings.HasPrefix(stack.TemplateURL, "s3://") {
+    // local templates are identified by their source; large ones would
+    // be uploaded to the staging bucket at launch
+    if stack.TemplateURL != "" { stack.StagedURL = stack.TemplateURL }
+    stack.TemplateURL = getStackTag(&cloudformation.Stack{Tags: input.Tags}, "flight:template")
+  }
+  if stack.Dropped == nil { stack.Dropped = []string{} }
+  sort.Strings(stack.Dropped)
+  p.drops = nil
+  for _, tag := range input.Tags {
+    stack.Tags = append(stack.Tags, PlannedValue{*tag.Key, *tag.Value})
+  }
+  for _, param := range input.Parameters {
+    stack.Parameters = append(stack.Parameters, PlannedValue{*param.ParameterKey, *param.ParameterValue})
+  }
+  sort.Slice(stack.Parameters, func(i, j int) bool { return stack.Parameters[i].Key < stack.Parameters[j].Key })
+  p.Stacks = append(p.Stacks, stack)
+}
+
+func (p *Plan) Render() string {
+  var buf bytes.Buffer
+  if len(p.Stacks) == 0 {
+    return "No stacks would be created.\n"
+  }
+  for i, stack := range p.Stacks {
+    fmt.Fprintf(&buf, "== %d. %s ==\n", i + 1, stack.StackName)
+    fmt.Fprintf(&buf, "Template: %s\n", stack.TemplateURL)
+    if stack.StagedURL != "" {
+      fmt.Fprintf(&buf, "Staging: would upload to %s\n", stack.StagedURL)
+    }
+    buf.WriteString("\n")
+    w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "    TAG\tVALUE")
+    for _, tag := range stack.Tags {
+      fmt.Fprintf(w, "    %s\t%s\n", tag.Key, tag.Value)
+    }
+    fmt.Fprintln(w, "\t")
+    fmt.Fprintln(w, "    PARAMETER\tVALUE")
+    for _, param := range stack.Parameters {
+      fmt.Fprintf(w, "    %s\t%s\n", param.Key, param.Value)
+    }
+    w.Flush()
+    if len(stack.Dropped) > 0 {
+      fmt.Fprintf(&buf, "\n    Dropped (%%NULL%%): %s\n", strings.Join(stack.Dropped, ", "))
+    }
+    buf.WriteString("\n")
+  }
+  return buf.String()
+}
+
+func planPlaceholder(stackName, key string) string {
+  return "<" + stackName + "." + key + ">"
+}
+
+// planProvider passes reads through to the underlying provider while
+// creating stacks, topics and queues in memory and discarding all other
+// writes.
+type planProvider struct {
+  Provider
+  plan *Plan
+  placeholders *MemoryProvider
+}
+
+func (p *planProvider) Stacks(c *Client) (StackService, error) {
+  svc, err := p.Provider.Stacks(c)
+  if err != nil { return nil, err }
+  return &planStackService{svc, p}, nil
+}
+
+func (p *planProvider) Network(c *Client) (NetworkService, error) {
+  svc, err := p.Provider.Network(c)
+  if err != nil { return nil, err }
+  return &planNetworkService{svc}, nil
+}
+
+func (p *planProvider) Events(c *Client) (EventBus, error) {
+  return p.placeholders, nil
+}
+
+func (p *planProvider) Autoscaling(c *Client) (AutoscalingService, error) {
+  svc, err := p.Provider.Autoscaling(c)
+  if err != nil { return nil, err }
+  return &planAutoscalingService{svc}, nil
+}
+
+func (p *planProvider) State(c *Client) (StateStore, error) {
+  store, err := p.Provider.State(c)
+  if err != nil { return nil, err }
+  return planStore(store), nil
+}
+
+type planStackService struct {
+  StackService
+  provider *planProvider
+}
+
+func (s *planStackService) planned(name string) bool {
+  _, err := s.provider.placeholders.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(name)})
+  return err == nil
+}
+
+func (s *planStackService) CreateStack(input *cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error) {
+  s.provider.plan.record(input)
+  placeholder := *input
+  placeholder.NotificationARNs = nil
+  return s.provider.placeholders.CreateStack(&placeholder)
+}
+
+func (s *planStackService) DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+  return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (s *planStackService) DescribeStacks(input *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+  if input.StackName != nil && !s.planned(*input.StackName) {
+    return s.StackService.DescribeStacks(input)
+  }
+  placeholders, err := s.provider.placeholders.DescribeStacks(input)
+  if err != nil { return nil, err }
+  for _, stack := range placeholders.Stacks {
+    outputs := []*cloudformation.Output{}
+    for _, output := range stack.Outputs {
+      value := planPlaceholder(*stack.StackName, *output.OutputKey)
+      if *output.OutputKey == "ConfigurationResult" {
+        value = fmt.Sprintf("{\"Data\":\"UUID:%s;Token:%s\"}",
+          planPlaceholder(*stack.StackName, "UUID"), planPlaceholder(*stack.StackName, "Token"))
+      }
+      outputs = append(outputs, &cloudformation.Output{OutputKey: output.OutputKey, OutputValue: aws.String(value)})
+    }
+    stack.Outputs = outputs
+  }
+  if input.StackName != nil { return placeholders, nil }
+  resp, err := s.StackService.DescribeStacks(input)
+  if err != nil { return nil, err }
+  if resp.NextToken == nil {
+    resp.Stacks = append(resp.Stacks, placeholders.Stacks...)
+  }
+  return resp, nil
+}
+
+func (s *planStackService) ListStackResources(input *cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error) {
+  if s.planned(*input.StackName) {
+    return s.provider.placeholders.ListStackResources(input)
+  }
+  return s.StackService.ListStackResources(input)
+}
+
+func (s *planStackService) WaitUntilStackCreateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  return nil
+}
+
+func (s *planStackService) WaitUntilStackDeleteCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  return nil
+}
+
+func (s *planStackService) CreateChangeSet(input *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
+  return &cloudformation.CreateChangeSetOutput{
+    Id: aws.String(planPlaceholder(*input.StackName, *input.ChangeSetName)),
+    StackId: aws.String(*input.StackName),
+  }, nil
+}
+
+func (s *planStackService) DescribeChangeSet(input *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+  return &cloudformation.DescribeChangeSetOutput{
+    StackName: input.StackName,
+    ChangeSetName: input.ChangeSetName,
+    Status: aws.String("CREATE_COMPLETE"),
+  }, nil
+}
+
+func (s *planStackService) ExecuteChangeSet(input *cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+  return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (s *planStackService) DeleteChangeSet(input *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+  return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (s *planStackService) WaitUntilChangeSetCreateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeChangeSetInput, opts ...request.WaiterOption) error {
+  return nil
+}
+
+func (s *planStackService) WaitUntilStackUpdateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  return nil
+}
+
+// planNetworkService reads from the underlying service and discards
+// deletions.
+type planNetworkService struct {
+  NetworkService
+}
+
+func (s *planNetworkService) DeleteNetworkInterface(input *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+  return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+// planAutoscalingService reads from the underlying service and discards
+// changes to groups.
+type planAutoscalingService struct {
+  AutoscalingService
+}
+
+func (s *planAutoscalingService) UpdateAutoScalingGroup(input *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+  return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (s *planAutoscalingService) SuspendProcesses(input *autoscaling.ScalingProcessQuery) (*autoscaling.SuspendProcessesOutput, error) {
+  return &autoscaling.SuspendProcessesOutput{}, nil
+}
+
+func (s *planAutoscalingService) ResumeProcesses(input *autoscaling.ScalingProcessQuery) (*autoscaling.ResumeProcessesOutput, error) {
+  return &autoscaling.ResumeProcessesOutput{}, nil
+}
+
+// planStateStore reads from the underlying store and discards writes.
+type planStateStore struct {
+  StateStore
+}
+
+// planStore wraps store so that writes to it are discarded.
+func planStore(store StateStore) StateStore {
+  if _, planned := store.(*planStateStore); planned { return store }
+  return &planStateStore{store}
+}
+
+func (s *planStateStore) PutDomain(record *DomainEntity) error {
+  return nil
+}
+
+func (s *planStateStore) DeleteDomain(name string) error {
+  return nil
+}
+
+func (s *planStateStore) AddNetworkBooking(domainName string, index int) error {
+  return nil
+}
+
+func (s *planStateStore) RemoveNetworkBooking(domainName string, index int) error {
+  return nil
+}
+
+func (s *planStateStore) PutCluster(record *ClusterEntity) error {
+  return nil
+}
+
+func (s *planStateStore) DeleteCluster(name string) error {
+  return nil
+}
+
+func (s *planStateStore) PutOperation(record *OperationEntity) error {
+  return nil
+}
+
+func (s *planStateStore) DeleteOperation(id string) error {
+  return nil
+}
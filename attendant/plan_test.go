@@ -0,0 +1,84 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "context"
+  "io/ioutil"
+  "path/filepath"
+  "reflect"
+  "testing"
+)
+
+type stateSnapshot struct {
+  domains []*DomainEntity
+  clusters []*ClusterEntity
+  operations []*OperationEntity
+}
+
+func snapshotState(t *testing.T, store StateStore) *stateSnapshot {
+  snapshot := &stateSnapshot{}
+  var err error
+  if snapshot.domains, err = store.Domains(); err != nil { t.Fatalf("Domains: %s", err) }
+  if snapshot.clusters, err = store.Clusters(); err != nil { t.Fatalf("Clusters: %s", err) }
+  if snapshot.operations, err = store.Operations(); err != nil { t.Fatalf("Operations: %s", err) }
+  return snapshot
+}
+
+func TestPlanWritesNoState(t *testing.T) {
+  c, _ := newTestClient(t)
+  path := filepath.Join(t.TempDir(), "state.db")
+  c.config.StateBackend = "bolt"
+  c.config.StateFile = path
+  createTestDomain(t, c, "d1")
+
+  store, err := c.OpenStateStore("bolt")
+  if err != nil { t.Fatalf("OpenStateStore: %s", err) }
+  before := snapshotState(t, store)
+  data, err := ioutil.ReadFile(path)
+  if err != nil { t.Fatalf("ReadFile: %s", err) }
+
+  plan := NewPlan()
+  err = c.Plan(plan, func() error {
+    return c.NewCluster("c1", c.NewDomain("d1", nil), nil).Create(context.Background(), true)
+  })
+  if err != nil { t.Fatalf("planned Create: %s", err) }
+  if len(plan.Stacks) == 0 {
+    t.Fatalf("no stacks were planned")
+  }
+
+  after := snapshotState(t, store)
+  if !reflect.DeepEqual(before, after) {
+    t.Errorf("state changed during plan: %+v, want %+v", after, before)
+  }
+  if changed, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(data, changed) {
+    t.Errorf("state file was written during plan")
+  }
+}
@@ -0,0 +1,228 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/request"
+  "github.com/aws/aws-sdk-go/service/autoscaling"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+  "github.com/aws/aws-sdk-go/service/ec2"
+  "github.com/aws/aws-sdk-go/service/sns"
+  "github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Provider supplies the backing services used by clusters, domains and
+// appliances.  Each method is given the client whose region and
+// credentials are to be used.  The AWS provider is used unless another
+// is installed with SetProvider.
+type Provider interface {
+  Stacks(c *Client) (StackService, error)
+  Network(c *Client) (NetworkService, error)
+  Events(c *Client) (EventBus, error)
+  Autoscaling(c *Client) (AutoscalingService, error)
+  State(c *Client) (StateStore, error)
+  Account(c *Client) (*Account, error)
+}
+
+// StackService is the subset of the CloudFormation API used to create,
+// inspect and destroy Flight stacks.  The waiters take a context so that
+// a caller can stop waiting on a stack operation without stopping the
+// operation itself.
+type StackService interface {
+  CreateStack(*cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error)
+  DeleteStack(*cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error)
+  DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error)
+  ListStacks(*cloudformation.ListStacksInput) (*cloudformation.ListStacksOutput, error)
+  ListStackResources(*cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error)
+  DescribeStackEvents(*cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error)
+  DescribeAccountLimits(*cloudformation.DescribeAccountLimitsInput) (*cloudformation.DescribeAccountLimitsOutput, error)
+  GetTemplateSummary(*cloudformation.GetTemplateSummaryInput) (*cloudformation.GetTemplateSummaryOutput, error)
+  WaitUntilStackCreateCompleteWithContext(aws.Context, *cloudformation.DescribeStacksInput, ...request.WaiterOption) error
+  WaitUntilStackDeleteCompleteWithContext(aws.Context, *cloudformation.DescribeStacksInput, ...request.WaiterOption) error
+
+  CreateChangeSet(*cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error)
+  DescribeChangeSet(*cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error)
+  ExecuteChangeSet(*cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error)
+  DeleteChangeSet(*cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error)
+  WaitUntilChangeSetCreateCompleteWithContext(aws.Context, *cloudformation.DescribeChangeSetInput, ...request.WaiterOption) error
+  WaitUntilStackUpdateCompleteWithContext(aws.Context, *cloudformation.DescribeStacksInput, ...request.WaiterOption) error
+}
+
+// NetworkService is the subset of the EC2 API used for key pairs,
+// network interfaces and VPN connections.
+type NetworkService interface {
+  DescribeKeyPairs(*ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error)
+  DescribeNetworkInterfaces(*ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error)
+  DeleteNetworkInterface(*ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error)
+  DescribeVpnConnections(*ec2.DescribeVpnConnectionsInput) (*ec2.DescribeVpnConnectionsOutput, error)
+}
+
+// EventBus carries stack notifications from an SNS topic to the SQS
+// queue that is polled while a stack operation is in progress.
+type EventBus interface {
+  CreateTopic(*sns.CreateTopicInput) (*sns.CreateTopicOutput, error)
+  DeleteTopic(*sns.DeleteTopicInput) (*sns.DeleteTopicOutput, error)
+  ListTopics(*sns.ListTopicsInput) (*sns.ListTopicsOutput, error)
+  Subscribe(*sns.SubscribeInput) (*sns.SubscribeOutput, error)
+  Unsubscribe(*sns.UnsubscribeInput) (*sns.UnsubscribeOutput, error)
+  ListSubscriptionsByTopic(*sns.ListSubscriptionsByTopicInput) (*sns.ListSubscriptionsByTopicOutput, error)
+
+  CreateQueue(*sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error)
+  DeleteQueue(*sqs.DeleteQueueInput) (*sqs.DeleteQueueOutput, error)
+  ListQueues(*sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error)
+  GetQueueAttributes(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error)
+  SetQueueAttributes(*sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error)
+  ReceiveMessage(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
+  DeleteMessage(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+}
+
+// AutoscalingService is the subset of the Auto Scaling API used to
+// inspect and resize compute groups.
+type AutoscalingService interface {
+  DescribeAutoScalingGroups(*autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
+  UpdateAutoScalingGroup(*autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error)
+  SuspendProcesses(*autoscaling.ScalingProcessQuery) (*autoscaling.SuspendProcessesOutput, error)
+  ResumeProcesses(*autoscaling.ScalingProcessQuery) (*autoscaling.ResumeProcessesOutput, error)
+}
+
+// StateStore holds the domain and cluster records that aren't kept in
+// stack tags, including the network bookings for each domain, and the
+// journal of multi-stack operations.
+type StateStore interface {
+  PutDomain(record *DomainEntity) error
+  GetDomain(name string) (*DomainEntity, error)
+  DeleteDomain(name string) error
+  Domains() ([]*DomainEntity, error)
+  AddNetworkBooking(domainName string, index int) error
+  RemoveNetworkBooking(domainName string, index int) error
+
+  PutCluster(record *ClusterEntity) error
+  GetCluster(name string) (*ClusterEntity, error)
+  DeleteCluster(name string) error
+  Clusters() ([]*ClusterEntity, error)
+
+  PutOperation(record *OperationEntity) error
+  GetOperation(id string) (*OperationEntity, error)
+  DeleteOperation(id string) error
+  Operations() ([]*OperationEntity, error)
+}
+
+var ErrEntityNotFound = fmt.Errorf("Entity not found.")
+var ErrNetworkBooked = fmt.Errorf("Network already booked.")
+var ErrNetworkNotBooked = fmt.Errorf("Network not booked.")
+var ErrTemplateUnavailable = fmt.Errorf("Template not available.")
+
+func SetProvider(p Provider) {
+  DefaultClient().SetProvider(p)
+}
+
+func CurrentProvider() Provider {
+  return DefaultClient().Provider()
+}
+
+// SetupBackend installs the provider selected by the backend setting in
+// the default client.
+func SetupBackend() error {
+  return DefaultClient().SetupBackend()
+}
+
+func (c *Client) SetProvider(p Provider) {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  c.provider = p
+  c.stackCache = nil
+}
+
+func (c *Client) Provider() Provider {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.provider == nil {
+    c.provider = awsProvider{}
+  }
+  return c.provider
+}
+
+// SetupBackend installs the provider selected by the backend setting.
+func (c *Client) SetupBackend() error {
+  switch c.config.Backend {
+  case "", "aws":
+    c.SetProvider(awsProvider{})
+  case "sim":
+    sim, err := NewSimulator(c.config.SimulatorDirectory)
+    if err != nil { return err }
+    c.SetProvider(sim)
+  default:
+    return fmt.Errorf("Unknown backend: %s (valid backends: aws, sim)", c.config.Backend)
+  }
+  return nil
+}
+
+type awsProvider struct{}
+
+type awsEventBus struct {
+  *sns.SNS
+  *sqs.SQS
+}
+
+func (p awsProvider) Stacks(c *Client) (StackService, error) {
+  sess, err := c.Session()
+  if err != nil { return nil, err }
+  return cloudformation.New(sess, c.serviceConfig("cloudformation")), nil
+}
+
+func (p awsProvider) Network(c *Client) (NetworkService, error) {
+  sess, err := c.Session()
+  if err != nil { return nil, err }
+  return ec2.New(sess, c.serviceConfig("ec2")), nil
+}
+
+func (p awsProvider) Events(c *Client) (EventBus, error) {
+  sess, err := c.Session()
+  if err != nil { return nil, err }
+  return &awsEventBus{sns.New(sess, c.serviceConfig("sns")), sqs.New(sess, c.serviceConfig("sqs"))}, nil
+}
+
+func (p awsProvider) Autoscaling(c *Client) (AutoscalingService, error) {
+  sess, err := c.Session()
+  if err != nil { return nil, err }
+  return autoscaling.New(sess, c.serviceConfig("autoscaling")), nil
+}
+
+func (p awsProvider) State(c *Client) (StateStore, error) {
+  db, err := c.Dynamo()
+  if err != nil { return nil, err }
+  return &dynamoStateStore{db, c}, nil
+}
+
+func (p awsProvider) Account(c *Client) (*Account, error) {
+  return awsAccount(c)
+}
@@ -0,0 +1,381 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "bytes"
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// ComputeUnits rates each instance type in compute units (cu) per
+// hour, at one unit per vCPU.
+var ComputeUnits = map[string]int64{
+  "c5.large": 2,
+  "c5.xlarge": 4,
+  "c5.2xlarge": 8,
+  "c5.4xlarge": 16,
+  "c5.9xlarge": 36,
+  "c5.18xlarge": 72,
+  "c4.large": 2,
+  "c4.xlarge": 4,
+  "c4.2xlarge": 8,
+  "c4.4xlarge": 16,
+  "c4.8xlarge": 36,
+  "c3.large": 2,
+  "c3.xlarge": 4,
+  "c3.2xlarge": 8,
+  "c3.4xlarge": 16,
+  "c3.8xlarge": 32,
+  "d2.xlarge": 4,
+  "d2.2xlarge": 8,
+  "d2.4xlarge": 16,
+  "d2.8xlarge": 36,
+  "g3.4xlarge": 16,
+  "f1.2xlarge": 8,
+  "f1.16xlarge": 64,
+  "g3.8xlarge": 32,
+  "g3.16xlarge": 64,
+  "g2.2xlarge": 8,
+  "g2.8xlarge": 32,
+  "h1.2xlarge": 8,
+  "h1.4xlarge": 16,
+  "h1.8xlarge": 32,
+  "h1.16xlarge": 64,
+  "i3.large": 2,
+  "i3.xlarge": 4,
+  "i3.2xlarge": 8,
+  "i3.4xlarge": 16,
+  "i3.8xlarge": 32,
+  "i3.16xlarge": 64,
+  "i2.xlarge": 4,
+  "i2.2xlarge": 8,
+  "i2.4xlarge": 16,
+  "i2.8xlarge": 32,
+  "m5.large": 2,
+  "m5.xlarge": 4,
+  "m5.2xlarge": 8,
+  "m5.4xlarge": 16,
+  "m5.12xlarge": 48,
+  "m5.24xlarge": 96,
+  "m4.large": 2,
+  "m4.xlarge": 4,
+  "m4.2xlarge": 8,
+  "m4.4xlarge": 16,
+  "m4.10xlarge": 40,
+  "m4.16xlarge": 64,
+  "m3.medium": 1,
+  "m3.large": 2,
+  "m3.xlarge": 4,
+  "m3.2xlarge": 8,
+  "p3.2xlarge": 8,
+  "p3.8xlarge": 32,
+  "p3.16xlarge": 64,
+  "p2.xlarge": 4,
+  "p2.8xlarge": 32,
+  "p2.16xlarge": 64,
+  "r4.large": 2,
+  "r4.xlarge": 4,
+  "r4.2xlarge": 8,
+  "r4.4xlarge": 16,
+  "r4.8xlarge": 32,
+  "r4.16xlarge": 64,
+  "r3.large": 2,
+  "r3.xlarge": 4,
+  "r3.2xlarge": 8,
+  "r3.4xlarge": 16,
+  "r3.8xlarge": 32,
+  "t2.nano": 1,
+  "t2.micro": 1,
+  "t2.small": 1,
+  "t2.medium": 2,
+  "t2.large": 2,
+  "t2.xlarge": 4,
+  "t2.2xlarge": 8,
+  "x1.16xlarge": 64,
+  "x1.32xlarge": 128,
+  "x1e.32xlarge": 128,
+  "x1e.16xlarge": 64,
+  "x1e.8xlarge": 32,
+  "x1e.4xlarge": 16,
+  "x1e.2xlarge": 8,
+  "x1e.xlarge": 4,
+}
+
+var ec2InstanceTypeRegexp = regexp.MustCompile(`[a-z][0-9][a-z]?\.[0-9]*(?:nano|micro|small|medium|large|xlarge)`)
+
+// ComputeUnitsFor returns the hourly compute units of an instance type,
+// given either as an EC2 type or as one of the Flight instance type
+// names, e.g. "compute-2C-3.75GB.small-c4.large".
+func ComputeUnitsFor(instanceType string) (int64, error) {
+  ec2Type := ec2InstanceTypeRegexp.FindString(instanceType)
+  if units, ok := ComputeUnits[ec2Type]; ok {
+    return units, nil
+  }
+  return 0, fmt.Errorf("No compute unit rating for instance type: %s", instanceType)
+}
+
+// QuotaItem is the compute unit burn of one set of instances within a
+// cluster.
+type QuotaItem struct {
+  StackName string `json:"StackName" yaml:"StackName"`
+  Role string `json:"Role" yaml:"Role"`
+  InstanceType string `json:"InstanceType" yaml:"InstanceType"`
+  Units int64 `json:"Units" yaml:"Units"`
+  Running int64 `json:"Running" yaml:"Running"`
+  MaxSize int64 `json:"MaxSize" yaml:"MaxSize"`
+  prefix string
+}
+
+func (i *QuotaItem) CurrentBurn() int64 {
+  return i.Units * i.Running
+}
+
+func (i *QuotaItem) MaximumBurn() int64 {
+  return i.Units * i.MaxSize
+}
+
+// QuotaUsage compares a cluster's compute unit burn with its quota.  A
+// quota of zero means the cluster has no budget.
+type QuotaUsage struct {
+  Quota int64 `json:"Quota" yaml:"Quota"`
+  Items []*QuotaItem `json:"Items" yaml:"Items"`
+}
+
+func (u *QuotaUsage) CurrentBurn() int64 {
+  var burn int64
+  for _, item := range u.Items {
+    burn += item.CurrentBurn()
+  }
+  return burn
+}
+
+func (u *QuotaUsage) MaximumBurn() int64 {
+  var burn int64
+  for _, item := range u.Items {
+    burn += item.MaximumBurn()
+  }
+  return burn
+}
+
+// stackInstances finds the instances a stack's parameters describe.
+// Each <Role>InstanceType parameter is one instance, or <Role>MaxNodes
+// instances when that parameter is present.
+func stackInstances(stackName string, params []*cloudformation.Parameter) ([]*QuotaItem, error) {
+  values := make(map[string]string)
+  for _, p := range params {
+    if p.ParameterValue != nil {
+      values[*p.ParameterKey] = *p.ParameterValue
+    }
+  }
+  items := []*QuotaItem{}
+  for _, p := range params {
+    if !strings.HasSuffix(*p.ParameterKey, "InstanceType") { continue }
+    role := strings.TrimSuffix(*p.ParameterKey, "InstanceType")
+    instanceType := values[*p.ParameterKey]
+    if instanceType == "other" {
+      instanceType = values[role + "InstanceTypeOther"]
+    }
+    if instanceType == "" { continue }
+    item := &QuotaItem{StackName: stackName, Role: strings.ToLower(role), InstanceType: instanceType, Running: 1, MaxSize: 1, prefix: role}
+    if maxNodes, ok := values[role + "MaxNodes"]; ok {
+      var err error
+      item.MaxSize, err = strconv.ParseInt(maxNodes, 10, 64)
+      if err != nil { return nil, fmt.Errorf("Invalid %sMaxNodes for stack %s: %s", role, stackName, maxNodes) }
+      item.Running = 0
+    }
+    items = append(items, item)
+  }
+  return items, nil
+}
+
+// quotaItems rates the instances a stack's parameters describe in
+// compute units.
+func quotaItems(stackName string, params []*cloudformation.Parameter) ([]*QuotaItem, error) {
+  items, err := stackInstances(stackName, params)
+  if err != nil { return nil, err }
+  for _, item := range items {
+    item.Units, err = ComputeUnitsFor(item.InstanceType)
+    if err != nil { return nil, err }
+  }
+  return items, nil
+}
+
+// instanceStacks loads the stacks that run the cluster's instances: its
+// master, then any compute queues and components.
+func (c *Cluster) instanceStacks() ([]*cloudformation.Stack, error) {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return nil, err }
+  masterName, err := c.updateStackName("master", "")
+  if err != nil { return nil, err }
+  masterStack, err := getStack(svc, masterName)
+  if err != nil { return nil, err }
+  c.Master = &Master{masterStack}
+  stacks := []*cloudformation.Stack{masterStack}
+  if c.Domain == nil {
+    return stacks, nil
+  }
+  err = c.LoadComputeGroups()
+  if err != nil { return nil, err }
+  for _, group := range c.ComputeGroups {
+    stacks = append(stacks, group.Stack)
+  }
+  componentStacks, err := getComponentStacksForCluster(context.Background(), c)
+  if err != nil { return nil, err }
+  return append(stacks, componentStacks...), nil
+}
+
+// computeGroup returns the loaded compute group for a stack, if any.
+func (c *Cluster) computeGroup(stackName string) *ComputeGroup {
+  for _, group := range c.ComputeGroups {
+    if *group.Stack.StackName == stackName {
+      return group
+    }
+  }
+  return nil
+}
+
+// QuotaUsage totals the compute unit burn of the cluster's master, its
+// compute queues and any components.  Queue sizes are taken from their
+// autoscaling groups where available.
+func (c *Cluster) QuotaUsage() (*QuotaUsage, error) {
+  stacks, err := c.instanceStacks()
+  if err != nil { return nil, err }
+  usage := &QuotaUsage{Items: []*QuotaItem{}}
+  usage.Quota, _ = strconv.ParseInt(getStackTag(c.Master.Stack, "flight:quota"), 10, 64)
+  for _, stack := range stacks {
+    items, err := quotaItems(*stack.StackName, stack.Parameters)
+    if err != nil { return nil, err }
+    if group := c.computeGroup(*stack.StackName); group != nil && group.ResourceName != "" {
+      for _, item := range items {
+        if item.Role == "compute" {
+          item.MaxSize, item.Running = int64(group.MaxSize()), int64(group.Running())
+        }
+      }
+    }
+    usage.Items = append(usage.Items, items...)
+  }
+  return usage, nil
+}
+
+// usageExcluding returns the maximum burn of everything but the named
+// stack.
+func (u *QuotaUsage) usageExcluding(stackName string) int64 {
+  var burn int64
+  for _, item := range u.Items {
+    if item.StackName != stackName {
+      burn += item.MaximumBurn()
+    }
+  }
+  return burn
+}
+
+// checkQuota refuses to launch a stack whose instances would take the
+// cluster's maximum burn over its quota.  A queue is capped to the
+// number of nodes that fit, as long as at least one does.
+func (c *Cluster) checkQuota(stackName string, params []*cloudformation.Parameter) error {
+  // instances are only rated when there is a quota to hold them to
+  if c.GetQuota() <= 0 { return nil }
+  usage, err := c.QuotaUsage()
+  if err != nil { return err }
+  if usage.Quota <= 0 { return nil }
+  items, err := quotaItems(stackName, params)
+  if err != nil { return err }
+  burn := usage.usageExcluding(stackName)
+  for _, item := range items {
+    burn += item.MaximumBurn()
+  }
+  if burn <= usage.Quota { return nil }
+
+  if len(items) == 1 && items[0].Role == "compute" {
+    item := items[0]
+    fit := (usage.Quota - (burn - item.MaximumBurn())) / item.Units
+    if fit >= 1 {
+      for _, p := range params {
+        if *p.ParameterKey == "ComputeMaxNodes" {
+          p.ParameterValue = aws.String(strconv.FormatInt(fit, 10))
+        } else if *p.ParameterKey == "ComputeInitialNodes" && p.ParameterValue != nil {
+          if initial, err := strconv.ParseInt(*p.ParameterValue, 10, 64); err == nil && initial > fit {
+            p.ParameterValue = aws.String(strconv.FormatInt(fit, 10))
+          }
+        }
+      }
+      c.MessageHandler(noticeEvent(fmt.Sprintf("Maximum size of %s capped from %d to %d nodes to fit the cluster quota of %dcu/h.", stackName, item.MaxSize, fit, usage.Quota)))
+      return nil
+    }
+  }
+  return fmt.Errorf("Launching %s would raise the maximum burn of cluster %s to %dcu/h, exceeding its quota of %dcu/h.", stackName, c.Name, burn, usage.Quota)
+}
+
+// checkQueueQuota refuses a new maximum size for a queue that would take
+// the cluster's maximum burn over its quota.
+func (c *Cluster) checkQueueQuota(stackName, instanceType string, maxSize int64) error {
+  if c.GetQuota() <= 0 { return nil }
+  usage, err := c.QuotaUsage()
+  if err != nil { return err }
+  if usage.Quota <= 0 { return nil }
+  units, err := ComputeUnitsFor(instanceType)
+  if err != nil { return err }
+  burn := usage.usageExcluding(stackName) + units * maxSize
+  if burn > usage.Quota {
+    fit := (usage.Quota - usage.usageExcluding(stackName)) / units
+    if fit < 0 { fit = 0 }
+    return fmt.Errorf("Maximum size %d would raise the maximum burn of cluster %s to %dcu/h, exceeding its quota of %dcu/h (at most %d nodes fit).", maxSize, c.Name, burn, usage.Quota, fit)
+  }
+  return nil
+}
+
+func (u *QuotaUsage) Render() string {
+  var buf bytes.Buffer
+  if u.Quota > 0 {
+    fmt.Fprintf(&buf, "Quota: %dcu/h\n", u.Quota)
+  } else {
+    buf.WriteString("Quota: none\n")
+  }
+  fmt.Fprintf(&buf, "Current burn: %dcu/h\n", u.CurrentBurn())
+  fmt.Fprintf(&buf, "Maximum burn: %dcu/h\n", u.MaximumBurn())
+  if u.Quota > 0 && u.MaximumBurn() > u.Quota {
+    fmt.Fprintf(&buf, "Over quota by: %dcu/h\n", u.MaximumBurn() - u.Quota)
+  }
+  buf.WriteString("\n")
+  w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+  fmt.Fprintln(w, "    STACK\tROLE\tINSTANCE TYPE\tCU/H\tRUNNING\tMAX\tCURRENT\tMAXIMUM")
+  for _, item := range u.Items {
+    fmt.Fprintf(w, "    %s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n", item.StackName, item.Role, item.InstanceType, item.Units, item.Running, item.MaxSize, item.CurrentBurn(), item.MaximumBurn())
+  }
+  w.Flush()
+  return buf.String()
+}
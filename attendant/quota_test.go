@@ -0,0 +1,157 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "context"
+  "strings"
+  "testing"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testParameters(values map[string]string) []*cloudformation.Parameter {
+  params := []*cloudformation.Parameter{}
+  for key, val := range values {
+    params = append(params, &cloudformation.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(val)})
+  }
+  return params
+}
+
+func TestComputeUnitsFor(t *testing.T) {
+  tests := map[string]int64{
+    "c4.large": 2,
+    "compute-2C-3.75GB.small-c4.large": 2,
+    "compute-8C-15GB.medium-c4.2xlarge": 8,
+  }
+  for instanceType, want := range tests {
+    got, err := ComputeUnitsFor(instanceType)
+    if err != nil || got != want {
+      t.Errorf("ComputeUnitsFor(%s) = %d, %v; want %d", instanceType, got, err, want)
+    }
+  }
+  if _, err := ComputeUnitsFor("not-an-instance"); err == nil {
+    t.Errorf("expected an error for an unknown instance type")
+  }
+}
+
+func TestQuotaItems(t *testing.T) {
+  items, err := quotaItems("flight-d1-c1-compute-q1", testParameters(map[string]string{
+    "ComputeInstanceType": "other",
+    "ComputeInstanceTypeOther": "c4.2xlarge",
+    "ComputeMaxNodes": "4",
+    "Unrelated": "x",
+  }))
+  if err != nil { t.Fatalf("quotaItems: %s", err) }
+  if len(items) != 1 {
+    t.Fatalf("%d items, want 1", len(items))
+  }
+  item := items[0]
+  if item.Role != "compute" || item.InstanceType != "c4.2xlarge" || item.Units != 8 {
+    t.Errorf("item = %+v", item)
+  }
+  if item.MaximumBurn() != 32 || item.CurrentBurn() != 0 {
+    t.Errorf("burn = %d current, %d maximum; want 0, 32", item.CurrentBurn(), item.MaximumBurn())
+  }
+
+  if _, err := quotaItems("s", testParameters(map[string]string{"ComputeInstanceType": "c4.large", "ComputeMaxNodes": "many"})); err == nil {
+    t.Errorf("expected an error for an invalid ComputeMaxNodes")
+  }
+}
+
+func TestCheckQuotaWithoutQuota(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", false)
+  // without a quota nothing is rated, so unknown instance types are fine
+  params := testParameters(map[string]string{"ComputeInstanceType": "unrated.type", "ComputeMaxNodes": "100"})
+  if err := cluster.checkQuota("flight-d1-c1-compute-q1", params); err != nil {
+    t.Errorf("checkQuota: %s", err)
+  }
+}
+
+func TestCheckQuota(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  probe := createTestCluster(t, d, "probe", false)
+  usage, err := probe.QuotaUsage()
+  if err != nil { t.Fatalf("QuotaUsage: %s", err) }
+  masterBurn := usage.MaximumBurn()
+
+  // room for the master and two c4.large nodes
+  cluster := c.NewCluster("c1", d, nil)
+  cluster.Quota = masterBurn + 4
+  if err := cluster.Create(context.Background(), false); err != nil {
+    t.Fatalf("Create: %s", err)
+  }
+
+  params := testParameters(map[string]string{"ComputeInstanceType": "c4.large", "ComputeMaxNodes": "8", "ComputeInitialNodes": "3"})
+  if err := cluster.checkQuota("flight-d1-c1-compute-q1", params); err != nil {
+    t.Fatalf("checkQuota: %s", err)
+  }
+  for _, p := range params {
+    if (*p.ParameterKey == "ComputeMaxNodes" || *p.ParameterKey == "ComputeInitialNodes") && *p.ParameterValue != "2" {
+      t.Errorf("%s = %s, want it capped to 2", *p.ParameterKey, *p.ParameterValue)
+    }
+  }
+
+  params = testParameters(map[string]string{"ComputeInstanceType": "c4.2xlarge", "ComputeMaxNodes": "1"})
+  if err := cluster.checkQuota("flight-d1-c1-compute-q2", params); err == nil {
+    t.Errorf("expected a queue that can't fit a single node to be refused")
+  }
+}
+
+func TestPrepareUpdateChecksQueue(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  probe := createTestCluster(t, d, "probe", true)
+  usage, err := probe.QuotaUsage()
+  if err != nil { t.Fatalf("QuotaUsage: %s", err) }
+
+  // exactly enough for the cluster as launched
+  cluster := c.NewCluster("c1", d, nil)
+  cluster.Quota = usage.MaximumBurn()
+  if err := cluster.Create(context.Background(), true); err != nil {
+    t.Fatalf("Create: %s", err)
+  }
+  ctx := context.Background()
+  if _, err := cluster.PrepareUpdate(ctx, "compute", "default", "", map[string]string{"ComputeMaxNodes": "1000"}, false); err == nil || !strings.Contains(err.Error(), "quota") {
+    t.Errorf("expected an update over the quota to be refused, got %v", err)
+  }
+  c.config.ComputeMaxNodes = 1
+  if _, err := cluster.PrepareUpdate(ctx, "compute", "default", "", map[string]string{"ComputeMaxNodes": "2"}, false); err == nil || !strings.Contains(err.Error(), "compute-max-nodes") {
+    t.Errorf("expected an update over compute-max-nodes to be refused, got %v", err)
+  }
+  c.config.ComputeMaxNodes = 0
+  if _, err := cluster.PrepareUpdate(ctx, "compute", "default", "", map[string]string{"ComputeMaxNodes": "1"}, false); err != nil {
+    t.Errorf("PrepareUpdate shrinking the queue: %s", err)
+  }
+}
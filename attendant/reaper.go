@@ -0,0 +1,159 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "fmt"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Reaped records what happened to an expired cluster or queue.
+type Reaped struct {
+  Descriptor string `json:"Descriptor" yaml:"Descriptor"`
+  StackName string `json:"StackName" yaml:"StackName"`
+  ExpiryTime int64 `json:"ExpiryTime" yaml:"ExpiryTime"`
+  Action string `json:"Action" yaml:"Action"`
+  Reason string `json:"Reason,omitempty" yaml:"Reason,omitempty"`
+}
+
+type reapTarget struct {
+  kind string
+  domain string
+  cluster string
+  queue string
+}
+
+func parseExpiryDescriptor(descriptor string) (*reapTarget, error) {
+  parts := strings.SplitN(descriptor, ":", 2)
+  if len(parts) == 2 {
+    names := strings.Split(parts[1], "/")
+    switch {
+    case parts[0] == "CLUSTER" && len(names) == 2:
+      return &reapTarget{kind: parts[0], domain: names[0], cluster: names[1]}, nil
+    case parts[0] == "QUEUE" && len(names) == 3:
+      return &reapTarget{kind: parts[0], domain: names[0], cluster: names[1], queue: names[2]}, nil
+    case parts[0] == "SOLO" && len(names) == 1:
+      return &reapTarget{kind: parts[0], cluster: names[0]}, nil
+    }
+  }
+  return nil, fmt.Errorf("Invalid expiry descriptor: %s", descriptor)
+}
+
+// clusterKey identifies the cluster a target or stack belongs to.
+func (t *reapTarget) clusterKey() string {
+  return t.domain + "/" + t.cluster
+}
+
+func stackClusterKey(stack *cloudformation.Stack) string {
+  return getStackTag(stack, "flight:domain") + "/" + getStackTag(stack, "flight:cluster")
+}
+
+func (t *reapTarget) destroy(ctx context.Context, c *Client) error {
+  var cluster *Cluster
+  if t.kind == "SOLO" {
+    cluster = c.NewCluster(t.cluster, nil, DiscardEvents)
+  } else {
+    cluster = c.NewCluster(t.cluster, c.NewDomain(t.domain, nil), DiscardEvents)
+  }
+  // stops the event processing that the destroy starts
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+  if t.kind == "QUEUE" {
+    return cluster.DestroyQueue(ctx, t.queue)
+  }
+  return cluster.Destroy(ctx)
+}
+
+func Reap(ctx context.Context, grace time.Duration, dryRun bool) ([]*Reaped, error) {
+  return DefaultClient().Reap(ctx, grace, dryRun)
+}
+
+// Reap destroys the clusters and queues that expired more than grace
+// ago.  Anything with a stack operation in progress is left alone, as
+// are queues belonging to a cluster that is itself being reaped.
+func (c *Client) Reap(ctx context.Context, grace time.Duration, dryRun bool) ([]*Reaped, error) {
+  c.StackCache().Invalidate()
+  stacks, err := c.ExpiredStacks()
+  if err != nil { return nil, err }
+  busy := make(map[string]bool)
+  err = c.eachStack(ctx, func(stack *cloudformation.Stack) {
+    if strings.HasSuffix(*stack.StackStatus, "_IN_PROGRESS") {
+      busy[stackClusterKey(stack)] = true
+      busy[*stack.StackName] = true
+    }
+  })
+  if err != nil { return nil, err }
+
+  // Reap whole clusters before queues.
+  sort.SliceStable(stacks, func(i, j int) bool {
+    return getStackTag(stacks[i], "flight:type") != "compute" && getStackTag(stacks[j], "flight:type") == "compute"
+  })
+
+  results := []*Reaped{}
+  clusters := make(map[string]bool)
+  now := time.Now()
+  for _, stack := range stacks {
+    result := &Reaped{Descriptor: expiryDescriptor(stack), StackName: *stack.StackName}
+    results = append(results, result)
+    result.ExpiryTime, _ = strconv.ParseInt(getStackTag(stack, "flight:expiry"), 10, 64)
+    target, err := parseExpiryDescriptor(result.Descriptor)
+    if err != nil {
+      result.Action, result.Reason = "skipped", err.Error()
+      continue
+    }
+    reapAt := time.Unix(result.ExpiryTime, 0).Add(grace)
+    switch {
+    case reapAt.After(now):
+      result.Action, result.Reason = "skipped", "in grace period until " + reapAt.Format(time.RFC3339)
+    case target.kind == "QUEUE" && clusters[target.clusterKey()]:
+      result.Action, result.Reason = "skipped", "cluster is also expired"
+    case target.kind == "QUEUE" && busy[*stack.StackName], target.kind != "QUEUE" && busy[target.clusterKey()]:
+      result.Action, result.Reason = "skipped", "stack operation in progress"
+    case dryRun:
+      result.Action = "would reap"
+    default:
+      if err := target.destroy(ctx, c); err != nil {
+        result.Action, result.Reason = "failed", err.Error()
+      } else {
+        result.Action = "reaped"
+      }
+    }
+    if target.kind != "QUEUE" && result.Action != "skipped" {
+      clusters[target.clusterKey()] = true
+    }
+  }
+  c.StackCache().Invalidate()
+  return results, nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "testing"
+)
+
+func TestParseExpiryDescriptor(t *testing.T) {
+  tests := map[string]reapTarget{
+    "CLUSTER:d1/c1": {kind: "CLUSTER", domain: "d1", cluster: "c1"},
+    "QUEUE:d1/c1/q1": {kind: "QUEUE", domain: "d1", cluster: "c1", queue: "q1"},
+    "SOLO:c1": {kind: "SOLO", cluster: "c1"},
+  }
+  for descriptor, want := range tests {
+    got, err := parseExpiryDescriptor(descriptor)
+    if err != nil {
+      t.Errorf("parseExpiryDescriptor(%s): %s", descriptor, err)
+      continue
+    }
+    if *got != want {
+      t.Errorf("parseExpiryDescriptor(%s) = %+v, want %+v", descriptor, *got, want)
+    }
+  }
+  for _, descriptor := range []string{"", "CLUSTER", "CLUSTER:d1", "QUEUE:d1/c1", "SOLO:d1/c1", "OTHER:d1/c1"} {
+    if _, err := parseExpiryDescriptor(descriptor); err == nil {
+      t.Errorf("parseExpiryDescriptor(%q) should fail", descriptor)
+    }
+  }
+}
+
+func TestExpiryDescriptorRoundTrip(t *testing.T) {
+  c, _ := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", false)
+  target, err := parseExpiryDescriptor(expiryDescriptor(cluster.Master.Stack))
+  if err != nil { t.Fatalf("parseExpiryDescriptor: %s", err) }
+  if target.clusterKey() != stackClusterKey(cluster.Master.Stack) {
+    t.Errorf("cluster key = %s, want %s", target.clusterKey(), stackClusterKey(cluster.Master.Stack))
+  }
+}
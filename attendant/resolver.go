@@ -0,0 +1,258 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+  "io/ioutil"
+  "os"
+  "regexp"
+  "sort"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Parameter values may contain tokens of the form:
+//
+//   %NAME%          a registered token, or the config key "name"
+//   %NAME|default%  as above, using default when NAME is not known
+//   %env:VAR%       the value of environment variable VAR
+//   %file:path%     the contents of a file
+//
+// A parameter whose value is exactly a token that resolves to %NULL% is
+// not passed to the template.
+type TokenScope int
+
+const (
+  ConfigScope TokenScope = iota
+  DomainScope
+  ClusterScope
+  ApplianceScope
+)
+
+type TokenContext struct {
+  Client *Client
+  Domain *Domain
+  Cluster *Cluster
+  Appliance *Appliance
+}
+
+type TokenFunc func(ctx *TokenContext) string
+
+var tokenPattern = regexp.MustCompile(`%(?:(env|file):([^%|]+)|([A-Z][A-Z0-9_]*))(?:\|([^%]*))?%`)
+
+var tokenRegistry = map[TokenScope]map[string]TokenFunc{
+  ConfigScope: {
+    "ACCESS_KEY_NAME": func(ctx *TokenContext) string { return ctx.Client.Config().AccessKeyName },
+  },
+  DomainScope: {
+    "VPC": func(ctx *TokenContext) string { return ctx.Domain.VPC() },
+    "DOMAIN": func(ctx *TokenContext) string { return ctx.Domain.Prefix() },
+    "PUB_ROUTE_TABLE": func(ctx *TokenContext) string { return ctx.Domain.PublicRouteTable() },
+    "PUB_SUBNET": func(ctx *TokenContext) string { return ctx.Domain.PublicSubnet() },
+    "MGT_SUBNET": func(ctx *TokenContext) string { return ctx.Domain.ManagementSubnet() },
+    "PRV_SUBNET": func(ctx *TokenContext) string { return ctx.Domain.PrivateSubnet() },
+    "PLACEMENT_GROUP": func(ctx *TokenContext) string { return ctx.Domain.PlacementGroup() },
+    "MASTER_IP": func(ctx *TokenContext) string { return ctx.Domain.MasterIP() },
+  },
+  ClusterScope: {
+    "CLUSTER_NAME": func(ctx *TokenContext) string { return ctx.Cluster.Name },
+    "MASTER_INSTANCE_TYPE": func(ctx *TokenContext) string {
+      if ctx.Client.Config().MasterInstanceOverride != "" { return "other" }
+      return ctx.Client.Config().MasterInstanceType
+    },
+    "MASTER_INSTANCE_OVERRIDE": func(ctx *TokenContext) string {
+      return nullIfEmpty(ctx.Client.Config().MasterInstanceOverride)
+    },
+    "MASTER_FEATURES": func(ctx *TokenContext) string {
+      // XXX - should password-auth be mandated within template?
+      masterFeatures := ctx.Client.Config().MasterFeatures
+      if masterFeatures != "" { return masterFeatures + " password-auth" }
+      return "password-auth"
+    },
+    "COMPUTE_INSTANCE_TYPE": func(ctx *TokenContext) string {
+      if ctx.Client.Config().QueueInstanceOverride != "" { return "other" }
+      // if we're launching via cluster launch, we use default-queue-instance-type, otherwise we use queue-instance-type
+      val := ctx.Client.Config().QueueInstanceType
+      if val == "" { val = ctx.Client.Config().DefaultQueueInstanceType }
+      return val
+    },
+    "COMPUTE_INSTANCE_OVERRIDE": func(ctx *TokenContext) string {
+      return nullIfEmpty(ctx.Client.Config().QueueInstanceOverride)
+    },
+    "NETWORK_POOL": func(ctx *TokenContext) string { return ctx.Cluster.Network.NetworkPool() },
+    "NETWORK_INDEX": func(ctx *TokenContext) string { return ctx.Cluster.Network.NetworkIndex() },
+    "PUB_SUBNET": func(ctx *TokenContext) string { return ctx.Cluster.Network.PublicSubnet() },
+    "MGT_SUBNET": func(ctx *TokenContext) string { return ctx.Cluster.Network.ManagementSubnet() },
+    "PRV_SUBNET": func(ctx *TokenContext) string { return ctx.Cluster.Network.PrivateSubnet() },
+    "PLACEMENT_GROUP": func(ctx *TokenContext) string { return ctx.Cluster.Network.PlacementGroup() },
+    "MASTER_IP": func(ctx *TokenContext) string { return ctx.Cluster.Master.PrivateIP() },
+    "CLUSTER_UUID": func(ctx *TokenContext) string { return ctx.Cluster.Master.ClusterUUID() },
+    "CLUSTER_SECURITY_TOKEN": func(ctx *TokenContext) string { return ctx.Cluster.Master.ClusterSecurityToken() },
+  },
+  ApplianceScope: {
+    "APPLIANCE_FEATURES": func(ctx *TokenContext) string {
+      val, _ := ctx.Client.Config().Setting(ctx.Appliance.Name + "-features")
+      return val
+    },
+    "APPLIANCE_PROFILES": func(ctx *TokenContext) string {
+      val, _ := ctx.Client.Config().Setting(ctx.Appliance.Name + "-profiles")
+      return val
+    },
+    "APPLIANCE_INSTANCE_TYPE": func(ctx *TokenContext) string {
+      val, _ := ctx.Client.Config().Setting(ctx.Appliance.Name + "-instance-type")
+      if val == "" { val = ctx.Client.Config().ApplianceInstanceType }
+      if val == "" { val = ApplianceInstanceTypes[0] }
+      return val
+    },
+  },
+}
+
+// RegisterToken adds a token to a scope, replacing any existing token
+// of the same name in that scope.
+func RegisterToken(scope TokenScope, name string, fn TokenFunc) {
+  tokenRegistry[scope][name] = fn
+}
+
+func nullIfEmpty(s string) string {
+  if s == "" { return "%NULL%" }
+  return s
+}
+
+// Resolver substitutes tokens using the scopes available for the
+// component being launched; more specific scopes take precedence.
+type Resolver struct {
+  ctx *TokenContext
+  scopes []TokenScope
+}
+
+func configResolver(client *Client) *Resolver {
+  return &Resolver{&TokenContext{Client: client}, []TokenScope{ConfigScope}}
+}
+
+func clusterResolver(cluster *Cluster) *Resolver {
+  if cluster.Domain == nil {
+    return &Resolver{&TokenContext{Client: cluster.Client(), Cluster: cluster}, []TokenScope{ClusterScope, ConfigScope}}
+  }
+  return &Resolver{&TokenContext{Client: cluster.Client(), Domain: cluster.Domain, Cluster: cluster}, []TokenScope{ClusterScope, DomainScope, ConfigScope}}
+}
+
+func applianceResolver(appliance *Appliance) *Resolver {
+  return &Resolver{&TokenContext{Client: appliance.Client(), Domain: appliance.Domain, Appliance: appliance}, []TokenScope{ApplianceScope, DomainScope, ConfigScope}}
+}
+
+func (r *Resolver) lookup(name string) (string, bool) {
+  for _, scope := range r.scopes {
+    if fn, exists := tokenRegistry[scope][name]; exists {
+      return fn(r.ctx), true
+    }
+  }
+  configKey := strings.ToLower(strings.Replace(name, "_", "-", -1))
+  return r.ctx.Client.Config().Setting(configKey)
+}
+
+func (r *Resolver) resolveToken(match []string) (string, error) {
+  source, arg, name, fallback := match[1], match[2], match[3], match[4]
+  hasFallback := strings.Contains(match[0], "|")
+  var val string
+  var found bool
+  switch source {
+  case "env":
+    val, found = os.LookupEnv(arg)
+  case "file":
+    data, err := ioutil.ReadFile(arg)
+    if err != nil && !(os.IsNotExist(err) && hasFallback) { return "", err }
+    val, found = strings.TrimRight(string(data), "\r\n"), err == nil
+  default:
+    if name == "NULL" { return "%NULL%", nil }
+    val, found = r.lookup(name)
+  }
+  if !found {
+    if hasFallback { return fallback, nil }
+    return "", fmt.Errorf("Unknown token: %s", match[0])
+  }
+  return val, nil
+}
+
+// Resolve substitutes every token in value.  The second result is false
+// when the value resolves to %NULL% and the parameter should be dropped.
+func (r *Resolver) Resolve(value string) (string, bool, error) {
+  matches := tokenPattern.FindAllStringSubmatch(value, -1)
+  if len(matches) == 1 && matches[0][0] == value {
+    val, err := r.resolveToken(matches[0])
+    if err != nil { return "", false, err }
+    return val, val != "%NULL%", nil
+  }
+  var err error
+  resolved := tokenPattern.ReplaceAllStringFunc(value, func(token string) string {
+    if err != nil { return "" }
+    var val string
+    val, err = r.resolveToken(tokenPattern.FindStringSubmatch(token))
+    if val == "%NULL%" { val = "" }
+    return val
+  })
+  if err != nil { return "", false, err }
+  return resolved, true, nil
+}
+
+// LaunchParameters resolves a parameter set into stack parameters and
+// checks them against the template they will be launched with.
+func (r *Resolver) LaunchParameters(set *ParameterSet, templateUrl string) ([]*cloudformation.Parameter, error) {
+  params, err := r.resolveParameters(set)
+  if err != nil { return nil, err }
+  err = set.Validate(r.ctx.Client, templateUrl, params)
+  if err != nil { return nil, err }
+  return params, nil
+}
+
+func (r *Resolver) resolveParameters(set *ParameterSet) ([]*cloudformation.Parameter, error) {
+  parameterSet := set.Values
+  keys := []string{}
+  for key, _ := range parameterSet {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+
+  params := []*cloudformation.Parameter{}
+  for _, key := range keys {
+    val, keep, err := r.Resolve(parameterSet[key])
+    if err != nil { return nil, fmt.Errorf("Unable to resolve parameter %s: %s", key, err.Error()) }
+    if keep {
+      params = append(params, &cloudformation.Parameter{
+        ParameterKey: aws.String(key),
+        ParameterValue: aws.String(val),
+      })
+    } else {
+      r.ctx.Client.noteDroppedParameter(key)
+    }
+  }
+  return params, nil
+}
@@ -0,0 +1,126 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestResolve(t *testing.T) {
+  c, _ := newTestClient(t)
+  c.Config().AccessKeyName = "my-key"
+  c.Config().Settings["compute-group-label"] = "gpu"
+  os.Setenv("FLY_TEST_RESOLVE", "from-env")
+  defer os.Unsetenv("FLY_TEST_RESOLVE")
+  dir, err := ioutil.TempDir("", "fly-resolve")
+  if err != nil { t.Fatal(err) }
+  defer os.RemoveAll(dir)
+  path := filepath.Join(dir, "value")
+  ioutil.WriteFile(path, []byte("from-file\n"), 0600)
+
+  r := configResolver(c)
+  tests := []struct {
+    value string
+    want string
+    keep bool
+  }{
+    {"plain", "plain", true},
+    {"%ACCESS_KEY_NAME%", "my-key", true},
+    {"key-%ACCESS_KEY_NAME%-1", "key-my-key-1", true},
+    {"%COMPUTE_GROUP_LABEL%", "gpu", true},
+    {"%UNKNOWN_SETTING|fallback%", "fallback", true},
+    {"%env:FLY_TEST_RESOLVE%", "from-env", true},
+    {"%file:" + path + "%", "from-file", true},
+    {"%file:" + path + ".missing|none%", "none", true},
+    {"%NULL%", "%NULL%", false},
+    {"a%NULL%b", "ab", true},
+  }
+  for _, test := range tests {
+    got, keep, err := r.Resolve(test.value)
+    if err != nil {
+      t.Errorf("Resolve(%q): %s", test.value, err)
+      continue
+    }
+    if got != test.want || keep != test.keep {
+      t.Errorf("Resolve(%q) = %q, %v; want %q, %v", test.value, got, keep, test.want, test.keep)
+    }
+  }
+}
+
+func TestResolveUnknownToken(t *testing.T) {
+  c, _ := newTestClient(t)
+  if _, _, err := configResolver(c).Resolve("%NO_SUCH_SETTING%"); err == nil {
+    t.Errorf("expected an error for an unknown token")
+  }
+}
+
+func TestResolveInstanceTypes(t *testing.T) {
+  c, _ := newTestClient(t)
+  cluster := c.NewCluster("c1", nil, nil)
+  r := clusterResolver(cluster)
+
+  if got, _, _ := r.Resolve("%MASTER_INSTANCE_TYPE%"); got != MasterInstanceTypes[0] {
+    t.Errorf("master instance type = %q, want %q", got, MasterInstanceTypes[0])
+  }
+  if got, _, _ := r.Resolve("%COMPUTE_INSTANCE_TYPE%"); got != ComputeInstanceTypes[0] {
+    t.Errorf("compute instance type = %q, want %q", got, ComputeInstanceTypes[0])
+  }
+  if _, keep, _ := r.Resolve("%MASTER_INSTANCE_OVERRIDE%"); keep {
+    t.Errorf("empty master instance override should be dropped")
+  }
+
+  c.Config().MasterInstanceOverride = "c5.large"
+  c.Config().QueueInstanceType = "compute-8C-15GB.medium-c4.2xlarge"
+  if got, _, _ := r.Resolve("%MASTER_INSTANCE_TYPE%"); got != "other" {
+    t.Errorf("overridden master instance type = %q, want other", got)
+  }
+  if got, _, _ := r.Resolve("%MASTER_INSTANCE_OVERRIDE%"); got != "c5.large" {
+    t.Errorf("master instance override = %q, want c5.large", got)
+  }
+  if got, _, _ := r.Resolve("%COMPUTE_INSTANCE_TYPE%"); got != "compute-8C-15GB.medium-c4.2xlarge" {
+    t.Errorf("compute instance type = %q", got)
+  }
+  if got, _, _ := r.Resolve("%MASTER_FEATURES%"); got != "password-auth" {
+    t.Errorf("master features = %q, want password-auth", got)
+  }
+}
+
+func TestResolveParametersDropsNull(t *testing.T) {
+  c, _ := newTestClient(t)
+  set := &ParameterSet{Source: "test", Values: map[string]string{"Keep": "x", "Drop": "%NULL%"}}
+  params, err := configResolver(c).resolveParameters(set)
+  if err != nil { t.Fatalf("resolveParameters: %s", err) }
+  if len(params) != 1 || *params[0].ParameterKey != "Keep" {
+    t.Errorf("params = %v, want only Keep", params)
+  }
+}
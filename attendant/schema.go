@@ -0,0 +1,182 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// ConfigExtraKeys are settings accepted beyond those in ConfigDefaults,
+// which have no default value.
+var ConfigExtraKeys = []string{
+  "config", "context", "contexts", "current-context", "domain", "key-pair",
+  "launch-with-default-queue", "simple-output",
+}
+
+// IsKnownConfigKey reports whether key is a setting that fly uses.  A
+// key may be qualified with the command it applies to, as in
+// "domain:clusterList".
+func IsKnownConfigKey(key string) bool {
+  if i := strings.Index(key, ":"); i >= 0 { key = key[:i] }
+  _, exists := ConfigDefaults[key]
+  return exists || containsS(ConfigExtraKeys, key)
+}
+
+type configCheck func(value string) error
+
+func oneOf(values []string) configCheck {
+  return func(value string) error {
+    if containsS(values, value) { return nil }
+    return fmt.Errorf("must be one of: %s", strings.Join(values, ", "))
+  }
+}
+
+func intRange(min, max int64) configCheck {
+  return func(value string) error {
+    i, err := strconv.ParseInt(value, 10, 64)
+    if err != nil { return fmt.Errorf("must be a whole number") }
+    if i < min { return fmt.Errorf("must be at least %d", min) }
+    if max > 0 && i > max { return fmt.Errorf("must be no more than %d", max) }
+    return nil
+  }
+}
+
+func isStateBackend(value string) error {
+  if containsS(StateBackends, StateBackendFor(value)) { return nil }
+  return fmt.Errorf("must be one of: %s (or sqlite, for bolt)", strings.Join(StateBackends, ", "))
+}
+
+func isBool(value string) error {
+  if _, err := strconv.ParseBool(value); err != nil { return fmt.Errorf("must be true or false") }
+  return nil
+}
+
+func isDuration(value string) error {
+  if _, err := time.ParseDuration(value); err != nil { return fmt.Errorf("must be a duration such as 30s or 1h") }
+  return nil
+}
+
+func isPrice(value string) error {
+  f, err := strconv.ParseFloat(value, 64)
+  if err != nil || f < 0 { return fmt.Errorf("must be a price in dollars per hour") }
+  return nil
+}
+
+var configChecks = map[string]configCheck{
+  "region": oneOf(AwsRegions),
+  "output": oneOf(OutputFormats),
+  "progress": oneOf(ProgressFormats),
+  "backend": oneOf([]string{"aws", "sim"}),
+  "state-backend": isStateBackend,
+  "scheduler-type": oneOf(SchedulerTypes),
+  "preload-software": oneOf(SoftwareTypes),
+  "master-instance-type": oneOf(MasterInstanceTypes),
+  "master-instance-override": oneOf(InstanceTypes),
+  "default-queue-instance-type": oneOf(ComputeInstanceTypes),
+  "queue-instance-type": oneOf(ComputeInstanceTypes),
+  "queue-instance-override": oneOf(InstanceTypes),
+  "appliance-instance-type": oneOf(ApplianceInstanceTypes),
+  "directory-instance-type": oneOf(ApplianceInstanceTypes),
+  "monitor-instance-type": oneOf(ApplianceInstanceTypes),
+  "access-manager-instance-type": oneOf(ApplianceInstanceTypes),
+  "storage-manager-instance-type": oneOf(ApplianceInstanceTypes),
+  "master-system-volume-type": oneOf(SystemVolumeTypes),
+  "compute-system-volume-type": oneOf(SystemVolumeTypes),
+  "master-home-volume-type": oneOf(OtherVolumeTypes),
+  "master-apps-volume-type": oneOf(OtherVolumeTypes),
+  "master-system-volume-size": intRange(20, 16384),
+  "master-home-volume-size": intRange(1, 16384),
+  "master-apps-volume-size": intRange(1, 16384),
+  "compute-initial-nodes": intRange(0, 0),
+  "compute-max-nodes": intRange(1, 0),
+  "swap-size": intRange(0, 0),
+  "swap-size-max": intRange(0, 0),
+  "oss-group-size": intRange(1, 0),
+  "region-concurrency": intRange(1, 64),
+  "compute-spot-price": isPrice,
+  "validate-parameters": isBool,
+  "delete-failed-stacks": isBool,
+  "cache-credentials": isBool,
+  "disable-tls-verify": isBool,
+  "launch-with-default-queue": isBool,
+  "stack-cache-ttl": isDuration,
+  "role-duration": isDuration,
+}
+
+// ValidateConfigValue checks the value of a setting against the values
+// that it may take.  Settings whose default is empty may also be left
+// empty, and settings without a known set of values are always valid.
+func ValidateConfigValue(key, value string) error {
+  if i := strings.Index(key, ":"); i >= 0 { key = key[:i] }
+  check, exists := configChecks[key]
+  if !exists { return nil }
+  if value == "" && ConfigDefaults[key] == "" { return nil }
+  if err := check(value); err != nil {
+    return fmt.Errorf("Invalid value '%s' for %s: %s.", value, key, err.Error())
+  }
+  return nil
+}
+
+// ValidateConfigSetting checks a setting as ValidateConfigValue does,
+// given a function returning the settings it is used with.  Any region
+// is accepted when a service endpoint is overridden, as it is at launch.
+func ValidateConfigSetting(key, value string, get func(key string) string) error {
+  if key == "region" && hasEndpointSetting(get) { return nil }
+  return ValidateConfigValue(key, value)
+}
+
+func hasEndpointSetting(get func(key string) string) bool {
+  for key := range ConfigDefaults {
+    if strings.HasSuffix(key, "-endpoint") && get(key) != "" { return true }
+  }
+  return false
+}
+
+// ValidateConfigLimits checks settings that limit one another, given a
+// function returning the effective value of each setting.
+func ValidateConfigLimits(get func(key string) string) []error {
+  errs := []error{}
+  limits := [][2]string{
+    {"compute-initial-nodes", "compute-max-nodes"},
+    {"swap-size", "swap-size-max"},
+  }
+  for _, limit := range limits {
+    value, err := strconv.ParseInt(get(limit[0]), 10, 64)
+    if err != nil { continue }
+    max, err := strconv.ParseInt(get(limit[1]), 10, 64)
+    if err != nil { continue }
+    if value > max {
+      errs = append(errs, fmt.Errorf("%s (%d) exceeds %s (%d).", limit[0], value, limit[1], max))
+    }
+  }
+  return errs
+}
@@ -0,0 +1,98 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "testing"
+)
+
+func TestIsKnownConfigKey(t *testing.T) {
+  for _, key := range []string{"region", "master-instance-type", "domain:clusterList", "contexts"} {
+    if !IsKnownConfigKey(key) {
+      t.Errorf("%s should be a known key", key)
+    }
+  }
+  if IsKnownConfigKey("regoin") {
+    t.Errorf("regoin should not be a known key")
+  }
+}
+
+func TestValidateConfigValue(t *testing.T) {
+  valid := map[string]string{
+    "region": "eu-west-1",
+    "compute-max-nodes": "10",
+    "stack-cache-ttl": "5m",
+    "validate-parameters": "false",
+    "compute-spot-price": "0.25",
+    "template-staging-bucket": "anything",
+    "price-file": "",
+    "state-backend": "sqlite",
+  }
+  for key, value := range valid {
+    if err := ValidateConfigValue(key, value); err != nil {
+      t.Errorf("ValidateConfigValue(%s, %q): %s", key, value, err)
+    }
+  }
+  invalid := map[string]string{
+    "region": "mars-1",
+    "compute-max-nodes": "0",
+    "region-concurrency": "100",
+    "stack-cache-ttl": "soon",
+    "validate-parameters": "maybe",
+    "compute-spot-price": "-1",
+    "master-instance-type": "",
+    "state-backend": "mysql",
+  }
+  for key, value := range invalid {
+    if err := ValidateConfigValue(key, value); err == nil {
+      t.Errorf("ValidateConfigValue(%s, %q) should fail", key, value)
+    }
+  }
+}
+
+func TestValidateConfigSettingRegion(t *testing.T) {
+  settings := map[string]string{}
+  get := func(key string) string { return settings[key] }
+  if err := ValidateConfigSetting("region", "local-1", get); err == nil {
+    t.Errorf("expected an unknown region to be refused without endpoint overrides")
+  }
+  settings["cloudformation-endpoint"] = "http://localhost:4566"
+  if err := ValidateConfigSetting("region", "local-1", get); err != nil {
+    t.Errorf("ValidateConfigSetting with an endpoint override: %s", err)
+  }
+}
+
+func TestValidateConfigLimits(t *testing.T) {
+  settings := map[string]string{"compute-initial-nodes": "4", "compute-max-nodes": "2", "swap-size": "1", "swap-size-max": "2"}
+  errs := ValidateConfigLimits(func(key string) string { return settings[key] })
+  if len(errs) != 1 {
+    t.Errorf("%d errors, want 1: %v", len(errs), errs)
+  }
+}
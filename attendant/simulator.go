@@ -0,0 +1,114 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "os"
+  "path/filepath"
+  "sync"
+  "time"
+)
+
+var SimulatorLatency = 200 * time.Millisecond
+
+// Simulator is a Provider that keeps a persistent in-memory backend for
+// each region under a local directory, so that domains, clusters and
+// appliances can be launched and managed without an AWS account.
+type Simulator struct {
+  Directory string
+
+  mutex sync.Mutex
+  regions map[string]*MemoryProvider
+}
+
+func NewSimulator(directory string) (*Simulator, error) {
+  if directory == "" {
+    directory = filepath.Join(os.Getenv("HOME"), ".fly-sim")
+  }
+  err := os.MkdirAll(directory, 0700)
+  if err != nil { return nil, err }
+  return &Simulator{Directory: directory, regions: make(map[string]*MemoryProvider)}, nil
+}
+
+func (s *Simulator) region(c *Client) (*MemoryProvider, error) {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  region := c.Config().AwsRegion
+  if p, exists := s.regions[region]; exists {
+    return p, nil
+  }
+  p := NewMemoryProvider(region)
+  p.KeyPairs = nil
+  p.Latency = SimulatorLatency
+  p.StateFile = filepath.Join(s.Directory, region + ".json")
+  p.Failures = make(map[string]string)
+  for _, resource := range c.Config().SimulatorFailures {
+    p.Failures[resource] = "Simulated failure"
+  }
+  err := p.Load()
+  if err != nil { return nil, err }
+  s.regions[region] = p
+  return p, nil
+}
+
+func (s *Simulator) Stacks(c *Client) (StackService, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.Stacks(c)
+}
+
+func (s *Simulator) Network(c *Client) (NetworkService, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.Network(c)
+}
+
+func (s *Simulator) Events(c *Client) (EventBus, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.Events(c)
+}
+
+func (s *Simulator) Autoscaling(c *Client) (AutoscalingService, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.Autoscaling(c)
+}
+
+func (s *Simulator) State(c *Client) (StateStore, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.State(c)
+}
+
+func (s *Simulator) Account(c *Client) (*Account, error) {
+  p, err := s.region(c)
+  if err != nil { return nil, err }
+  return p.Account(c)
+}
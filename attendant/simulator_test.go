@@ -0,0 +1,80 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "testing"
+)
+
+func newTestSimulatorClient(t *testing.T, directory, region string) *Client {
+  sim, err := NewSimulator(directory)
+  if err != nil { t.Fatalf("NewSimulator: %s", err) }
+  c, err := NewClient(WithProvider(sim), WithRegion(region), WithEventSink(DiscardEvents))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  return c
+}
+
+func simulatedStackExists(t *testing.T, c *Client, name string) bool {
+  svc, err := c.CloudFormation()
+  if err != nil { t.Fatalf("CloudFormation: %s", err) }
+  _, err = getStack(svc, name)
+  return err == nil
+}
+
+func TestSimulator(t *testing.T) {
+  latency := SimulatorLatency
+  SimulatorLatency = 0
+  defer func() { SimulatorLatency = latency }()
+  directory := t.TempDir()
+
+  c := newTestSimulatorClient(t, directory, "us-east-1")
+  createTestDomain(t, c, "d1")
+
+  // a new simulator picks up the state saved by the last one
+  c = newTestSimulatorClient(t, directory, "us-east-1")
+  if !simulatedStackExists(t, c, "flight-d1") {
+    t.Errorf("domain stack wasn't kept between simulators")
+  }
+  if simulatedStackExists(t, c.ForRegion("eu-west-1"), "flight-d1") {
+    t.Errorf("domain stack appeared in another region")
+  }
+}
+
+func TestSimulatorFailures(t *testing.T) {
+  latency := SimulatorLatency
+  SimulatorLatency = 0
+  defer func() { SimulatorLatency = latency }()
+
+  c := newTestSimulatorClient(t, t.TempDir(), "us-east-1")
+  c.config.SimulatorFailures = []string{"InternetGateway"}
+  if err := c.NewDomain("d1", nil).Create(context.Background(), "d1", ""); err == nil {
+    t.Errorf("expected the simulated failure to fail the launch")
+  }
+}
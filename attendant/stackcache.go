@@ -0,0 +1,209 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package attendant
+
+import (
+  "encoding/json"
+  "io/ioutil"
+  "os"
+  "sync"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/request"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// StackCache holds the stack listings for each account and region so
+// that repeated lookups don't describe every stack again.  Listings
+// expire after TTL and are dropped by Invalidate whenever fly creates,
+// updates or deletes a stack.  If Path is set the listings are also
+// kept on disk and shared between invocations.
+type StackCache struct {
+  TTL time.Duration
+  Path string
+  mutex sync.Mutex
+  entries map[string]*stackCacheEntry
+}
+
+type stackCacheEntry struct {
+  Time time.Time
+  Stacks []*cloudformation.Stack
+  transient bool
+}
+
+func NewStackCache(ttl time.Duration, path string) *StackCache {
+  return &StackCache{TTL: ttl, Path: path, entries: make(map[string]*stackCacheEntry)}
+}
+
+// CurrentStackCache returns the stack cache of the default client.
+func CurrentStackCache() *StackCache {
+  return DefaultClient().StackCache()
+}
+
+func (c *Client) StackCache() *StackCache {
+  planning := c.plan != nil
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.stackCache == nil {
+    path := c.config.StackCacheFile
+    if planning {
+      // planned stacks are never written to disk
+      path = ""
+    }
+    c.stackCache = NewStackCache(c.config.StackCacheTTL, path)
+  }
+  return c.stackCache
+}
+
+// stackCacheKey identifies the account and region that the client
+// refers to.  If the account can't be determined the listing is only
+// cached for this invocation, so that one set of credentials is never
+// shown another account's stacks.
+func (c *Client) stackCacheKey() (string, bool) {
+  key := c.config.Backend
+  if c.config.Backend == "sim" {
+    key += ":" + c.config.SimulatorDirectory
+  }
+  if account, err := c.Account(); err == nil {
+    return key + ":" + account.Id + "/" + c.config.AwsRegion, true
+  }
+  return key + "/" + c.config.AwsRegion, false
+}
+
+func (c *StackCache) Get(key string) ([]*cloudformation.Stack, bool) {
+  if c.TTL <= 0 { return nil, false }
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  entry, exists := c.entries[key]
+  if !exists && c.Path != "" {
+    c.load()
+    entry, exists = c.entries[key]
+  }
+  if !exists || time.Since(entry.Time) > c.TTL {
+    return nil, false
+  }
+  return entry.Stacks, true
+}
+
+func (c *StackCache) Put(key string, stacks []*cloudformation.Stack) {
+  c.put(key, stacks, true)
+}
+
+func (c *StackCache) put(key string, stacks []*cloudformation.Stack, persist bool) {
+  if c.TTL <= 0 { return }
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  if c.Path != "" {
+    c.load()
+  }
+  c.entries[key] = &stackCacheEntry{time.Now(), stacks, !persist}
+  if c.Path != "" {
+    c.save()
+  }
+}
+
+// Invalidate drops every cached listing, including those on disk.
+func (c *StackCache) Invalidate() {
+  c.mutex.Lock()
+  defer c.mutex.Unlock()
+  c.entries = make(map[string]*stackCacheEntry)
+  if c.Path != "" {
+    os.Remove(c.Path)
+  }
+}
+
+// load merges unexpired listings from disk with those held in memory.
+func (c *StackCache) load() {
+  data, err := ioutil.ReadFile(c.Path)
+  if err != nil { return }
+  entries := make(map[string]*stackCacheEntry)
+  if json.Unmarshal(data, &entries) != nil { return }
+  for key, entry := range entries {
+    if current, exists := c.entries[key]; exists && !current.Time.Before(entry.Time) {
+      continue
+    }
+    if time.Since(entry.Time) <= c.TTL {
+      c.entries[key] = entry
+    }
+  }
+}
+
+func (c *StackCache) save() {
+  entries := make(map[string]*stackCacheEntry)
+  for key, entry := range c.entries {
+    if !entry.transient {
+      entries[key] = entry
+    }
+  }
+  data, err := json.Marshal(entries)
+  if err != nil { return }
+  tmp := c.Path + ".tmp"
+  if ioutil.WriteFile(tmp, data, 0600) == nil {
+    os.Rename(tmp, c.Path)
+  }
+}
+
+// cachingStackService invalidates the stack cache whenever a stack is
+// changed.
+type cachingStackService struct {
+  StackService
+  client *Client
+}
+
+func (s *cachingStackService) CreateStack(input *cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error) {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.CreateStack(input)
+}
+
+func (s *cachingStackService) DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error) {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.DeleteStack(input)
+}
+
+func (s *cachingStackService) ExecuteChangeSet(input *cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.ExecuteChangeSet(input)
+}
+
+func (s *cachingStackService) WaitUntilStackCreateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.WaitUntilStackCreateCompleteWithContext(ctx, input, opts...)
+}
+
+func (s *cachingStackService) WaitUntilStackDeleteCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.WaitUntilStackDeleteCompleteWithContext(ctx, input, opts...)
+}
+
+func (s *cachingStackService) WaitUntilStackUpdateCompleteWithContext(ctx aws.Context, input *cloudformation.DescribeStacksInput, opts ...request.WaiterOption) error {
+  defer s.client.StackCache().Invalidate()
+  return s.StackService.WaitUntilStackUpdateCompleteWithContext(ctx, input, opts...)
+}
@@ -0,0 +1,138 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testStacks(names ...string) []*cloudformation.Stack {
+  stacks := []*cloudformation.Stack{}
+  for _, name := range names {
+    stacks = append(stacks, &cloudformation.Stack{StackName: aws.String(name), StackStatus: aws.String("CREATE_COMPLETE")})
+  }
+  return stacks
+}
+
+func TestStackCacheTTL(t *testing.T) {
+  cache := NewStackCache(50 * time.Millisecond, "")
+  cache.Put("k", testStacks("flight-d1"))
+  if stacks, exists := cache.Get("k"); !exists || len(stacks) != 1 {
+    t.Fatalf("Get = %v, %v; want the cached listing", stacks, exists)
+  }
+  if _, exists := cache.Get("other"); exists {
+    t.Errorf("Get found a listing for another key")
+  }
+  time.Sleep(60 * time.Millisecond)
+  if _, exists := cache.Get("k"); exists {
+    t.Errorf("Get returned an expired listing")
+  }
+
+  disabled := NewStackCache(0, "")
+  disabled.Put("k", testStacks("flight-d1"))
+  if _, exists := disabled.Get("k"); exists {
+    t.Errorf("a cache with no TTL returned a listing")
+  }
+}
+
+func TestStackCacheFile(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "stacks.json")
+  cache := NewStackCache(time.Minute, path)
+  cache.Put("k", testStacks("flight-d1", "flight-d2"))
+  cache.put("transient", testStacks("flight-d3"), false)
+
+  other := NewStackCache(time.Minute, path)
+  if stacks, exists := other.Get("k"); !exists || len(stacks) != 2 {
+    t.Errorf("Get = %v, %v; want the listing saved by another cache", stacks, exists)
+  }
+  if _, exists := other.Get("transient"); exists {
+    t.Errorf("a transient listing was saved to disk")
+  }
+
+  expired := NewStackCache(time.Nanosecond, path)
+  if _, exists := expired.Get("k"); exists {
+    t.Errorf("Get loaded an expired listing from disk")
+  }
+
+  cache.Invalidate()
+  if _, err := os.Stat(path); !os.IsNotExist(err) {
+    t.Errorf("Invalidate left the cache file in place")
+  }
+  if _, exists := NewStackCache(time.Minute, path).Get("k"); exists {
+    t.Errorf("Get returned an invalidated listing")
+  }
+}
+
+func TestStackCacheInvalidatedByChanges(t *testing.T) {
+  c, paged := newPagedTestClient(t, 100)
+  count := func() int {
+    n := 0
+    if err := c.eachStack(context.Background(), func(*cloudformation.Stack) { n += 1 }); err != nil {
+      t.Fatalf("eachStack: %s", err)
+    }
+    return n
+  }
+
+  if n := count(); n != 0 {
+    t.Fatalf("listed %d stacks, want 0", n)
+  }
+  count()
+  if paged.listings != 1 {
+    t.Errorf("described stacks %d times, want 1 with a cached listing", paged.listings)
+  }
+
+  d := createTestDomain(t, c, "d1")
+  if n := count(); n != 1 {
+    t.Errorf("listed %d stacks after a launch, want 1", n)
+  }
+
+  if err := d.Destroy(context.Background()); err != nil { t.Fatalf("Domain.Destroy: %s", err) }
+  if n := count(); n != 0 {
+    t.Errorf("listed %d stacks after a destroy, want 0", n)
+  }
+}
+
+func TestStackCacheKeyedByRegion(t *testing.T) {
+  c, _ := newTestClient(t)
+  key, persist := c.stackCacheKey()
+  if !persist {
+    t.Errorf("listing for a known account isn't persisted")
+  }
+  other, _ := c.ForRegion("eu-west-1").stackCacheKey()
+  if key == other {
+    t.Errorf("regions share the cache key %s", key)
+  }
+}
@@ -0,0 +1,511 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/aws/aws-sdk-go/aws/request"
+  "github.com/aws/aws-sdk-go/service/s3"
+  bolt "go.etcd.io/bbolt"
+)
+
+var StateBackends = []string{"dynamo", "bolt", "s3", "sim"}
+
+// StateBackendAliases are other names accepted for state backends.  The
+// bolt backend keeps state in a local database file, as sqlite would.
+var StateBackendAliases = map[string]string{"sqlite": "bolt"}
+
+// StateBackendFor returns the backend that name refers to.
+func StateBackendFor(name string) string {
+  if backend, exists := StateBackendAliases[name]; exists { return backend }
+  return name
+}
+
+var s3StateUpdateAttempts = 10
+
+// OpenStateStore returns the named state store backend.  An empty name
+// selects the default store of the current provider.
+func OpenStateStore(backend string) (StateStore, error) {
+  return DefaultClient().OpenStateStore(backend)
+}
+
+func (c *Client) OpenStateStore(backend string) (StateStore, error) {
+  switch StateBackendFor(backend) {
+  case "":
+    return c.Provider().State(c)
+  case "dynamo":
+    db, err := c.Dynamo()
+    if err != nil { return nil, err }
+    return &dynamoStateStore{db, c}, nil
+  case "bolt":
+    path := c.config.StateFile
+    if path == "" {
+      path = filepath.Join(os.Getenv("HOME"), ".fly-state.db")
+    }
+    return &boltStateStore{path}, nil
+  case "s3":
+    if c.config.StateBucket == "" {
+      return nil, fmt.Errorf("The s3 state backend requires a state bucket.")
+    }
+    svc, err := c.S3()
+    if err != nil { return nil, err }
+    return &s3StateStore{svc, c.config.StateBucket, c.config.StatePrefix}, nil
+  case "sim":
+    if sim, ok := c.Provider().(*Simulator); ok { return sim.State(c) }
+    sim, err := NewSimulator(c.config.SimulatorDirectory)
+    if err != nil { return nil, err }
+    return sim.State(c)
+  }
+  return nil, fmt.Errorf("Unknown state backend: %s (valid backends: %s)", backend, strings.Join(StateBackends, ", "))
+}
+
+// MigrateState copies all domain, cluster and operation records,
+// including network bookings, from one state store to another.
+func MigrateState(from, to StateStore) (int, int, int, error) {
+  domains, err := from.Domains()
+  if err != nil { return 0, 0, 0, err }
+  clusters, err := from.Clusters()
+  if err != nil { return 0, 0, 0, err }
+  operations, err := from.Operations()
+  if err != nil { return 0, 0, 0, err }
+  for _, record := range domains {
+    err = to.PutDomain(record)
+    if err != nil { return 0, 0, 0, err }
+  }
+  for _, record := range clusters {
+    err = to.PutCluster(record)
+    if err != nil { return len(domains), 0, 0, err }
+  }
+  for _, record := range operations {
+    err = to.PutOperation(record)
+    if err != nil { return len(domains), len(clusters), 0, err }
+  }
+  return len(domains), len(clusters), len(operations), nil
+}
+
+type boltStateStore struct {
+  path string
+}
+
+func (s *boltStateStore) open() (*bolt.DB, error) {
+  db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+  if err == bolt.ErrTimeout {
+    return nil, fmt.Errorf("Timed out waiting for lock on state file: %s", s.path)
+  }
+  return db, err
+}
+
+func (s *boltStateStore) update(bucket string, fn func(b *bolt.Bucket) error) error {
+  db, err := s.open()
+  if err != nil { return err }
+  defer db.Close()
+  return db.Update(func(tx *bolt.Tx) error {
+    b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+    if err != nil { return err }
+    return fn(b)
+  })
+}
+
+func (s *boltStateStore) view(bucket string, fn func(b *bolt.Bucket) error) error {
+  db, err := s.open()
+  if err != nil { return err }
+  defer db.Close()
+  return db.View(func(tx *bolt.Tx) error {
+    b := tx.Bucket([]byte(bucket))
+    if b == nil { return nil }
+    return fn(b)
+  })
+}
+
+func boltGet(b *bolt.Bucket, name string, record interface{}) error {
+  data := b.Get([]byte(name))
+  if data == nil { return ErrEntityNotFound }
+  return json.Unmarshal(data, record)
+}
+
+func boltPut(b *bolt.Bucket, name string, record interface{}) error {
+  data, err := json.Marshal(record)
+  if err != nil { return err }
+  return b.Put([]byte(name), data)
+}
+
+func (s *boltStateStore) PutDomain(record *DomainEntity) error {
+  return s.update("FlightDomains", func(b *bolt.Bucket) error {
+    return boltPut(b, record.Name, record)
+  })
+}
+
+func (s *boltStateStore) GetDomain(name string) (*DomainEntity, error) {
+  var record DomainEntity
+  err := ErrEntityNotFound
+  viewErr := s.view("FlightDomains", func(b *bolt.Bucket) error {
+    err = boltGet(b, name, &record)
+    return nil
+  })
+  if viewErr != nil { return nil, viewErr }
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *boltStateStore) DeleteDomain(name string) error {
+  return s.update("FlightDomains", func(b *bolt.Bucket) error {
+    return b.Delete([]byte(name))
+  })
+}
+
+func (s *boltStateStore) Domains() ([]*DomainEntity, error) {
+  records := []*DomainEntity{}
+  err := s.view("FlightDomains", func(b *bolt.Bucket) error {
+    return b.ForEach(func(k, v []byte) error {
+      var record DomainEntity
+      err := json.Unmarshal(v, &record)
+      if err != nil { return err }
+      records = append(records, &record)
+      return nil
+    })
+  })
+  return records, err
+}
+
+func (s *boltStateStore) AddNetworkBooking(domainName string, index int) error {
+  return s.update("FlightDomains", func(b *bolt.Bucket) error {
+    record := DomainEntity{Name: domainName}
+    err := boltGet(b, domainName, &record)
+    if err != nil && err != ErrEntityNotFound { return err }
+    if containsI(record.NetBookings, index) { return ErrNetworkBooked }
+    record.NetBookings = append(record.NetBookings, index)
+    return boltPut(b, domainName, &record)
+  })
+}
+
+func (s *boltStateStore) RemoveNetworkBooking(domainName string, index int) error {
+  return s.update("FlightDomains", func(b *bolt.Bucket) error {
+    var record DomainEntity
+    err := boltGet(b, domainName, &record)
+    if err == ErrEntityNotFound { return ErrNetworkNotBooked }
+    if err != nil { return err }
+    if !containsI(record.NetBookings, index) { return ErrNetworkNotBooked }
+    record.NetBookings = removeI(record.NetBookings, index)
+    return boltPut(b, domainName, &record)
+  })
+}
+
+func (s *boltStateStore) PutCluster(record *ClusterEntity) error {
+  return s.update("FlightClusters", func(b *bolt.Bucket) error {
+    return boltPut(b, record.Name, record)
+  })
+}
+
+func (s *boltStateStore) GetCluster(name string) (*ClusterEntity, error) {
+  var record ClusterEntity
+  err := ErrEntityNotFound
+  viewErr := s.view("FlightClusters", func(b *bolt.Bucket) error {
+    err = boltGet(b, name, &record)
+    return nil
+  })
+  if viewErr != nil { return nil, viewErr }
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *boltStateStore) DeleteCluster(name string) error {
+  return s.update("FlightClusters", func(b *bolt.Bucket) error {
+    return b.Delete([]byte(name))
+  })
+}
+
+func (s *boltStateStore) Clusters() ([]*ClusterEntity, error) {
+  records := []*ClusterEntity{}
+  err := s.view("FlightClusters", func(b *bolt.Bucket) error {
+    return b.ForEach(func(k, v []byte) error {
+      var record ClusterEntity
+      err := json.Unmarshal(v, &record)
+      if err != nil { return err }
+      records = append(records, &record)
+      return nil
+    })
+  })
+  return records, err
+}
+
+func (s *boltStateStore) PutOperation(record *OperationEntity) error {
+  return s.update("FlightOperations", func(b *bolt.Bucket) error {
+    return boltPut(b, record.Id, record)
+  })
+}
+
+func (s *boltStateStore) GetOperation(id string) (*OperationEntity, error) {
+  var record OperationEntity
+  err := ErrEntityNotFound
+  viewErr := s.view("FlightOperations", func(b *bolt.Bucket) error {
+    err = boltGet(b, id, &record)
+    return nil
+  })
+  if viewErr != nil { return nil, viewErr }
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *boltStateStore) DeleteOperation(id string) error {
+  return s.update("FlightOperations", func(b *bolt.Bucket) error {
+    return b.Delete([]byte(id))
+  })
+}
+
+func (s *boltStateStore) Operations() ([]*OperationEntity, error) {
+  records := []*OperationEntity{}
+  err := s.view("FlightOperations", func(b *bolt.Bucket) error {
+    return b.ForEach(func(k, v []byte) error {
+      var record OperationEntity
+      err := json.Unmarshal(v, &record)
+      if err != nil { return err }
+      records = append(records, &record)
+      return nil
+    })
+  })
+  return records, err
+}
+
+// s3StateStore keeps each record as a JSON object.  Network bookings
+// are updated with conditional writes so that concurrent bookings
+// cannot overwrite each other.
+type s3StateStore struct {
+  svc *s3.S3
+  bucket string
+  prefix string
+}
+
+func (s *s3StateStore) key(kind, name string) string {
+  return s.prefix + kind + "/" + name + ".json"
+}
+
+func (s *s3StateStore) get(key string, record interface{}) (string, error) {
+  o, err := throttleProtected(
+    func() (interface{}, error) {
+      return s.svc.GetObject(&s3.GetObjectInput{
+        Bucket: aws.String(s.bucket),
+        Key: aws.String(key),
+      })
+    },
+  )
+  if err != nil {
+    if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+      return "", ErrEntityNotFound
+    }
+    return "", err
+  }
+  resp := o.(*s3.GetObjectOutput)
+  defer resp.Body.Close()
+  data, err := ioutil.ReadAll(resp.Body)
+  if err != nil { return "", err }
+  return aws.StringValue(resp.ETag), json.Unmarshal(data, record)
+}
+
+func (s *s3StateStore) put(key string, record interface{}, conditions map[string]string) error {
+  data, err := json.Marshal(record)
+  if err != nil { return err }
+  _, err = throttleProtected(
+    func() (interface{}, error) {
+      return s.svc.PutObjectWithContext(aws.BackgroundContext(), &s3.PutObjectInput{
+        Bucket: aws.String(s.bucket),
+        Key: aws.String(key),
+        Body: bytes.NewReader(data),
+        ContentType: aws.String("application/json"),
+      }, request.WithSetRequestHeaders(conditions))
+    },
+  )
+  return err
+}
+
+func (s *s3StateStore) delete(key string) error {
+  _, err := throttleProtected(
+    func() (interface{}, error) {
+      return s.svc.DeleteObject(&s3.DeleteObjectInput{
+        Bucket: aws.String(s.bucket),
+        Key: aws.String(key),
+      })
+    },
+  )
+  return err
+}
+
+func (s *s3StateStore) each(kind string, fn func(key string) error) error {
+  var keys []string
+  err := s.svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+    Bucket: aws.String(s.bucket),
+    Prefix: aws.String(s.prefix + kind + "/"),
+  }, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+    for _, object := range page.Contents {
+      keys = append(keys, *object.Key)
+    }
+    return true
+  })
+  if err != nil { return err }
+  for _, key := range keys {
+    if err = fn(key); err != nil { return err }
+  }
+  return nil
+}
+
+func (s *s3StateStore) updateDomain(domainName string, fn func(record *DomainEntity, exists bool) error) error {
+  key := s.key("domains", domainName)
+  for i := 0; i < s3StateUpdateAttempts; i++ {
+    var record DomainEntity
+    etag, err := s.get(key, &record)
+    exists := err == nil
+    conditions := map[string]string{"If-Match": etag}
+    if err == ErrEntityNotFound {
+      record = DomainEntity{Name: domainName}
+      conditions = map[string]string{"If-None-Match": "*"}
+    } else if err != nil {
+      return err
+    }
+    if err = fn(&record, exists); err != nil { return err }
+    err = s.put(key, &record, conditions)
+    if !isPreconditionFailure(err) { return err }
+  }
+  return fmt.Errorf("Unable to update domain record '%s': too many concurrent updates.", domainName)
+}
+
+func isPreconditionFailure(err error) bool {
+  if aerr, ok := err.(awserr.Error); ok {
+    return aerr.Code() == "PreconditionFailed" || aerr.Code() == "ConditionalRequestConflict"
+  }
+  return false
+}
+
+func (s *s3StateStore) PutDomain(record *DomainEntity) error {
+  return s.put(s.key("domains", record.Name), record, nil)
+}
+
+func (s *s3StateStore) GetDomain(name string) (*DomainEntity, error) {
+  var record DomainEntity
+  _, err := s.get(s.key("domains", name), &record)
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *s3StateStore) DeleteDomain(name string) error {
+  return s.delete(s.key("domains", name))
+}
+
+func (s *s3StateStore) Domains() ([]*DomainEntity, error) {
+  records := []*DomainEntity{}
+  err := s.each("domains", func(key string) error {
+    var record DomainEntity
+    _, err := s.get(key, &record)
+    if err == ErrEntityNotFound { return nil }
+    if err != nil { return err }
+    records = append(records, &record)
+    return nil
+  })
+  return records, err
+}
+
+func (s *s3StateStore) AddNetworkBooking(domainName string, index int) error {
+  return s.updateDomain(domainName, func(record *DomainEntity, exists bool) error {
+    if containsI(record.NetBookings, index) { return ErrNetworkBooked }
+    record.NetBookings = append(record.NetBookings, index)
+    return nil
+  })
+}
+
+func (s *s3StateStore) RemoveNetworkBooking(domainName string, index int) error {
+  return s.updateDomain(domainName, func(record *DomainEntity, exists bool) error {
+    if !exists || !containsI(record.NetBookings, index) { return ErrNetworkNotBooked }
+    record.NetBookings = removeI(record.NetBookings, index)
+    return nil
+  })
+}
+
+func (s *s3StateStore) PutCluster(record *ClusterEntity) error {
+  return s.put(s.key("clusters", record.Name), record, nil)
+}
+
+func (s *s3StateStore) GetCluster(name string) (*ClusterEntity, error) {
+  var record ClusterEntity
+  _, err := s.get(s.key("clusters", name), &record)
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *s3StateStore) DeleteCluster(name string) error {
+  return s.delete(s.key("clusters", name))
+}
+
+func (s *s3StateStore) Clusters() ([]*ClusterEntity, error) {
+  records := []*ClusterEntity{}
+  err := s.each("clusters", func(key string) error {
+    var record ClusterEntity
+    _, err := s.get(key, &record)
+    if err == ErrEntityNotFound { return nil }
+    if err != nil { return err }
+    records = append(records, &record)
+    return nil
+  })
+  return records, err
+}
+
+func (s *s3StateStore) PutOperation(record *OperationEntity) error {
+  return s.put(s.key("operations", record.Id), record, nil)
+}
+
+func (s *s3StateStore) GetOperation(id string) (*OperationEntity, error) {
+  var record OperationEntity
+  _, err := s.get(s.key("operations", id), &record)
+  if err != nil { return nil, err }
+  return &record, nil
+}
+
+func (s *s3StateStore) DeleteOperation(id string) error {
+  return s.delete(s.key("operations", id))
+}
+
+func (s *s3StateStore) Operations() ([]*OperationEntity, error) {
+  records := []*OperationEntity{}
+  err := s.each("operations", func(key string) error {
+    var record OperationEntity
+    _, err := s.get(key, &record)
+    if err == ErrEntityNotFound { return nil }
+    if err != nil { return err }
+    records = append(records, &record)
+    return nil
+  })
+  return records, err
+}
@@ -0,0 +1,216 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "crypto/md5"
+  "encoding/hex"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "sort"
+  "strings"
+  "sync"
+  "testing"
+)
+
+// fakeS3 serves the object calls used by the s3 state store, including
+// conditional writes.
+type fakeS3 struct {
+  mutex sync.Mutex
+  objects map[string][]byte
+}
+
+func etagFor(data []byte) string {
+  sum := md5.Sum(data)
+  return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+  w.WriteHeader(status)
+  fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  f.mutex.Lock()
+  defer f.mutex.Unlock()
+  // path-style requests, as /bucket/key
+  parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+  if len(parts) == 1 || parts[1] == "" {
+    prefix := r.URL.Query().Get("prefix")
+    keys := []string{}
+    for key := range f.objects {
+      if strings.HasPrefix(key, prefix) { keys = append(keys, key) }
+    }
+    sort.Strings(keys)
+    fmt.Fprint(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+    for _, key := range keys {
+      fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+    }
+    fmt.Fprintf(w, "<KeyCount>%d</KeyCount></ListBucketResult>", len(keys))
+    return
+  }
+  key := parts[1]
+  data, exists := f.objects[key]
+  switch r.Method {
+  case "GET":
+    if !exists {
+      s3Error(w, http.StatusNotFound, "NoSuchKey")
+      return
+    }
+    w.Header().Set("ETag", etagFor(data))
+    w.Write(data)
+  case "PUT":
+    if match := r.Header.Get("If-Match"); match != "" && (!exists || match != etagFor(data)) {
+      s3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+      return
+    }
+    if r.Header.Get("If-None-Match") == "*" && exists {
+      s3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+      return
+    }
+    body, _ := ioutil.ReadAll(r.Body)
+    f.objects[key] = body
+    w.Header().Set("ETag", etagFor(body))
+  case "DELETE":
+    delete(f.objects, key)
+    w.WriteHeader(http.StatusNoContent)
+  }
+}
+
+func newTestS3StateStore(t *testing.T) StateStore {
+  server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+  t.Cleanup(server.Close)
+  config := defaultConfiguration()
+  config.StateBackend = "s3"
+  config.StateBucket = "state"
+  config.Endpoints = map[string]string{"s3": server.URL}
+  c, err := NewClient(WithConfig(*config), WithCredentials("key", "secret"))
+  if err != nil { t.Fatalf("NewClient: %s", err) }
+  store, err := c.State()
+  if err != nil { t.Fatalf("State: %s", err) }
+  return store
+}
+
+func newTestBoltStateStore(t *testing.T) StateStore {
+  c, _ := newTestClient(t)
+  c.config.StateFile = filepath.Join(t.TempDir(), "state.db")
+  // sqlite is accepted as another name for bolt
+  store, err := c.OpenStateStore("sqlite")
+  if err != nil { t.Fatalf("OpenStateStore: %s", err) }
+  if _, ok := store.(*boltStateStore); !ok {
+    t.Fatalf("sqlite backend opened a %T", store)
+  }
+  return store
+}
+
+// testStateStore exercises the behaviour every state store shares.
+func testStateStore(t *testing.T, store StateStore) {
+  if _, err := store.GetDomain("d1"); err != ErrEntityNotFound {
+    t.Errorf("GetDomain of a missing domain: %v, want ErrEntityNotFound", err)
+  }
+  if err := store.PutDomain(&DomainEntity{Name: "d1", Prefix: "10.0"}); err != nil {
+    t.Fatalf("PutDomain: %s", err)
+  }
+  if err := store.AddNetworkBooking("d1", 1); err != nil { t.Fatalf("AddNetworkBooking: %s", err) }
+  if err := store.AddNetworkBooking("d1", 2); err != nil { t.Fatalf("AddNetworkBooking: %s", err) }
+  if err := store.AddNetworkBooking("d1", 1); err != ErrNetworkBooked {
+    t.Errorf("booking a booked network: %v, want ErrNetworkBooked", err)
+  }
+  if err := store.RemoveNetworkBooking("d1", 2); err != nil { t.Fatalf("RemoveNetworkBooking: %s", err) }
+  if err := store.RemoveNetworkBooking("d1", 2); err != ErrNetworkNotBooked {
+    t.Errorf("removing an unbooked network: %v, want ErrNetworkNotBooked", err)
+  }
+  domain, err := store.GetDomain("d1")
+  if err != nil { t.Fatalf("GetDomain: %s", err) }
+  if domain.Prefix != "10.0" || len(domain.NetBookings) != 1 || domain.NetBookings[0] != 1 {
+    t.Errorf("domain record = %+v", domain)
+  }
+
+  if err := store.PutCluster(&ClusterEntity{Name: "c1", Domain: "d1", NetworkIndex: 1}); err != nil {
+    t.Fatalf("PutCluster: %s", err)
+  }
+  if clusters, err := store.Clusters(); err != nil || len(clusters) != 1 || clusters[0].NetworkIndex != 1 {
+    t.Errorf("Clusters = %v, %v", clusters, err)
+  }
+  op := &OperationEntity{Id: "op1", Kind: "launch", Cluster: "c1", Steps: []*OperationStep{{"network", "done"}}, Status: "failed"}
+  if err := store.PutOperation(op); err != nil { t.Fatalf("PutOperation: %s", err) }
+  if got, err := store.GetOperation("op1"); err != nil || got.Status != "failed" || len(got.Steps) != 1 {
+    t.Errorf("GetOperation = %+v, %v", got, err)
+  }
+
+  if err := store.DeleteCluster("c1"); err != nil { t.Fatalf("DeleteCluster: %s", err) }
+  if _, err := store.GetCluster("c1"); err != ErrEntityNotFound {
+    t.Errorf("GetCluster after delete: %v, want ErrEntityNotFound", err)
+  }
+  if err := store.DeleteOperation("op1"); err != nil { t.Fatalf("DeleteOperation: %s", err) }
+  if ops, err := store.Operations(); err != nil || len(ops) != 0 {
+    t.Errorf("Operations after delete = %v, %v", ops, err)
+  }
+  if err := store.DeleteDomain("d1"); err != nil { t.Fatalf("DeleteDomain: %s", err) }
+  if domains, err := store.Domains(); err != nil || len(domains) != 0 {
+    t.Errorf("Domains after delete = %v, %v", domains, err)
+  }
+}
+
+func TestBoltStateStore(t *testing.T) {
+  testStateStore(t, newTestBoltStateStore(t))
+}
+
+func TestS3StateStore(t *testing.T) {
+  testStateStore(t, newTestS3StateStore(t))
+}
+
+func TestMemoryStateStore(t *testing.T) {
+  testStateStore(t, NewMemoryProvider("us-east-1"))
+}
+
+func TestMigrateState(t *testing.T) {
+  from := newTestBoltStateStore(t)
+  from.PutDomain(&DomainEntity{Name: "d1"})
+  from.AddNetworkBooking("d1", 3)
+  from.PutCluster(&ClusterEntity{Name: "c1", Domain: "d1", NetworkIndex: 3})
+  from.PutOperation(&OperationEntity{Id: "op1", Kind: "launch", Cluster: "c1"})
+
+  to := newTestS3StateStore(t)
+  domains, clusters, operations, err := MigrateState(from, to)
+  if err != nil { t.Fatalf("MigrateState: %s", err) }
+  if domains != 1 || clusters != 1 || operations != 1 {
+    t.Errorf("migrated %d domains, %d clusters and %d operations, want one of each", domains, clusters, operations)
+  }
+  domain, err := to.GetDomain("d1")
+  if err != nil || len(domain.NetBookings) != 1 || domain.NetBookings[0] != 3 {
+    t.Errorf("migrated domain = %+v, %v", domain, err)
+  }
+  if _, err := to.GetOperation("op1"); err != nil {
+    t.Errorf("GetOperation after migration: %s", err)
+  }
+}
@@ -0,0 +1,141 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "fmt"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TemplateBodyLimit is the largest template that CloudFormation accepts
+// inline; larger local templates are uploaded to the staging bucket.
+var TemplateBodyLimit = 51200
+
+// Tag values are limited to 256 characters.
+var templateTagLimit = 256
+
+// stackTemplate is a template ready to be passed to CloudFormation,
+// either by URL or, for local templates, inline.
+type stackTemplate struct {
+  url string
+  body string
+  source string
+}
+
+func (t *stackTemplate) urlParam() *string {
+  if t.url == "" { return nil }
+  return aws.String(t.url)
+}
+
+func (t *stackTemplate) bodyParam() *string {
+  if t.body == "" { return nil }
+  return aws.String(t.body)
+}
+
+func isLocalTemplate(templateUrl string) bool {
+  return strings.HasPrefix(templateUrl, "file://")
+}
+
+// loadTemplate prepares the template at templateUrl.  Local templates
+// are identified by their path and a hash of their content so that the
+// flight:template tag records which build a stack was created from.
+func (c *Client) loadTemplate(templateUrl string) (*stackTemplate, error) {
+  if !isLocalTemplate(templateUrl) {
+    return &stackTemplate{url: templateUrl, source: templateUrl}, nil
+  }
+  path := strings.TrimPrefix(templateUrl, "file://")
+  data, err := ioutil.ReadFile(path)
+  if err != nil { return nil, err }
+  sum := fmt.Sprintf("%x", sha256.Sum256(data))
+
+  source := path + "@sha256:" + sum
+  if len(source) > templateTagLimit {
+    source = "..." + source[len(source) - templateTagLimit + 3:]
+  }
+  template := &stackTemplate{source: source}
+  if len(data) <= TemplateBodyLimit {
+    template.body = string(data)
+    return template, nil
+  }
+  if c.Config().TemplateStagingBucket == "" {
+    return nil, fmt.Errorf("Template %s is larger than %d bytes; a template staging bucket must be configured to launch it.", path, TemplateBodyLimit)
+  }
+  if c.plan != nil {
+    // a plan only reports where the template would be staged
+    template.url = "s3://" + c.Config().TemplateStagingBucket + "/" + c.stagingKey(filepath.Base(path), sum)
+    return template, nil
+  }
+  template.url, err = c.stageTemplate(filepath.Base(path), sum, data)
+  if err != nil { return nil, err }
+  return template, nil
+}
+
+func (c *Client) stagingKey(name, sum string) string {
+  return c.Config().TemplateStagingPrefix + sum + "/" + name
+}
+
+// stageTemplate uploads a template to the staging bucket, keyed by its
+// hash so that each build is only stored once.
+func (c *Client) stageTemplate(name, sum string, data []byte) (string, error) {
+  bucket := c.Config().TemplateStagingBucket
+  key := c.stagingKey(name, sum)
+  c.mutex.Lock()
+  url, exists := c.stagedTemplates[bucket + "/" + key]
+  c.mutex.Unlock()
+  if exists {
+    return url, nil
+  }
+  svc, err := c.S3()
+  if err != nil { return "", err }
+  _, err = throttleProtected(
+    func() (interface{}, error) {
+      return svc.PutObject(&s3.PutObjectInput{
+        Bucket: aws.String(bucket),
+        Key: aws.String(key),
+        Body: bytes.NewReader(data),
+        ContentType: aws.String("application/json"),
+      })
+    },
+  )
+  if err != nil { return "", fmt.Errorf("Unable to stage template %s: %s", name, err.Error()) }
+  req, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+  if err = req.Build(); err != nil { return "", err }
+  url = req.HTTPRequest.URL.String()
+  c.mutex.Lock()
+  c.stagedTemplates[bucket + "/" + key] = url
+  c.mutex.Unlock()
+  return url, nil
+}
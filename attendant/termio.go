@@ -29,56 +29,138 @@
 package attendant
 
 import (
+  "bufio"
+  "encoding/json"
   "fmt"
+  "io"
   "log"
   "os"
-  "strconv"
   "strings"
   "sync"
   "time"
 
   "github.com/briandowns/spinner"
+  "github.com/mattn/go-isatty"
   "github.com/sethgrid/curse"
 )
 
-var attSpinner = spinner.New(spinner.CharSets[11], 100*time.Millisecond)  // Build our new spinner
 var loggingEnabled = false
 
+func newSpinner() *spinner.Spinner {
+  s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
+  s.Writer = os.Stderr
+  return s
+}
+
+// Spinner returns the spinner of the default client.
 func Spinner() *spinner.Spinner {
-  return attSpinner
+  return DefaultClient().Spinner()
+}
+
+// Spinner returns the spinner that shows the client's progress.  Clients
+// for other regions share it, as there is only the one terminal.
+func (c *Client) Spinner() *spinner.Spinner {
+  return c.spinner
+}
+
+// ProgressFormats are the values accepted by the progress setting.
+// The spinner redraws the terminal as resources complete; plain and
+// json print one line per event and suit CI logs.
+var ProgressFormats = []string{"spinner", "plain", "json"}
+
+func IsValidProgressFormat(format string) bool {
+  return containsS(ProgressFormats, format)
+}
+
+// ProgressFormat is the renderer in use for progress events, allowing
+// for FLY_SIMPLE_OUTPUT selecting plain lines in place of the spinner.
+func ProgressFormat() string {
+  return DefaultClient().ProgressFormat()
+}
+
+func (c *Client) ProgressFormat() string {
+  if c.config.Progress == "" || c.config.Progress == "spinner" {
+    if c.config.SimpleOutput { return "plain" }
+    return "spinner"
+  }
+  return c.config.Progress
 }
 
 func Spin(fn func()) {
-  if Config().SimpleOutput {
+  DefaultClient().Spin(fn)
+}
+
+func (c *Client) Spin(fn func()) {
+  if c.ProgressFormat() != "spinner" || c.IsStructuredOutput() {
     fn()
   } else {
-    attSpinner.Start()
+    c.spinner.Start()
     fn()
-    attSpinner.Stop()
+    c.spinner.Stop()
   }
 }
 
 func SpinWithSuffix(fn func(), suffix string) {
-  attSpinner.Suffix = " " + suffix
-  Spin(fn)
-  attSpinner.Suffix = ""
+  DefaultClient().SpinWithSuffix(fn, suffix)
 }
 
-func CreateCreateHandler(resourceTotal int) (func(msg string), error) {
-  return createHandlerFunction(resourceTotal, "CREATE_IN_PROGRESS", "CREATE_COMPLETE", "✅")
+func (c *Client) SpinWithSuffix(fn func(), suffix string) {
+  c.spinner.Suffix = " " + suffix
+  c.Spin(fn)
+  c.spinner.Suffix = ""
 }
 
-func CreateDestroyHandler(resourceTotal int) (func(msg string), error) {
-  return createHandlerFunction(resourceTotal, "DELETE_IN_PROGRESS", "DELETE_COMPLETE", "❎")
+// IsTerminal reports whether f is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+  return isatty.IsTerminal(f.Fd())
 }
 
-func createHandlerFunction(resourceTotal int, inProgressText, completeText, completionRune string) (func(msg string), error) {
-  var resRegistry = make(map[string]int)
-  var completeRegistry = make(map[string]bool)
-  var disableCounters = false
-  var counterDelta = 0
-  var mutex sync.RWMutex
+// Prompt asks a question on the terminal while progress is shown,
+// pausing the spinner for the answer.  The question is erased once
+// answered so that the spinner's progress lines stay where they were.
+func Prompt(question string) string {
+  return DefaultClient().Prompt(question)
+}
+
+func (c *Client) Prompt(question string) string {
+  spinning := c.spinner.Active()
+  if spinning { c.spinner.Stop() }
+  fmt.Fprint(os.Stderr, question)
+  answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+  if spinning {
+    if c, err := curse.New(); err == nil {
+      c.MoveUp(1).EraseCurrentLine()
+    }
+    c.spinner.Start()
+  }
+  return strings.TrimSpace(answer)
+}
 
+func CreateCreateHandler(resourceTotal int) (EventHandler, error) {
+  return DefaultClient().CreateCreateHandler(resourceTotal)
+}
+
+func CreateUpdateHandler(resourceTotal int) (EventHandler, error) {
+  return DefaultClient().CreateUpdateHandler(resourceTotal)
+}
+
+func CreateDestroyHandler(resourceTotal int) (EventHandler, error) {
+  return DefaultClient().CreateDestroyHandler(resourceTotal)
+}
+
+func (c *Client) CreateCreateHandler(resourceTotal int) (EventHandler, error) {
+  return c.createHandlerFunction(resourceTotal, "CREATE_IN_PROGRESS", "CREATE_COMPLETE", "✅")
+}
+
+func (c *Client) CreateUpdateHandler(resourceTotal int) (EventHandler, error) {
+  return c.createHandlerFunction(resourceTotal, "UPDATE_IN_PROGRESS", "UPDATE_COMPLETE", "✅")
+}
+
+func (c *Client) CreateDestroyHandler(resourceTotal int) (EventHandler, error) {
+  return c.createHandlerFunction(resourceTotal, "DELETE_IN_PROGRESS", "DELETE_COMPLETE", "❎")
+}
+
+func (c *Client) createHandlerFunction(resourceTotal int, inProgressText, completeText, completionRune string) (EventHandler, error) {
   if loggingEnabled {
     f, err := os.OpenFile("fly.log", os.O_RDWR | os.O_CREATE | os.O_APPEND, 0666)
     if err != nil { return nil, err }
@@ -86,63 +168,136 @@ func createHandlerFunction(resourceTotal int, inProgressText, completeText, comp
     log.SetOutput(f)
   }
 
-  if Config().SimpleOutput {
-    return func(msg string) { fmt.Println(msg) }, nil
+  // structured output owns stdout, so progress lines go to stderr
+  var w io.Writer = os.Stdout
+  if c.IsStructuredOutput() { w = os.Stderr }
+
+  switch c.ProgressFormat() {
+  case "plain":
+    return PlainRenderer(w), nil
+  case "json":
+    return JSONRenderer(w), nil
+  }
+  if c.IsStructuredOutput() {
+    return DiscardEvents, nil
+  }
+  return SpinnerRenderer(c.spinner, resourceTotal, inProgressText, completeText, completionRune)
+}
+
+// PlainRenderer prints each resource change, notice and the end of the
+// operation on a line of its own.  The events that only drive the
+// spinner's counters are left out.
+func PlainRenderer(w io.Writer) EventHandler {
+  var mutex sync.Mutex
+  return func(event *Event) {
+    switch event.Kind {
+    case CountersEvent, DisableCountersEvent, EnableCountersEvent:
+      return
+    }
+    mutex.Lock()
+    defer mutex.Unlock()
+    fmt.Fprintln(w, event.String())
+  }
+}
+
+// JSONRenderer prints each event as a line of JSON.
+func JSONRenderer(w io.Writer) EventHandler {
+  var mutex sync.Mutex
+  enc := json.NewEncoder(w)
+  enc.SetEscapeHTML(false)
+  return func(event *Event) {
+    mutex.Lock()
+    defer mutex.Unlock()
+    enc.Encode(event)
   }
+}
+
+// SpinnerRenderer shows a line for each resource between the in
+// progress and complete statuses given, updating the line in place
+// when the resource completes.
+func SpinnerRenderer(s *spinner.Spinner, resourceTotal int, inProgressText, completeText, completionRune string) (EventHandler, error) {
+  var resRegistry = make(map[string]int)
+  var completeRegistry = make(map[string]bool)
+  var resNames = make(map[string]string)
+  var disableCounters = false
+  var counterDelta = 0
+  var mutex sync.RWMutex
 
   c, err := curse.New()
   if err != nil { return nil, err }
 
-  fn := func(msg string) {
+  fn := func(event *Event) {
     mutex.Lock()
     defer mutex.Unlock()
-    if loggingEnabled { log.Println(msg) }
-    if msg == "DONE" {
-      Spinner().Stop()
+    if loggingEnabled { log.Println(event.String()) }
+    switch event.Kind {
+    case DoneEvent:
+      s.Stop()
       time.Sleep(250*time.Millisecond)
       for res, idx := range resRegistry {
         if ! completeRegistry[res] {
           lines := len(resRegistry) - idx
           c.MoveUp(lines).EraseCurrentLine()
-          s := strings.Split(res, " ")
-          fmt.Printf("%s  %s\n", completionRune, s[0])
+          fmt.Printf("%s  %s\n", completionRune, resNames[res])
           c.MoveDown(lines - 1)
         }
       }
-      Spinner().Suffix = ""
-      Spinner().Start()
+      s.Suffix = ""
+      s.Start()
       return
-    } else if strings.HasPrefix(msg, "COUNTERS=") {
-      s := strings.Split(msg, "=")
-      c, err := strconv.Atoi(s[1])
-      if err == nil { resourceTotal = c }
+    case CountersEvent:
+      resourceTotal = event.Count
       return
-    } else if msg == "DISABLE-COUNTERS" {
+    case DisableCountersEvent:
       disableCounters = true
       return
-    } else if msg == "ENABLE-COUNTERS" {
+    case EnableCountersEvent:
       disableCounters = false
       return
+    case NoticeEvent:
+      s.Stop()
+      time.Sleep(250*time.Millisecond)
+      fmt.Println("⚠️  " + event.Message)
+      // notices take up a line, so must be registered to keep the
+      // cursor movements for later resources correct
+      res := event.String()
+      resRegistry[res] = len(resRegistry)
+      completeRegistry[res] = true
+      counterDelta += 1
+      s.Start()
+      return
     }
-    s := strings.Split(strings.TrimSpace(msg), " ")
-    state := s[0]
-    res := s[1] + " " + s[2]
-    name := s[1]
-    if state != inProgressText && state != completeText {
+    if event.Kind != ResourceEvent { return }
+    state := event.Status
+    res := event.LogicalId + " (" + event.PhysicalId + ")"
+    name := event.LogicalId
+    if state == "CREATE_FAILED" || state == "UPDATE_FAILED" || state == "DELETE_FAILED" {
+      s.Stop()
+      time.Sleep(250*time.Millisecond)
+      fmt.Printf("❌  %s: %s\n", name, event.Reason)
+      // registered under its own key so the resource's line is still
+      // found if it is later reported again
+      resRegistry[event.String()] = len(resRegistry)
+      completeRegistry[event.String()] = true
+      counterDelta += 1
+      s.Start()
+      return
+    } else if state != inProgressText && state != completeText {
       return
     } else if state == inProgressText {
       if _, exists := resRegistry[res]; !exists {
-        Spinner().Stop()
+        s.Stop()
         time.Sleep(250*time.Millisecond)
         fmt.Println("⏳  " + name)
-        Spinner().Start()
+        s.Start()
         resRegistry[res] = len(resRegistry)
+        resNames[res] = name
       }
     } else {
       if completeRegistry[res] == true {
         return
       } else {
-        Spinner().Stop()
+        s.Stop()
         // attempt to stop gaps appearing, assuming that it's a race
         // condition between the following Printf and the Spinner
         // actually getting around to stopping.
@@ -150,6 +305,7 @@ func createHandlerFunction(resourceTotal int, inProgressText, completeText, comp
         if _, exists := resRegistry[res]; !exists {
           fmt.Printf("%s  %s\n", completionRune, name)
           resRegistry[res] = len(resRegistry)
+          resNames[res] = name
         } else {
           lines := len(resRegistry) - resRegistry[res]
           c.MoveUp(lines).EraseCurrentLine()
@@ -161,9 +317,9 @@ func createHandlerFunction(resourceTotal int, inProgressText, completeText, comp
         }
         completeRegistry[res] = true
         if resourceTotal > 0 && !disableCounters {
-          Spinner().Suffix = fmt.Sprintf(" (%d/%d)", len(completeRegistry) - counterDelta, resourceTotal)
+          s.Suffix = fmt.Sprintf(" (%d/%d)", len(completeRegistry) - counterDelta, resourceTotal)
         }
-        Spinner().Start()
+        s.Start()
       }
     }
   }
@@ -0,0 +1,373 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "bytes"
+  "fmt"
+  "path"
+  "sort"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  "github.com/aws/aws-sdk-go/aws"
+  "github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// StackUpdate is a change to a running stack, prepared as a
+// CloudFormation change set that can be applied or discarded.
+type StackUpdate struct {
+  StackName string `json:"StackName" yaml:"StackName"`
+  ChangeSetName string `json:"ChangeSetName" yaml:"ChangeSetName"`
+  TemplateURL string `json:"TemplateURL" yaml:"TemplateURL"`
+  Parameters []ParameterChange `json:"Parameters" yaml:"Parameters"`
+  Resources []ResourceChange `json:"Resources" yaml:"Resources"`
+}
+
+type ParameterChange struct {
+  Key string `json:"Key" yaml:"Key"`
+  Old string `json:"Old" yaml:"Old"`
+  New string `json:"New" yaml:"New"`
+}
+
+type ResourceChange struct {
+  Action string `json:"Action" yaml:"Action"`
+  LogicalId string `json:"LogicalId" yaml:"LogicalId"`
+  ResourceType string `json:"ResourceType" yaml:"ResourceType"`
+  Replacement string `json:"Replacement" yaml:"Replacement"`
+}
+
+func (u *StackUpdate) HasChanges() bool {
+  return len(u.Resources) > 0
+}
+
+func (c *Cluster) updateStackName(stackType, name string) (string, error) {
+  if c.Domain == nil {
+    if stackType != "master" {
+      return "", fmt.Errorf("Solo clusters can only have their master stack updated.")
+    }
+    return "flight-cluster-" + c.Name, nil
+  }
+  switch stackType {
+  case "master":
+    return fmt.Sprintf("flight-%s-%s-master", c.Domain.Name, c.Name), nil
+  case "compute":
+    return fmt.Sprintf("flight-%s-%s-compute-%s", c.Domain.Name, c.Name, name), nil
+  case "component":
+    return fmt.Sprintf("flight-%s-%s-component-%s", c.Domain.Name, c.Name, name), nil
+  }
+  return "", fmt.Errorf("Unknown stack type: %s", stackType)
+}
+
+func (c *Cluster) eventTopicName() string {
+  if c.Domain == nil {
+    return "flight-cluster-" + c.Name
+  }
+  return "flight-" + c.Domain.Name + "-cluster-" + c.Name
+}
+
+// templateFileFor returns the name of the template a stack was created
+// from, as recorded in its flight:template tag.
+func templateFileFor(stack *cloudformation.Stack) (string, error) {
+  source := getStackTag(stack, "flight:template")
+  if source == "" {
+    return "", fmt.Errorf("Unable to determine the template used for stack %s.", *stack.StackName)
+  }
+  return path.Base(strings.SplitN(source, "@sha256:", 2)[0]), nil
+}
+
+// PrepareUpdate creates a change set for one of the cluster's stacks.
+// Parameters from paramsFile and overrides replace those the stack is
+// running with; all others keep their previous values.  The template
+// is only changed when newTemplate is set, using the current template
+// root and set.
+func (c *Cluster) PrepareUpdate(ctx context.Context, stackType, name, paramsFile string, overrides map[string]string, newTemplate bool) (*StackUpdate, error) {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return nil, err }
+
+  stackName, err := c.updateStackName(stackType, name)
+  if err != nil { return nil, err }
+  stack, err := getStack(svc, stackName)
+  if err != nil { return nil, err }
+  if c.Domain == nil {
+    c.Master = &Master{stack}
+  } else {
+    err = c.loadInfrastructure(svc)
+    if err != nil { return nil, err }
+  }
+
+  update := &StackUpdate{StackName: stackName}
+  input := &cloudformation.CreateChangeSetInput{
+    StackName: aws.String(stackName),
+    ChangeSetName: aws.String(fmt.Sprintf("flight-update-%d", time.Now().Unix())),
+    ChangeSetType: aws.String("UPDATE"),
+    Capabilities: []*string{aws.String("CAPABILITY_IAM")},
+  }
+  summary := &cloudformation.GetTemplateSummaryInput{StackName: aws.String(stackName)}
+  if newTemplate {
+    templateFile, err := templateFileFor(stack)
+    if err != nil { return nil, err }
+    update.TemplateURL = c.Client().TemplateUrl(templateFile)
+    template, err := c.Client().loadTemplate(update.TemplateURL)
+    if err != nil { return nil, err }
+    input.TemplateURL = template.urlParam()
+    input.TemplateBody = template.bodyParam()
+    for _, tag := range stack.Tags {
+      if *tag.Key == "flight:template" {
+        tag = &cloudformation.Tag{Key: tag.Key, Value: aws.String(template.source)}
+      }
+      input.Tags = append(input.Tags, tag)
+    }
+  } else {
+    input.UsePreviousTemplate = aws.Bool(true)
+  }
+
+  set := &ParameterSet{Source: stackName, Values: make(map[string]string)}
+  if paramsFile != "" {
+    set, err = loadComponentParameters(paramsFile)
+    if err != nil { return nil, err }
+  }
+  for key, val := range overrides {
+    set.Values[key] = val
+  }
+  resolved, err := clusterResolver(c).resolveParameters(set)
+  if err != nil { return nil, err }
+  values := make(map[string]string)
+  for _, param := range resolved {
+    values[*param.ParameterKey] = *param.ParameterValue
+  }
+
+  var declared map[string]*templateParameter
+  if newTemplate {
+    declared, err = c.Client().declaredParameters(update.TemplateURL)
+  } else {
+    declared, err = c.Client().summaryParameters(summary)
+  }
+  if err == ErrTemplateUnavailable {
+    declared = nil
+  } else if err != nil {
+    return nil, fmt.Errorf("Unable to retrieve template for %s: %s", stackName, err.Error())
+  }
+
+  // Parameters that aren't being changed keep their previous values,
+  // unless the template no longer declares them.
+  previous := make(map[string]string)
+  for _, param := range stack.Parameters {
+    key := *param.ParameterKey
+    previous[key] = aws.StringValue(param.ParameterValue)
+    if _, exists := set.Values[key]; exists { continue }
+    if declared != nil && declared[key] == nil { continue }
+    input.Parameters = append(input.Parameters, &cloudformation.Parameter{ParameterKey: param.ParameterKey, UsePreviousValue: aws.Bool(true)})
+  }
+  input.Parameters = append(input.Parameters, resolved...)
+
+  if declared != nil && c.Client().Config().ValidateParameters {
+    // Check the parameters the stack will have after the update.
+    combined := &ParameterSet{Source: set.Source, Values: make(map[string]string), lines: set.lines}
+    checked := make(map[string]string)
+    dropped := make(map[string]bool)
+    for key, val := range previous {
+      if _, exists := set.Values[key]; !exists && declared[key] != nil {
+        combined.Values[key] = val
+        checked[key] = val
+      }
+    }
+    for key, _ := range set.Values {
+      combined.Values[key] = set.Values[key]
+      if val, exists := values[key]; exists {
+        checked[key] = val
+      } else {
+        dropped[key] = true
+      }
+    }
+    err = combined.check(declared, stackName, checked, dropped)
+    if err != nil { return nil, err }
+  }
+
+  keys := []string{}
+  for key, _ := range previous {
+    keys = append(keys, key)
+  }
+  for key, _ := range values {
+    if _, exists := previous[key]; !exists {
+      keys = append(keys, key)
+    }
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    old, val := previous[key], values[key]
+    _, given := set.Values[key]
+    if !given && (declared == nil || declared[key] != nil) { continue }
+    if old != val {
+      update.Parameters = append(update.Parameters, ParameterChange{key, old, val})
+    }
+  }
+
+  if stackType == "compute" {
+    err = c.checkQueueUpdate(stackName, previous, values)
+    if err != nil { return nil, err }
+  }
+
+  _, err = throttleProtectedWithContext(ctx, func() (interface{}, error) { return svc.CreateChangeSet(input) })
+  if err != nil { return nil, err }
+  update.ChangeSetName = *input.ChangeSetName
+
+  describeInput := &cloudformation.DescribeChangeSetInput{StackName: input.StackName, ChangeSetName: input.ChangeSetName}
+  // A change set without changes fails to create, so its status is
+  // checked before the waiter's error.
+  _, waitErr := throttleProtectedWithContext(ctx, func() (interface{}, error) { return nil, svc.WaitUntilChangeSetCreateCompleteWithContext(ctx, describeInput) })
+  if waitErr != nil && ctx.Err() != nil {
+    c.DiscardUpdate(update)
+    return nil, ctx.Err()
+  }
+  o, err := throttleProtectedWithContext(ctx, func() (interface{}, error) { return svc.DescribeChangeSet(describeInput) })
+  if err != nil { return nil, err }
+  changeSet := o.(*cloudformation.DescribeChangeSetOutput)
+  if aws.StringValue(changeSet.Status) == "FAILED" {
+    c.DiscardUpdate(update)
+    reason := aws.StringValue(changeSet.StatusReason)
+    if strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates") {
+      return update, nil
+    }
+    return nil, fmt.Errorf("Unable to prepare update for %s: %s", stackName, reason)
+  }
+  if waitErr != nil {
+    c.DiscardUpdate(update)
+    return nil, fmt.Errorf("Unable to prepare update for %s: %s", stackName, waitErr.Error())
+  }
+  for _, change := range changeSet.Changes {
+    if change.ResourceChange == nil { continue }
+    update.Resources = append(update.Resources, ResourceChange{
+      Action: aws.StringValue(change.ResourceChange.Action),
+      LogicalId: aws.StringValue(change.ResourceChange.LogicalResourceId),
+      ResourceType: aws.StringValue(change.ResourceChange.ResourceType),
+      Replacement: aws.StringValue(change.ResourceChange.Replacement),
+    })
+  }
+  return update, nil
+}
+
+// checkQueueUpdate makes the checks that ModifyQueue makes when an
+// update changes a queue's maximum size or instance type.
+func (c *Cluster) checkQueueUpdate(stackName string, previous, values map[string]string) error {
+  maxNodes, maxGiven := values["ComputeMaxNodes"]
+  instanceType, typeGiven := values["ComputeInstanceType"]
+  if !maxGiven && !typeGiven { return nil }
+  if !maxGiven { maxNodes = previous["ComputeMaxNodes"] }
+  if !typeGiven { instanceType = previous["ComputeInstanceType"] }
+  maxSize, err := strconv.ParseInt(maxNodes, 10, 64)
+  if err != nil { return fmt.Errorf("Invalid ComputeMaxNodes for %s: %s", stackName, maxNodes) }
+  if maxGiven {
+    if err := c.checkComputeCeiling(maxSize); err != nil { return err }
+  }
+  previousMax, err := strconv.ParseInt(previous["ComputeMaxNodes"], 10, 64)
+  if err != nil || maxSize > previousMax || instanceType != previous["ComputeInstanceType"] {
+    return c.checkQueueQuota(stackName, instanceType, maxSize)
+  }
+  return nil
+}
+
+// ApplyUpdate executes a prepared change set, passing stack events to
+// the cluster's message handler until the update completes.
+func (c *Cluster) ApplyUpdate(ctx context.Context, update *StackUpdate) error {
+  if err := c.applyUpdate(ctx, update); err != nil { return err }
+  c.MessageHandler(NewDoneEvent())
+  return nil
+}
+
+func (c *Cluster) applyUpdate(ctx context.Context, update *StackUpdate) error {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return err }
+  qUrl, err := c.Client().getEventQueueUrl(c.eventTopicName())
+  if err != nil { return err }
+  go c.processQueue(ctx, qUrl)
+
+  _, err = throttleProtectedWithContext(ctx, func() (interface{}, error) {
+    return svc.ExecuteChangeSet(&cloudformation.ExecuteChangeSetInput{
+      StackName: aws.String(update.StackName),
+      ChangeSetName: aws.String(update.ChangeSetName),
+    })
+  })
+  if err != nil { return err }
+  _, err = throttleProtectedWithContext(ctx, func() (interface{}, error) {
+    return nil, svc.WaitUntilStackUpdateCompleteWithContext(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(update.StackName)})
+  })
+  // an interrupted wait leaves the update to carry on
+  if err != nil && ctx.Err() != nil { return ctx.Err() }
+  if err != nil {
+    if failure := stackFailure(svc, update.StackName, "UPDATE_FAILED"); failure != nil {
+      return failure
+    }
+  }
+  return err
+}
+
+// DiscardUpdate deletes a prepared change set without executing it.
+func (c *Cluster) DiscardUpdate(update *StackUpdate) error {
+  svc, err := c.Client().CloudFormation()
+  if err != nil { return err }
+  _, err = throttleProtected(func() (interface{}, error) {
+    return svc.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+      StackName: aws.String(update.StackName),
+      ChangeSetName: aws.String(update.ChangeSetName),
+    })
+  })
+  return err
+}
+
+func (u *StackUpdate) Render() string {
+  var buf bytes.Buffer
+  fmt.Fprintf(&buf, "== %s ==\n", u.StackName)
+  if u.TemplateURL != "" {
+    fmt.Fprintf(&buf, "Template: %s\n", u.TemplateURL)
+  }
+  buf.WriteString("\n")
+  w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+  if len(u.Parameters) > 0 {
+    fmt.Fprintln(w, "    PARAMETER\tCURRENT\tNEW")
+    for _, param := range u.Parameters {
+      fmt.Fprintf(w, "    %s\t%s\t%s\n", param.Key, valueOrDash(param.Old), valueOrDash(param.New))
+    }
+    fmt.Fprintln(w, "\t\t")
+  }
+  fmt.Fprintln(w, "    RESOURCE\tTYPE\tACTION\tREPLACEMENT")
+  for _, res := range u.Resources {
+    fmt.Fprintf(w, "    %s\t%s\t%s\t%s\n", res.LogicalId, res.ResourceType, res.Action, valueOrDash(res.Replacement))
+  }
+  w.Flush()
+  return buf.String()
+}
+
+func valueOrDash(s string) string {
+  if s == "" { return "-" }
+  return s
+}
@@ -0,0 +1,77 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package attendant
+
+import (
+  "context"
+  "strings"
+  "testing"
+)
+
+func TestPrepareUpdateParameterDiff(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+  stack, err := getStack(mem, "flight-d1-c1-compute-default")
+  if err != nil { t.Fatalf("getStack: %s", err) }
+  old := getStackParameter(stack, "ComputeMaxNodes")
+
+  ctx := context.Background()
+  update, err := cluster.PrepareUpdate(ctx, "compute", "default", "", map[string]string{"ComputeMaxNodes": "3"}, false)
+  if err != nil { t.Fatalf("PrepareUpdate: %s", err) }
+  if len(update.Parameters) != 1 || update.Parameters[0] != (ParameterChange{"ComputeMaxNodes", old, "3"}) {
+    t.Errorf("parameter changes = %+v, want only ComputeMaxNodes %s -> 3", update.Parameters, old)
+  }
+  if err := cluster.ApplyUpdate(ctx, update); err != nil { t.Fatalf("ApplyUpdate: %s", err) }
+  stack, err = getStack(mem, "flight-d1-c1-compute-default")
+  if err != nil { t.Fatalf("getStack: %s", err) }
+  if val := getStackParameter(stack, "ComputeMaxNodes"); val != "3" {
+    t.Errorf("ComputeMaxNodes = %s after the update, want 3", val)
+  }
+}
+
+func TestApplyUpdateReportsFailure(t *testing.T) {
+  c, mem := newTestClient(t)
+  d := createTestDomain(t, c, "d1")
+  cluster := createTestCluster(t, d, "c1", true)
+
+  ctx := context.Background()
+  update, err := cluster.PrepareUpdate(ctx, "compute", "default", "", map[string]string{"ComputeMaxNodes": "3"}, false)
+  if err != nil { t.Fatalf("PrepareUpdate: %s", err) }
+  if !update.HasChanges() { t.Fatalf("update has no changes") }
+  mem.Failures = map[string]string{"ComputeGroup": "Simulated failure"}
+  err = cluster.ApplyUpdate(ctx, update)
+  if err == nil { t.Fatalf("expected the update to fail") }
+  if !strings.Contains(err.Error(), "ComputeGroup") || !strings.Contains(err.Error(), "Simulated failure") {
+    t.Errorf("error %q doesn't report the failed resource", err.Error())
+  }
+  if status, _ := mem.stackStatus("flight-d1-c1-compute-default"); status != "UPDATE_ROLLBACK_COMPLETE" {
+    t.Errorf("stack status = %s, want UPDATE_ROLLBACK_COMPLETE", status)
+  }
+}
@@ -45,3 +45,13 @@ func containsS(s []string, e string) bool {
   }
   return false
 }
+
+func removeI(s []int, e int) []int {
+  r := []int{}
+  for _, a := range s {
+    if a != e {
+      r = append(r, a)
+    }
+  }
+  return r
+}
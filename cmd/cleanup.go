@@ -29,7 +29,9 @@
 package cmd
 
 import (
+  "context"
   "fmt"
+  "io"
   "strings"
   
   "github.com/spf13/cobra"
@@ -43,69 +45,65 @@ var cleanupCmd = &cobra.Command{
   Long: `Clean up Alces Flight resources.`,
   SilenceUsage: true,
   RunE: func(cmd *cobra.Command, args []string) error {
-    var domains []attendant.Domain
-    var err error
     if err := attendant.PreflightCheck(); err != nil { return err }
-    regions := getRegions(cmd)
-    for _, region := range regions {
-      attendant.Config().AwsRegion = region
-      attendant.SpinWithSuffix(func() {
-        domains, err = attendant.AllDomains()
-      }, region)
-      if err != nil { return err }
-      var stacks = []string{}
-      for _, domain := range domains {
-        var status *attendant.DomainStatus
-        var networkIndices = []int{}
-        attendant.SpinWithSuffix(func() { status, err = domain.Status() }, region + ": " + domain.Name)
-        if err != nil { return err }
-        stacks = append(stacks, "flight-" + domain.Name)
-        // list all topics, subscriptions, queues and remove any that aren't accounted for
-        for _, cluster := range status.Clusters {
-          stacks = append(stacks, "flight-" + domain.Name + "-cluster-" + cluster.Name)
-          if ( cluster.Network != nil ) {
-            networkIndices = append(networkIndices, cluster.Network.Index)
-          }
-        }
-        for _, appliance := range status.Appliances {
-          stacks = append(stacks, "flight-" + domain.Name + "-" + appliance.Name)
+    dryrun, _ := cmd.Flags().GetBool("dry-run")
+    results := eachRegion(getRegions(cmd), func(client *attendant.Client, out io.Writer) (interface{}, error) {
+      return nil, cleanupRegion(client, out, dryrun)
+    })
+    return printRegions(results)
+  },
+}
+
+func cleanupRegion(client *attendant.Client, out io.Writer, dryrun bool) error {
+  region := client.Config().AwsRegion
+  domains, err := client.AllDomains()
+  if err != nil { return err }
+  var stacks = []string{}
+  for _, domain := range domains {
+    var networkIndices = []int{}
+    status, err := domain.Status(context.Background())
+    if err != nil { return err }
+    stacks = append(stacks, "flight-" + domain.Name)
+    // list all topics, subscriptions, queues and remove any that aren't accounted for
+    for _, cluster := range status.Clusters {
+      stacks = append(stacks, "flight-" + domain.Name + "-cluster-" + cluster.Name)
+      if ( cluster.Network != nil ) {
+        networkIndices = append(networkIndices, cluster.Network.Index)
+      }
+    }
+    for _, appliance := range status.Appliances {
+      stacks = append(stacks, "flight-" + domain.Name + "-" + appliance.Name)
+    }
+    entity, err := domain.LoadEntity()
+    if err != nil { return err }
+    for _, booking := range entity.NetBookings {
+      for _, a := range networkIndices {
+        if a == booking {
+          break
         }
-        entity, err := domain.LoadEntity()
-        if err != nil { return err }
-        for _, booking := range entity.NetBookings {
-          for _, a := range networkIndices {
-            if a == booking {
-              break
-            }
-            fmt.Printf("🗑  Purge stale network booking: %s/%d\n", domain.Name, booking)
-            if dryrun, _ := cmd.Flags().GetBool("dry-run"); !dryrun {
-              domain.ReleaseNetwork(booking)
-            }
-          }
+        fmt.Fprintf(out, "🗑  Purge stale network booking: %s/%d\n", domain.Name, booking)
+        if !dryrun {
+          domain.ReleaseNetwork(booking)
         }
       }
-      var soloStatus *attendant.DomainStatus
-      attendant.SpinWithSuffix(func() { soloStatus, err = attendant.SoloStatus() }, region + " (Solo)")
-      if err != nil { return err }
-      for _, cluster := range soloStatus.Clusters {
-        stacks = append(stacks, "flight-cluster-" + cluster.Name)
-      }
+    }
+  }
+  soloStatus, err := client.SoloStatus(context.Background())
+  if err != nil { return err }
+  for _, cluster := range soloStatus.Clusters {
+    stacks = append(stacks, "flight-cluster-" + cluster.Name)
+  }
 
-      if ( len(stacks) > 0 ) {
-        fmt.Println("Active resources (" + region + "): " + strings.Join(stacks,", ") + "\n")
-        handler := func(msg string) {
-          attendant.Spinner().Stop()
-          fmt.Println(msg)
-          attendant.Spinner().Start()
-        }
-        dryrun, _ := cmd.Flags().GetBool("dry-run")
-        attendant.SpinWithSuffix(func() { err = attendant.CleanFlightEventHandling(stacks, dryrun, handler) }, region)
-        if err != nil { return err }
-        fmt.Println("")
-      }
+  if ( len(stacks) > 0 ) {
+    fmt.Fprintln(out, "Active resources (" + region + "): " + strings.Join(stacks,", ") + "\n")
+    handler := func(msg string) {
+      fmt.Fprintln(out, msg)
     }
-    return nil
-  },
+    err = client.CleanFlightEventHandling(stacks, dryrun, handler)
+    if err != nil { return err }
+    fmt.Fprintln(out, "")
+  }
+  return nil
 }
 
 func init() {
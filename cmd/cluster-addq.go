@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   "time"
   
@@ -79,9 +80,22 @@ var clusterAddqCmd = &cobra.Command{
       expiryTime = time.Now().Add(duration).Unix()
     }
 
-    fmt.Printf("Adding queue '%s' to cluster '%s' in domain '%s' (%s)...\n\n", args[1], args[0], domain.Name, attendant.Config().AwsRegion)
-    err = addQ(domain, args[0], args[1], componentParamsFile, expiryTime)
+    if isPlan(cmd) {
+      return showPlan(cmd, func() error {
+        cluster := attendant.NewCluster(args[0], domain, attendant.DiscardEvents)
+        if attendant.Config().Settings["compute-group-label"] == "" {
+          attendant.Config().Settings["compute-group-label"] = args[1]
+        }
+        err := cluster.AddQueue(context.Background(), args[1], componentParamsFile, expiryTime)
+        cluster.MessageHandler = nil
+        return err
+      })
+    }
+
+    fmt.Printf("Adding queue '%s' to cluster '%s' in domain '%s' (%s)...\n\n", args[1], args[0], domain.Name, currentLocation())
+    group, err := addQ(domain, args[0], args[1], componentParamsFile, expiryTime)
     if err != nil { return err }
+    setResult(group.Details())
     fmt.Println("\nCluster queue created.\n")
     return nil
   },
@@ -96,16 +110,27 @@ func init() {
   clusterAddqCmd.Flags().StringP("queue-instance-type", "t", "", "Compute instance type (default: \"" + attendant.ComputeInstanceTypes[0] + "\")")
   clusterAddqCmd.Flags().IntP("runtime", "r", 0, "Maximum runtime for queue (minutes)")
   viper.BindPFlag("queue-instance-type", clusterAddqCmd.Flags().Lookup("queue-instance-type"))
+  addPlanFlags(clusterAddqCmd)
 }
 
-func addQ(domain *attendant.Domain, clusterName, queueName, componentParamsFile string, expiryTime int64) error {
+func addQ(domain *attendant.Domain, clusterName, queueName, componentParamsFile string, expiryTime int64) (*attendant.ComputeGroup, error) {
   handler, err := attendant.CreateCreateHandler(attendant.ComputeGroupResourceCount)
-  if err != nil { return err }
+  if err != nil { return nil, err }
   cluster := attendant.NewCluster(clusterName, domain, handler)
-  if viper.GetString("compute-group-label") == "" {
-    viper.Set("compute-group-label", queueName)
+  if attendant.Config().Settings["compute-group-label"] == "" {
+    attendant.Config().Settings["compute-group-label"] = queueName
   }
-  attendant.Spin(func() { err = cluster.AddQueue(queueName, componentParamsFile, expiryTime) })
+  interrupts := watchInterrupts(true)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.AddQueue(interrupts.ctx, queueName, componentParamsFile, expiryTime) })
+  err = interrupts.outcome(err, func(ctx context.Context) error {
+    handler, err := attendant.CreateDestroyHandler(attendant.ComputeGroupResourceCount)
+    if err != nil { return err }
+    cluster.MessageHandler = handler
+    attendant.Spin(func() { err = cluster.DestroyQueue(ctx, queueName) })
+    return err
+  })
   cluster.MessageHandler = nil
-  return err
+  if err != nil { return nil, err }
+  return cluster.ComputeGroups[len(cluster.ComputeGroups) - 1], nil
 }
@@ -0,0 +1,120 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+type costReport struct {
+  Region string `json:"Region" yaml:"Region"`
+  Hours float64 `json:"Hours" yaml:"Hours"`
+  CurrentHourly float64 `json:"CurrentHourly" yaml:"CurrentHourly"`
+  MaximumHourly float64 `json:"MaximumHourly" yaml:"MaximumHourly"`
+  CurrentProjected float64 `json:"CurrentProjected" yaml:"CurrentProjected"`
+  MaximumProjected float64 `json:"MaximumProjected" yaml:"MaximumProjected"`
+  Items []*attendant.CostItem `json:"Items" yaml:"Items"`
+}
+
+func newCostReport(e *attendant.CostEstimate) *costReport {
+  return &costReport{e.Region, e.Hours, e.CurrentHourly(), e.MaximumHourly(), e.CurrentHourly() * e.Hours, e.MaximumHourly() * e.Hours, e.Items}
+}
+
+// clusterCostCmd represents the cost command
+var clusterCostCmd = &cobra.Command{
+  Use:   "cost <cluster>",
+  Short: "Estimate the cost of a running cluster",
+  Long: `Estimate the hourly and projected cost of a running cluster.
+
+The current cost counts the instances that are running; the maximum
+counts every compute queue at its maximum size.  Queues with a spot
+price are costed at the lower of it and the on-demand price, shown as
+"spot (max)" since that is the most that will be paid.
+Costs are projected until the cluster expires or, if it has no expiry,
+over a month, unless --hours is given.
+
+Prices are bundled estimates for each region; set price-file to a YAML
+file of prices by region to override them, e.g.:
+
+  us-east-1:
+    instances:
+      c4.large: 0.10
+    volumes:
+      gp2: 0.10`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    var domain *attendant.Domain
+    var err error
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    solo, _ := cmd.Flags().GetBool("solo")
+    if !solo {
+      domain, err = findDomain("clusterCost", false)
+      if err != nil { return err }
+    }
+
+    cluster := attendant.NewCluster(args[0], domain, nil)
+    var estimate *attendant.CostEstimate
+    hours, _ := cmd.Flags().GetFloat64("hours")
+    attendant.SpinWithSuffix(func() {
+      estimate, err = cluster.Cost(hours)
+      if err == nil && hours <= 0 {
+        estimate.Hours = attendant.HoursPerMonth
+        if expiry := cluster.GetExpiryTime(); expiry > time.Now().Unix() {
+          estimate.Hours = float64(expiry - time.Now().Unix()) / 3600
+        }
+      }
+    }, attendant.Config().AwsRegion + ": " + cluster.Name)
+    if err != nil { return err }
+    setResult(newCostReport(estimate))
+    if solo {
+      fmt.Println("== " + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
+    } else {
+      fmt.Println("== " + domain.Name + "/" + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
+    }
+    fmt.Print(estimate.Render())
+    return nil
+  },
+}
+
+func init() {
+  clusterCmd.AddCommand(clusterCostCmd)
+  addDomainFlag(clusterCostCmd, "clusterCost")
+  clusterCostCmd.Flags().BoolP("solo", "s", false, "Estimate the cost of a Flight Compute Solo cluster")
+  clusterCostCmd.Flags().Float64("hours", 0, "Number of hours to project the cost over")
+}
@@ -55,7 +55,7 @@ var clusterDelqCmd = &cobra.Command{
     domain, err = findDomain("clusterDelq", false)
     if err != nil { return err }
 
-    fmt.Printf("Removing queue '%s' from cluster '%s' in domain '%s' (%s)...\n\n", args[1], args[0], domain.Name, attendant.Config().AwsRegion)
+    fmt.Printf("Removing queue '%s' from cluster '%s' in domain '%s' (%s)...\n\n", args[1], args[0], domain.Name, currentLocation())
     err = delq(domain, args[0], args[1])
     if err != nil { return err }
     fmt.Println("\nCluster queue destroyed.\n")
@@ -72,7 +72,9 @@ func delq(domain *attendant.Domain, clusterName, queueName string) error {
   handler, err := attendant.CreateDestroyHandler(attendant.ComputeGroupResourceCount)
   if err != nil { return err }
   cluster := attendant.NewCluster(clusterName, domain, handler)
-  attendant.Spin(func() { err = cluster.DestroyQueue(queueName) })
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.DestroyQueue(interrupts.ctx, queueName) })
   cluster.MessageHandler = nil
-  return err
+  return interrupts.outcome(err, nil)
 }
@@ -53,13 +53,13 @@ var clusterDestroyCmd = &cobra.Command{
     if err := attendant.PreflightCheck(); err != nil { return err }
     solo, _ := cmd.Flags().GetBool("solo")
     if solo {
-      fmt.Printf("Destroying Flight Compute Solo cluster '%s' in (%s)...\n\n", args[0], attendant.Config().AwsRegion)
+      fmt.Printf("Destroying Flight Compute Solo cluster '%s' in (%s)...\n\n", args[0], currentLocation())
       domain = nil
     } else {
       domain, err = findDomain("clusterDestroy", false)
       if err != nil { return err }
 
-      fmt.Printf("Destroying cluster '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, attendant.Config().AwsRegion)
+      fmt.Printf("Destroying cluster '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, currentLocation())
     }
     err = destroyCluster(domain, args[0])
     if err != nil { return err }
@@ -78,7 +78,9 @@ func destroyCluster(domain *attendant.Domain, name string) error {
   handler, err := attendant.CreateDestroyHandler(attendant.ClusterResourceCount)
   if err != nil { return err }
   cluster := attendant.NewCluster(name, domain, handler)
-  attendant.Spin(func() { err = cluster.Destroy() })
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.Destroy(interrupts.ctx) })
   cluster.MessageHandler = nil
-  return err
+  return interrupts.outcome(err, nil)
 }
@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   
   "github.com/spf13/cobra"
@@ -64,10 +65,19 @@ var clusterExpandCmd = &cobra.Command{
 
     if err := setupKeyPair("clusterExpand"); err != nil { return err }
 
+    if isPlan(cmd) {
+      return showPlan(cmd, func() error {
+        cluster := attendant.NewCluster(args[0], domain, attendant.DiscardEvents)
+        err := cluster.Expand(context.Background(), args[1], componentName, componentParamsFile)
+        cluster.MessageHandler = nil
+        return err
+      })
+    }
+
     if componentName == "" {
-      fmt.Printf("Expanding cluster '%s' in domain '%s' (%s) with '%s'...\n\n", args[0], domain.Name, attendant.Config().AwsRegion, args[1])
+      fmt.Printf("Expanding cluster '%s' in domain '%s' (%s) with '%s'...\n\n", args[0], domain.Name, currentLocation(), args[1])
     } else {
-      fmt.Printf("Expanding cluster '%s' in domain '%s' (%s) with '%s (%s)'...\n\n", args[0], domain.Name, attendant.Config().AwsRegion, args[1], componentName)
+      fmt.Printf("Expanding cluster '%s' in domain '%s' (%s) with '%s (%s)'...\n\n", args[0], domain.Name, currentLocation(), args[1], componentName)
     }
     err = expandCluster(domain, args[0], args[1], componentName, componentParamsFile)
     if err != nil { return err }
@@ -83,13 +93,23 @@ func init() {
   addTemplateSetFlag(clusterExpandCmd, "clusterExpand")
   clusterExpandCmd.Flags().StringP("name", "n", "", "Provide a name for the component")
   clusterExpandCmd.Flags().StringP("params", "p", "", "File containing parameters to use for launching the component")
+  addPlanFlags(clusterExpandCmd)
 }
 
 func expandCluster(domain *attendant.Domain, clusterName, componentType, componentName, componentParamsFile string) error {
   handler, err := attendant.CreateCreateHandler(0)
   if err != nil { return err }
   cluster := attendant.NewCluster(clusterName, domain, handler)
-  attendant.Spin(func() { err = cluster.Expand(componentType, componentName, componentParamsFile) })
+  interrupts := watchInterrupts(true)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.Expand(interrupts.ctx, componentType, componentName, componentParamsFile) })
+  err = interrupts.outcome(err, func(ctx context.Context) error {
+    handler, err := attendant.CreateDestroyHandler(0)
+    if err != nil { return err }
+    cluster.MessageHandler = handler
+    attendant.Spin(func() { err = cluster.Reduce(ctx, componentType, componentName) })
+    return err
+  })
   cluster.MessageHandler = nil
   return err
 }
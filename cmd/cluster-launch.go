@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   "strings"
   "time"
@@ -88,8 +89,9 @@ var clusterLaunchCmd = &cobra.Command{
     var soloMode string
     solo, _ := cmd.Flags().GetBool("solo")
     soloLegacy, _ := cmd.Flags().GetBool("solo-legacy")
+    var launchMsg string
     if solo || soloLegacy {
-      fmt.Printf("Launching Flight Compute Solo cluster '%s' (%s)...\n\n", args[0], attendant.Config().AwsRegion)
+      launchMsg = fmt.Sprintf("Launching Flight Compute Solo cluster '%s' (%s)...\n\n", args[0], currentLocation())
       domain = nil
       if soloLegacy {
         soloMode = "legacy"
@@ -104,11 +106,24 @@ var clusterLaunchCmd = &cobra.Command{
         return fmt.Errorf("Domain is not ready: " + domain.Name)
       }
 
-      fmt.Printf("Launching cluster '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, attendant.Config().AwsRegion)
+      launchMsg = fmt.Sprintf("Launching cluster '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, currentLocation())
     }
+    if isPlan(cmd) {
+      return showPlan(cmd, func() error {
+        cluster := attendant.NewCluster(args[0], domain, attendant.DiscardEvents)
+        cluster.SoloMode = soloMode
+        cluster.ExpiryTime = expiryTime
+        cluster.Quota = quota
+        err := cluster.Create(context.Background(), withQ)
+        cluster.MessageHandler = nil
+        return err
+      })
+    }
+    fmt.Print(launchMsg)
     cluster, err = launchCluster(domain, args[0], withQ, expiryTime, quota, soloMode)
     if err != nil { return err }
 
+    setResult(cluster.Details())
     fmt.Println("\nCluster launched.\n")
     fmt.Println("== Cluster details ==")
     fmt.Println(cluster.GetDetails() + "\n")
@@ -146,6 +161,7 @@ func init() {
   addDomainFlag(clusterLaunchCmd, "clusterLaunch")
   addTemplateSetFlag(clusterLaunchCmd, "clusterLaunch")
   addTemplateRootFlag(clusterLaunchCmd, "clusterLaunch")
+  addPlanFlags(clusterLaunchCmd)
 }
 
 func launchCluster(domain *attendant.Domain, name string, withQ bool, expiryTime int64, quota int64, soloMode string) (*attendant.Cluster, error) {
@@ -168,7 +184,9 @@ func launchCluster(domain *attendant.Domain, name string, withQ bool, expiryTime
   cluster.SoloMode = soloMode
   cluster.ExpiryTime = expiryTime
   cluster.Quota = quota
-  attendant.Spin(func() { err = cluster.Create(withQ) })
+  interrupts := watchInterrupts(true)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.Create(interrupts.ctx, withQ) })
   cluster.MessageHandler = nil
-  return cluster, err
+  return cluster, interrupts.outcome(err, nil)
 }
@@ -29,7 +29,10 @@
 package cmd
 
 import (
+  "context"
   "fmt"
+  "io"
+  "sort"
   "strings"
 
   "github.com/spf13/cobra"
@@ -45,93 +48,122 @@ var clusterListCmd = &cobra.Command{
   Long: `List running Flight Compute clusters.`,
   SilenceUsage: true,
   RunE: func(cmd *cobra.Command, args []string) error {
-    var status *attendant.DomainStatus
-    var domains []attendant.Domain
-    var err error
-
     if err := attendant.PreflightCheck(); err != nil { return err }
 
-    regions := getRegions(cmd)
-    for _, region := range regions {
-      attendant.Config().AwsRegion = region
-      solo, _ := cmd.Flags().GetBool("solo")
-      all, _ := cmd.Flags().GetBool("all")
-      expired, _ := cmd.Flags().GetBool("expired")
+    solo, _ := cmd.Flags().GetBool("solo")
+    all, _ := cmd.Flags().GetBool("all")
+    expired, _ := cmd.Flags().GetBool("expired")
+    results := eachRegion(getRegions(cmd), func(client *attendant.Client, out io.Writer) (interface{}, error) {
       if expired {
-        clusterNames, err := attendant.ExpiredClusters()
-        if err != nil { return err }
+        clusterNames, err := client.ExpiredClusters()
+        if err != nil { return nil, err }
         for _, name := range clusterNames {
-          fmt.Println(name)
+          fmt.Fprintln(out, name)
         }
-      } else {
-        if !solo || all {
-          if domain, err := findDomain("clusterList", false); err == nil {
-            domains = []attendant.Domain{*domain}
-          } else {
-            if err.Error() == "This operation requires you to specify a domain" {
-              attendant.SpinWithSuffix(func() {
-                domains, err = attendant.AllDomains()
-              }, region)
-            } else { return err }
-          }
-          if err != nil { return err }
-          for _, domain := range domains {
-            attendant.SpinWithSuffix(func() { status, err = domain.Status() }, region + ": " + domain.Name)
-            if err != nil { return err }
-            if attendant.Config().SimpleOutput {
-              for _, cluster := range status.Clusters {
-                err = cluster.LoadComputeGroups()
-                if err != nil { return err }
-                fmt.Printf("%s=%d\n", cluster.Name, len(cluster.ComputeGroups))
-              }
-            } else {
-              fmt.Printf("== Clusters in '%s' (%s) ==\n", domain.Name, attendant.Config().AwsRegion)
-              printClusters(status)
-              fmt.Println("")
-            }
-          }
+        return clusterNames, nil
+      }
+      return listClusters(client, out, solo, all)
+    })
+    if expired {
+      expiredNames := []string{}
+      for _, result := range results {
+        if result.Err == nil {
+          expiredNames = append(expiredNames, result.Data.([]string)...)
         }
-        if solo || all {
-          attendant.SpinWithSuffix(func() { status, err = attendant.SoloStatus() }, region + " (Solo)")
-          if err != nil { return err }
-          if len(status.Clusters) > 0 {
-            fmt.Printf("== Solo Clusters (%s) ==\n", attendant.Config().AwsRegion)
-            printClusters(status)
-            fmt.Println("")
-          }
-          if all {
-            var others []*cloudformation.Stack
-            attendant.SpinWithSuffix(func() { others, err = attendant.OtherStacks() }, region + " (Other resources)")
-            if err != nil { return err }
-            if len(others) > 0 {
-              fmt.Printf("== Other resources (%s) ==\n", attendant.Config().AwsRegion)
-              for _, stack := range others {
-                guessType := ""
-                for _, tag := range stack.Tags {
-                  if *tag.Key == "alces:orchestrator" {
-                    guessType = " (Alces FlightDeck Resource)"
-                    break
-                  }
-                }
-                if guessType == "" {
-                  if strings.Contains(*stack.Description, "Alces Flight Compute") {
-                    guessType = " (Flight Compute from AWS Marketplace)"
-                  } else {
-                    guessType = " (Unknown)"
-                  }
-                }
-                fmt.Println("    " + *stack.StackName + guessType)
-              }
-              fmt.Println("")
-            }
-          }
+      }
+      setResult(expiredNames)
+    } else {
+      listed := []*attendant.ClusterDetails{}
+      for _, result := range results {
+        if result.Err == nil {
+          listed = append(listed, result.Data.([]*attendant.ClusterDetails)...)
         }
       }
+      setResult(listed)
     }
-    return nil
+    return printRegions(results)
   },
 }
 
+// listClusters writes the clusters in one region to out, returning
+// their details for structured output.
+func listClusters(client *attendant.Client, out io.Writer, solo, all bool) ([]*attendant.ClusterDetails, error) {
+  where := location(client)
+  listed := []*attendant.ClusterDetails{}
+  if !solo || all {
+    var domains []attendant.Domain
+    if domain, err := findDomainIn(client, "clusterList", false); err == nil {
+      domains = []attendant.Domain{*domain}
+    } else if err.Error() == "This operation requires you to specify a domain" {
+      domains, err = client.AllDomains()
+      if err != nil { return nil, err }
+    } else {
+      return nil, err
+    }
+    for _, domain := range domains {
+      status, err := domain.Status(context.Background())
+      if err != nil { return nil, err }
+      if attendant.IsStructuredOutput() {
+        listed = append(listed, clusterDetails(status)...)
+      } else if attendant.Config().SimpleOutput {
+        for _, cluster := range status.Clusters {
+          err = cluster.LoadComputeGroups()
+          if err != nil { return nil, err }
+          fmt.Fprintf(out, "%s=%d\n", cluster.Name, len(cluster.ComputeGroups))
+        }
+      } else {
+        fmt.Fprintf(out, "== Clusters in '%s' (%s) ==\n", domain.Name, where)
+        printClusters(out, status)
+        fmt.Fprintln(out, "")
+      }
+    }
+  }
+  if solo || all {
+    status, err := client.SoloStatus(context.Background())
+    if err != nil { return nil, err }
+    if attendant.IsStructuredOutput() {
+      listed = append(listed, clusterDetails(status)...)
+    } else if len(status.Clusters) > 0 {
+      fmt.Fprintf(out, "== Solo Clusters (%s) ==\n", where)
+      printClusters(out, status)
+      fmt.Fprintln(out, "")
+    }
+    if all {
+      others, err := client.OtherStacks()
+      if err != nil { return nil, err }
+      if len(others) > 0 {
+        fmt.Fprintf(out, "== Other resources (%s) ==\n", where)
+        for _, stack := range others {
+          fmt.Fprintln(out, "    " + *stack.StackName + guessStackType(stack))
+        }
+        fmt.Fprintln(out, "")
+      }
+    }
+  }
+  return listed, nil
+}
+
+func guessStackType(stack *cloudformation.Stack) string {
+  guessType := ""
+  for _, tag := range stack.Tags {
+    if *tag.Key == "alces:orchestrator" {
+      guessType = " (Alces FlightDeck Resource)"
+      break
+    }
+  }
+  if guessType == "" {
+    if strings.Contains(*stack.Description, "Alces Flight Compute") {
+      guessType = " (Flight Compute from AWS Marketplace)"
+    } else {
+      guessType = " (Unknown)"
+    }
+  }
+  if *stack.StackStatus != "CREATE_COMPLETE" && *stack.StackStatus != "UPDATE_COMPLETE" {
+    guessType += " [" + *stack.StackStatus + "]"
+  }
+  return guessType
+}
+
 func init() {
   clusterCmd.AddCommand(clusterListCmd)
   addDomainFlag(clusterListCmd, "clusterList")
@@ -141,22 +173,30 @@ func init() {
   clusterListCmd.Flags().String("regions", "", "Select regions to query")
 }
 
-func printClusters(status *attendant.DomainStatus) {
+func printClusters(out io.Writer, status *attendant.DomainStatus) {
   if len(status.Clusters) > 0 {
     for _, cluster := range status.Clusters {
-      var details string
-      clusterName := cluster.Name
-      if cluster.Domain != nil {
-        clusterName = cluster.Domain.Name + "/" + clusterName
-      }
-      attendant.SpinWithSuffix(func() { details = cluster.GetDetails() }, attendant.Config().AwsRegion + ": " + clusterName)
-      fmt.Println("    " + cluster.Name)
-      fmt.Println("    " + strings.Repeat("-", len(cluster.Name)))
+      details := cluster.GetDetails()
+      fmt.Fprintln(out, "    " + cluster.Name)
+      fmt.Fprintln(out, "    " + strings.Repeat("-", len(cluster.Name)))
       for _, s := range strings.Split(details,"\n") {
-        fmt.Println("    " + s)
+        fmt.Fprintln(out, "    " + s)
       }
     }
   } else {
-    fmt.Println("<none>")
+    fmt.Fprintln(out, "<none>")
+  }
+}
+
+func clusterDetails(status *attendant.DomainStatus) []*attendant.ClusterDetails {
+  names := []string{}
+  for name := range status.Clusters {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  details := []*attendant.ClusterDetails{}
+  for _, name := range names {
+    details = append(details, status.Clusters[name].Details())
   }
+  return details
 }
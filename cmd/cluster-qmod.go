@@ -0,0 +1,105 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// clusterQmodCmd represents the qmod command
+var clusterQmodCmd = &cobra.Command{
+  Use:   "qmod <cluster> <name>",
+  Short: "Resize a compute queue or suspend and resume its scaling",
+  Long: `Resize a compute queue on a running Flight Compute cluster, or
+suspend and resume its autoscaling processes.
+
+New maximum and desired sizes are saved to the queue's parameters so
+that they are kept when the queue is updated.
+
+Scaling processes: all, ` + strings.Join(attendant.ScalingProcesses, ", "),
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) <= 1 {
+      cmd.Help()
+      return nil
+    }
+
+    var domain *attendant.Domain
+    var err error
+
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    domain, err = findDomain("clusterQmod", false)
+    if err != nil { return err }
+
+    mod := &attendant.QueueModification{}
+    for flag, size := range map[string]**int64{"min": &mod.MinSize, "max": &mod.MaxSize, "desired": &mod.DesiredCapacity} {
+      if cmd.Flags().Changed(flag) {
+        val, _ := cmd.Flags().GetInt64(flag)
+        *size = &val
+      }
+    }
+    mod.Suspend, _ = cmd.Flags().GetStringSlice("suspend")
+    mod.Resume, _ = cmd.Flags().GetStringSlice("resume")
+    if mod.MinSize == nil && mod.MaxSize == nil && mod.DesiredCapacity == nil && len(mod.Suspend) == 0 && len(mod.Resume) == 0 {
+      return fmt.Errorf("Nothing to modify; please specify --min, --max, --desired, --suspend or --resume.")
+    }
+
+    fmt.Printf("Modifying queue '%s' on cluster '%s' in domain '%s' (%s)...\n\n", args[1], args[0], domain.Name, currentLocation())
+    err = qmod(domain, args[0], args[1], mod)
+    if err != nil { return err }
+    fmt.Println("\nCluster queue modified.")
+    return nil
+  },
+}
+
+func init() {
+  clusterCmd.AddCommand(clusterQmodCmd)
+  addDomainFlag(clusterQmodCmd, "clusterQmod")
+  clusterQmodCmd.Flags().Int64("min", 0, "Minimum number of nodes")
+  clusterQmodCmd.Flags().Int64("max", 0, "Maximum number of nodes")
+  clusterQmodCmd.Flags().Int64("desired", 0, "Number of nodes to run now")
+  clusterQmodCmd.Flags().StringSlice("suspend", []string{}, "Scaling processes to suspend, or 'all'")
+  clusterQmodCmd.Flags().StringSlice("resume", []string{}, "Scaling processes to resume, or 'all'")
+}
+
+func qmod(domain *attendant.Domain, clusterName, queueName string, mod *attendant.QueueModification) error {
+  handler, err := attendant.CreateUpdateHandler(0)
+  if err != nil { return err }
+  cluster := attendant.NewCluster(clusterName, domain, handler)
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.ModifyQueue(interrupts.ctx, queueName, mod) })
+  cluster.MessageHandler = nil
+  return interrupts.outcome(err, nil)
+}
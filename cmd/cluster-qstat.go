@@ -77,6 +77,7 @@ var clusterQstatCmd = &cobra.Command{
           }
         }
         if foundGroup != nil {
+          setResult(foundGroup.Details())
           fmt.Println("== " + cluster.Domain.Name + "/" + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
           fmt.Println()
           showGroupDetails(foundGroup)
@@ -85,10 +86,13 @@ var clusterQstatCmd = &cobra.Command{
         }
       } else {
         fmt.Println("== " + cluster.Domain.Name + "/" + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
+        queues := []attendant.QueueDetails{}
         for  _, group := range cluster.ComputeGroups {
           fmt.Println()
           showGroupDetails(group)
+          queues = append(queues, group.Details())
         }
+        setResult(queues)
       }
       return nil
     } else {
@@ -110,6 +114,9 @@ func showGroupDetails(group *attendant.ComputeGroup) {
   fmt.Printf("    Capacity: %d-%d\n", group.MinSize(), group.MaxSize())
   fmt.Printf("     Running: %d\n", group.Running())
   fmt.Printf("     Pending: %d\n", group.DesiredCapacity() - group.Running())
+  if suspended := group.SuspendedProcesses(); len(suspended) > 0 {
+    fmt.Printf("   Suspended: %s\n", strings.Join(suspended, ", "))
+  }
   if group.ExpiryTime > 0 {
     fmt.Printf("      Expiry: %s\n", time.Unix(group.ExpiryTime, 0).Format(time.RFC3339))
   }
@@ -0,0 +1,92 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+type quotaReport struct {
+  Quota int64 `json:"Quota" yaml:"Quota"`
+  CurrentBurn int64 `json:"CurrentBurn" yaml:"CurrentBurn"`
+  MaximumBurn int64 `json:"MaximumBurn" yaml:"MaximumBurn"`
+  Items []*attendant.QuotaItem `json:"Items" yaml:"Items"`
+}
+
+// clusterQuotaCmd represents the quota command
+var clusterQuotaCmd = &cobra.Command{
+  Use:   "quota <cluster>",
+  Short: "Show the compute unit burn of a cluster against its quota",
+  Long: `Show the compute unit burn of a cluster against its quota.
+
+The current burn counts the instances that are running; the maximum
+burn counts every compute queue at its maximum size.  Queues and
+components can't be added, nor queues grown, beyond the quota.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    var domain *attendant.Domain
+    var err error
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    solo, _ := cmd.Flags().GetBool("solo")
+    if !solo {
+      domain, err = findDomain("clusterQuota", false)
+      if err != nil { return err }
+    }
+
+    cluster := attendant.NewCluster(args[0], domain, nil)
+    var usage *attendant.QuotaUsage
+    attendant.SpinWithSuffix(func() {
+      usage, err = cluster.QuotaUsage()
+    }, attendant.Config().AwsRegion + ": " + cluster.Name)
+    if err != nil { return err }
+    setResult(&quotaReport{usage.Quota, usage.CurrentBurn(), usage.MaximumBurn(), usage.Items})
+    if solo {
+      fmt.Println("== " + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
+    } else {
+      fmt.Println("== " + domain.Name + "/" + cluster.Name + " (" + attendant.Config().AwsRegion + ") ==")
+    }
+    fmt.Print(usage.Render())
+    return nil
+  },
+}
+
+func init() {
+  clusterCmd.AddCommand(clusterQuotaCmd)
+  addDomainFlag(clusterQuotaCmd, "clusterQuota")
+  clusterQuotaCmd.Flags().BoolP("solo", "s", false, "Show the quota of a Flight Compute Solo cluster")
+}
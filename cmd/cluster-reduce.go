@@ -59,9 +59,9 @@ var clusterReduceCmd = &cobra.Command{
     if err != nil { return err }
 
     if componentName == "" {
-      fmt.Printf("Reducing cluster '%s' in domain '%s' (%s) with '%s'...\n\n", args[0], domain.Name, attendant.Config().AwsRegion, args[1])
+      fmt.Printf("Reducing cluster '%s' in domain '%s' (%s) with '%s'...\n\n", args[0], domain.Name, currentLocation(), args[1])
     } else {
-      fmt.Printf("Reducing cluster '%s' in domain '%s' (%s) with '%s (%s)'...\n\n", args[0], domain.Name, attendant.Config().AwsRegion, args[1], componentName)
+      fmt.Printf("Reducing cluster '%s' in domain '%s' (%s) with '%s (%s)'...\n\n", args[0], domain.Name, currentLocation(), args[1], componentName)
     }
     err = reduceCluster(domain, args[0], args[1], componentName)
     if err != nil { return err }
@@ -80,7 +80,9 @@ func reduceCluster(domain *attendant.Domain, clusterName, componentType, compone
   handler, err := attendant.CreateDestroyHandler(0)
   if err != nil { return err }
   cluster := attendant.NewCluster(clusterName, domain, handler)
-  attendant.Spin(func() { err = cluster.Reduce(componentType, componentName) })
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = cluster.Reduce(interrupts.ctx, componentType, componentName) })
   cluster.MessageHandler = nil
-  return err
+  return interrupts.outcome(err, nil)
 }
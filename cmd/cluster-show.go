@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   "strings"
   
@@ -57,10 +58,14 @@ var clusterShowCmd = &cobra.Command{
     if solo {
       var status *attendant.DomainStatus
       var details string
-      attendant.SpinWithSuffix(func() { status, err = attendant.SoloStatus() }, attendant.Config().AwsRegion + " (Solo)")
+      attendant.SpinWithSuffix(func() { status, err = attendant.SoloStatus(context.Background()) }, attendant.Config().AwsRegion + " (Solo)")
       found := false
       for _, cluster := range status.Clusters {
         if cluster.Name == args[0] {
+          if attendant.IsStructuredOutput() {
+            setResult(cluster.Details())
+            return nil
+          }
           attendant.SpinWithSuffix(func() { details = cluster.GetDetails() }, attendant.Config().AwsRegion + ": " + args[0])
           fmt.Println(cluster.Name)
           fmt.Println(strings.Repeat("-", len(cluster.Name)))
@@ -82,6 +87,10 @@ var clusterShowCmd = &cobra.Command{
       var exists bool
       attendant.SpinWithSuffix(func() { exists = cluster.Exists() }, attendant.Config().AwsRegion + ": " + cluster.Domain.Name + "/" + cluster.Name)
       if exists {
+        if attendant.IsStructuredOutput() {
+          setResult(cluster.Details())
+          return nil
+        }
         fmt.Println(cluster.Name)
         fmt.Println(strings.Repeat("-", len(cluster.Name)))
 
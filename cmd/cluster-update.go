@@ -0,0 +1,146 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "strings"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// clusterUpdateCmd represents the update command
+var clusterUpdateCmd = &cobra.Command{
+  Use:   "update <cluster>",
+  Short: "Change the parameters or template of a running cluster",
+  Long: `Change the parameters or template of a running cluster.
+
+The master stack is updated unless a queue or component is selected.
+Parameters given with --params or --set replace those the stack is
+running with; all others keep their current values.  The template is
+only changed when --template-set or --template-root is given.
+
+The changes are shown, including any resources that will be replaced,
+and applied once confirmed.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    var domain *attendant.Domain
+    var err error
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    solo, _ := cmd.Flags().GetBool("solo")
+    if !solo {
+      domain, err = findDomain("clusterUpdate", false)
+      if err != nil { return err }
+    }
+
+    stackType, name := "master", ""
+    queueName, _ := cmd.Flags().GetString("queue")
+    componentName, _ := cmd.Flags().GetString("component")
+    if queueName != "" && componentName != "" {
+      return fmt.Errorf("Only one of a queue or a component may be updated at a time.")
+    } else if queueName != "" {
+      stackType, name = "compute", queueName
+    } else if componentName != "" {
+      stackType, name = "component", componentName
+    }
+
+    paramsFile, _ := cmd.Flags().GetString("params")
+    settings, _ := cmd.Flags().GetStringArray("set")
+    overrides := make(map[string]string)
+    for _, setting := range settings {
+      kv := strings.SplitN(setting, "=", 2)
+      if len(kv) != 2 || kv[0] == "" {
+        return fmt.Errorf("Invalid parameter setting: %s (expected KEY=VALUE)", setting)
+      }
+      overrides[kv[0]] = kv[1]
+    }
+
+    if err := setupTemplateSource("clusterUpdate"); err != nil { return err }
+    newTemplate := cmd.Flags().Changed("template-set") || cmd.Flags().Changed("template-root")
+    if paramsFile == "" && len(overrides) == 0 && !newTemplate {
+      return fmt.Errorf("Nothing to update; please specify parameters with --params or --set, or a new template.")
+    }
+
+    cluster := attendant.NewCluster(args[0], domain, nil)
+    var update *attendant.StackUpdate
+    attendant.SpinWithSuffix(func() {
+      update, err = cluster.PrepareUpdate(context.Background(), stackType, name, paramsFile, overrides, newTemplate)
+    }, "preparing changes")
+    if err != nil { return err }
+    setResult(update)
+    if !update.HasChanges() {
+      fmt.Printf("No changes to apply to %s.\n", update.StackName)
+      return nil
+    }
+
+    fmt.Println(update.Render())
+    confirmed, _ := cmd.Flags().GetBool("yes")
+    if !confirmed && attendant.IsStructuredOutput() {
+      if err := cluster.DiscardUpdate(update); err != nil { return err }
+      return fmt.Errorf("Updates must be confirmed with --yes when using structured output.")
+    }
+    if !confirmed && !confirm("Apply these changes?") {
+      if err := cluster.DiscardUpdate(update); err != nil { return err }
+      fmt.Println("Update cancelled.")
+      return nil
+    }
+
+    fmt.Printf("\nUpdating %s (%s)...\n\n", update.StackName, currentLocation())
+    cluster.MessageHandler, err = attendant.CreateUpdateHandler(len(update.Resources))
+    if err != nil { return err }
+    interrupts := watchInterrupts(false)
+    defer interrupts.stop()
+    attendant.Spin(func() { err = cluster.ApplyUpdate(interrupts.ctx, update) })
+    cluster.MessageHandler = nil
+    if err = interrupts.outcome(err, nil); err != nil { return err }
+    fmt.Println("\nCluster updated.")
+    return nil
+  },
+}
+
+func init() {
+  clusterCmd.AddCommand(clusterUpdateCmd)
+  addDomainFlag(clusterUpdateCmd, "clusterUpdate")
+  addTemplateSetFlag(clusterUpdateCmd, "clusterUpdate")
+  addTemplateRootFlag(clusterUpdateCmd, "clusterUpdate")
+  clusterUpdateCmd.Flags().BoolP("solo", "s", false, "Update a Flight Compute Solo cluster")
+  clusterUpdateCmd.Flags().StringP("queue", "q", "", "Update the named queue")
+  clusterUpdateCmd.Flags().StringP("component", "c", "", "Update the named component, e.g. cluster-parallel-storage")
+  clusterUpdateCmd.Flags().StringP("params", "p", "", "File containing parameters to change")
+  clusterUpdateCmd.Flags().StringArray("set", []string{}, "Change a single parameter (KEY=VALUE); may be repeated")
+  clusterUpdateCmd.Flags().Bool("yes", false, "Apply the changes without asking for confirmation")
+}
@@ -0,0 +1,111 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+
+  "github.com/spf13/cobra"
+  "github.com/spf13/viper"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// configSetting is a setting in effect and where its value came from.
+type configSetting struct {
+  Key string `json:"Key" yaml:"Key"`
+  Value string `json:"Value" yaml:"Value"`
+  Source string `json:"Source" yaml:"Source"`
+}
+
+// configShowCmd represents the show command
+var configShowCmd = &cobra.Command{
+  Use:   "show",
+  Short: "Show configuration settings",
+  Long: `Show the settings that differ from their defaults, or every setting
+with --effective, along with where each value comes from: a flag, a
+FLY_* environment variable, the context in use, the config file or the
+default.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    effective, _ := cmd.Flags().GetBool("effective")
+    file, err := configFileSettings()
+    if err != nil { return err }
+    env := configEnvSettings()
+    var context map[string]string
+    if activeContext != "" {
+      contexts, err := loadContexts()
+      if err != nil { return err }
+      context = contexts[activeContext]
+    }
+
+    keys := make(map[string]string)
+    for key := range attendant.ConfigDefaults {
+      keys[key] = ""
+    }
+    for _, settings := range []map[string]string{file, env, context} {
+      for key := range settings {
+        if attendant.IsKnownConfigKey(key) { keys[key] = "" }
+      }
+    }
+    shown := []*configSetting{}
+    for _, key := range sortedKeys(keys) {
+      source := "default"
+      if flag := RootCmd.PersistentFlags().Lookup(key); flag != nil && flag.Changed {
+        source = "flag"
+      } else if _, set := env[key]; set {
+        source = "env"
+      } else if _, set := context[key]; set {
+        source = "context " + activeContext
+      } else if _, set := file[key]; set {
+        source = "file"
+      }
+      if source == "default" && !effective { continue }
+      value := viper.GetString(key)
+      if key == "secret-key" && value != "" { value = "********" }
+      shown = append(shown, &configSetting{key, value, source})
+    }
+
+    setResult(shown)
+    if len(shown) == 0 {
+      fmt.Println("All settings have their default values.")
+      return nil
+    }
+    fmt.Printf("%-32s %-16s %s\n", "KEY", "SOURCE", "VALUE")
+    for _, setting := range shown {
+      fmt.Printf("%-32s %-16s %s\n", setting.Key, setting.Source, setting.Value)
+    }
+    return nil
+  },
+}
+
+func init() {
+  configCmd.AddCommand(configShowCmd)
+  configShowCmd.Flags().Bool("effective", false, "Show every setting, including those with default values")
+}
@@ -0,0 +1,203 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "os"
+  "sort"
+  "strings"
+
+  "github.com/spf13/cobra"
+  "github.com/spf13/viper"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// configProblem is a setting that failed validation.
+type configProblem struct {
+  Source string `json:"Source" yaml:"Source"`
+  Key string `json:"Key,omitempty" yaml:"Key,omitempty"`
+  Message string `json:"Message" yaml:"Message"`
+}
+
+// configValidateCmd represents the validate command
+var configValidateCmd = &cobra.Command{
+  Use:   "validate",
+  Short: "Check configuration settings",
+  Long: `Check every setting in the config file, its contexts and FLY_*
+environment variables against the known settings and the values they
+may take.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    problems := []*configProblem{}
+    check := func(source, key, value string, settings map[string]string) {
+      if !attendant.IsKnownConfigKey(key) {
+        message := fmt.Sprintf("Unknown setting '%s'.", key)
+        if suggestion := closestConfigKey(key); suggestion != "" {
+          message += fmt.Sprintf(" Did you mean '%s'?", suggestion)
+        }
+        problems = append(problems, &configProblem{source, key, message})
+      } else if err := attendant.ValidateConfigSetting(key, value, settingsGetter(settings)); err != nil {
+        problems = append(problems, &configProblem{source, key, err.Error()})
+      }
+    }
+
+    file, err := configFileSettings()
+    if err != nil { return err }
+    for _, key := range sortedKeys(file) {
+      check(viper.ConfigFileUsed(), key, file[key], file)
+    }
+    env := configEnvSettings()
+    for _, key := range sortedKeys(env) {
+      check("FLY_" + strings.ToUpper(strings.Replace(key, "-", "_", -1)), key, env[key], env)
+    }
+    contexts, err := loadContexts()
+    if err != nil {
+      problems = append(problems, &configProblem{Source: "contexts", Message: err.Error()})
+    }
+    for _, name := range sortedContextNames(contexts) {
+      for _, key := range sortedKeys(contexts[name]) {
+        check("context " + name, key, contexts[name][key], contexts[name])
+      }
+    }
+    for _, err := range attendant.ValidateConfigLimits(viper.GetString) {
+      problems = append(problems, &configProblem{Source: "effective settings", Message: err.Error()})
+    }
+
+    setResult(problems)
+    if len(problems) == 0 {
+      fmt.Println("✅  Configuration is valid.")
+      return nil
+    }
+    for _, problem := range problems {
+      fmt.Printf("💥  %s: %s\n", problem.Source, problem.Message)
+    }
+    return &resultError{fmt.Errorf("%d problem(s) found in the configuration.", len(problems))}
+  },
+}
+
+func init() {
+  configCmd.AddCommand(configValidateCmd)
+}
+
+// configFileSettings reads the config file on its own, so that the
+// settings it holds can be told apart from defaults and overrides.
+// Contexts are left out.
+func configFileSettings() (map[string]string, error) {
+  settings := make(map[string]string)
+  path := viper.ConfigFileUsed()
+  if path == "" { return settings, nil }
+  v := viper.New()
+  v.SetConfigFile(path)
+  if err := v.ReadInConfig(); err != nil { return nil, err }
+  for _, key := range v.AllKeys() {
+    if strings.HasPrefix(key, "contexts.") { continue }
+    settings[key] = v.GetString(key)
+  }
+  return settings, nil
+}
+
+// settingsGetter looks settings up in those from one source, falling
+// back to the effective settings.
+func settingsGetter(settings map[string]string) func(key string) string {
+  return func(key string) string {
+    if value, exists := settings[key]; exists { return value }
+    return viper.GetString(key)
+  }
+}
+
+// configEnvSettings returns the settings given by FLY_* environment
+// variables.
+func configEnvSettings() map[string]string {
+  settings := make(map[string]string)
+  for _, entry := range os.Environ() {
+    if !strings.HasPrefix(entry, "FLY_") { continue }
+    pair := strings.SplitN(strings.TrimPrefix(entry, "FLY_"), "=", 2)
+    if len(pair) != 2 { continue }
+    settings[strings.ToLower(strings.Replace(pair[0], "_", "-", -1))] = pair[1]
+  }
+  return settings
+}
+
+// closestConfigKey suggests the known setting nearest to a mistyped
+// key, if any is close enough to be likely.
+func closestConfigKey(key string) string {
+  known := append([]string{}, attendant.ConfigExtraKeys...)
+  for k := range attendant.ConfigDefaults {
+    known = append(known, k)
+  }
+  sort.Strings(known)
+  best, bestDistance := "", 4
+  for _, candidate := range known {
+    if d := editDistance(key, candidate); d < bestDistance {
+      best, bestDistance = candidate, d
+    }
+  }
+  return best
+}
+
+func editDistance(a, b string) int {
+  prev := make([]int, len(b) + 1)
+  for j := range prev { prev[j] = j }
+  for i := 1; i <= len(a); i++ {
+    cur := make([]int, len(b) + 1)
+    cur[0] = i
+    for j := 1; j <= len(b); j++ {
+      cost := 1
+      if a[i-1] == b[j-1] { cost = 0 }
+      cur[j] = minInt(minInt(prev[j] + 1, cur[j-1] + 1), prev[j-1] + cost)
+    }
+    prev = cur
+  }
+  return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+  if a < b { return a }
+  return b
+}
+
+func sortedKeys(m map[string]string) []string {
+  keys := []string{}
+  for key := range m {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  return keys
+}
+
+func sortedContextNames(contexts map[string]map[string]string) []string {
+  names := []string{}
+  for name := range contexts {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
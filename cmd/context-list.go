@@ -0,0 +1,91 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "sort"
+
+  "github.com/spf13/cobra"
+)
+
+// contextDetails describes a context for structured output.
+type contextDetails struct {
+  Name string `json:"Name" yaml:"Name"`
+  Current bool `json:"Current" yaml:"Current"`
+  Settings map[string]string `json:"Settings" yaml:"Settings"`
+}
+
+func newContextDetails(name string, settings map[string]string) *contextDetails {
+  shown := make(map[string]string)
+  for key, value := range settings {
+    if key == "secret-key" { value = "********" }
+    shown[key] = value
+  }
+  return &contextDetails{Name: name, Current: name == activeContext, Settings: shown}
+}
+
+// contextListCmd represents the list command
+var contextListCmd = &cobra.Command{
+  Use:   "list",
+  Short: "List configuration contexts",
+  Long: `List the configuration contexts defined in the config file.  The
+context in use is marked with '*'.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    contexts, err := loadContexts()
+    if err != nil { return err }
+    names := []string{}
+    for name := range contexts {
+      names = append(names, name)
+    }
+    sort.Strings(names)
+    listed := []*contextDetails{}
+    for _, name := range names {
+      listed = append(listed, newContextDetails(name, contexts[name]))
+    }
+    setResult(listed)
+    if len(names) == 0 {
+      fmt.Println("No contexts are defined.")
+      return nil
+    }
+    fmt.Printf("%-8s %-20s %-14s %-20s %s\n", "CURRENT", "NAME", "REGION", "PROFILE", "DOMAIN")
+    for _, name := range names {
+      current := ""
+      if name == activeContext { current = "*" }
+      settings := contexts[name]
+      fmt.Printf("%-8s %-20s %-14s %-20s %s\n", current, name, settings["region"], settings["profile"], settings["domain"])
+    }
+    return nil
+  },
+}
+
+func init() {
+  contextCmd.AddCommand(contextListCmd)
+}
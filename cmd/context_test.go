@@ -0,0 +1,170 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/spf13/viper"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+const testContextConfig = `# team settings
+region: us-east-1
+contexts:
+  staging:
+    region: eu-west-2
+    domain: stage
+    template-root: https://example.com/templates
+  customer:
+    region: ap-south-1
+    profile: customer
+`
+
+// useTestConfig reads config as the config file, undoing what it and
+// any context applied when the test finishes.
+func useTestConfig(t *testing.T, config string) string {
+  path := filepath.Join(t.TempDir(), "fly.yml")
+  if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil { t.Fatalf("WriteFile: %s", err) }
+  viper.SetConfigFile(path)
+  if err := viper.ReadInConfig(); err != nil { t.Fatalf("ReadInConfig: %s", err) }
+  t.Cleanup(func() {
+    for _, key := range append(contextKeys, "context") {
+      viper.Set(key, nil)
+    }
+    viper.SetConfigType("yaml")
+    viper.ReadConfig(strings.NewReader(""))
+    viper.SetConfigFile("")
+    activeContext = ""
+  })
+  return path
+}
+
+func TestLoadContexts(t *testing.T) {
+  useTestConfig(t, testContextConfig)
+  contexts, err := loadContexts()
+  if err != nil { t.Fatalf("loadContexts: %s", err) }
+  if len(contexts) != 2 || contexts["staging"]["domain"] != "stage" || contexts["customer"]["profile"] != "customer" {
+    t.Errorf("contexts = %v", contexts)
+  }
+
+  useTestConfig(t, "contexts:\n  staging:\n    colour: blue\n")
+  if _, err := loadContexts(); err == nil || !strings.Contains(err.Error(), "Unknown setting 'colour'") {
+    t.Errorf("loadContexts = %v, want an unknown setting error", err)
+  }
+}
+
+func TestUseContext(t *testing.T) {
+  useTestConfig(t, testContextConfig + "current-context: customer\n")
+  if err := useContext(); err != nil { t.Fatalf("useContext: %s", err) }
+  if activeContext != "customer" || viper.GetString("region") != "ap-south-1" || viper.GetString("profile") != "customer" {
+    t.Errorf("context %q gave region %s, profile %s", activeContext, viper.GetString("region"), viper.GetString("profile"))
+  }
+
+  // --context overrides the current context, and FLY_* variables
+  // override the context's settings
+  viper.Set("context", "Staging")
+  t.Setenv("FLY_REGION", "eu-west-1")
+  viper.Set("region", nil)
+  if err := useContext(); err != nil { t.Fatalf("useContext: %s", err) }
+  if activeContext != "staging" || viper.GetString("domain") != "stage" {
+    t.Errorf("context %q gave domain %s, want staging's", activeContext, viper.GetString("domain"))
+  }
+  if viper.GetString("region") == "eu-west-2" {
+    t.Errorf("the context's region overrode FLY_REGION")
+  }
+
+  viper.Set("context", "missing")
+  if err := useContext(); err == nil {
+    t.Errorf("useContext accepted an unknown --context")
+  }
+}
+
+func TestUseMissingCurrentContext(t *testing.T) {
+  useTestConfig(t, testContextConfig + "current-context: gone\n")
+  if err := useContext(); err != nil {
+    t.Errorf("useContext = %v, want a missing current context to be ignored", err)
+  }
+  if activeContext != "" || viper.GetString("region") != "us-east-1" {
+    t.Errorf("context %q gave region %s", activeContext, viper.GetString("region"))
+  }
+}
+
+func TestWriteCurrentContext(t *testing.T) {
+  path := useTestConfig(t, testContextConfig)
+  for _, name := range []string{"staging", "customer"} {
+    if err := writeCurrentContext(name); err != nil { t.Fatalf("writeCurrentContext: %s", err) }
+  }
+  data, err := ioutil.ReadFile(path)
+  if err != nil { t.Fatalf("ReadFile: %s", err) }
+  if string(data) != testContextConfig + "current-context: customer\n" {
+    t.Errorf("config file is now:\n%s", data)
+  }
+
+  jsonPath := filepath.Join(t.TempDir(), "fly.json")
+  if err := ioutil.WriteFile(jsonPath, []byte("{}"), 0644); err != nil { t.Fatalf("WriteFile: %s", err) }
+  viper.SetConfigFile(jsonPath)
+  if err := writeCurrentContext("staging"); err == nil {
+    t.Errorf("writeCurrentContext changed a JSON config file")
+  }
+}
+
+func TestTemplateSourcePrecedence(t *testing.T) {
+  cfg := attendant.Config()
+  savedRoot, savedSet := cfg.TemplateRoot, cfg.TemplateSet
+  defer func() { cfg.TemplateRoot, cfg.TemplateSet = savedRoot, savedSet }()
+  defer viper.Set("template-set:test-launch", nil)
+  useTestConfig(t, testContextConfig)
+  viper.Set("context", "staging")
+  if err := useContext(); err != nil { t.Fatalf("useContext: %s", err) }
+
+  cfg.TemplateRoot, cfg.TemplateSet = savedRoot, "previous"
+  if err := setupTemplateSource("test-launch"); err != nil { t.Fatalf("setupTemplateSource: %s", err) }
+  if cfg.TemplateRoot != "https://example.com/templates" || cfg.TemplateSet != "" {
+    t.Errorf("root %q, set %q; want the context's template root", cfg.TemplateRoot, cfg.TemplateSet)
+  }
+
+  // the command's own flag wins over the context
+  cfg.TemplateRoot, cfg.TemplateSet = savedRoot, ""
+  viper.Set("template-set:test-launch", "gpu")
+  if err := setupTemplateSource("test-launch"); err != nil { t.Fatalf("setupTemplateSource: %s", err) }
+  if cfg.TemplateRoot != savedRoot || cfg.TemplateSet != "gpu" {
+    t.Errorf("root %q, set %q; want the flag's template set", cfg.TemplateRoot, cfg.TemplateSet)
+  }
+
+  viper.Set("template-root:test-launch", "https://example.com/other")
+  defer viper.Set("template-root:test-launch", nil)
+  if err := setupTemplateSource("test-launch"); err == nil {
+    t.Errorf("setupTemplateSource accepted both a template root and set")
+  }
+}
@@ -0,0 +1,79 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// domainCostCmd represents the cost command
+var domainCostCmd = &cobra.Command{
+  Use:   "cost <domain>",
+  Short: "Estimate the cost of a Flight Compute domain",
+  Long: `Estimate the hourly and projected cost of the clusters and
+appliances running in a Flight Compute domain.
+
+Costs are projected over a month unless --hours is given.  See
+'fly cluster cost --help' for how costs are estimated.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    domain := attendant.NewDomain(args[0], nil)
+    hours, _ := cmd.Flags().GetFloat64("hours")
+    if hours <= 0 {
+      hours = attendant.HoursPerMonth
+    }
+    var estimate *attendant.CostEstimate
+    var err error
+    attendant.SpinWithSuffix(func() { estimate, err = domain.Cost(hours) }, attendant.Config().AwsRegion + ": " + domain.Name)
+    if err != nil { return err }
+    setResult(newCostReport(estimate))
+    fmt.Println("== " + domain.Name + " (" + attendant.Config().AwsRegion + ") ==")
+    if len(estimate.Items) == 0 {
+      fmt.Println("No clusters or appliances running.")
+      return nil
+    }
+    fmt.Print(estimate.Render())
+    return nil
+  },
+}
+
+func init() {
+  domainCmd.AddCommand(domainCostCmd)
+  domainCostCmd.Flags().Float64("hours", 0, "Number of hours to project the cost over")
+}
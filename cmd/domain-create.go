@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   
   "github.com/spf13/cobra"
@@ -55,7 +56,16 @@ var domainCreateCmd = &cobra.Command{
 
     if err := attendant.PreflightCheck(); err != nil { return err }
 
-    fmt.Printf("Creating domain '%s' (%s)...\n\n", args[0], attendant.Config().AwsRegion)
+    if isPlan(cmd) {
+      return showPlan(cmd, func() error {
+        domain := attendant.NewDomain(args[0], attendant.DiscardEvents)
+        err := domain.Create(context.Background(), args[0], domainParamsFile)
+        domain.MessageHandler = nil
+        return err
+      })
+    }
+
+    fmt.Printf("Creating domain '%s' (%s)...\n\n", args[0], currentLocation())
     _, err = createDomain(args[0], domainParamsFile)
     if err != nil { return err }
 
@@ -69,13 +79,23 @@ func init() {
   addTemplateSetFlag(domainCreateCmd, "domainCreate")
   addTemplateRootFlag(domainCreateCmd, "domainCreate")
   domainCreateCmd.Flags().StringP("params", "p", "", "File containing parameters to use when creating the domain")
+  addPlanFlags(domainCreateCmd)
 }
 
 func createDomain(name string, domainParamsFile string) (*attendant.Domain, error) {
   handler, err := attendant.CreateCreateHandler(attendant.DomainResourceCount)
   if err != nil { return nil, err }
   domain := attendant.NewDomain(name, handler)
-  attendant.Spin(func() { err = domain.Create(name, domainParamsFile) } )
+  interrupts := watchInterrupts(true)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = domain.Create(interrupts.ctx, name, domainParamsFile) } )
+  err = interrupts.outcome(err, func(ctx context.Context) error {
+    handler, err := attendant.CreateDestroyHandler(attendant.DomainResourceCount)
+    if err != nil { return err }
+    domain.MessageHandler = handler
+    attendant.Spin(func() { err = domain.Destroy(ctx) })
+    return err
+  })
   domain.MessageHandler = nil
   return domain, err
 }
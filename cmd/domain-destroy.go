@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   
   "github.com/spf13/cobra"
@@ -53,19 +54,19 @@ var domainDestroyCmd = &cobra.Command{
 
     if err := attendant.PreflightCheck(); err != nil { return err }
     domain := attendant.NewDomain(args[0], nil)
-    attendant.Spin(func() { status, err = domain.Status() })
+    attendant.Spin(func() { status, err = domain.Status(context.Background()) })
     if err != nil { return err }
 
     if len(status.Clusters) + len(status.Appliances) > 0 {
       if force, _ := cmd.Flags().GetBool("force"); force {
         for _, cluster := range status.Clusters {
-          fmt.Printf("Destroying cluster '%s' in domain '%s' (%s)...\n\n", cluster.Name, domain.Name, attendant.Config().AwsRegion)
+          fmt.Printf("Destroying cluster '%s' in domain '%s' (%s)...\n\n", cluster.Name, domain.Name, currentLocation())
           err = destroyCluster(domain, cluster.Name)
           if err != nil { return err }
           fmt.Println("\nCluster destroyed.\n")
         }
         for _, appliance := range status.Appliances {
-          fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", appliance.Name, domain.Name, attendant.Config().AwsRegion)
+          fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", appliance.Name, domain.Name, currentLocation())
           err = destroyAppliance(domain, appliance.Name)
           if err != nil { return err }
           fmt.Println("\nAppliance destroyed.\n")
@@ -75,7 +76,7 @@ var domainDestroyCmd = &cobra.Command{
       }
     }
 
-    fmt.Printf("Destroying domain '%s' (%s)...\n\n", domain.Name, attendant.Config().AwsRegion)
+    fmt.Printf("Destroying domain '%s' (%s)...\n\n", domain.Name, currentLocation())
     err = destroyDomain(domain)
     if err != nil { return err }
     fmt.Println("Domain destroyed.")
@@ -93,7 +94,9 @@ func destroyDomain(domain *attendant.Domain) error {
   handler, err := attendant.CreateDestroyHandler(attendant.DomainResourceCount)
   if err != nil { return err }
   domain.MessageHandler = handler
-  attendant.Spin(func() { err = domain.Destroy() })
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = domain.Destroy(interrupts.ctx) })
   domain.MessageHandler = nil
-  return err
+  return interrupts.outcome(err, nil)
 }
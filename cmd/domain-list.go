@@ -30,6 +30,7 @@ package cmd
 
 import (
   "fmt"
+  "io"
   
   "github.com/spf13/cobra"
 
@@ -43,32 +44,38 @@ var domainListCmd = &cobra.Command{
   Long: `List your Flight Compute domains.`,
   SilenceUsage: true,
   RunE: func(cmd *cobra.Command, args []string) error {
-    var domains []attendant.Domain
-    var err error
-
     if err := attendant.PreflightCheck(); err != nil { return err }
-    regions := getRegions(cmd)
-    for _, region := range regions {
-      attendant.Config().AwsRegion = region
-      attendant.SpinWithSuffix(func() {
-        domains, err = attendant.AllDomains()
-      }, region)
-      if err != nil { return err }
-      fmt.Printf("== Domains (%s) ==\n", attendant.Config().AwsRegion)
+    results := eachRegion(getRegions(cmd), func(client *attendant.Client, out io.Writer) (interface{}, error) {
+      domains, err := client.AllDomains()
+      if err != nil { return nil, err }
+      listed := []*attendant.DomainDetails{}
+      fmt.Fprintf(out, "== Domains (%s) ==\n", client.Config().AwsRegion)
       if len(domains) > 0 {
         for _, domain := range domains {
-          if *domain.Stack.StackStatus == "CREATE_IN_PROGRESS" {
-            fmt.Println(domain.Name + " (not ready)")
-          } else {
-            fmt.Println(domain.Name)
+          listed = append(listed, domain.Details())
+          switch *domain.Stack.StackStatus {
+          case "CREATE_IN_PROGRESS":
+            fmt.Fprintln(out, domain.Name + " (not ready)")
+          case "CREATE_COMPLETE", "UPDATE_COMPLETE":
+            fmt.Fprintln(out, domain.Name)
+          default:
+            fmt.Fprintln(out, domain.Name + " (" + *domain.Stack.StackStatus + ")")
           }
         }
       } else {
-        fmt.Println("<none>")
+        fmt.Fprintln(out, "<none>")
+      }
+      fmt.Fprintln(out, "")
+      return listed, nil
+    })
+    listed := []*attendant.DomainDetails{}
+    for _, result := range results {
+      if result.Err == nil {
+        listed = append(listed, result.Data.([]*attendant.DomainDetails)...)
       }
-      fmt.Println("")
     }
-    return nil
+    setResult(listed)
+    return printRegions(results)
   },
 }
 
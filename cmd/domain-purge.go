@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
   
   "github.com/spf13/cobra"
@@ -59,39 +60,42 @@ var domainPurgeCmd = &cobra.Command{
       return nil
     }
 
-    attendant.Spin(func() { status, err = domain.Status() })
+    attendant.Spin(func() { status, err = domain.Status(context.Background()) })
     if err != nil { return err }
 
     if len(status.Clusters) + len(status.Appliances) > 0 {
       var ch chan string = make(chan string)
       handler, err := attendant.CreateDestroyHandler(0)
       if err != nil { return err }
+      interrupts := watchInterrupts(false)
+      defer interrupts.stop()
       for _, cluster := range status.Clusters {
         cluster.MessageHandler = handler
         go func(cluster *attendant.Cluster, ch chan<- string) {
           n := fmt.Sprintf("flight-%s-%s", cluster.Domain.Name, cluster.Name)
-          handler("DELETE_IN_PROGRESS " + n + " " + n)
-          cluster.Purge()
+          handler(attendant.NewStackEvent("DELETE_IN_PROGRESS", n))
+          cluster.Purge(interrupts.ctx)
           ch <- n
         }(cluster, ch)
       }
       for _, appliance := range status.Appliances {
         appliance.MessageHandler = handler
         go func(appliance *attendant.Appliance, ch chan<- string) {
-          handler("DELETE_IN_PROGRESS " + *appliance.Stack.StackName + " " + *appliance.Stack.StackName)
-          appliance.Purge()
+          handler(attendant.NewStackEvent("DELETE_IN_PROGRESS", *appliance.Stack.StackName))
+          appliance.Purge(interrupts.ctx)
           ch <- *appliance.Stack.StackName
         }(appliance, ch)
       }
       count := len(status.Clusters) + len(status.Appliances)
       for item := <- ch; item != ""; item = <- ch {
-        handler("DELETE_COMPLETE " + item + " " + item)
+        handler(attendant.NewStackEvent("DELETE_COMPLETE", item))
         count -= 1
         if count == 0 {
           break
         }
       }
-      handler("DONE")
+      handler(attendant.NewDoneEvent())
+      if err := interrupts.outcome(interrupts.ctx.Err(), nil); err != nil { return err }
       fmt.Println("Purge complete.")
     } else {
       return fmt.Errorf("Domain '%s' (%s) has no running infrastructure or cluster stacks. Can't purge.\n", domain.Name, attendant.Config().AwsRegion)
@@ -29,7 +29,10 @@
 package cmd
 
 import (
+  "bytes"
+  "context"
   "fmt"
+  "io"
   "strings"
   
   "github.com/spf13/cobra"
@@ -56,19 +59,20 @@ var domainStatusCmd = &cobra.Command{
     }
 
     if err := attendant.PreflightCheck(); err != nil { return err }
+    if attendant.IsStructuredOutput() {
+      return structuredStatus(cmd, args, all, showVpnConfig)
+    }
     if all {
-      regions := getRegions(cmd)
-      for _, region := range regions {
-        var err error
-        var domains []attendant.Domain
-        attendant.Config().AwsRegion = region
-        attendant.SpinWithSuffix(func() { domains, err = attendant.AllDomains() }, region)
-        if err != nil { return err }
+      results := eachRegion(getRegions(cmd), func(client *attendant.Client, out io.Writer) (interface{}, error) {
+        domains, err := client.AllDomains()
+        if err != nil { return nil, err }
         for _, domain := range domains {
-          statusFor(&domain)
-          fmt.Println("")
+          statusFor(out, &domain)
+          fmt.Fprintln(out, "")
         }
-      }
+        return nil, nil
+      })
+      return printRegions(results)
     } else {
       domain := attendant.NewDomain(args[0], nil)
       if showVpnConfig {
@@ -77,7 +81,9 @@ var domainStatusCmd = &cobra.Command{
         if attendant.Config().SimpleOutput {
           simpleStatusFor(domain)
         } else {
-          statusFor(domain)
+          var out bytes.Buffer
+          attendant.SpinWithSuffix(func() { statusFor(&out, domain) }, attendant.Config().AwsRegion + ": " + domain.Name)
+          fmt.Print(out.String())
         }
       }
     }
@@ -92,11 +98,43 @@ func init() {
   domainStatusCmd.Flags().String("regions", "", "Select regions to query")
 }
 
+func structuredStatus(cmd *cobra.Command, args []string, all, showVpnConfig bool) error {
+  if !all {
+    status, err := attendant.NewDomain(args[0], nil).Status(context.Background())
+    if err != nil { return err }
+    if showVpnConfig {
+      setResult(status.VPNDetails)
+    } else {
+      setResult(status.Details())
+    }
+    return nil
+  }
+  results := eachRegion(getRegions(cmd), func(client *attendant.Client, out io.Writer) (interface{}, error) {
+    domains, err := client.AllDomains()
+    if err != nil { return nil, err }
+    listed := []*attendant.DomainDetails{}
+    for _, domain := range domains {
+      status, err := domain.Status(context.Background())
+      if err != nil { return nil, err }
+      listed = append(listed, status.Details())
+    }
+    return listed, nil
+  })
+  listed := []*attendant.DomainDetails{}
+  for _, result := range results {
+    if result.Err == nil {
+      listed = append(listed, result.Data.([]*attendant.DomainDetails)...)
+    }
+  }
+  setResult(listed)
+  return failedRegions(results)
+}
+
 func vpnConfigFor(domain *attendant.Domain) {
   var err error
   var status *attendant.DomainStatus
 
-  attendant.SpinWithSuffix(func() { status, err = domain.Status() }, attendant.Config().AwsRegion + ": " + domain.Name)
+  attendant.SpinWithSuffix(func() { status, err = domain.Status(context.Background()) }, domain.Client().Config().AwsRegion + ": " + domain.Name)
   if err != nil {
     fmt.Println(err.Error())
     return
@@ -107,7 +145,7 @@ func vpnConfigFor(domain *attendant.Domain) {
 }
 
 func simpleStatusFor(domain *attendant.Domain) {
-  status, err := domain.Status()
+  status, err := domain.Status(context.Background())
   if err != nil {
     fmt.Println(err.Error())
     return
@@ -117,71 +155,68 @@ func simpleStatusFor(domain *attendant.Domain) {
   }
 }
 
-func statusFor(domain *attendant.Domain) {
-  var err error
-  var status *attendant.DomainStatus
-
-  attendant.SpinWithSuffix(func() { status, err = domain.Status() }, attendant.Config().AwsRegion + ": " + domain.Name)
+// statusFor writes a report on the state of a domain to out.
+func statusFor(out io.Writer, domain *attendant.Domain) {
+  status, err := domain.Status(context.Background())
   if err != nil {
-    fmt.Println(err.Error())
+    fmt.Fprintln(out, err.Error())
     return
   }
 
-  fmt.Printf(">>> Domain '%s' (%s) <<<\n\n", domain.Name, attendant.Config().AwsRegion)
+  fmt.Fprintf(out, ">>> Domain '%s' (%s) <<<\n\n", domain.Name, location(domain.Client()))
 
-  fmt.Println("== Networking ==")
+  fmt.Fprintln(out, "== Networking ==")
   if status.HasInternetAccess {
-    fmt.Println(" * Internet access: enabled")
+    fmt.Fprintln(out, " * Internet access: enabled")
   } else {
-    fmt.Println(" * Internet access: disabled")
+    fmt.Fprintln(out, " * Internet access: disabled")
   }
 
   if status.VPNConnectionId != "" {
-    fmt.Println(" * VPN connection: " + status.VPNConnectionId)
-    fmt.Println("   Outside address: " + status.VPNDetails.OutsideClientAddr)
-    fmt.Println("   Client ASN: " + status.VPNDetails.ClientASN)
-    fmt.Println("   Tun 1 Client inside address: " + status.VPNDetails.Tunnel1.InsideClientAddr)
-    fmt.Println("         AWS outside address: " + status.VPNDetails.Tunnel1.OutsideAwsAddr)
-    fmt.Println("         AWS inside address: " + status.VPNDetails.Tunnel1.InsideAwsAddr)
-    fmt.Println("         AWS ASN: " + status.VPNDetails.Tunnel1.AwsASN)
-    fmt.Println("         Shared key: " + status.VPNDetails.Tunnel1.SharedKey)
-    fmt.Println("   Tun 2 Client inside address: " + status.VPNDetails.Tunnel2.InsideClientAddr)
-    fmt.Println("         AWS outside address: " + status.VPNDetails.Tunnel2.OutsideAwsAddr)
-    fmt.Println("         AWS inside address: " + status.VPNDetails.Tunnel2.InsideAwsAddr)
-    fmt.Println("         AWS ASN: " + status.VPNDetails.Tunnel2.AwsASN)
-    fmt.Println("         Shared key: " + status.VPNDetails.Tunnel2.SharedKey)
+    fmt.Fprintln(out, " * VPN connection: " + status.VPNConnectionId)
+    fmt.Fprintln(out, "   Outside address: " + status.VPNDetails.OutsideClientAddr)
+    fmt.Fprintln(out, "   Client ASN: " + status.VPNDetails.ClientASN)
+    fmt.Fprintln(out, "   Tun 1 Client inside address: " + status.VPNDetails.Tunnel1.InsideClientAddr)
+    fmt.Fprintln(out, "         AWS outside address: " + status.VPNDetails.Tunnel1.OutsideAwsAddr)
+    fmt.Fprintln(out, "         AWS inside address: " + status.VPNDetails.Tunnel1.InsideAwsAddr)
+    fmt.Fprintln(out, "         AWS ASN: " + status.VPNDetails.Tunnel1.AwsASN)
+    fmt.Fprintln(out, "         Shared key: " + status.VPNDetails.Tunnel1.SharedKey)
+    fmt.Fprintln(out, "   Tun 2 Client inside address: " + status.VPNDetails.Tunnel2.InsideClientAddr)
+    fmt.Fprintln(out, "         AWS outside address: " + status.VPNDetails.Tunnel2.OutsideAwsAddr)
+    fmt.Fprintln(out, "         AWS inside address: " + status.VPNDetails.Tunnel2.InsideAwsAddr)
+    fmt.Fprintln(out, "         AWS ASN: " + status.VPNDetails.Tunnel2.AwsASN)
+    fmt.Fprintln(out, "         Shared key: " + status.VPNDetails.Tunnel2.SharedKey)
   }
 
   if status.PeerVPC != "" {
-    fmt.Println(" * Peer VPC: " + status.PeerVPC)
-    fmt.Println(" * Peer network: " + status.PeerVPCCIDRBlock)
+    fmt.Fprintln(out, " * Peer VPC: " + status.PeerVPC)
+    fmt.Fprintln(out, " * Peer network: " + status.PeerVPCCIDRBlock)
   }
 
-  fmt.Println("\n== Infrastructure ==\n")
+  fmt.Fprint(out, "\n== Infrastructure ==\n\n")
   if len(status.Appliances) > 0 {
     for _, appliance := range status.Appliances {
-      fmt.Println("    " + appliance.Name)
-      fmt.Println("    " + strings.Repeat("-", len(appliance.Name)))
+      fmt.Fprintln(out, "    " + appliance.Name)
+      fmt.Fprintln(out, "    " + strings.Repeat("-", len(appliance.Name)))
       for _, s := range strings.Split(appliance.GetDetails(),"\n") {
-        fmt.Println("    " + s)
+        fmt.Fprintln(out, "    " + s)
       }
     }
   } else {
-    fmt.Println("<none>\n")
+    fmt.Fprint(out, "<none>\n\n")
   }
 
-  fmt.Println("== Clusters ==\n")
+  fmt.Fprint(out, "== Clusters ==\n\n")
   if len(status.Clusters) > 0 {
     for _, cluster := range status.Clusters {
-      var details string
-      attendant.SpinWithSuffix(func() { details = cluster.GetDetails() }, attendant.Config().AwsRegion + ": " + domain.Name + "/" + cluster.Name)
-      fmt.Println("    " + cluster.Name)
-      fmt.Println("    " + strings.Repeat("-", len(cluster.Name)))
+      details := cluster.GetDetails()
+      fmt.Fprintln(out, "    " + cluster.Name)
+      fmt.Fprintln(out, "    " + strings.Repeat("-", len(cluster.Name)))
       for _, s := range strings.Split(details,"\n") {
-        fmt.Println("    " + s)
+        fmt.Fprintln(out, "    " + s)
       }
     }
   } else {
-    fmt.Println("<none>")
+    fmt.Fprintln(out, "<none>")
   }
 }
@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
 
   "github.com/spf13/cobra"
@@ -58,7 +59,7 @@ var infraDestroyCmd = &cobra.Command{
     if err != nil { return err }
 
     var status *attendant.DomainStatus
-    attendant.Spin(func() { status, err = domain.Status() })
+    attendant.Spin(func() { status, err = domain.Status(context.Background()) })
     if err != nil { return err }
 
     force, _ := cmd.Flags().GetBool("force")
@@ -69,13 +70,13 @@ var infraDestroyCmd = &cobra.Command{
 
     if all {
       for appliance, _ := range status.Appliances {
-        fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", appliance, domain.Name, attendant.Config().AwsRegion)
+        fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", appliance, domain.Name, currentLocation())
         err = destroyAppliance(domain, appliance)
         if err != nil { break }
         fmt.Println("\nAppliance destroyed.")
       }
     } else {
-      fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, attendant.Config().AwsRegion)
+      fmt.Printf("Destroying appliance '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, currentLocation())
       err = destroyAppliance(domain, args[0])
       if err == nil { fmt.Println("\nAppliance destroyed.") }
     }
@@ -95,7 +96,9 @@ func destroyAppliance(domain *attendant.Domain, name string) error {
   handler, err := attendant.CreateDestroyHandler(attendant.ApplianceResourceCounts[name])
   if err != nil { return err }
   appliance := attendant.NewAppliance(name, domain, handler)
-  attendant.Spin(func() { err = appliance.Destroy() })
+  interrupts := watchInterrupts(false)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = appliance.Destroy(interrupts.ctx) })
   appliance.MessageHandler = nil
-  return err
+  return interrupts.outcome(err, nil)
 }
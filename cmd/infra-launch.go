@@ -29,6 +29,7 @@
 package cmd
 
 import (
+  "context"
   "fmt"
 
   "github.com/spf13/cobra"
@@ -64,10 +65,33 @@ var infraLaunchCmd = &cobra.Command{
     domain, err := findDomain("infraLaunch", true)
     if err != nil { return err }
 
+    if isPlan(cmd) {
+      var names []string
+      if base {
+        names = attendant.BaseApplianceNames
+      } else if all {
+        for applianceName, _ := range attendant.ApplianceTemplates {
+          names = append(names, applianceName)
+        }
+      } else {
+        names = []string{args[0]}
+      }
+      return showPlan(cmd, func() error {
+        for _, applianceName := range names {
+          if err := checkApplianceInstanceType(applianceName); err != nil { return err }
+          appliance := attendant.NewAppliance(applianceName, domain, attendant.DiscardEvents)
+          err := appliance.Create(context.Background())
+          appliance.MessageHandler = nil
+          if err != nil { return err }
+        }
+        return nil
+      })
+    }
+
     if base {
       for _, applianceName := range attendant.BaseApplianceNames {
         var appliance *attendant.Appliance
-        fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", applianceName, domain.Name, attendant.Config().AwsRegion)
+        fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", applianceName, domain.Name, currentLocation())
         appliance, err = launchAppliance(domain, applianceName)
         if err != nil { break }
         fmt.Println("\nAppliance launched.\n")
@@ -77,7 +101,7 @@ var infraLaunchCmd = &cobra.Command{
     } else if all {
       for applianceName, _ := range attendant.ApplianceTemplates {
         var appliance *attendant.Appliance
-        fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", applianceName, domain.Name, attendant.Config().AwsRegion)
+        fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", applianceName, domain.Name, currentLocation())
         appliance, err = launchAppliance(domain, applianceName)
         if err != nil { break }
         fmt.Println("\nAppliance launched.\n")
@@ -86,7 +110,7 @@ var infraLaunchCmd = &cobra.Command{
       }
     } else {
       var appliance *attendant.Appliance
-      fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, attendant.Config().AwsRegion)
+      fmt.Printf("Launching appliance '%s' in domain '%s' (%s)...\n\n", args[0], domain.Name, currentLocation())
       appliance, err = launchAppliance(domain, args[0])
       if err == nil {
         fmt.Println("\nAppliance launched.\n")
@@ -111,19 +135,34 @@ func init() {
 
   infraLaunchCmd.Flags().BoolP("base", "b", false, "Launch all base appliances into a domain")
   infraLaunchCmd.Flags().BoolP("all", "a", false, "Launch all base and optional appliances into a domain")
+  addPlanFlags(infraLaunchCmd)
 }
 
-func launchAppliance(domain *attendant.Domain, name string) (*attendant.Appliance, error) {
+func checkApplianceInstanceType(name string) error {
   instanceType := viper.GetString(name + "-instance-type")
   if instanceType == "" { instanceType = viper.GetString("appliance-instance-type") }
   if instanceType != "" && ! attendant.IsValidApplianceInstanceType(instanceType) {
-    return nil, fmt.Errorf("Invalid instance type '%s'. Try one of: %s\n", instanceType, attendant.ApplianceInstanceTypes)
+    return fmt.Errorf("Invalid instance type '%s'. Try one of: %s\n", instanceType, attendant.ApplianceInstanceTypes)
   }
+  return nil
+}
+
+func launchAppliance(domain *attendant.Domain, name string) (*attendant.Appliance, error) {
+  if err := checkApplianceInstanceType(name); err != nil { return nil, err }
 
   handler, err := attendant.CreateCreateHandler(attendant.ApplianceResourceCounts[name])
   if err != nil { return nil, err }
   appliance := attendant.NewAppliance(name, domain, handler)
-  attendant.Spin(func() { err = appliance.Create() })
+  interrupts := watchInterrupts(true)
+  defer interrupts.stop()
+  attendant.Spin(func() { err = appliance.Create(interrupts.ctx) })
+  err = interrupts.outcome(err, func(ctx context.Context) error {
+    handler, err := attendant.CreateDestroyHandler(attendant.ApplianceResourceCounts[name])
+    if err != nil { return err }
+    appliance.MessageHandler = handler
+    attendant.Spin(func() { err = appliance.Destroy(ctx) })
+    return err
+  })
   appliance.MessageHandler = nil
   return appliance, err
 }
@@ -0,0 +1,151 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "os/signal"
+  "strings"
+  "sync"
+  "syscall"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// Choices offered when a long-running command is interrupted.
+const (
+  keepWaiting = "wait"
+  detach = "detach"
+  abort = "abort"
+)
+
+// interruption watches for Ctrl-C while a command waits on stack
+// operations.  The user may keep waiting, detach and leave the
+// operations running, or abort and roll them back; detaching or
+// aborting cancels ctx.  Without a terminal to ask on, and on SIGTERM,
+// the command detaches.
+type interruption struct {
+  ctx context.Context
+  cancel context.CancelFunc
+  signals chan os.Signal
+  canAbort bool
+  mutex sync.Mutex
+  choice string
+}
+
+// watchInterrupts starts watching for Ctrl-C.  Aborting is only offered
+// if the command is able to roll back what it was doing.
+func watchInterrupts(canAbort bool) *interruption {
+  ctx, cancel := context.WithCancel(context.Background())
+  i := &interruption{ctx: ctx, cancel: cancel, signals: make(chan os.Signal, 1), canAbort: canAbort}
+  signal.Notify(i.signals, os.Interrupt, syscall.SIGTERM)
+  go i.watch()
+  return i
+}
+
+// stop restores the default handling of Ctrl-C.
+func (i *interruption) stop() {
+  signal.Stop(i.signals)
+  i.cancel()
+}
+
+func (i *interruption) Choice() string {
+  i.mutex.Lock()
+  defer i.mutex.Unlock()
+  return i.choice
+}
+
+func (i *interruption) watch() {
+  for sig := range i.signals {
+    if i.Choice() != "" {
+      // a second interrupt while detaching or aborting gives up at once
+      os.Exit(130)
+    }
+    choice := detach
+    if sig == os.Interrupt && attendant.IsTerminal(os.Stdin) {
+      choice = i.ask()
+    }
+    if choice == keepWaiting { continue }
+    i.mutex.Lock()
+    i.choice = choice
+    i.mutex.Unlock()
+    i.cancel()
+  }
+}
+
+func (i *interruption) ask() string {
+  question := "Interrupted: [w]ait, [d]etach"
+  if i.canAbort {
+    question += " or [a]bort and roll back"
+  }
+  answers := make(chan string, 1)
+  go func() { answers <- attendant.Prompt(question + "? ") }()
+  select {
+  case answer := <-answers:
+    switch strings.ToLower(answer) {
+    case "d", "detach":
+      return detach
+    case "a", "abort":
+      if i.canAbort { return abort }
+    }
+    return keepWaiting
+  case <-i.signals:
+    os.Exit(130)
+  }
+  return keepWaiting
+}
+
+// outcome reports how an interrupted command ended.  An interrupted
+// operation from the journal is aborted through the journal; rollback
+// undoes anything else the command started.  Errors other than
+// interruption are returned unchanged.
+func (i *interruption) outcome(err error, rollback func(ctx context.Context) error) error {
+  if err == nil || i.Choice() == "" { return err }
+  if interrupted, ok := err.(*attendant.InterruptedError); ok {
+    op := interrupted.Operation
+    if i.Choice() == abort {
+      handler, err := attendant.CreateDestroyHandler(0)
+      if err != nil { return err }
+      fmt.Printf("\nAborting %s of cluster '%s'...\n\n", op.Kind, op.Target())
+      attendant.Spin(func() { err = op.Abort(context.Background(), handler) })
+      if err != nil { return err }
+      return fmt.Errorf("Operation %s aborted.", op.Id)
+    }
+    return fmt.Errorf("Detached from operation %s, which continues in the background. Run 'fly op resume %s' to wait for it to finish, or 'fly op abort %s --yes' to undo it.", op.Id, op.Id, op.Id)
+  }
+  if i.Choice() == abort && rollback != nil {
+    fmt.Print("\nRolling back...\n\n")
+    if err := rollback(context.Background()); err != nil { return err }
+    return fmt.Errorf("Aborted.")
+  }
+  return fmt.Errorf("Detached; stack operations continue in the background.")
+}
@@ -0,0 +1,93 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "syscall"
+  "testing"
+  "time"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+func TestInterruptOnTerminateDetaches(t *testing.T) {
+  i := watchInterrupts(true)
+  defer i.stop()
+  i.signals <- syscall.SIGTERM
+  select {
+  case <-i.ctx.Done():
+  case <-time.After(time.Second):
+    t.Fatalf("SIGTERM didn't cancel the command's context")
+  }
+  if i.Choice() != detach {
+    t.Errorf("choice = %q, want %q", i.Choice(), detach)
+  }
+}
+
+func TestInterruptOutcome(t *testing.T) {
+  failure := fmt.Errorf("Stack failed.")
+  if err := (&interruption{}).outcome(failure, nil); err != failure {
+    t.Errorf("uninterrupted outcome = %v, want the original error", err)
+  }
+  if err := (&interruption{choice: detach}).outcome(nil, nil); err != nil {
+    t.Errorf("outcome of a finished command = %v, want nil", err)
+  }
+
+  op := &attendant.OperationEntity{Id: "op-1", Cluster: "c1"}
+  err := (&interruption{choice: detach}).outcome(&attendant.InterruptedError{Operation: op}, nil)
+  if err == nil || !strings.Contains(err.Error(), "fly op resume op-1") || !strings.Contains(err.Error(), "fly op abort op-1") {
+    t.Errorf("detached operation error = %v, want resume and abort instructions", err)
+  }
+
+  err = (&interruption{choice: detach}).outcome(context.Canceled, nil)
+  if err == nil || !strings.Contains(err.Error(), "Detached") {
+    t.Errorf("detached outcome = %v", err)
+  }
+
+  rolledBack := false
+  err = (&interruption{choice: abort}).outcome(context.Canceled, func(ctx context.Context) error {
+    rolledBack = true
+    return ctx.Err()
+  })
+  if !rolledBack {
+    t.Errorf("aborting didn't roll back")
+  }
+  if err == nil || err.Error() != "Aborted." {
+    t.Errorf("aborted outcome = %v, want Aborted.", err)
+  }
+
+  rollbackErr := fmt.Errorf("Rollback failed.")
+  err = (&interruption{choice: abort}).outcome(context.Canceled, func(context.Context) error { return rollbackErr })
+  if err != rollbackErr {
+    t.Errorf("failed rollback outcome = %v, want the rollback error", err)
+  }
+}
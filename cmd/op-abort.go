@@ -0,0 +1,78 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "context"
+  "fmt"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// opAbortCmd represents the abort command
+var opAbortCmd = &cobra.Command{
+  Use:   "abort <id>",
+  Short: "Abort an interrupted operation",
+  Long: `Abort an interrupted operation.  An interrupted launch is undone by
+destroying the stacks it created and releasing its network booking.
+Destruction can't be undone, so aborting a destroy or purge only removes
+it from the journal; any stacks it didn't reach are left in place.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    op, err := attendant.LoadOperation(args[0])
+    if err != nil { return err }
+
+    if confirmed, _ := cmd.Flags().GetBool("yes"); !confirmed {
+      fmt.Printf("You must supply `--yes` parameter to confirm you want to abort operation: %s\n", op.Id)
+      return nil
+    }
+
+    handler, err := attendant.CreateDestroyHandler(0)
+    if err != nil { return err }
+    fmt.Printf("Aborting %s of cluster '%s' (%s)...\n\n", op.Kind, op.Target(), op.Region)
+    attendant.Spin(func() { err = op.Abort(context.Background(), handler) })
+    if err != nil { return err }
+    setResult(op)
+    fmt.Printf("\nOperation %s aborted.\n", op.Id)
+    return nil
+  },
+}
+
+func init() {
+  opCmd.AddCommand(opAbortCmd)
+  opAbortCmd.Flags().Bool("yes", false, "Confirm aborting the operation")
+}
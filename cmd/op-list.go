@@ -0,0 +1,75 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "time"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// opListCmd represents the list command
+var opListCmd = &cobra.Command{
+  Use:   "list",
+  Short: "List interrupted operations",
+  Long: `List cluster launch, destroy and purge operations that have not completed.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    var ops []*attendant.OperationEntity
+    var err error
+    attendant.Spin(func() { ops, err = attendant.Operations() })
+    if err != nil { return err }
+    setResult(ops)
+    if len(ops) == 0 {
+      fmt.Printf("No operations in progress (%s).\n", attendant.Config().AwsRegion)
+      return nil
+    }
+    fmt.Printf("%-10s %-16s %-20s %-11s %-24s %s\n", "ID", "KIND", "CLUSTER", "STATUS", "PROGRESS", "UPDATED")
+    for _, op := range ops {
+      fmt.Printf("%-10s %-16s %-20s %-11s %-24s %s\n",
+        op.Id, op.Kind, op.Target(), op.Status, op.Progress(),
+        time.Unix(op.UpdateTime, 0).UTC().Format(time.RFC3339))
+    }
+    if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+      for _, op := range ops {
+        fmt.Println("\n" + op.Render())
+      }
+    }
+    return nil
+  },
+}
+
+func init() {
+  opCmd.AddCommand(opListCmd)
+  opListCmd.Flags().BoolP("verbose", "v", false, "Show the steps of each operation")
+}
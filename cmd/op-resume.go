@@ -0,0 +1,80 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// opResumeCmd represents the resume command
+var opResumeCmd = &cobra.Command{
+  Use:   "resume <id>",
+  Short: "Resume an interrupted operation",
+  Long: `Resume an interrupted operation from the first step that did not complete.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) == 0 {
+      cmd.Help()
+      return nil
+    }
+
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    op, err := attendant.LoadOperation(args[0])
+    if err != nil { return err }
+
+    var handler attendant.EventHandler
+    if op.Kind == attendant.ClusterLaunchOperation {
+      handler, err = attendant.CreateCreateHandler(attendant.ClusterResourceCount)
+    } else {
+      handler, err = attendant.CreateDestroyHandler(attendant.ClusterResourceCount)
+    }
+    if err != nil { return err }
+
+    fmt.Printf("Resuming %s of cluster '%s' (%s) at step %s...\n\n", op.Kind, op.Target(), op.Region, op.Progress())
+    var cluster *attendant.Cluster
+    interrupts := watchInterrupts(op.Kind == attendant.ClusterLaunchOperation)
+    defer interrupts.stop()
+    attendant.Spin(func() { cluster, err = op.Resume(interrupts.ctx, handler) })
+    if cluster != nil {
+      cluster.MessageHandler = nil
+    }
+    if err = interrupts.outcome(err, nil); err != nil { return err }
+    setResult(op)
+    fmt.Printf("\nOperation %s complete.\n", op.Id)
+    return nil
+  },
+}
+
+func init() {
+  opCmd.AddCommand(opResumeCmd)
+}
@@ -0,0 +1,127 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "os"
+
+  "github.com/aws/aws-sdk-go/aws/awserr"
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// commandResult is printed in place of a command's usual output when
+// structured output (--output json or yaml) is selected.
+type commandResult struct {
+  Command string `json:"Command" yaml:"Command"`
+  Status string `json:"Status" yaml:"Status"`
+  Data interface{} `json:"Data,omitempty" yaml:"Data,omitempty"`
+  Error *commandError `json:"Error,omitempty" yaml:"Error,omitempty"`
+  RegionErrors []*commandError `json:"RegionErrors,omitempty" yaml:"RegionErrors,omitempty"`
+}
+
+type commandError struct {
+  Message string `json:"Message" yaml:"Message"`
+  Code string `json:"Code,omitempty" yaml:"Code,omitempty"`
+  Region string `json:"Region,omitempty" yaml:"Region,omitempty"`
+}
+
+// resultError is an error from a command whose result is still
+// reported under structured output.
+type resultError struct {
+  error
+}
+
+var resultData interface{}
+var textOutput = os.Stdout
+
+// beginOutput diverts the text a command prints while structured output
+// is selected, so that only the result document reaches stdout.
+func beginOutput() error {
+  if !attendant.IsValidOutputFormat(attendant.Config().Output) {
+    return fmt.Errorf("Invalid output format '%s'. Try one of: %s", attendant.Config().Output, attendant.OutputFormats)
+  }
+  if !attendant.IsStructuredOutput() { return nil }
+  devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+  if err != nil { return err }
+  os.Stdout = devNull
+  RootCmd.SilenceErrors = true
+  return nil
+}
+
+// setResult records the data to report for a command under structured
+// output.
+func setResult(data interface{}) {
+  resultData = data
+}
+
+// finishOutput prints the result of a command, or its error, under
+// structured output.  A command that failed in only some of the
+// regions it queried keeps the data from the others, with a "partial"
+// status.
+func finishOutput(cmd *cobra.Command, err error) {
+  if !attendant.IsStructuredOutput() || !RootCmd.SilenceErrors { return }
+  os.Stdout = textOutput
+  result := commandResult{Command: cmd.CommandPath(), Status: "ok", Data: resultData}
+  if rerr, ok := err.(*regionErrors); ok {
+    result.Status = "partial"
+    if len(rerr.failed) == rerr.total {
+      result.Status = "error"
+      result.Data = nil
+    }
+    result.Error = &commandError{Message: err.Error()}
+    for _, failed := range rerr.failed {
+      regionError := newCommandError(failed.Err)
+      regionError.Region = failed.Region
+      result.RegionErrors = append(result.RegionErrors, regionError)
+    }
+  } else if err != nil {
+    result.Status = "error"
+    if _, ok := err.(*resultError); !ok {
+      result.Data = nil
+    }
+    result.Error = newCommandError(err)
+  }
+  out, merr := attendant.Marshal(attendant.Config().Output, result)
+  if merr != nil {
+    fmt.Fprintln(os.Stderr, merr.Error())
+    return
+  }
+  fmt.Print(out)
+}
+
+func newCommandError(err error) *commandError {
+  e := &commandError{Message: err.Error()}
+  if aerr, ok := err.(awserr.Error); ok {
+    e.Code = aerr.Code()
+  }
+  return e
+}
@@ -0,0 +1,90 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "testing"
+
+  "github.com/aws/aws-sdk-go/aws/awserr"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// finishResult runs finishOutput for a command that produced data and
+// err, returning the JSON document it prints.
+func finishResult(t *testing.T, data interface{}, err error) map[string]interface{} {
+  out, ferr := ioutil.TempFile(t.TempDir(), "output")
+  if ferr != nil { t.Fatalf("TempFile: %s", ferr) }
+  savedText, savedStdout, savedFormat := textOutput, os.Stdout, attendant.Config().Output
+  defer func() {
+    textOutput, os.Stdout, resultData = savedText, savedStdout, nil
+    attendant.Config().Output = savedFormat
+    RootCmd.SilenceErrors = false
+  }()
+  textOutput = out
+  attendant.Config().Output = "json"
+  RootCmd.SilenceErrors = true
+  setResult(data)
+  finishOutput(RootCmd, err)
+
+  printed, ferr := ioutil.ReadFile(out.Name())
+  if ferr != nil { t.Fatalf("ReadFile: %s", ferr) }
+  result := make(map[string]interface{})
+  if ferr := json.Unmarshal(printed, &result); ferr != nil {
+    t.Fatalf("output isn't JSON: %s\n%s", ferr, printed)
+  }
+  return result
+}
+
+func TestStructuredOutput(t *testing.T) {
+  result := finishResult(t, []string{"c1"}, nil)
+  if result["Status"] != "ok" || result["Data"] == nil || result["Error"] != nil {
+    t.Errorf("result = %v", result)
+  }
+}
+
+func TestStructuredOutputErrors(t *testing.T) {
+  result := finishResult(t, []string{"c1"}, awserr.New("ValidationError", "Stack does not exist", nil))
+  if result["Status"] != "error" || result["Data"] != nil {
+    t.Errorf("result = %v", result)
+  }
+  if e, ok := result["Error"].(map[string]interface{}); !ok || e["Code"] != "ValidationError" {
+    t.Errorf("error = %v, want the AWS error code", result["Error"])
+  }
+
+  // some errors still report what the command found
+  result = finishResult(t, []string{"problem"}, &resultError{fmt.Errorf("1 problem(s) found.")})
+  if result["Status"] != "error" || result["Data"] == nil {
+    t.Errorf("result = %v, want data with the error", result)
+  }
+}
@@ -0,0 +1,74 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// paramsValidateCmd represents the validate command
+var paramsValidateCmd = &cobra.Command{
+  Use:   "validate <file>",
+  Short: "Check a parameter file against its template",
+  Long: `Check a parameter file against the CloudFormation template it will be
+launched with, reporting unknown keys, missing required parameters and
+values that the template doesn't allow.
+
+The template defaults to the one named after the file, so a file called
+cluster-compute.yml is checked against cluster-compute.json.  Values
+that contain tokens are only resolved at launch, so aren't checked here.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if len(args) != 1 {
+      return fmt.Errorf("Please specify a parameter file to validate.")
+    }
+    templateName, _ := cmd.Flags().GetString("template")
+    if err := setupTemplateSource("paramsValidate"); err != nil { return err }
+
+    var err error
+    attendant.SpinWithSuffix(func() {
+      err = attendant.ValidateParameterFile(args[0], templateName)
+    }, args[0])
+    if err != nil { return err }
+    setResult(map[string]string{"File": args[0], "Result": "valid"})
+    fmt.Printf("Parameter file %s is valid.\n", args[0])
+    return nil
+  },
+}
+
+func init() {
+  paramsCmd.AddCommand(paramsValidateCmd)
+  paramsValidateCmd.Flags().StringP("template", "t", "", "Template to validate against, e.g. cluster-compute")
+  addTemplateSetFlag(paramsValidateCmd, "paramsValidate")
+  addTemplateRootFlag(paramsValidateCmd, "paramsValidate")
+}
@@ -0,0 +1,121 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "context"
+  "fmt"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// reapCmd represents the reap command
+var reapCmd = &cobra.Command{
+  Use:   "reap",
+  Short: "Destroy expired clusters and queues",
+  Long: `Destroy clusters and queues whose expiry time has passed.
+
+With --daemon, keep checking for expired clusters and queues at the
+given interval until interrupted.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    if err := attendant.PreflightCheck(); err != nil { return err }
+    dryrun, _ := cmd.Flags().GetBool("dry-run")
+    grace, _ := cmd.Flags().GetDuration("grace")
+    daemon, _ := cmd.Flags().GetBool("daemon")
+    interval, _ := cmd.Flags().GetDuration("interval")
+    if grace < 0 {
+      return fmt.Errorf("Grace period must not be negative.")
+    }
+    if daemon && interval <= 0 {
+      return fmt.Errorf("Interval must be greater than zero.")
+    }
+    if daemon && attendant.IsStructuredOutput() {
+      return fmt.Errorf("Structured output can't be used with --daemon.")
+    }
+    regions := getRegions(cmd)
+    for {
+      reaped := []*attendant.Reaped{}
+      for _, region := range regions {
+        results, err := reapRegion(region, grace, dryrun)
+        if err != nil {
+          if !daemon { return err }
+          fmt.Printf("💥  %s: %s\n", region, err.Error())
+        }
+        reaped = append(reaped, results...)
+      }
+      if !daemon {
+        setResult(reaped)
+        return nil
+      }
+      time.Sleep(interval)
+    }
+  },
+}
+
+func reapRegion(region string, grace time.Duration, dryrun bool) ([]*attendant.Reaped, error) {
+  var results []*attendant.Reaped
+  var err error
+  client := attendant.DefaultClient().ForRegion(region)
+  attendant.SpinWithSuffix(func() {
+    results, err = client.Reap(context.Background(), grace, dryrun)
+  }, region)
+  if err != nil { return nil, err }
+  if len(results) == 0 {
+    fmt.Printf("Nothing has expired (%s).\n", region)
+    return results, nil
+  }
+  fmt.Printf("Expired resources (%s):\n", region)
+  for _, result := range results {
+    expiry := time.Unix(result.ExpiryTime, 0).Format(time.RFC3339)
+    switch result.Action {
+    case "reaped":
+      fmt.Printf("🗑  Reaped %s (%s, expired %s)\n", result.Descriptor, result.StackName, expiry)
+    case "would reap":
+      fmt.Printf("🗑  Would reap %s (%s, expired %s)\n", result.Descriptor, result.StackName, expiry)
+    case "failed":
+      fmt.Printf("💥  Failed to reap %s (%s): %s\n", result.Descriptor, result.StackName, result.Reason)
+    default:
+      fmt.Printf("⏭  Skipped %s (%s): %s\n", result.Descriptor, result.StackName, result.Reason)
+    }
+  }
+  fmt.Println("")
+  return results, nil
+}
+
+func init() {
+  RootCmd.AddCommand(reapCmd)
+  reapCmd.Flags().Bool("dry-run", false, "Perform a dry run displaying what would be reaped")
+  reapCmd.Flags().Duration("grace", 0, "Period to wait after expiry before reaping")
+  reapCmd.Flags().Bool("daemon", false, "Keep reaping at the given interval")
+  reapCmd.Flags().Duration("interval", 5 * time.Minute, "Interval between checks when running as a daemon")
+  reapCmd.Flags().String("regions", "", "Select regions to query")
+}
@@ -0,0 +1,147 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "sync"
+
+  "github.com/spf13/viper"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// regionResult holds what one region contributed to a command run
+// across several regions: the text it would print, its data for
+// structured output and any error that stopped it.
+type regionResult struct {
+  Region string
+  Text string
+  Data interface{}
+  Err error
+}
+
+// regionErrors is returned by commands that carried on past regions
+// that failed.
+type regionErrors struct {
+  failed []*regionResult
+  total int
+}
+
+func (e *regionErrors) Error() string {
+  if len(e.failed) == 1 {
+    return fmt.Sprintf("Region %s failed: %s", e.failed[0].Region, e.failed[0].Err.Error())
+  }
+  return fmt.Sprintf("%d of %d regions failed.", len(e.failed), e.total)
+}
+
+// eachRegion runs fn for every region, querying at most
+// region-concurrency regions at once behind a single spinner.  Text
+// written to out is kept to be printed by printRegions.  Results are in
+// the order the regions were given, and a region that fails doesn't
+// stop the others.
+func eachRegion(regions []string, fn func(client *attendant.Client, out io.Writer) (interface{}, error)) []*regionResult {
+  results := make([]*regionResult, len(regions))
+  limit := viper.GetInt("region-concurrency")
+  if limit < 1 { limit = 1 }
+  suffix := fmt.Sprintf("%d regions", len(regions))
+  if len(regions) == 1 { suffix = regions[0] }
+  attendant.SpinWithSuffix(func() {
+    var wg sync.WaitGroup
+    slots := make(chan bool, limit)
+    for i, region := range regions {
+      client := attendant.DefaultClient().ForRegion(region)
+      result := &regionResult{Region: region}
+      results[i] = result
+      wg.Add(1)
+      slots <- true
+      go func() {
+        defer wg.Done()
+        var out bytes.Buffer
+        result.Data, result.Err = fn(client, &out)
+        result.Text = out.String()
+        <-slots
+      }()
+    }
+    wg.Wait()
+  }, suffix)
+  return results
+}
+
+// printRegions prints the text gathered for each region in turn,
+// followed by the error for any region that failed.  When only one
+// region was queried, its error is returned as it is.
+func printRegions(results []*regionResult) error {
+  if len(results) == 1 && results[0].Err != nil {
+    fmt.Print(results[0].Text)
+    return results[0].Err
+  }
+  for _, result := range results {
+    fmt.Print(result.Text)
+    if result.Err != nil {
+      fmt.Printf("💥  %s: %s\n\n", result.Region, result.Err.Error())
+    }
+  }
+  return failedRegions(results)
+}
+
+func failedRegions(results []*regionResult) error {
+  failed := []*regionResult{}
+  for _, result := range results {
+    if result.Err != nil {
+      failed = append(failed, result)
+    }
+  }
+  if len(failed) == 0 { return nil }
+  return &regionErrors{failed: failed, total: len(results)}
+}
+
+// location describes the region and account that a client refers to,
+// and the context in use, for report headers.  The account is left out
+// if it can't be found.
+func location(client *attendant.Client) string {
+  where := client.Config().AwsRegion
+  if account, err := client.Account(); err == nil {
+    where += ", account " + account.String()
+  }
+  return where + contextSuffix()
+}
+
+// currentLocation describes the region in use, and the context if one
+// is, for command banners.
+func currentLocation() string {
+  return attendant.Config().AwsRegion + contextSuffix()
+}
+
+func contextSuffix() string {
+  if activeContext == "" { return "" }
+  return ", context " + activeContext
+}
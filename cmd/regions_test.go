@@ -0,0 +1,126 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/spf13/viper"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+func TestEachRegion(t *testing.T) {
+  saved := viper.Get("region-concurrency")
+  defer viper.Set("region-concurrency", saved)
+  viper.Set("region-concurrency", 2)
+
+  var mutex sync.Mutex
+  running, most := 0, 0
+  regions := []string{"us-east-1", "eu-west-1", "eu-west-2", "ap-south-1"}
+  results := eachRegion(regions, func(client *attendant.Client, out io.Writer) (interface{}, error) {
+    mutex.Lock()
+    running += 1
+    if running > most { most = running }
+    mutex.Unlock()
+    time.Sleep(10 * time.Millisecond)
+    mutex.Lock()
+    running -= 1
+    mutex.Unlock()
+
+    region := client.Config().AwsRegion
+    if region == "eu-west-1" {
+      return nil, fmt.Errorf("Region unreachable.")
+    }
+    fmt.Fprintf(out, "%s\n", region)
+    return region, nil
+  })
+
+  if most > 2 {
+    t.Errorf("%d regions were queried at once, want at most 2", most)
+  }
+  if len(results) != len(regions) {
+    t.Fatalf("got %d results, want %d", len(results), len(regions))
+  }
+  for i, result := range results {
+    if result.Region != regions[i] {
+      t.Errorf("result %d is for %s, want %s", i, result.Region, regions[i])
+    }
+    if result.Region == "eu-west-1" {
+      if result.Err == nil { t.Errorf("eu-west-1 didn't report its error") }
+      continue
+    }
+    if result.Err != nil || result.Data != result.Region || result.Text != result.Region + "\n" {
+      t.Errorf("result for %s = %+v", result.Region, result)
+    }
+  }
+
+  err := failedRegions(results)
+  rerr, ok := err.(*regionErrors)
+  if !ok {
+    t.Fatalf("failedRegions = %v, want regionErrors", err)
+  }
+  if rerr.total != 4 || len(rerr.failed) != 1 {
+    t.Errorf("failed %d of %d, want 1 of 4", len(rerr.failed), rerr.total)
+  }
+  if err.Error() != "Region eu-west-1 failed: Region unreachable." {
+    t.Errorf("error = %q", err.Error())
+  }
+  if failedRegions(results[2:]) != nil {
+    t.Errorf("failedRegions reported an error for regions that succeeded")
+  }
+}
+
+func TestStructuredOutputRegionErrors(t *testing.T) {
+  failed := &regionResult{Region: "eu-west-1", Err: fmt.Errorf("Region unreachable.")}
+  result := finishResult(t, []string{"c1"}, &regionErrors{failed: []*regionResult{failed}, total: 2})
+  if result["Status"] != "partial" || result["Data"] == nil {
+    t.Errorf("result = %v, want partial data", result)
+  }
+  errs, ok := result["RegionErrors"].([]interface{})
+  if !ok || len(errs) != 1 {
+    t.Fatalf("region errors = %v", result["RegionErrors"])
+  }
+  if e := errs[0].(map[string]interface{}); e["Region"] != "eu-west-1" || e["Message"] != "Region unreachable." {
+    t.Errorf("region error = %v", e)
+  }
+
+  second := &regionResult{Region: "us-east-1", Err: fmt.Errorf("Access denied.")}
+  result = finishResult(t, []string{}, &regionErrors{failed: []*regionResult{failed, second}, total: 2})
+  if result["Status"] != "error" || result["Data"] != nil {
+    t.Errorf("result = %v, want an error when every region failed", result)
+  }
+  if e, _ := result["Error"].(map[string]interface{}); e["Message"] != "2 of 2 regions failed." {
+    t.Errorf("error = %v", result["Error"])
+  }
+}
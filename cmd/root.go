@@ -29,8 +29,11 @@
 package cmd
 
 import (
+  "bufio"
   "fmt"
+  "io/ioutil"
   "os"
+  "path/filepath"
   "strings"
 
   "github.com/spf13/cobra"
@@ -76,7 +79,9 @@ on your Alces Flight Compute architecture.`,
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-  if err := RootCmd.Execute(); err != nil {
+  cmd, err := RootCmd.ExecuteC()
+  finishOutput(cmd, err)
+  if err != nil {
     // fmt.Println(err.Error())
     os.Exit(-255)
   }
@@ -86,21 +91,35 @@ func init() {
   cobra.OnInitialize(initConfig)
   attendant.Init()
   RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.fly.yml)")
+  RootCmd.PersistentFlags().String("context", "", "Named context from the config file to use")
   defaultRegion := os.Getenv("AWS_REGION")
   if defaultRegion == "" { defaultRegion = "us-east-1" }
   RootCmd.Flags().Bool("version", false, "Show version information")
   RootCmd.PersistentFlags().String("region", defaultRegion, "AWS region")
   RootCmd.PersistentFlags().String("access-key", "", "AWS access key ID")
   RootCmd.PersistentFlags().String("secret-key", "", "AWS secret access key")
+  RootCmd.PersistentFlags().String("profile", "", "AWS profile from the shared config and credentials files")
+  RootCmd.PersistentFlags().String("role-arn", "", "ARN of an IAM role to assume")
   RootCmd.PersistentFlags().String("parameter-directory", "", "Directory containing component parameter files")
+  RootCmd.PersistentFlags().String("backend", "aws", "Backend to use (aws or sim)")
+  RootCmd.PersistentFlags().String("output", "text", "Output format (" + strings.Join(attendant.OutputFormats, ", ") + ")")
+  RootCmd.PersistentFlags().String("progress", "spinner", "Progress display (" + strings.Join(attendant.ProgressFormats, ", ") + ")")
+  RootCmd.PersistentFlags().Bool("delete-failed-stacks", false, "Delete stacks that fail to create rather than leaving them for inspection")
   RootCmd.Flags().Bool("show-config-example", false, "Display an example configuration file")
   RootCmd.Flags().Bool("show-config-values", false, "Display valid configuration values")
   RootCmd.Flags().String("create-parameter-directory", "", "Write default parameter files to a directory")
 
+  viper.BindPFlag("context", RootCmd.PersistentFlags().Lookup("context"))
   viper.BindPFlag("region", RootCmd.PersistentFlags().Lookup("region"))
   viper.BindPFlag("access-key", RootCmd.PersistentFlags().Lookup("access-key"))
   viper.BindPFlag("secret-key", RootCmd.PersistentFlags().Lookup("secret-key"))
+  viper.BindPFlag("profile", RootCmd.PersistentFlags().Lookup("profile"))
+  viper.BindPFlag("role-arn", RootCmd.PersistentFlags().Lookup("role-arn"))
   viper.BindPFlag("parameter-directory", RootCmd.PersistentFlags().Lookup("parameter-directory"))
+  viper.BindPFlag("backend", RootCmd.PersistentFlags().Lookup("backend"))
+  viper.BindPFlag("output", RootCmd.PersistentFlags().Lookup("output"))
+  viper.BindPFlag("progress", RootCmd.PersistentFlags().Lookup("progress"))
+  viper.BindPFlag("delete-failed-stacks", RootCmd.PersistentFlags().Lookup("delete-failed-stacks"))
 
   if os.Getenv("FLY_SIMPLE_OUTPUT") != "" {
     attendant.Config().SimpleOutput = true
@@ -133,6 +152,10 @@ func initConfig() {
       os.Exit(1)
     }
   }
+  if err := useContext(); err != nil {
+    fmt.Println(err.Error())
+    os.Exit(1)
+  }
 
   cfg := attendant.Config()
   cfg.AwsRegion = viper.GetString("region")
@@ -150,7 +173,169 @@ func initConfig() {
      cfg.AwsSecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
   }
 
+  cfg.AwsProfile = viper.GetString("profile")
+  if cfg.AwsProfile == "" {
+    cfg.AwsProfile = os.Getenv("AWS_PROFILE")
+  }
+  cfg.AwsRoleArn = viper.GetString("role-arn")
+  cfg.AwsExternalId = viper.GetString("external-id")
+  cfg.AwsRoleSessionName = viper.GetString("role-session-name")
+  cfg.AwsMfaSerial = viper.GetString("mfa-serial")
+  cfg.AwsRoleDuration = viper.GetDuration("role-duration")
+  cfg.CacheCredentials = viper.GetBool("cache-credentials")
+  cfg.CredentialCacheFile = viper.GetString("credential-cache-file")
+
   cfg.ParameterDirectory = viper.GetString("parameter-directory")
+  cfg.TemplateStagingBucket = viper.GetString("template-staging-bucket")
+  cfg.TemplateStagingPrefix = viper.GetString("template-staging-prefix")
+  cfg.ValidateParameters = viper.GetBool("validate-parameters")
+  cfg.PriceFile = viper.GetString("price-file")
+  cfg.DeleteFailedStacks = viper.GetBool("delete-failed-stacks")
+  cfg.ComputeMaxNodes = viper.GetInt64("compute-max-nodes")
+  cfg.MasterInstanceType = viper.GetString("master-instance-type")
+  cfg.MasterInstanceOverride = viper.GetString("master-instance-override")
+  cfg.MasterFeatures = viper.GetString("master-features")
+  cfg.QueueInstanceType = viper.GetString("queue-instance-type")
+  cfg.DefaultQueueInstanceType = viper.GetString("default-queue-instance-type")
+  cfg.QueueInstanceOverride = viper.GetString("queue-instance-override")
+  cfg.ApplianceInstanceType = viper.GetString("appliance-instance-type")
+  for _, key := range viper.AllKeys() {
+    cfg.Settings[key] = viper.GetString(key)
+  }
+  // settings only given in the environment may still be used as tokens
+  for _, env := range os.Environ() {
+    pair := strings.SplitN(env, "=", 2)
+    if !strings.HasPrefix(pair[0], "FLY_") { continue }
+    key := strings.ToLower(strings.Replace(strings.TrimPrefix(pair[0], "FLY_"), "_", "-", -1))
+    if _, exists := cfg.Settings[key]; !exists {
+      cfg.Settings[key] = pair[1]
+    }
+  }
+  cfg.StackCacheTTL = viper.GetDuration("stack-cache-ttl")
+  cfg.StackCacheFile = viper.GetString("stack-cache-file")
+  cfg.Output = viper.GetString("output")
+  cfg.Progress = viper.GetString("progress")
+  if !attendant.IsValidProgressFormat(cfg.Progress) {
+    fmt.Printf("Invalid progress display '%s'. Try one of: %s\n", cfg.Progress, attendant.ProgressFormats)
+    os.Exit(1)
+  }
+  if err := beginOutput(); err != nil {
+    fmt.Println(err.Error())
+    os.Exit(1)
+  }
+
+  for _, service := range attendant.EndpointServices {
+    cfg.Endpoints[service] = viper.GetString(service + "-endpoint")
+  }
+  cfg.DisableTLSVerify = viper.GetBool("disable-tls-verify")
+
+  cfg.StateBackend = viper.GetString("state-backend")
+  cfg.StateFile = viper.GetString("state-file")
+  cfg.StateBucket = viper.GetString("state-bucket")
+  cfg.StatePrefix = viper.GetString("state-prefix")
+
+  cfg.Backend = viper.GetString("backend")
+  cfg.SimulatorDirectory = viper.GetString("simulator-directory")
+  if failures := viper.GetString("simulator-failures"); failures != "" {
+    cfg.SimulatorFailures = strings.Split(failures, ",")
+  }
+  if err := attendant.SetupBackend(); err != nil {
+    fmt.Println(err.Error())
+    os.Exit(1)
+  }
+}
+
+// contextKeys are the settings that a named context may hold.
+var contextKeys = []string{
+  "region", "access-key", "secret-key", "profile", "role-arn", "external-id",
+  "role-session-name", "mfa-serial", "domain", "key-pair", "template-set",
+  "template-root", "parameter-directory",
+}
+
+// activeContext is the name of the context in use, if any.
+var activeContext string
+
+// loadContexts returns the settings of each context defined under
+// "contexts" in the config file.
+func loadContexts() (map[string]map[string]string, error) {
+  contexts := make(map[string]map[string]string)
+  for name, value := range viper.GetStringMap("contexts") {
+    settings, ok := value.(map[string]interface{})
+    if !ok {
+      return nil, fmt.Errorf("Context '%s' must be a map of settings.", name)
+    }
+    contexts[name] = make(map[string]string)
+    for key, val := range settings {
+      if !containsString(contextKeys, key) {
+        return nil, fmt.Errorf("Unknown setting '%s' in context '%s'. Try one of: %s", key, name, strings.Join(contextKeys, ", "))
+      }
+      contexts[name][key] = fmt.Sprint(val)
+    }
+  }
+  return contexts, nil
+}
+
+// useContext applies the settings of the context chosen with --context
+// or FLY_CONTEXT, or else of the current context named in the config
+// file.  Settings given by flags or FLY_* environment variables take
+// precedence over those of the context.
+func useContext() error {
+  name := viper.GetString("context")
+  fromFile := name == ""
+  if fromFile { name = viper.GetString("current-context") }
+  if name == "" { return nil }
+  contexts, err := loadContexts()
+  if err != nil { return err }
+  settings, exists := contexts[strings.ToLower(name)]
+  if !exists {
+    if fromFile {
+      fmt.Fprintf(os.Stderr, "Current context '%s' not found; ignoring it. Run 'fly context use' to choose another.\n", name)
+      return nil
+    }
+    return fmt.Errorf("Context '%s' not found.", name)
+  }
+  replacer := strings.NewReplacer("-", "_")
+  for key, value := range settings {
+    if flag := RootCmd.PersistentFlags().Lookup(key); flag != nil && flag.Changed { continue }
+    if os.Getenv("FLY_" + strings.ToUpper(replacer.Replace(key))) != "" { continue }
+    viper.Set(key, value)
+  }
+  activeContext = strings.ToLower(name)
+  return nil
+}
+
+// writeCurrentContext records the current context in the config file.
+// The file is edited in place to keep its other contents and comments.
+func writeCurrentContext(name string) error {
+  path := viper.ConfigFileUsed()
+  if path == "" { return fmt.Errorf("No config file found.") }
+  if ext := filepath.Ext(path); ext != ".yml" && ext != ".yaml" {
+    return fmt.Errorf("The current context can only be recorded in a YAML config file.")
+  }
+  info, err := os.Stat(path)
+  if err != nil { return err }
+  data, err := ioutil.ReadFile(path)
+  if err != nil { return err }
+  entry := "current-context: " + name
+  lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+  found := false
+  for i, line := range lines {
+    if strings.HasPrefix(line, "current-context:") {
+      lines[i] = entry
+      found = true
+    }
+  }
+  if !found {
+    lines = append(lines, entry)
+  }
+  return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n") + "\n"), info.Mode())
+}
+
+func containsString(s []string, e string) bool {
+  for _, a := range s {
+    if a == e { return true }
+  }
+  return false
 }
 
 func addDomainFlag(command *cobra.Command, cmdName string) {
@@ -159,18 +344,22 @@ func addDomainFlag(command *cobra.Command, cmdName string) {
 }
 
 func findDomain(cmdName string, defaultOk bool) (*attendant.Domain, error) {
+  return findDomainIn(attendant.DefaultClient(), cmdName, defaultOk)
+}
+
+func findDomainIn(client *attendant.Client, cmdName string, defaultOk bool) (*attendant.Domain, error) {
   var domain *attendant.Domain
   var err error
   name := viper.GetString("domain:" + cmdName)
   if name == "" { name = viper.GetString("domain") }
   if name == "" {
     if defaultOk {
-      domain, err = attendant.DefaultDomain()
+      domain, err = client.DefaultDomain()
     } else {
       return nil, fmt.Errorf("This operation requires you to specify a domain")
     }
   } else {
-    domain = attendant.NewDomain(name, nil)
+    domain = client.NewDomain(name, nil)
     err = domain.AssertExists()
   }
   if err != nil {
@@ -202,7 +391,7 @@ func addTemplateSetFlag(command *cobra.Command, cmdName string) {
 }
 
 func addTemplateRootFlag(command *cobra.Command, cmdName string) {
-  command.Flags().String("template-root", "", "Specify an explicit template root URL")
+  command.Flags().String("template-root", "", "Specify an explicit template root URL or local directory")
   viper.BindPFlag("template-root:" + cmdName, command.Flags().Lookup("template-root"))
 }
 
@@ -212,8 +401,11 @@ func setupTemplateSource(cmdName string) error {
   if templateRoot != "" && templateSet != "" {
     return fmt.Errorf("Template set cannot be used in conjunction with template root\n")
   } else {
-    if templateRoot == "" { templateRoot = viper.GetString("template-root") }
-    if templateSet == "" { templateSet = viper.GetString("template-set") }
+    // the command's own flags win over the context and config file
+    if templateRoot == "" && templateSet == "" {
+      templateRoot = viper.GetString("template-root")
+      templateSet = viper.GetString("template-set")
+    }
     if templateRoot != "" {
       attendant.Config().TemplateRoot = templateRoot
       attendant.Config().TemplateSet = ""
@@ -236,3 +428,34 @@ func getRegions(cmd *cobra.Command) []string {
   }
   return regions
 }
+
+func addPlanFlags(command *cobra.Command) {
+  command.Flags().Bool("plan", false, "Show the stacks that would be created without creating anything")
+}
+
+func isPlan(command *cobra.Command) bool {
+  plan, _ := command.Flags().GetBool("plan")
+  return plan
+}
+
+// showPlan runs fn under a plan and shows the stacks it would create,
+// as a table or in the selected output format.
+func showPlan(command *cobra.Command, fn func() error) error {
+  plan := attendant.NewPlan()
+  var err error
+  attendant.Spin(func() { err = plan.Run(fn) })
+  if err != nil { return err }
+  if attendant.IsStructuredOutput() {
+    setResult(plan)
+    return nil
+  }
+  fmt.Print(plan.Render())
+  return nil
+}
+
+func confirm(prompt string) bool {
+  fmt.Printf("%s [y/N] ", prompt)
+  answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+  answer = strings.ToLower(strings.TrimSpace(answer))
+  return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,80 @@
+// Copyright © 2016 Alces Software Ltd <support@alces-software.com>
+// This file is part of Flight Attendant.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as
+// published by the Free Software Foundation, either version 3 of the
+// License, or (at your option) any later version.
+//
+// This software is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+// Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public
+// License along with this software.  If not, see
+// <http://www.gnu.org/licenses/>.
+//
+// This package is available under a dual licensing model whereby use of
+// the package in projects that are licensed so as to be compatible with
+// AGPL Version 3 may use the package under the terms of that
+// license. However, if AGPL Version 3.0 terms are incompatible with your
+// planned use of this package, alternative license terms are available
+// from Alces Software Ltd - please direct inquiries about licensing to
+// licensing@alces-software.com.
+//
+// For more information, please visit <http://www.alces-software.com/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "strings"
+
+  "github.com/spf13/cobra"
+
+  "github.com/alces-software/flight-attendant/attendant"
+)
+
+// stateMigrateCmd represents the migrate command
+var stateMigrateCmd = &cobra.Command{
+  Use:   "migrate",
+  Short: "Copy state records between state backends",
+  Long: `Copy domain, cluster, network booking and operation records from
+one state backend to another.
+
+Valid backends: ` + strings.Join(attendant.StateBackends, ", ") + `
+
+The bolt backend keeps state in a local database file ($HOME/.fly-state.db
+unless state-file is set) and may also be given as sqlite.`,
+  SilenceUsage: true,
+  RunE: func(cmd *cobra.Command, args []string) error {
+    fromName, _ := cmd.Flags().GetString("from")
+    toName, _ := cmd.Flags().GetString("to")
+    if fromName == "" || toName == "" {
+      return fmt.Errorf("Both a source (--from) and destination (--to) backend must be specified.")
+    }
+    if attendant.StateBackendFor(fromName) == attendant.StateBackendFor(toName) {
+      return fmt.Errorf("Source and destination backends must differ.")
+    }
+    from, err := attendant.OpenStateStore(fromName)
+    if err != nil { return err }
+    to, err := attendant.OpenStateStore(toName)
+    if err != nil { return err }
+
+    var domains, clusters, operations int
+    attendant.SpinWithSuffix(func() {
+      domains, clusters, operations, err = attendant.MigrateState(from, to)
+    }, fromName + " -> " + toName)
+    if err != nil { return err }
+    fmt.Printf("Migrated %d domain(s), %d cluster(s) and %d operation(s) from %s to %s.\n", domains, clusters, operations, fromName, toName)
+    return nil
+  },
+}
+
+func init() {
+  stateCmd.AddCommand(stateMigrateCmd)
+  stateMigrateCmd.Flags().String("from", "", "Backend to copy state from")
+  stateMigrateCmd.Flags().String("to", "", "Backend to copy state to")
+}